@@ -6,7 +6,9 @@ import (
 	"os/exec"
 	"path/filepath"
 
+	"carya/internal/git"
 	"carya/internal/housekeeping"
+	"carya/internal/repository"
 
 	"github.com/spf13/cobra"
 )
@@ -47,9 +49,9 @@ var checkoutCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		// Check for auto-approve in config if flag not set
+		// Check for auto-approve in the settings if flag not set
 		if !autoApprove {
-			autoApprove = config.IsAutoApprove("post-checkout")
+			autoApprove = loadConfig(findRepository()).Housekeeping.AutoApprovePostCheckout
 		}
 
 		executor := housekeeping.NewExecutor(config)
@@ -62,23 +64,24 @@ var checkoutCmd = &cobra.Command{
 
 // checkoutBranch executes git checkout and returns whether housekeeping.json was changed and the list of changed files
 func checkoutBranch(branch string) (bool, []string, error) {
-	wd, err := os.Getwd()
+	repo, err := repository.New()
 	if err != nil {
-		return false, nil, fmt.Errorf("failed to get working directory: %w", err)
+		return false, nil, err
 	}
+	root := repo.RootPath()
 
-	caryaDir := filepath.Join(wd, ".carya")
+	caryaDir := repo.CaryaPath()
 	housekeepingPath := filepath.Join(caryaDir, "housekeeping.json")
-	relPath, err := filepath.Rel(wd, housekeepingPath)
+	relPath, err := filepath.Rel(root, housekeepingPath)
 	if err != nil {
 		return false, nil, fmt.Errorf("failed to get relative path: %w", err)
 	}
 
 	// Get the hash of housekeeping.json before checkout
-	beforeHash, _ := getFileHash(relPath)
+	beforeHash, _ := git.FileHash(root, relPath)
 
 	// Get the current HEAD commit before checkout
-	beforeCommit, err := getHeadCommit()
+	beforeCommit, err := git.HeadCommit(root)
 	if err != nil {
 		return false, nil, fmt.Errorf("failed to get HEAD commit: %w", err)
 	}
@@ -88,20 +91,20 @@ func checkoutBranch(branch string) (bool, []string, error) {
 	checkoutCmd := exec.Command("git", "checkout", branch)
 	checkoutCmd.Stdout = os.Stdout
 	checkoutCmd.Stderr = os.Stderr
-	checkoutCmd.Dir = wd
+	checkoutCmd.Dir = root
 
 	if err := checkoutCmd.Run(); err != nil {
 		return false, nil, fmt.Errorf("git checkout failed: %w", err)
 	}
 
 	// Get the hash of housekeeping.json after checkout
-	afterHash, _ := getFileHash(relPath)
+	afterHash, _ := git.FileHash(root, relPath)
 
 	// Check if the file changed
 	housekeepingChanged := beforeHash != "" && afterHash != "" && beforeHash != afterHash
 
 	// Get the list of changed files
-	changedFiles, err := getChangedFiles(beforeCommit)
+	changedFiles, err := git.ChangedFiles(root, beforeCommit)
 	if err != nil {
 		// Don't fail if we can't get changed files, just return empty list
 		changedFiles = []string{}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/feature"
+	"carya/internal/git"
+	"carya/internal/repository"
+	"carya/internal/store"
+)
+
+// findRepository returns the Carya repository containing the current directory, or the one
+// it would be created in, exiting with an error message if it can't be determined.
+func findRepository() *repository.Repository {
+	repo, err := repository.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return repo
+}
+
+// requireRepository returns the Carya repository containing the current directory,
+// exiting with an error message if there is none.
+func requireRepository() *repository.Repository {
+	repo := findRepository()
+	if !repo.Exists() {
+		fmt.Fprintf(os.Stderr, "Error: Not a Carya repository (or any of its parent directories). Run 'carya init' first.\n")
+		os.Exit(1)
+	}
+	return repo
+}
+
+// openStore opens the chunk store of the Carya repository containing the current directory,
+// exiting with an error message if there is no repository.
+func openStore() (*repository.Repository, store.Store) {
+	repo := requireRepository()
+
+	chunkStore, err := store.OpenWithConfig(repo.CaryaPath(), loadConfig(repo).Store)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening chunk store: %v\n", err)
+		os.Exit(1)
+	}
+
+	return repo, chunkStore
+}
+
+// workTreeRoot returns the top level of the git work tree holding repo, which paths in
+// chunk diffs are relative to, or the repository root outside git.
+func workTreeRoot(repo *repository.Repository) string {
+	if topLevel, err := git.TopLevel(repo.RootPath()); err == nil {
+		return topLevel
+	}
+	return repo.RootPath()
+}
+
+// chunkSelection describes which chunks a command should operate on.
+type chunkSelection struct {
+	IDs     []string    // Explicit chunk IDs
+	Files   []string    // Files or directories the chunks must belong to
+	Since   time.Time   // Only chunks started at or after this time
+	Until   time.Time   // Only chunks started at or before this time
+	Feature feature.Tag // Only chunks tagged with this feature
+
+	IncludeCommitted bool // Also select chunks already in a git commit, which are skipped unless given by ID
+}
+
+// empty reports whether no selection criteria were given.
+func (sel chunkSelection) empty() bool {
+	return len(sel.IDs) == 0 && len(sel.Files) == 0 && sel.Since.IsZero() && sel.Until.IsZero() && sel.Feature == ""
+}
+
+// selectChunks returns the chunks matching the selection, oldest first.
+func selectChunks(s chunk.ChunkStore, sel chunkSelection) ([]chunk.Chunk, error) {
+	var candidates []chunk.Chunk
+	if len(sel.IDs) > 0 {
+		for _, id := range sel.IDs {
+			c, err := s.GetChunk(chunk.ChunkID(id))
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, *c)
+		}
+	} else {
+		var err error
+		candidates, err = s.FindChunksBetween(sel.Since, sel.Until)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var files []string
+	for _, f := range sel.Files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %q: %w", f, err)
+		}
+		files = append(files, abs)
+	}
+
+	var selected []chunk.Chunk
+	for _, c := range candidates {
+		if !sel.Since.IsZero() && c.StartTime.Before(sel.Since) {
+			continue
+		}
+		if !sel.Until.IsZero() && c.StartTime.After(sel.Until) {
+			continue
+		}
+		if len(files) > 0 && !matchesAnyPath(c.FilePath, files) {
+			continue
+		}
+		if sel.Feature != "" && c.FeatureTag != sel.Feature {
+			continue
+		}
+		if c.Committed != "" && len(sel.IDs) == 0 && !sel.IncludeCommitted {
+			continue
+		}
+		selected = append(selected, c)
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].StartTime.Before(selected[j].StartTime)
+	})
+	return selected, nil
+}
+
+// matchesAnyPath reports whether path is one of paths or lies inside one of them.
+func matchesAnyPath(path string, paths []string) bool {
+	for _, p := range paths {
+		if path == p || strings.HasPrefix(path, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeFlag parses a time given either as a duration ago ("90m", "2h", "3d", "1w")
+// or as an absolute date ("2006-01-02", "2006-01-02 15:04" or RFC 3339).
+// An empty value yields the zero time.
+func parseTimeFlag(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	// Days and weeks are not understood by time.ParseDuration
+	if n := len(value); n > 1 && (value[n-1] == 'd' || value[n-1] == 'w') {
+		if count, err := strconv.Atoi(value[:n-1]); err == nil {
+			unit := 24 * time.Hour
+			if value[n-1] == 'w' {
+				unit *= 7
+			}
+			return time.Now().Add(-time.Duration(count) * unit), nil
+		}
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q (use e.g. 2h, 3d, 2006-01-02 or 2006-01-02 15:04)", value)
+}
+
+// sizeUnits are the suffixes used by formatSize, largest first.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}}
+
+// formatSize formats a size in bytes for display.
+func formatSize(size int64) string {
+	for _, u := range sizeUnits {
+		if size >= u.bytes {
+			return fmt.Sprintf("%.1f%s", float64(size)/float64(u.bytes), u.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+// displayPath returns path relative to the current directory when possible.
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
+
+// displayChunkPath returns the file a chunk changed for display, noting files the chunk
+// created, deleted or renamed.
+func displayChunkPath(c chunk.Chunk) string {
+	switch c.Op {
+	case chunk.OpCreate:
+		return displayPath(c.FilePath) + " (new)"
+	case chunk.OpDelete:
+		return displayPath(c.FilePath) + " (deleted)"
+	case chunk.OpRename:
+		return displayPath(c.OldPath) + " → " + displayPath(c.FilePath)
+	}
+	return displayPath(c.FilePath)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"carya/internal/chunk"
+	"carya/internal/deps"
+	"carya/internal/feature"
+	"carya/internal/staging"
+
+	"github.com/spf13/cobra"
+)
+
+var commitCmd = &cobra.Command{
+	Use:   "commit [chunk-id...]",
+	Short: "Create a git commit from selected chunks",
+	Long: `Stage exactly the hunks of the selected chunks into the git index and commit them.
+Chunks can be selected by ID, file, time range, or feature. Changes that are not part of the
+selected chunks are left untouched in the working tree.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		message, _ := cmd.Flags().GetString("message")
+		files, _ := cmd.Flags().GetStringSlice("file")
+		sinceStr, _ := cmd.Flags().GetString("since")
+		untilStr, _ := cmd.Flags().GetString("until")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		featureTag, _ := cmd.Flags().GetString("feature")
+		withDeps, _ := cmd.Flags().GetBool("with-deps")
+		includeCommitted, _ := cmd.Flags().GetBool("include-committed")
+
+		since, err := parseTimeFlag(sinceStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --since: %v\n", err)
+			os.Exit(1)
+		}
+		until, err := parseTimeFlag(untilStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --until: %v\n", err)
+			os.Exit(1)
+		}
+
+		sel := chunkSelection{IDs: args, Files: files, Since: since, Until: until, Feature: feature.Tag(featureTag),
+			IncludeCommitted: includeCommitted}
+		if sel.empty() {
+			fmt.Fprintln(os.Stderr, "Error: Select chunks by ID, --file, --since, --until or --feature")
+			os.Exit(1)
+		}
+
+		repo, chunkStore := openStore()
+		defer chunkStore.Close()
+
+		chunks, err := selectChunks(chunkStore, sel)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error selecting chunks: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(chunks) == 0 {
+			fmt.Println("No chunks match the selection.")
+			return
+		}
+
+		missing, err := deps.UncommittedPrerequisites(chunkStore, chunkIDs(chunks))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading dependencies: %v\n", err)
+			os.Exit(1)
+		}
+		if withDeps && len(missing) > 0 {
+			chunks, err = addPrerequisites(chunkStore, chunks, missing)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading prerequisites: %v\n", err)
+				os.Exit(1)
+			}
+			missing = nil
+		}
+
+		fmt.Printf("Selected %d chunks:\n", len(chunks))
+		for _, c := range chunks {
+			fmt.Printf("  • %s  %s  %s\n", c.ID, displayChunkPath(c), c.StartTime.Format("2006-01-02 15:04"))
+		}
+
+		if len(missing) > 0 {
+			printMissingPrerequisites(missing)
+		}
+
+		if dryRun {
+			return
+		}
+
+		// A missing overlap prerequisite only stops the commit if the hunks fail to apply without it
+		hash, err := staging.Commit(repo.RootPath(), staging.Whole(chunks), message)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating commit: %v\n", err)
+			if deps.HasKind(missing, deps.KindOverlap) {
+				fmt.Fprintln(os.Stderr, "  The selection is missing prerequisite chunks; rerun with --with-deps to include them")
+			}
+			os.Exit(1)
+		}
+
+		fmt.Printf("✓ Created commit %s from %d chunks\n", shortHash(hash), len(chunks))
+		if err := chunkStore.MarkCommitted(chunkIDs(chunks), hash); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to mark the chunks as committed: %v\n", err)
+		}
+	},
+}
+
+// addPrerequisites adds the chunks the edges depend on to the selection, keeping it oldest
+// first. Prerequisites that are already committed are left out, as their changes are in HEAD.
+func addPrerequisites(s chunk.ChunkStore, chunks []chunk.Chunk, edges []deps.Edge) ([]chunk.Chunk, error) {
+	for _, e := range edges {
+		c, err := s.GetChunk(e.DependsOn)
+		if err != nil {
+			return nil, err
+		}
+		if c.Committed != "" {
+			continue
+		}
+		fmt.Printf("  + %s  %s  (prerequisite of %s)\n", c.ID, displayChunkPath(*c), e.Chunk)
+		chunks = append(chunks, *c)
+	}
+
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i].StartTime.Before(chunks[j].StartTime)
+	})
+	return chunks, nil
+}
+
+// chunkIDs returns the IDs of the given chunks.
+func chunkIDs(chunks []chunk.Chunk) []chunk.ChunkID {
+	ids := make([]chunk.ChunkID, len(chunks))
+	for i, c := range chunks {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+// shortHash abbreviates a commit hash for display.
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+func init() {
+	commitCmd.Flags().StringP("message", "m", "", "Commit message (opens your editor if omitted)")
+	commitCmd.Flags().StringSliceP("file", "f", nil, "Only include chunks for these files or directories")
+	commitCmd.Flags().String("since", "", "Only include chunks started after this time (e.g. 2h, 3d, 2006-01-02)")
+	commitCmd.Flags().String("until", "", "Only include chunks started before this time")
+	commitCmd.Flags().String("feature", "", "Only include chunks tagged with this feature")
+	commitCmd.Flags().Bool("with-deps", false, "Also include the chunks the selected chunks depend on")
+	commitCmd.Flags().Bool("include-committed", false, "Also include chunks that are already committed (chunks given by ID always are)")
+	commitCmd.Flags().Bool("dry-run", false, "Show the selected chunks without committing")
+	rootCmd.AddCommand(commitCmd)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"carya/internal/config"
+	"carya/internal/daemon"
+	"carya/internal/repository"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change settings",
+	Long: `Show and change Carya's settings.
+
+Settings are read from the user's config file (~/.config/carya/config), then from the
+repository's .carya/config, then from environment variables named after the key
+(CARYA_ENGINE_FLUSH_TIMEOUT for engine.flush_timeout), each overriding the ones before.
+The files hold "name = value" lines under [section] headers, as git's config files do:
+
+  [engine]
+  	flush_timeout = 30m
+  [watcher]
+  	ignore = node_modules/, dist/`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a setting",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		settings := loadConfig(findRepository())
+
+		setting, err := settings.Get(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if structuredOutput() {
+			printOutput(setting)
+			return
+		}
+		fmt.Println(setting.Value)
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List every setting with its value and where it was set",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		settings := loadConfig(findRepository()).Settings()
+
+		if structuredOutput() {
+			printOutput(configListOutput{Settings: settings})
+			return
+		}
+
+		width := 0
+		for _, s := range settings {
+			width = max(width, len(s.Key)+len(s.Value)+3)
+		}
+		for _, s := range settings {
+			fmt.Printf("%-*s  (%s)\n", width, s.Key+" = "+s.Value, s.Origin)
+			if verbose {
+				fmt.Printf("    %s\n", s.Description)
+			}
+		}
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting in the repository's or the user's config file",
+	Long: `Change a setting in the repository's .carya/config, or with --user in the user's
+config file. The value is checked before it is written; --unset removes the setting so
+that it falls back to the user's value or the default.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		user, _ := cmd.Flags().GetBool("user")
+		unset, _ := cmd.Flags().GetBool("unset")
+		if unset != (len(args) == 1) {
+			fmt.Fprintln(os.Stderr, "Error: Give a key and a value, or a key with --unset")
+			os.Exit(1)
+		}
+
+		var file *config.File
+		var err error
+		if user {
+			file, err = config.UserFile()
+		} else {
+			file, err = config.RepositoryFile(requireRepository().CaryaPath())
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		key := strings.ToLower(args[0])
+		if unset {
+			removed, err := file.Unset(key)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if !removed {
+				fmt.Printf("%s is not set in %s\n", key, displayPath(file.Path))
+				return
+			}
+		} else if err := file.Set(key, args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := file.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if unset {
+			fmt.Printf("✓ Removed %s from %s\n", key, displayPath(file.Path))
+		} else {
+			fmt.Printf("✓ Set %s = %s in %s\n", key, args[1], displayPath(file.Path))
+		}
+		printConfigNotes(key)
+	},
+}
+
+// printConfigNotes points out why a setting that was just changed may not take effect yet.
+func printConfigNotes(key string) {
+	repo := findRepository()
+	settings := loadConfig(repo)
+	setting, err := settings.Get(key)
+	if err != nil {
+		return
+	}
+
+	if setting.Origin == config.OriginEnvironment {
+		fmt.Printf("  $%s overrides it with %s\n", config.EnvVar(key), setting.Value)
+	}
+	if key == "store.backend" {
+		fmt.Println("  The existing history was not moved; 'carya db convert' moves it to another backend")
+	}
+	section, _, _ := strings.Cut(key, ".")
+	if section == "engine" || section == "watcher" || section == "store" {
+		d := daemon.New(repo.PIDPath(), repo.LogPath(), repo.SocketPath())
+		if !d.IsRunning() {
+			return
+		}
+		if section == "store" {
+			fmt.Println("  Restart the daemon ('carya stop' and 'carya start') for the change to take effect")
+		} else {
+			fmt.Println("  Run 'carya reload' for the running daemon to pick up the change")
+		}
+	}
+}
+
+// configListOutput is the structured form of the settings.
+type configListOutput struct {
+	Settings []config.Setting `json:"settings"`
+}
+
+// loadConfig returns the settings of repo, exiting with an error message naming the
+// offending key if any of them is invalid.
+func loadConfig(repo *repository.Repository) *config.Config {
+	settings, err := config.Load(repo.CaryaPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in configuration: %v\n", err)
+		os.Exit(1)
+	}
+	return settings
+}
+
+func init() {
+	configListCmd.Flags().BoolP("verbose", "v", false, "Describe each setting")
+	configSetCmd.Flags().Bool("user", false, "Change the user's config file instead of the repository's")
+	configSetCmd.Flags().Bool("unset", false, "Remove the setting instead of changing it")
+
+	structuredCommand(configGetCmd)
+	structuredCommand(configListCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configSetCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -6,11 +6,17 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"carya/internal/chunk"
+	"carya/internal/config"
 	"carya/internal/daemon"
+	coreengine "carya/internal/engine"
 	"carya/internal/features/engine"
 	"carya/internal/features/watcher"
 	"carya/internal/repository"
+	"carya/internal/retention"
+	corewatcher "carya/internal/watcher"
 
 	"github.com/spf13/cobra"
 )
@@ -26,13 +32,14 @@ var daemonCmd = &cobra.Command{
 		}
 
 		if !repo.Exists() {
-			log.Fatalf("Not a Carya repository. Run 'carya init' first.")
+			log.Fatalf("Not a Carya repository (or any of its parent directories). Run 'carya init' first.")
 		}
 
 		// Create daemon manager
 		d := daemon.New(
 			repo.PIDPath(),
 			repo.LogPath(),
+			repo.SocketPath(),
 		)
 
 		// Write PID file
@@ -41,7 +48,7 @@ var daemonCmd = &cobra.Command{
 		}
 
 		// Ensure PID file is removed on exit
-		defer d.RemovePID()
+		defer d.ReleasePID()
 
 		// Redirect logs to file
 		logFile, err := os.OpenFile(repo.LogPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
@@ -53,15 +60,29 @@ var daemonCmd = &cobra.Command{
 
 		log.Println("Starting Carya daemon...")
 
+		settings, err := config.Load(repo.CaryaPath())
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+
 		// Initialize engine feature
 		engineFeature := engine.NewEngineFeature()
-		if err := engineFeature.Initialize(repo); err != nil {
+		if err := engineFeature.InitializeWithConfig(repo, settings); err != nil {
 			log.Fatalf("Failed to initialize engine: %v", err)
 		}
 
+		applyRetention(repo, engineFeature.Engine(), settings.Retention)
+		if err := engineFeature.Engine().TrackHead(repo.RootPath()); err != nil {
+			log.Printf("Not recording git branches on chunks: %v", err)
+		}
+
 		// Initialize watcher feature with engine
 		watcherFeature := watcher.NewWatcherFeature()
-		if err := watcherFeature.InitializeWithEngine(repo, engineFeature.Engine()); err != nil {
+		watcherOpts, err := watcherOptions(cmd, settings)
+		if err != nil {
+			log.Fatalf("Failed to initialize watcher: %v", err)
+		}
+		if err := watcherFeature.InitializeWithOptions(repo, engineFeature.Engine(), watcherOpts); err != nil {
 			log.Fatalf("Failed to initialize watcher: %v", err)
 		}
 
@@ -77,56 +98,243 @@ var daemonCmd = &cobra.Command{
 		}
 		defer watcherFeature.Stop()
 
+		// Start control socket
+		stopCh := make(chan struct{}, 1)
+		reload := func() error {
+			return reloadConfig(cmd, repo, settings, engineFeature.Engine(), watcherFeature.Watcher())
+		}
+		server := newControlServer(d, repo, engineFeature.Engine(), watcherFeature.Watcher(), reload, stopCh)
+		if err := server.Listen(); err != nil {
+			log.Fatalf("Failed to start control socket: %v", err)
+		}
+		defer server.Close()
+
 		log.Println("Carya daemon is now watching for file changes")
 
 		// Set up signal handling
 		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM, syscall.SIGUSR1)
-
-		// Wait for signals
-		for sig := range sigCh {
-			switch sig {
-			case syscall.SIGUSR1:
-				// Manual flush requested
-				log.Println("Received flush signal, flushing all chunks...")
-				if err := engineFeature.Engine().FlushAll(); err != nil {
-					log.Printf("Error flushing chunks: %v", err)
-				} else {
-					log.Println("All chunks flushed successfully")
+		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+		// Wait for a shutdown request
+		select {
+		case <-sigCh:
+		case <-stopCh:
+		}
+
+		log.Println("Shutting down Carya daemon...")
+		watcherFeature.Watcher().Pause()
+		watcherFeature.Watcher().Flush()
+		if flushed, err := engineFeature.Engine().FlushAll(); err != nil {
+			log.Printf("Error flushing chunks: %v", err)
+		} else if len(flushed) > 0 {
+			log.Printf("Flushed %d chunks before shutdown", len(flushed))
+		}
+	},
+}
+
+// watcherOptions returns the watcher settings, overridden by the --quiet-window and
+// --max-file-size flags of cmd where they were given.
+func watcherOptions(cmd *cobra.Command, settings *config.Config) (corewatcher.Options, error) {
+	opts := settings.Watcher
+	if cmd.Flags().Changed("quiet-window") {
+		opts.QuietWindow, _ = cmd.Flags().GetDuration("quiet-window")
+	}
+	if cmd.Flags().Changed("max-file-size") {
+		maxFileSize, _ := cmd.Flags().GetString("max-file-size")
+		size, err := config.ParseSize(maxFileSize)
+		if err != nil {
+			return opts, fmt.Errorf("--max-file-size: %w", err)
+		}
+		opts.MaxFileSize = size
+	}
+	return opts, nil
+}
+
+// reloadConfig re-reads the settings of repo and applies them to the running engine and
+// watcher, keeping the overrides of the daemon's flags. The storage backend stays the one
+// in started, the settings the daemon started with, until it restarts.
+func reloadConfig(cmd *cobra.Command, repo *repository.Repository, started *config.Config, eng *coreengine.Engine, w *corewatcher.Watcher) error {
+	settings, err := config.Load(repo.CaryaPath())
+	if err != nil {
+		return fmt.Errorf("failed to reload configuration: %w", err)
+	}
+	watcherOpts, err := watcherOptions(cmd, settings)
+	if err != nil {
+		return fmt.Errorf("failed to reload configuration: %w", err)
+	}
+
+	eng.SetOptions(settings.Engine)
+	w.SetOptions(watcherOpts)
+	applyRetention(repo, eng, settings.Retention)
+	if settings.Store != started.Store {
+		log.Printf("Still using the %s store; restart the daemon to switch to %s", started.Store.Backend, settings.Store.Backend)
+	}
+	log.Println("Reloaded configuration")
+	return nil
+}
+
+// applyRetention hands the repository's retention policy to the engine, which compacts
+// old chunks when it goes idle if the policy asks for it.
+func applyRetention(repo *repository.Repository, eng *coreengine.Engine, policy retention.Policy) {
+	eng.SetRetention(repo.RootPath(), policy)
+	if policy.AutoCompact {
+		log.Printf("Compacting chunks older than %d days when idle", policy.KeepDays)
+	}
+}
+
+// newControlServer creates the control socket server answering requests for the running daemon.
+// A reload request calls reload, which re-reads the settings and retention policy, before
+// re-reading the ignore rules; a stop request flushes all chunks and then signals stopCh.
+func newControlServer(d *daemon.Daemon, repo *repository.Repository, eng *coreengine.Engine, w *corewatcher.Watcher, reload func() error, stopCh chan<- struct{}) *daemon.Server {
+	startedAt := time.Now()
+	server := daemon.NewServer(d.GetSocketPath())
+
+	flush := func(daemon.Request) (interface{}, error) {
+		// Changes still in their quiet window belong in the flush too
+		w.Flush()
+		chunks, err := eng.FlushAll()
+		flushed := make([]daemon.FlushedChunk, 0, len(chunks))
+		for _, c := range chunks {
+			flushed = append(flushed, daemon.FlushedChunk{ID: string(c.ID), FilePath: c.FilePath})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("flushed %d chunks, then failed: %w", len(flushed), err)
+		}
+		log.Printf("Flushed %d chunks on request", len(flushed))
+		return flushed, nil
+	}
+
+	server.Handle(daemon.CommandStatus, func(daemon.Request) (interface{}, error) {
+		return daemon.Status{
+			PID:          os.Getpid(),
+			StartedAt:    startedAt,
+			WatchDir:     w.WatchDir(),
+			Paused:       w.Paused(),
+			ActiveChunks: len(eng.ActiveChunks()),
+		}, nil
+	})
+
+	server.Handle(daemon.CommandFlush, flush)
+
+	server.Handle(daemon.CommandFlushFile, func(req daemon.Request) (interface{}, error) {
+		w.Flush()
+		pending := false
+		for _, info := range eng.ActiveChunks() {
+			pending = pending || info.FilePath == req.Path
+		}
+		if !pending {
+			return nil, fmt.Errorf("no pending changes to %s", displayPath(req.Path))
+		}
+		if err := eng.ForceFlush(req.Path); err != nil {
+			return nil, err
+		}
+		log.Printf("Flushed %s on request", req.Path)
+		return nil, nil
+	})
+
+	server.HandleStream(daemon.CommandEvents, func(req daemon.Request, send func(interface{}) error, done <-chan struct{}) error {
+		sub := eng.Events().Subscribe(256)
+		defer sub.Close()
+		for {
+			select {
+			case event, ok := <-sub.Events():
+				if !ok {
+					return nil
 				}
-			case os.Interrupt, syscall.SIGTERM:
-				// Shutdown requested
-				log.Println("Shutting down Carya daemon...")
-				return
+				// Clients load snapshots from the store when they need them; the chunks are
+				// shared with other subscribers, so strip them from a copy
+				chunks := make([]chunk.Chunk, len(event.Chunks))
+				for i, c := range event.Chunks {
+					c.Before, c.After = nil, nil
+					chunks[i] = c
+				}
+				event.Chunks = chunks
+				if err := send(event); err != nil {
+					return err
+				}
+			case <-done:
+				return nil
 			}
 		}
-	},
+	})
+
+	server.Handle(daemon.CommandActive, func(daemon.Request) (interface{}, error) {
+		infos := eng.ActiveChunks()
+		active := make([]daemon.ActiveChunk, 0, len(infos))
+		for _, info := range infos {
+			active = append(active, daemon.ActiveChunk{
+				FilePath:   info.FilePath,
+				Op:         info.Op.String(),
+				StartTime:  info.StartTime,
+				LastUpdate: info.LastUpdate,
+			})
+		}
+		return active, nil
+	})
+
+	server.Handle(daemon.CommandPause, func(daemon.Request) (interface{}, error) {
+		w.Pause()
+		log.Println("Paused watching")
+		return nil, nil
+	})
+
+	server.Handle(daemon.CommandResume, func(daemon.Request) (interface{}, error) {
+		w.Resume()
+		log.Println("Resumed watching")
+		return nil, nil
+	})
+
+	server.Handle(daemon.CommandReload, func(daemon.Request) (interface{}, error) {
+		if err := reload(); err != nil {
+			return nil, err
+		}
+		w.Reload()
+		return nil, nil
+	})
+
+	server.Handle(daemon.CommandStop, func(req daemon.Request) (interface{}, error) {
+		w.Pause()
+		flushed, err := flush(req)
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case stopCh <- struct{}{}:
+		default:
+		}
+		return flushed, nil
+	})
+
+	return server
 }
 
 var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start Carya watcher in the background",
 	Run: func(cmd *cobra.Command, args []string) {
-		repo, err := repository.New()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-		if !repo.Exists() {
-			fmt.Fprintf(os.Stderr, "Error: Not a Carya repository. Run 'carya init' first.\n")
-			os.Exit(1)
-		}
+		repo := requireRepository()
 
-		d := daemon.New(repo.PIDPath(), repo.LogPath())
+		d := daemon.New(repo.PIDPath(), repo.LogPath(), repo.SocketPath())
 
 		if d.IsRunning() {
 			fmt.Println("Carya daemon is already running")
 			os.Exit(0)
 		}
 
+		// Check the settings here, where errors can be reported, rather than in the daemon's log
+		if _, err := watcherOptions(cmd, loadConfig(repo)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
 		// Start daemon in background
-		if err := d.Start([]string{"daemon"}); err != nil {
+		daemonArgs := []string{"daemon", "--repo", repo.RootPath()}
+		for _, name := range []string{"quiet-window", "max-file-size"} {
+			if flag := cmd.Flags().Lookup(name); flag.Changed {
+				daemonArgs = append(daemonArgs, "--"+name, flag.Value.String())
+			}
+		}
+		if err := d.Start(daemonArgs); err != nil {
 			fmt.Fprintf(os.Stderr, "Error starting daemon: %v\n", err)
 			os.Exit(1)
 		}
@@ -140,25 +348,16 @@ var stopCmd = &cobra.Command{
 	Use:   "stop",
 	Short: "Stop the Carya watcher daemon",
 	Run: func(cmd *cobra.Command, args []string) {
-		repo, err := repository.New()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
+		d := runningDaemon()
 
-		d := daemon.New(repo.PIDPath(), repo.LogPath())
-
-		if !d.IsRunning() {
-			fmt.Println("Carya daemon is not running")
-			os.Exit(0)
-		}
-
-		if err := d.Stop(); err != nil {
+		flushed, err := d.Stop()
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error stopping daemon: %v\n", err)
 			os.Exit(1)
 		}
 
 		fmt.Println("✓ Carya daemon stopped")
+		printFlushed(flushed)
 	},
 }
 
@@ -172,61 +371,160 @@ var statusCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		d := daemon.New(repo.PIDPath(), repo.LogPath())
+		d := daemon.New(repo.PIDPath(), repo.LogPath(), repo.SocketPath())
 
-		if d.IsRunning() {
-			pid, _ := d.ReadPID()
-			fmt.Printf("✓ Carya daemon is running (PID: %d)\n", pid)
-			fmt.Printf("  Log file: %s\n", d.GetLogPath())
-		} else {
+		if !d.IsRunning() {
+			if structuredOutput() {
+				printOutput(statusOutput{})
+				return
+			}
 			fmt.Println("Carya daemon is not running")
+			return
+		}
+
+		var status daemon.Status
+		if err := d.Call(daemon.CommandStatus, &status); err != nil {
+			pid, _ := d.ReadPID()
+			fmt.Fprintf(os.Stderr, "Error: Carya daemon (PID: %d) is running but not responding: %v\n", pid, err)
+			os.Exit(1)
+		}
+
+		var active []daemon.ActiveChunk
+		if err := d.Call(daemon.CommandActive, &active); err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing active chunks: %v\n", err)
+			os.Exit(1)
+		}
+
+		if structuredOutput() {
+			printOutput(statusOutput{Running: true, Status: &status, LogFile: d.GetLogPath(), Active: active})
+			return
+		}
+
+		state := "running"
+		if status.Paused {
+			state = "paused"
+		}
+		fmt.Printf("✓ Carya daemon is %s (PID: %d, up %s)\n", state, status.PID, time.Since(status.StartedAt).Round(time.Second))
+		fmt.Printf("  Watching: %s\n", status.WatchDir)
+		fmt.Printf("  Log file: %s\n", d.GetLogPath())
+
+		if len(active) == 0 {
+			fmt.Println("  No active chunks")
+			return
+		}
+		fmt.Printf("  Active chunks (%d):\n", len(active))
+		for _, a := range active {
+			fmt.Printf("    • %s (%s)  started %s, last change %s ago\n",
+				displayPath(a.FilePath), a.Op, a.StartTime.Format("15:04:05"), time.Since(a.LastUpdate).Round(time.Second))
 		}
 	},
 }
 
+// statusOutput is the structured form of carya status.
+type statusOutput struct {
+	Running        bool                 `json:"running"`
+	*daemon.Status                      // Reported by the daemon; omitted when it isn't running
+	LogFile        string               `json:"log_file,omitempty"`
+	Active         []daemon.ActiveChunk `json:"active,omitempty"` // Chunks still collecting changes
+}
+
 var flushCmd = &cobra.Command{
 	Use:   "flush",
 	Short: "Flush all pending chunks to storage",
 	Run: func(cmd *cobra.Command, args []string) {
-		repo, err := repository.New()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		var flushed []daemon.FlushedChunk
+		if err := runningDaemon().Call(daemon.CommandFlush, &flushed); err != nil {
+			fmt.Fprintf(os.Stderr, "Error flushing chunks: %v\n", err)
 			os.Exit(1)
 		}
 
-		d := daemon.New(repo.PIDPath(), repo.LogPath())
+		fmt.Println("✓ Flushed active chunks")
+		printFlushed(flushed)
+	},
+}
 
-		if !d.IsRunning() {
-			fmt.Println("Carya daemon is not running")
+var pauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Stop recording file changes until 'carya resume'",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runningDaemon().Call(daemon.CommandPause, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Error pausing daemon: %v\n", err)
 			os.Exit(1)
 		}
+		fmt.Println("✓ Carya daemon paused")
+	},
+}
 
-		pid, err := d.ReadPID()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading PID: %v\n", err)
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume recording file changes",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runningDaemon().Call(daemon.CommandResume, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Error resuming daemon: %v\n", err)
 			os.Exit(1)
 		}
+		fmt.Println("✓ Carya daemon resumed")
+	},
+}
 
-		process, err := os.FindProcess(pid)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error finding process: %v\n", err)
+var reloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Reload configuration and ignore rules in the running daemon",
+	Long: `Make the running daemon re-read its settings (see 'carya config'), ignore rules and
+retention policy. A change to store.backend only takes effect when the daemon restarts.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runningDaemon().Call(daemon.CommandReload, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reloading daemon: %v\n", err)
 			os.Exit(1)
 		}
+		fmt.Println("✓ Carya daemon reloaded its configuration")
+	},
+}
 
-		if err := process.Signal(syscall.SIGUSR1); err != nil {
-			fmt.Fprintf(os.Stderr, "Error sending flush signal: %v\n", err)
-			os.Exit(1)
-		}
+// runningDaemon returns the daemon of the repository in the current directory,
+// exiting with a message if it is not running.
+func runningDaemon() *daemon.Daemon {
+	repo, err := repository.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-		fmt.Println("✓ Flush signal sent to daemon")
-		fmt.Printf("  Check log file for results: %s\n", d.GetLogPath())
-	},
+	d := daemon.New(repo.PIDPath(), repo.LogPath(), repo.SocketPath())
+
+	if !d.IsRunning() {
+		fmt.Println("Carya daemon is not running")
+		os.Exit(1)
+	}
+
+	return d
+}
+
+// printFlushed lists the chunks saved by a flush.
+func printFlushed(flushed []daemon.FlushedChunk) {
+	if len(flushed) == 0 {
+		fmt.Println("  No pending changes")
+		return
+	}
+	fmt.Printf("  Saved %d chunks:\n", len(flushed))
+	for _, f := range flushed {
+		fmt.Printf("    • %s  %s\n", f.ID, displayPath(f.FilePath))
+	}
 }
 
 func init() {
+	for _, cmd := range []*cobra.Command{daemonCmd, startCmd} {
+		cmd.Flags().Duration("quiet-window", corewatcher.DefaultQuietWindow, "How long a file must stay unchanged before its changes are recorded (overrides watcher.quiet_window)")
+		cmd.Flags().String("max-file-size", "10M", "Files larger than this are recorded as changed without tracking their contents (overrides watcher.max_file_size)")
+	}
+
 	rootCmd.AddCommand(daemonCmd)
 	rootCmd.AddCommand(startCmd)
 	rootCmd.AddCommand(stopCmd)
+	structuredCommand(statusCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(flushCmd)
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
+	rootCmd.AddCommand(reloadCmd)
 }
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"carya/internal/config"
+	"carya/internal/daemon"
+	"carya/internal/repository"
+	"carya/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Inspect, upgrade and convert the chunk database",
+	Long: `Inspect and upgrade the schema of the chunk database in .carya/chunks.db, or move
+the history to another storage backend.
+Carya migrates the database automatically when it opens it, backing it up first;
+these commands show where a database stands and let you upgrade it explicitly.`,
+}
+
+// requireSQLiteBackend exits with an error message if the repository doesn't keep its
+// history in SQLite, the only backend with schema migrations.
+func requireSQLiteBackend(repo *repository.Repository) {
+	backend := loadConfig(repo).Store.Backend
+	if backend != store.BackendSQLite {
+		fmt.Fprintf(os.Stderr, "Error: This repository uses the %s backend; schema migrations only apply to sqlite.\n", backend)
+		os.Exit(1)
+	}
+}
+
+var dbStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the schema version of the chunk database",
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := requireRepository()
+		requireSQLiteBackend(repo)
+
+		status, err := store.ReadSchemaStatus(repo.DBPath())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading schema version: %v\n", err)
+			os.Exit(1)
+		}
+
+		if structuredOutput() {
+			printOutput(newDBStatusOutput(repo.DBPath(), status))
+			return
+		}
+
+		fmt.Printf("Database: %s\n", displayPath(repo.DBPath()))
+		fmt.Printf("Schema version: %d (latest: %d)\n", status.Version, status.Latest)
+
+		if len(status.Applied) > 0 {
+			fmt.Println("\nApplied migrations:")
+			for _, m := range status.Applied {
+				fmt.Printf("  %3d  %s  %s\n", m.Version, m.AppliedAt.Format("2006-01-02 15:04"), m.Description)
+			}
+		}
+
+		switch {
+		case status.Version > status.Latest:
+			fmt.Println("\nThe database was migrated by a newer version of Carya. Upgrade Carya to use it.")
+		case len(status.Pending) > 0:
+			fmt.Println("\nPending migrations:")
+			for _, m := range status.Pending {
+				fmt.Printf("  %3d  %s\n", m.Version, m.Description)
+			}
+			fmt.Println("\nRun 'carya db migrate' to apply them.")
+		default:
+			fmt.Println("\n✓ The database is up to date")
+		}
+	},
+}
+
+// migrationOutput is the structured form of a schema migration.
+type migrationOutput struct {
+	Version     int        `json:"version"`
+	Description string     `json:"description"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"` // Omitted for pending migrations
+}
+
+// dbStatusOutput is the structured form of the schema status of a database.
+type dbStatusOutput struct {
+	Database string            `json:"database"`
+	Version  int               `json:"version"`
+	Latest   int               `json:"latest"`
+	Applied  []migrationOutput `json:"applied"`
+	Pending  []migrationOutput `json:"pending"`
+}
+
+// newDBStatusOutput converts the schema status of the database at path to its structured form.
+func newDBStatusOutput(path string, status *store.SchemaStatus) dbStatusOutput {
+	out := dbStatusOutput{
+		Database: path,
+		Version:  status.Version,
+		Latest:   status.Latest,
+		Applied:  make([]migrationOutput, 0, len(status.Applied)),
+		Pending:  make([]migrationOutput, 0, len(status.Pending)),
+	}
+	for _, m := range status.Applied {
+		appliedAt := m.AppliedAt
+		out.Applied = append(out.Applied, migrationOutput{Version: m.Version, Description: m.Description, AppliedAt: &appliedAt})
+	}
+	for _, m := range status.Pending {
+		out.Pending = append(out.Pending, migrationOutput{Version: m.Version, Description: m.Description})
+	}
+	return out
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the chunk database to the latest schema",
+	Long: `Apply all pending schema migrations to the chunk database. A copy of the
+database is written next to it before the first migration runs.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := requireRepository()
+		requireSQLiteBackend(repo)
+
+		result, err := store.Migrate(repo.DBPath())
+		if result != nil && result.BackupPath != "" {
+			fmt.Printf("Backed up database to %s\n", displayPath(result.BackupPath))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error migrating database: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(result.Applied) == 0 {
+			fmt.Printf("✓ The database is already at the latest schema version (%d)\n", result.To)
+			return
+		}
+		for _, m := range result.Applied {
+			fmt.Printf("  %3d  %s\n", m.Version, m.Description)
+		}
+		fmt.Printf("✓ Migrated database from version %d to %d\n", result.From, result.To)
+	},
+}
+
+var dbConvertCmd = &cobra.Command{
+	Use:   "convert --to <backend>",
+	Short: "Move the history to another storage backend",
+	Long: `Copy all chunks, snapshots, features, dependencies and skipped changes to another
+storage backend and switch the repository to it:
+
+  sqlite  SQLite database in .carya/chunks.db (needs a build of Carya with cgo)
+  bolt    bbolt key-value database in .carya/chunks.bolt (pure Go)
+  json    JSON file in .carya/chunks.json, readable with ordinary tools
+
+The data of the previous backend is left in place. An existing file of the target
+backend is renamed out of the way first. Stop the daemon before converting.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		to, _ := cmd.Flags().GetString("to")
+		repo := requireRepository()
+
+		target := store.Backend(to)
+		if err := target.Available(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --to: %v\n", err)
+			os.Exit(1)
+		}
+
+		d := daemon.New(repo.PIDPath(), repo.LogPath(), repo.SocketPath())
+		if d.IsRunning() {
+			fmt.Fprintln(os.Stderr, "Error: The daemon is running. Stop it with 'carya stop' before converting.")
+			os.Exit(1)
+		}
+
+		settings := loadConfig(repo)
+		if settings.Store.Backend == target {
+			fmt.Printf("✓ The repository already uses the %s backend\n", target)
+			return
+		}
+
+		source, err := store.OpenWithConfig(repo.CaryaPath(), settings.Store)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening chunk store: %v\n", err)
+			os.Exit(1)
+		}
+		defer source.Close()
+
+		targetPath := target.Path(repo.CaryaPath())
+		if _, err := os.Stat(targetPath); err == nil {
+			backupPath := fmt.Sprintf("%s.%s.bak", targetPath, time.Now().Format("20060102-150405"))
+			if err := os.Rename(targetPath, backupPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error moving %s out of the way: %v\n", displayPath(targetPath), err)
+				os.Exit(1)
+			}
+			fmt.Printf("Moved existing %s to %s\n", displayPath(targetPath), displayPath(backupPath))
+		} else if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		dest, err := store.OpenBackend(target, targetPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s store: %v\n", target, err)
+			os.Exit(1)
+		}
+		result, err := store.Copy(dest, source)
+		dest.Close()
+		if err != nil {
+			// Don't leave a partial copy behind for DefaultBackend to pick up
+			os.Remove(targetPath)
+			fmt.Fprintf(os.Stderr, "Error converting history: %v\n", err)
+			os.Exit(1)
+		}
+
+		file, err := config.RepositoryFile(repo.CaryaPath())
+		if err == nil {
+			err = file.Set("store.backend", string(target))
+		}
+		if err == nil {
+			err = file.Save()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("✓ Converted %d chunks, %d features, %d dependencies and %d skipped changes from %s to %s\n",
+			result.Chunks, result.Features, result.Dependencies, result.Skipped, settings.Store.Backend, target)
+		fmt.Printf("The %s data in %s was kept; delete it once you no longer need it.\n",
+			settings.Store.Backend, displayPath(settings.Store.Backend.Path(repo.CaryaPath())))
+		if settings.Origin("store.backend") == config.OriginEnvironment {
+			fmt.Printf("$%s still selects the %s backend; unset it to use the converted history.\n",
+				config.EnvVar("store.backend"), settings.Store.Backend)
+		}
+	},
+}
+
+func init() {
+	dbConvertCmd.Flags().String("to", "", "Backend to move the history to (sqlite, bolt or json)")
+	dbConvertCmd.MarkFlagRequired("to")
+	structuredCommand(dbStatusCmd)
+	dbCmd.AddCommand(dbStatusCmd)
+	dbCmd.AddCommand(dbMigrateCmd)
+	dbCmd.AddCommand(dbConvertCmd)
+	rootCmd.AddCommand(dbCmd)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/deps"
+	"carya/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var depsCmd = &cobra.Command{
+	Use:   "deps [chunk-id]",
+	Short: "Show dependencies between chunks",
+	Long: `Show which chunks a chunk depends on and which chunks depend on it.
+A chunk depends on an earlier chunk when it edits lines that chunk changed (overlap),
+or when it uses a symbol that chunk declared (symbol). Without a chunk ID, every
+recorded dependency is listed.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		transitive, _ := cmd.Flags().GetBool("transitive")
+		rebuild, _ := cmd.Flags().GetBool("rebuild")
+
+		_, chunkStore := openStore()
+		defer chunkStore.Close()
+
+		if rebuild {
+			count, err := rebuildDependencies(chunkStore)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error rebuilding dependencies: %v\n", err)
+				os.Exit(1)
+			}
+			if structuredOutput() {
+				// Keep stdout for the document, which lists the rebuilt dependencies
+				fmt.Fprintf(os.Stderr, "✓ Rebuilt dependencies for %d chunks\n", count)
+			} else {
+				fmt.Printf("✓ Rebuilt dependencies for %d chunks\n", count)
+				if len(args) == 0 {
+					return
+				}
+			}
+		}
+
+		if len(args) == 0 {
+			edges, err := chunkStore.ListDependencies()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error listing dependencies: %v\n", err)
+				os.Exit(1)
+			}
+			if structuredOutput() {
+				printOutput(depsListOutput{Dependencies: newEdgeOutputs(edges)})
+				return
+			}
+			if len(edges) == 0 {
+				fmt.Println("No dependencies recorded.")
+				return
+			}
+			for _, e := range edges {
+				fmt.Printf("%s → %s  (%s)\n", e.Chunk, e.DependsOn, e)
+			}
+			return
+		}
+
+		id := chunk.ChunkID(args[0])
+		c, err := chunkStore.GetChunk(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var prerequisites, dependents []deps.Edge
+		if transitive {
+			prerequisites, err = deps.Prerequisites(chunkStore, []chunk.ChunkID{id})
+			if err == nil {
+				dependents, err = deps.Dependents(chunkStore, []chunk.ChunkID{id})
+			}
+		} else {
+			prerequisites, err = chunkStore.FindDependencies(id)
+			if err == nil {
+				dependents, err = chunkStore.FindDependents(id)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading dependencies: %v\n", err)
+			os.Exit(1)
+		}
+
+		if structuredOutput() {
+			printOutput(chunkDepsOutput{
+				Chunk:      newChunkOutput(*c, false),
+				DependsOn:  newEdgeOutputs(prerequisites),
+				RequiredBy: newEdgeOutputs(dependents),
+			})
+			return
+		}
+
+		fmt.Printf("Chunk %s  %s\n", c.ID, displayChunkPath(*c))
+
+		fmt.Println("\nDepends on:")
+		printEdges(prerequisites, func(e deps.Edge) chunk.ChunkID { return e.DependsOn })
+
+		fmt.Println("\nRequired by:")
+		printEdges(dependents, func(e deps.Edge) chunk.ChunkID { return e.Chunk })
+	},
+}
+
+// edgeOutput is the structured form of a dependency edge.
+type edgeOutput struct {
+	Chunk     chunk.ChunkID `json:"chunk"`      // The dependent chunk
+	DependsOn chunk.ChunkID `json:"depends_on"` // The chunk it depends on
+	Kind      deps.Kind     `json:"kind"`
+	Detail    string        `json:"detail,omitempty"`
+}
+
+// newEdgeOutputs converts edges to their structured form.
+func newEdgeOutputs(edges []deps.Edge) []edgeOutput {
+	out := make([]edgeOutput, 0, len(edges))
+	for _, e := range edges {
+		out = append(out, edgeOutput{Chunk: e.Chunk, DependsOn: e.DependsOn, Kind: e.Kind, Detail: e.Detail})
+	}
+	return out
+}
+
+// depsListOutput is the structured form of every recorded dependency.
+type depsListOutput struct {
+	Dependencies []edgeOutput `json:"dependencies"`
+}
+
+// chunkDepsOutput is the structured form of the dependencies of one chunk.
+type chunkDepsOutput struct {
+	Chunk      chunkOutput  `json:"chunk"`
+	DependsOn  []edgeOutput `json:"depends_on"`
+	RequiredBy []edgeOutput `json:"required_by"`
+}
+
+// printEdges prints one line per edge, naming the chunk at the other end of it.
+func printEdges(edges []deps.Edge, other func(deps.Edge) chunk.ChunkID) {
+	if len(edges) == 0 {
+		fmt.Println("  (none)")
+		return
+	}
+	for _, e := range edges {
+		fmt.Printf("  • %s  (%s)\n", other(e), e)
+	}
+}
+
+// rebuildDependencies recomputes the dependencies of every chunk, oldest first.
+// Returns the number of chunks analyzed.
+func rebuildDependencies(s store.Store) (int, error) {
+	chunks, err := s.FindChunksBetween(time.Time{}, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+
+	tracker := deps.NewTracker(s)
+	for _, c := range chunks {
+		if err := tracker.LinkChunk(c); err != nil {
+			return 0, fmt.Errorf("chunk %s: %w", c.ID, err)
+		}
+	}
+	return len(chunks), nil
+}
+
+// printMissingPrerequisites warns about prerequisites of the selected chunks that are not selected.
+func printMissingPrerequisites(missing []deps.Edge) {
+	fmt.Fprintf(os.Stderr, "Warning: the selection is missing %d prerequisite chunks:\n", len(missing))
+	for _, e := range missing {
+		fmt.Fprintf(os.Stderr, "  • %s  needed by %s  (%s)\n", e.DependsOn, e.Chunk, e)
+	}
+}
+
+func init() {
+	depsCmd.Flags().BoolP("transitive", "t", false, "Include indirect dependencies")
+	depsCmd.Flags().Bool("rebuild", false, "Recompute the dependencies of all chunks")
+	structuredCommand(depsCmd)
+	rootCmd.AddCommand(depsCmd)
+}
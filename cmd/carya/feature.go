@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/feature"
+	"carya/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var featureCmd = &cobra.Command{
+	Use:   "feature",
+	Short: "Group chunks into features",
+	Long: `Manage features, named units of work that chunks are tagged with.
+While a feature is active, every new chunk is automatically tagged with it.`,
+}
+
+var featureStartCmd = &cobra.Command{
+	Use:   "start <name>",
+	Short: "Start (or resume) a feature and make it active",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		description, _ := cmd.Flags().GetString("description")
+		tag := feature.Tag(args[0])
+
+		_, chunkStore := openStore()
+		defer chunkStore.Close()
+
+		existing, err := chunkStore.GetFeature(tag)
+		switch {
+		case errors.Is(err, store.ErrFeatureNotFound):
+			f := feature.Feature{
+				Tag:         tag,
+				Description: description,
+				Status:      feature.StatusOpen,
+				CreatedAt:   time.Now(),
+			}
+			if err := chunkStore.CreateFeature(f); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error loading feature: %v\n", err)
+			os.Exit(1)
+		case existing.Status == feature.StatusActive:
+			fmt.Printf("Feature %s is already active\n", tag)
+			return
+		}
+
+		if err := chunkStore.SetActiveFeature(tag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error activating feature: %v\n", err)
+			os.Exit(1)
+		}
+
+		if existing != nil {
+			fmt.Printf("✓ Resumed feature %s\n", tag)
+		} else {
+			fmt.Printf("✓ Started feature %s\n", tag)
+		}
+		fmt.Println("  New chunks will be tagged with this feature")
+	},
+}
+
+var featureStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Deactivate the current feature without closing it",
+	Run: func(cmd *cobra.Command, args []string) {
+		_, chunkStore := openStore()
+		defer chunkStore.Close()
+
+		active, err := chunkStore.ActiveFeature()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading active feature: %v\n", err)
+			os.Exit(1)
+		}
+		if active == nil {
+			fmt.Println("No feature is active")
+			return
+		}
+
+		if err := chunkStore.SetActiveFeature(""); err != nil {
+			fmt.Fprintf(os.Stderr, "Error deactivating feature: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("✓ Stopped feature %s\n", active.Tag)
+	},
+}
+
+var featureCloseCmd = &cobra.Command{
+	Use:   "close [name]",
+	Short: "Close a feature (defaults to the active one)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, chunkStore := openStore()
+		defer chunkStore.Close()
+
+		var tag feature.Tag
+		if len(args) == 1 {
+			tag = feature.Tag(args[0])
+		} else {
+			active, err := chunkStore.ActiveFeature()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading active feature: %v\n", err)
+				os.Exit(1)
+			}
+			if active == nil {
+				fmt.Fprintln(os.Stderr, "Error: No feature is active. Specify the feature to close.")
+				os.Exit(1)
+			}
+			tag = active.Tag
+		}
+
+		if err := chunkStore.CloseFeature(tag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing feature: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("✓ Closed feature %s\n", tag)
+	},
+}
+
+var featureListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List features",
+	Run: func(cmd *cobra.Command, args []string) {
+		showAll, _ := cmd.Flags().GetBool("all")
+
+		_, chunkStore := openStore()
+		defer chunkStore.Close()
+
+		features, err := chunkStore.ListFeatures()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing features: %v\n", err)
+			os.Exit(1)
+		}
+
+		var shown []featureOutput
+		for _, f := range features {
+			if f.Status == feature.StatusClosed && !showAll {
+				continue
+			}
+
+			chunks, err := chunkStore.FindChunksByFeature(f.Tag)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading chunks for %s: %v\n", f.Tag, err)
+				os.Exit(1)
+			}
+			shown = append(shown, newFeatureOutput(f, len(chunks)))
+		}
+
+		if structuredOutput() {
+			printOutput(featureListOutput{Features: append([]featureOutput{}, shown...)})
+			return
+		}
+
+		for _, f := range shown {
+			marker := " "
+			if f.Status == feature.StatusActive {
+				marker = "*"
+			}
+			fmt.Printf("%s %s  (%s, %d chunks, started %s)\n", marker, f.Tag, f.Status, f.Chunks, f.CreatedAt.Format("2006-01-02 15:04"))
+			if f.Description != "" {
+				fmt.Printf("    %s\n", f.Description)
+			}
+		}
+
+		if len(shown) == 0 {
+			fmt.Println("No features. Start one with 'carya feature start <name>'.")
+		}
+	},
+}
+
+// featureOutput is the structured form of a feature.
+type featureOutput struct {
+	Tag         feature.Tag    `json:"tag"`
+	Description string         `json:"description,omitempty"`
+	Status      feature.Status `json:"status"`
+	Chunks      int            `json:"chunks"` // Number of chunks tagged with the feature
+	CreatedAt   time.Time      `json:"created_at"`
+	ClosedAt    *time.Time     `json:"closed_at,omitempty"`
+}
+
+// newFeatureOutput converts a feature with the given number of chunks to its structured form.
+func newFeatureOutput(f feature.Feature, chunks int) featureOutput {
+	out := featureOutput{Tag: f.Tag, Description: f.Description, Status: f.Status, Chunks: chunks, CreatedAt: f.CreatedAt}
+	if !f.ClosedAt.IsZero() {
+		out.ClosedAt = &f.ClosedAt
+	}
+	return out
+}
+
+// featureListOutput is the structured form of the feature list.
+type featureListOutput struct {
+	Features []featureOutput `json:"features"`
+}
+
+var featureCurrentCmd = &cobra.Command{
+	Use:   "current",
+	Short: "Show the active feature",
+	Run: func(cmd *cobra.Command, args []string) {
+		_, chunkStore := openStore()
+		defer chunkStore.Close()
+
+		active, err := chunkStore.ActiveFeature()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading active feature: %v\n", err)
+			os.Exit(1)
+		}
+		if active == nil {
+			fmt.Println("No feature is active")
+			return
+		}
+		fmt.Println(active.Tag)
+	},
+}
+
+var featureTagCmd = &cobra.Command{
+	Use:   "tag <name> <chunk-id>...",
+	Short: "Tag existing chunks with a feature",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		tag := feature.Tag(args[0])
+
+		_, chunkStore := openStore()
+		defer chunkStore.Close()
+
+		if _, err := chunkStore.GetFeature(tag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := chunkStore.TagChunks(toChunkIDs(args[1:]), tag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error tagging chunks: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("✓ Tagged %d chunks with %s\n", len(args)-1, tag)
+	},
+}
+
+var featureUntagCmd = &cobra.Command{
+	Use:   "untag <chunk-id>...",
+	Short: "Remove the feature tag from chunks",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, chunkStore := openStore()
+		defer chunkStore.Close()
+
+		if err := chunkStore.TagChunks(toChunkIDs(args), ""); err != nil {
+			fmt.Fprintf(os.Stderr, "Error untagging chunks: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("✓ Untagged %d chunks\n", len(args))
+	},
+}
+
+// toChunkIDs converts command-line arguments to chunk IDs.
+func toChunkIDs(args []string) []chunk.ChunkID {
+	ids := make([]chunk.ChunkID, len(args))
+	for i, arg := range args {
+		ids[i] = chunk.ChunkID(arg)
+	}
+	return ids
+}
+
+func init() {
+	featureStartCmd.Flags().StringP("description", "d", "", "Description of the feature")
+	featureListCmd.Flags().BoolP("all", "a", false, "Include closed features")
+	structuredCommand(featureListCmd)
+
+	featureCmd.AddCommand(featureStartCmd)
+	featureCmd.AddCommand(featureStopCmd)
+	featureCmd.AddCommand(featureCloseCmd)
+	featureCmd.AddCommand(featureListCmd)
+	featureCmd.AddCommand(featureCurrentCmd)
+	featureCmd.AddCommand(featureTagCmd)
+	featureCmd.AddCommand(featureUntagCmd)
+
+	rootCmd.AddCommand(featureCmd)
+}
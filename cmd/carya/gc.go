@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/retention"
+
+	"github.com/spf13/cobra"
+)
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Compact old chunks and clean up unused file snapshots",
+	Long: `Compact old chunks and clean up the storage of file snapshots.
+
+Chunks newer than the retention period are kept as they are. Older chunks whose changes
+are already in the upstream branch are dropped, and each remaining run of a file's chunks
+of one feature and branch is merged per hour or day. The defaults come from the
+retention.* settings, which also control whether the daemon compacts on its own when idle:
+
+  [retention]
+  keep_days = 30
+  thin = day
+  drop_pushed = true
+  auto_compact = false
+
+Snapshots still stored inline in the chunk database by older versions of Carya are then
+moved to the object store in .carya/objects, and objects no chunk refers to any more are
+removed. Objects written within the grace period are kept, since a running daemon may be
+about to save the chunk using them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		grace, _ := cmd.Flags().GetDuration("grace")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		repo, chunkStore := openStore()
+		defer chunkStore.Close()
+
+		policy := loadConfig(repo).Retention
+		if cmd.Flags().Changed("keep") {
+			policy.KeepDays, _ = cmd.Flags().GetInt("keep")
+		}
+		if cmd.Flags().Changed("thin") {
+			thin, _ := cmd.Flags().GetString("thin")
+			policy.Thin = retention.Granularity(thin)
+		}
+		if cmd.Flags().Changed("drop-pushed") {
+			policy.DropPushed, _ = cmd.Flags().GetBool("drop-pushed")
+		}
+
+		plan, err := retention.PlanCompaction(chunkStore, repo.RootPath(), policy, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error planning compaction: %v\n", err)
+			os.Exit(1)
+		}
+		if dryRun {
+			if structuredOutput() {
+				printOutput(newGCOutput(plan, policy, true))
+				return
+			}
+			printCompactionPlan(plan)
+			return
+		}
+		if err := retention.Apply(chunkStore, plan); err != nil {
+			fmt.Fprintf(os.Stderr, "Error compacting chunks: %v\n", err)
+			os.Exit(1)
+		}
+		if plan.Removed() > 0 && !structuredOutput() {
+			merged := 0
+			for _, m := range plan.Merges {
+				merged += len(m.Replaces)
+			}
+			fmt.Printf("✓ Compacted chunks older than %d days: dropped %d, merged %d into %d\n",
+				policy.KeepDays, len(plan.Pushed)+len(plan.Unneeded), merged, len(plan.Merges))
+		}
+
+		// Only the SQLite backend has inline snapshots and space to reclaim
+		type sqliteMaintainer interface {
+			MoveInlineSnapshots() (int, error)
+			Vacuum() error
+		}
+		moved := 0
+		if db, ok := chunkStore.(sqliteMaintainer); ok {
+			if moved, err = db.MoveInlineSnapshots(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error moving snapshots to the object store: %v\n", err)
+				os.Exit(1)
+			}
+			if moved > 0 || plan.Removed() > 0 {
+				if err := db.Vacuum(); err != nil {
+					fmt.Fprintf(os.Stderr, "Error compacting database: %v\n", err)
+					os.Exit(1)
+				}
+			}
+		}
+		if moved > 0 && !structuredOutput() {
+			fmt.Printf("✓ Moved the snapshots of %d chunks to the object store\n", moved)
+		}
+
+		result, err := chunkStore.PruneObjects(grace)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing unused objects: %v\n", err)
+			os.Exit(1)
+		}
+		if structuredOutput() {
+			out := newGCOutput(plan, policy, false)
+			out.MovedSnapshots = moved
+			out.Objects = &pruneOutput{Removed: result.Removed, Freed: result.Freed, Kept: result.Kept}
+			printOutput(out)
+			return
+		}
+		fmt.Printf("✓ Removed %d unused objects (%s), kept %d\n", result.Removed, formatSize(result.Freed), result.Kept)
+	},
+}
+
+// gcOutput is the structured form of a gc run.
+type gcOutput struct {
+	DryRun         bool            `json:"dry_run"`
+	KeepDays       int             `json:"keep_days"`
+	Upstream       string          `json:"upstream,omitempty"` // Branch pushed chunks were looked up in
+	Dropped        []droppedOutput `json:"dropped"`
+	Merged         []mergeOutput   `json:"merged"`
+	Removed        int             `json:"removed"`                   // Chunks removed by compaction
+	MovedSnapshots int             `json:"moved_snapshots,omitempty"` // Chunks whose snapshots moved to the object store
+	Objects        *pruneOutput    `json:"objects,omitempty"`         // Omitted in a dry run
+}
+
+// droppedOutput is the structured form of a chunk dropped by compaction.
+type droppedOutput struct {
+	ID     chunk.ChunkID `json:"id"`
+	File   string        `json:"file"`
+	Reason string        `json:"reason"` // "pushed" or "unneeded"
+}
+
+// mergeOutput is the structured form of chunks merged by compaction.
+type mergeOutput struct {
+	Into      chunk.ChunkID   `json:"into"`
+	File      string          `json:"file"`
+	Replaces  []chunk.ChunkID `json:"replaces"`
+	StartTime time.Time       `json:"start_time"`
+	EndTime   time.Time       `json:"end_time"`
+}
+
+// pruneOutput is the structured form of the removal of unused objects.
+type pruneOutput struct {
+	Removed int   `json:"removed"`
+	Freed   int64 `json:"freed"` // Bytes freed on disk
+	Kept    int   `json:"kept"`
+}
+
+// newGCOutput converts a compaction plan to the structured form of a gc run.
+func newGCOutput(plan *retention.Plan, policy retention.Policy, dryRun bool) gcOutput {
+	out := gcOutput{
+		DryRun:   dryRun,
+		KeepDays: policy.KeepDays,
+		Upstream: plan.Upstream,
+		Dropped:  []droppedOutput{},
+		Merged:   []mergeOutput{},
+		Removed:  plan.Removed(),
+	}
+	for _, c := range plan.Pushed {
+		out.Dropped = append(out.Dropped, droppedOutput{ID: c.ID, File: c.FilePath, Reason: "pushed"})
+	}
+	for _, c := range plan.Unneeded {
+		out.Dropped = append(out.Dropped, droppedOutput{ID: c.ID, File: c.FilePath, Reason: "unneeded"})
+	}
+	for _, m := range plan.Merges {
+		out.Merged = append(out.Merged, mergeOutput{
+			Into:      m.Into.ID,
+			File:      m.Into.FilePath,
+			Replaces:  m.Replaces,
+			StartTime: m.Into.StartTime,
+			EndTime:   m.Into.EndTime,
+		})
+	}
+	return out
+}
+
+// printCompactionPlan lists the chunks a compaction would drop and merge.
+func printCompactionPlan(plan *retention.Plan) {
+	if plan.Removed() == 0 {
+		fmt.Println("Nothing to compact")
+		return
+	}
+
+	for _, c := range plan.Pushed {
+		fmt.Printf("drop   %s  %s (in %s)\n", c.ID, displayChunkPath(c), plan.Upstream)
+	}
+	for _, c := range plan.Unneeded {
+		fmt.Printf("drop   %s  %s (no net change)\n", c.ID, displayChunkPath(c))
+	}
+	for _, m := range plan.Merges {
+		fmt.Printf("merge  %d chunks into %s  %s (%s - %s)\n", len(m.Replaces), m.Into.ID, displayChunkPath(m.Into),
+			m.Into.StartTime.Format("2006-01-02 15:04"), m.Into.EndTime.Format("15:04"))
+	}
+	fmt.Printf("\nWould remove %d chunks (dry run)\n", plan.Removed())
+}
+
+func init() {
+	gcCmd.Flags().Int("keep", retention.DefaultPolicy().KeepDays, "Keep every chunk from the last this many days")
+	gcCmd.Flags().String("thin", string(retention.DefaultPolicy().Thin), "Merge older chunks of a file per: none, hour or day")
+	gcCmd.Flags().Bool("drop-pushed", retention.DefaultPolicy().DropPushed, "Drop older chunks already in the upstream branch")
+	gcCmd.Flags().Bool("dry-run", false, "Show what compaction would remove without changing anything")
+	gcCmd.Flags().Duration("grace", time.Hour, "Keep unreferenced objects written more recently than this")
+	structuredCommand(gcCmd)
+	rootCmd.AddCommand(gcCmd)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"carya/internal/housekeeping"
@@ -90,7 +91,15 @@ var housekeepingListCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		config, err := housekeeping.LoadConfig()
 		if err != nil {
-			fmt.Printf("Error loading config: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+
+		if structuredOutput() {
+			printOutput(housekeepingListOutput{
+				PostPull:     append([]housekeeping.Command{}, config.PostPull...),
+				PostCheckout: append([]housekeeping.Command{}, config.PostCheckout...),
+			})
 			return
 		}
 
@@ -119,6 +128,12 @@ var housekeepingListCmd = &cobra.Command{
 	},
 }
 
+// housekeepingListOutput is the structured form of carya housekeeping list.
+type housekeepingListOutput struct {
+	PostPull     []housekeeping.Command `json:"post-pull"`
+	PostCheckout []housekeeping.Command `json:"post-checkout"`
+}
+
 var housekeepingEditCmd = &cobra.Command{
 	Use:   "edit",
 	Short: "Edit the housekeeping configuration file",
@@ -164,10 +179,19 @@ var housekeepingDetectCmd = &cobra.Command{
 	Short: "Detect package managers and build systems in the project",
 	Long:  `Scan the project directory to detect package managers and build systems.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		detector := housekeeping.NewDetector(".")
+		detector := housekeeping.NewDetector(findRepository().RootPath())
 		detected, err := detector.DetectPackages()
 		if err != nil {
-			fmt.Printf("Error detecting packages: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error detecting packages: %v\n", err)
+			os.Exit(1)
+		}
+
+		if structuredOutput() {
+			packages := make([]detectedPackageOutput, 0, len(detected))
+			for _, pkg := range detected {
+				packages = append(packages, detectedPackageOutput{Name: pkg.Type.Name, Description: pkg.Type.Description, Path: pkg.Path})
+			}
+			printOutput(packages)
 			return
 		}
 
@@ -178,11 +202,18 @@ var housekeepingDetectCmd = &cobra.Command{
 
 		fmt.Println("Detected package managers and build systems:")
 		for _, pkg := range detected {
-			fmt.Printf("  • %s (%s)\n", pkg.Type.Description, pkg.Path)
+			fmt.Printf("  • %s (%s)\n", pkg.Type.Description, displayPath(pkg.Path))
 		}
 	},
 }
 
+// detectedPackageOutput is the structured form of a detected package manager or build system.
+type detectedPackageOutput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Path        string `json:"path"`
+}
+
 var housekeepingSuggestCmd = &cobra.Command{
 	Use:   "suggest [category]",
 	Short: "Suggest housekeeping commands based on detected packages",
@@ -192,14 +223,19 @@ var housekeepingSuggestCmd = &cobra.Command{
 		category := args[0]
 
 		if category != "post-pull" && category != "post-checkout" {
-			fmt.Printf("Error: Invalid category '%s'. Must be 'post-pull' or 'post-checkout'\n", category)
-			return
+			fmt.Fprintf(os.Stderr, "Error: Invalid category '%s'. Must be 'post-pull' or 'post-checkout'\n", category)
+			os.Exit(1)
 		}
 
-		detector := housekeeping.NewDetector(".")
+		detector := housekeeping.NewDetector(findRepository().RootPath())
 		suggestions, err := detector.GetSuggestedCommands(category)
 		if err != nil {
-			fmt.Printf("Error getting suggestions: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error getting suggestions: %v\n", err)
+			os.Exit(1)
+		}
+
+		if structuredOutput() {
+			printOutput(append([]housekeeping.Command{}, suggestions...))
 			return
 		}
 
@@ -232,7 +268,7 @@ var housekeepingAutoCmd = &cobra.Command{
 			return
 		}
 
-		detector := housekeeping.NewDetector(".")
+		detector := housekeeping.NewDetector(findRepository().RootPath())
 		suggestions, err := detector.GetSuggestedCommands(category)
 		if err != nil {
 			fmt.Printf("Error getting suggestions: %v\n", err)
@@ -291,6 +327,10 @@ func init() {
 	// Add flags to the run command
 	housekeepingRunCmd.Flags().Bool("auto", false, "Run commands without confirmation")
 
+	structuredCommand(housekeepingListCmd)
+	structuredCommand(housekeepingDetectCmd)
+	structuredCommand(housekeepingSuggestCmd)
+
 	// Add subcommands to housekeeping
 	housekeepingCmd.AddCommand(housekeepingSetupCmd)
 	housekeepingCmd.AddCommand(housekeepingAddCmd)
@@ -48,7 +48,7 @@ var initCmd = &cobra.Command{
 					fmt.Fprintf(os.Stderr, "Warning: Failed to initialize repository: %v\n", err)
 					fmt.Fprintf(os.Stderr, "You can manually start it later with 'carya start'\n")
 				} else {
-					d := daemon.New(repo.PIDPath(), repo.LogPath())
+					d := daemon.New(repo.PIDPath(), repo.LogPath(), repo.SocketPath())
 
 					if d.IsRunning() {
 						fmt.Println("Carya daemon is already running")
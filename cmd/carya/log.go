@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"carya/internal/chunk"
+	"carya/internal/feature"
+
+	"github.com/spf13/cobra"
+)
+
+var logCmd = &cobra.Command{
+	Use:   "log",
+	Short: "List recorded chunks",
+	Long: `List recorded chunks, newest first, optionally filtered by time, path, feature,
+branch, origin and diff contents. Chunks whose changes have since been committed to git
+are marked as such.
+
+--path takes a glob: "*.go" matches Go files in any directory, "internal/**/*_test.go"
+matches test files anywhere below internal, and a directory matches everything inside it.
+When more chunks match than --limit allows, the command prints a cursor; pass it to
+--cursor to see the next page. With --output json or yaml, the chunks and the cursor are
+printed as a document, including each chunk's diff with --patch.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		sinceStr, _ := cmd.Flags().GetString("since")
+		untilStr, _ := cmd.Flags().GetString("until")
+		pathGlob, _ := cmd.Flags().GetString("path")
+		text, _ := cmd.Flags().GetString("grep")
+		featureTag, _ := cmd.Flags().GetString("feature")
+		branch, _ := cmd.Flags().GetString("branch")
+		manual, _ := cmd.Flags().GetBool("manual")
+		auto, _ := cmd.Flags().GetBool("auto")
+		limit, _ := cmd.Flags().GetInt("limit")
+		cursor, _ := cmd.Flags().GetString("cursor")
+		patch, _ := cmd.Flags().GetBool("patch")
+
+		since, err := parseTimeFlag(sinceStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --since: %v\n", err)
+			os.Exit(1)
+		}
+		until, err := parseTimeFlag(untilStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --until: %v\n", err)
+			os.Exit(1)
+		}
+		if manual && auto {
+			fmt.Fprintln(os.Stderr, "Error: --manual and --auto can't be combined")
+			os.Exit(1)
+		}
+
+		// Patterns naming a location are relative to the current directory, like file arguments
+		if strings.ContainsRune(pathGlob, filepath.Separator) && !filepath.IsAbs(pathGlob) {
+			if pathGlob, err = filepath.Abs(pathGlob); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: --path: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		q := chunk.Query{
+			Since:      since,
+			Until:      until,
+			PathGlob:   pathGlob,
+			FeatureTag: feature.Tag(featureTag),
+			Branch:     branch,
+			Text:       text,
+			After:      chunk.Cursor(cursor),
+			Limit:      limit,
+		}
+		if manual || auto {
+			q.Manual = &manual
+		}
+
+		_, chunkStore := openStore()
+		defer chunkStore.Close()
+
+		page, err := chunkStore.QueryChunks(q)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error querying chunks: %v\n", err)
+			os.Exit(1)
+		}
+
+		if structuredOutput() {
+			printOutput(newChunkListOutput(page.Chunks, page.Next, patch))
+			return
+		}
+		if len(page.Chunks) == 0 {
+			fmt.Println("No chunks match.")
+			return
+		}
+		for _, c := range page.Chunks {
+			printLogEntry(c, patch)
+		}
+		if page.Next != "" {
+			fmt.Printf("\nMore chunks match; continue with --cursor %s\n", page.Next)
+		}
+	},
+}
+
+// printLogEntry prints a one-line summary of a chunk, followed by its diff if patch is set.
+func printLogEntry(c chunk.Chunk, patch bool) {
+	var notes []string
+	if c.FeatureTag != "" {
+		notes = append(notes, "["+string(c.FeatureTag)+"]")
+	}
+	if c.Manual {
+		notes = append(notes, "(manual)")
+	}
+	if c.Branch != "" {
+		notes = append(notes, "on "+c.Branch)
+	}
+	if c.Committed != "" {
+		notes = append(notes, "(committed in "+shortHash(c.Committed)+")")
+	}
+	line := fmt.Sprintf("%s  %s  %s %s", c.StartTime.Format("2006-01-02 15:04"), c.ID, displayChunkPath(c), strings.Join(notes, " "))
+	fmt.Println(strings.TrimRight(line, " "))
+
+	if patch && c.Diff != "" {
+		fmt.Println(strings.TrimRight(c.Diff, "\n"))
+		fmt.Println()
+	}
+}
+
+func init() {
+	logCmd.Flags().String("since", "", "Only list chunks started after this time (e.g. 2h, 3d, 2006-01-02)")
+	logCmd.Flags().String("until", "", "Only list chunks started before this time")
+	logCmd.Flags().String("path", "", "Only list chunks for files matching this glob (e.g. *.go, internal/**)")
+	logCmd.Flags().String("grep", "", "Only list chunks whose diff contains this text (case-insensitive)")
+	logCmd.Flags().String("feature", "", "Only list chunks tagged with this feature")
+	logCmd.Flags().String("branch", "", "Only list chunks made while this git branch was checked out")
+	logCmd.Flags().Bool("manual", false, "Only list manually created chunks")
+	logCmd.Flags().Bool("auto", false, "Only list automatically created chunks")
+	logCmd.Flags().IntP("limit", "n", 20, "Number of chunks per page (0 for all)")
+	logCmd.Flags().String("cursor", "", "Continue after the previous page, using the cursor it printed")
+	logCmd.Flags().BoolP("patch", "p", false, "Show each chunk's diff")
+	structuredCommand(logCmd)
+	rootCmd.AddCommand(logCmd)
+}
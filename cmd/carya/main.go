@@ -16,8 +16,20 @@ var rootCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Carya is running. Use 'carya --help' for a list of commands.")
 	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if repoDir != "" {
+			// Like git -C: the repository is found from there and relative paths start there
+			if err := os.Chdir(repoDir); err != nil {
+				return fmt.Errorf("--repo: %w", err)
+			}
+		}
+		return checkOutputFormat(cmd)
+	},
 }
 
+// repoDir is the value of the global --repo flag.
+var repoDir string
+
 // Execute runs the root command and handles any errors that occur during execution.
 // It prints errors to stderr and exits with code 1 if an error occurs.
 func Execute() {
@@ -27,6 +39,10 @@ func Execute() {
 	}
 }
 
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&repoDir, "repo", "C", "", "Run as if carya was started in this directory")
+}
+
 // main is the entry point for the Carya CLI application.
 func main() {
 	Execute()
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"carya/internal/chunk"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by --output.
+const (
+	outputTable = "table" // Human-readable text
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// structuredAnnotation marks commands that honour --output json and yaml.
+const structuredAnnotation = "carya/structured-output"
+
+// outputFormat is the value of the global --output flag.
+var outputFormat = outputTable
+
+// structuredCommand marks cmd as printing structured output when --output asks for it.
+func structuredCommand(cmd *cobra.Command) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[structuredAnnotation] = "true"
+}
+
+// checkOutputFormat validates --output and rejects json and yaml for commands that only print text.
+func checkOutputFormat(cmd *cobra.Command) error {
+	switch outputFormat {
+	case outputTable:
+		return nil
+	case outputJSON, outputYAML:
+		if cmd.Annotations[structuredAnnotation] == "" {
+			return fmt.Errorf("--output %s is not supported by '%s'", outputFormat, cmd.CommandPath())
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid --output %q (use json, yaml or table)", outputFormat)
+}
+
+// structuredOutput reports whether --output asks for JSON or YAML instead of text.
+func structuredOutput() bool {
+	return outputFormat != outputTable
+}
+
+// printOutput writes v to stdout in the format chosen with --output, using its JSON field
+// names for YAML too. It exits with an error message if v can't be encoded.
+func printOutput(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err == nil && outputFormat == outputYAML {
+		data, err = jsonToYAML(data)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding output: %v\n", err)
+		os.Exit(1)
+	}
+
+	os.Stdout.Write(bytes.TrimRight(data, "\n"))
+	fmt.Println()
+}
+
+// jsonToYAML re-encodes a JSON document as YAML, keeping the order of its keys.
+func jsonToYAML(data []byte) ([]byte, error) {
+	// JSON is valid YAML, so it parses into a node tree that only needs its JSON styling dropped
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	clearStyle(&node)
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// clearStyle switches a node and its children from the flow style and quoting of JSON to
+// YAML's default block style.
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+// chunkOutput is the structured form of a chunk.
+type chunkOutput struct {
+	ID        chunk.ChunkID `json:"id"`
+	File      string        `json:"file"`
+	OldPath   string        `json:"old_path,omitempty"`
+	Op        string        `json:"op"`
+	StartTime time.Time     `json:"start_time"`
+	EndTime   time.Time     `json:"end_time"`
+	Feature   string        `json:"feature,omitempty"`
+	Manual    bool          `json:"manual"`
+	Branch    string        `json:"branch,omitempty"`
+	Head      string        `json:"head,omitempty"`
+	Committed string        `json:"committed,omitempty"`
+	Diff      string        `json:"diff,omitempty"` // Only included when asked for
+}
+
+// newChunkOutput converts a chunk to its structured form, with its diff if withDiff is set.
+func newChunkOutput(c chunk.Chunk, withDiff bool) chunkOutput {
+	out := chunkOutput{
+		ID:        c.ID,
+		File:      c.FilePath,
+		OldPath:   c.OldPath,
+		Op:        c.Op.String(),
+		StartTime: c.StartTime,
+		EndTime:   c.EndTime,
+		Feature:   string(c.FeatureTag),
+		Manual:    c.Manual,
+		Branch:    c.Branch,
+		Head:      c.Head,
+		Committed: c.Committed,
+	}
+	if withDiff {
+		out.Diff = strings.TrimRight(c.Diff, "\n")
+	}
+	return out
+}
+
+// chunkListOutput is the structured form of a page of chunks.
+type chunkListOutput struct {
+	Chunks []chunkOutput `json:"chunks"`
+	Next   chunk.Cursor  `json:"next,omitempty"` // Cursor for the next page; empty on the last one
+}
+
+// newChunkListOutput converts a page of chunks to its structured form.
+func newChunkListOutput(chunks []chunk.Chunk, next chunk.Cursor, withDiff bool) chunkListOutput {
+	out := chunkListOutput{Chunks: make([]chunkOutput, 0, len(chunks)), Next: next}
+	for _, c := range chunks {
+		out.Chunks = append(out.Chunks, newChunkOutput(c, withDiff))
+	}
+	return out
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable, "Output format: table (human-readable text), json or yaml")
+}
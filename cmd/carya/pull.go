@@ -5,9 +5,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 
+	"carya/internal/git"
 	"carya/internal/housekeeping"
+	"carya/internal/repository"
 
 	"github.com/spf13/cobra"
 )
@@ -47,9 +48,9 @@ var pullCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		// Check for auto-approve in config if flag not set
+		// Check for auto-approve in the settings if flag not set
 		if !autoApprove {
-			autoApprove = config.IsAutoApprove("post-pull")
+			autoApprove = loadConfig(findRepository()).Housekeeping.AutoApprovePostPull
 		}
 
 		executor := housekeeping.NewExecutor(config)
@@ -63,23 +64,24 @@ var pullCmd = &cobra.Command{
 // pullFromGit executes git pull and returns whether housekeeping.json was changed and the list of changed files
 func pullFromGit() (bool, []string, error) {
 	// Get the path to housekeeping.json relative to git root
-	wd, err := os.Getwd()
+	repo, err := repository.New()
 	if err != nil {
-		return false, nil, fmt.Errorf("failed to get working directory: %w", err)
+		return false, nil, err
 	}
+	root := repo.RootPath()
 
-	caryaDir := filepath.Join(wd, ".carya")
+	caryaDir := repo.CaryaPath()
 	housekeepingPath := filepath.Join(caryaDir, "housekeeping.json")
-	relPath, err := filepath.Rel(wd, housekeepingPath)
+	relPath, err := filepath.Rel(root, housekeepingPath)
 	if err != nil {
 		return false, nil, fmt.Errorf("failed to get relative path: %w", err)
 	}
 
 	// Get the hash of housekeeping.json before pull
-	beforeHash, _ := getFileHash(relPath)
+	beforeHash, _ := git.FileHash(root, relPath)
 
 	// Get the current HEAD commit before pull
-	beforeCommit, err := getHeadCommit()
+	beforeCommit, err := git.HeadCommit(root)
 	if err != nil {
 		return false, nil, fmt.Errorf("failed to get HEAD commit: %w", err)
 	}
@@ -89,20 +91,20 @@ func pullFromGit() (bool, []string, error) {
 	pullCmd := exec.Command("git", "pull")
 	pullCmd.Stdout = os.Stdout
 	pullCmd.Stderr = os.Stderr
-	pullCmd.Dir = wd
+	pullCmd.Dir = root
 
 	if err := pullCmd.Run(); err != nil {
 		return false, nil, fmt.Errorf("git pull failed: %w", err)
 	}
 
 	// Get the hash of housekeeping.json after pull
-	afterHash, _ := getFileHash(relPath)
+	afterHash, _ := git.FileHash(root, relPath)
 
 	// Check if the file changed
 	housekeepingChanged := beforeHash != "" && afterHash != "" && beforeHash != afterHash
 
 	// Get the list of changed files
-	changedFiles, err := getChangedFiles(beforeCommit)
+	changedFiles, err := git.ChangedFiles(root, beforeCommit)
 	if err != nil {
 		// Don't fail if we can't get changed files, just return empty list
 		changedFiles = []string{}
@@ -111,59 +113,6 @@ func pullFromGit() (bool, []string, error) {
 	return housekeepingChanged, changedFiles, nil
 }
 
-// getFileHash returns the git hash of a file
-func getFileHash(filepath string) (string, error) {
-	cmd := exec.Command("git", "hash-object", filepath)
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-
-	return strings.TrimSpace(string(output)), nil
-}
-
-// getHeadCommit returns the current HEAD commit hash
-func getHeadCommit() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "HEAD")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-
-	return strings.TrimSpace(string(output)), nil
-}
-
-// getChangedFiles returns the list of files changed between a commit and HEAD
-func getChangedFiles(fromCommit string) ([]string, error) {
-	currentCommit, err := getHeadCommit()
-	if err != nil {
-		return nil, err
-	}
-
-	// If commits are the same, no changes occurred
-	if fromCommit == currentCommit {
-		return []string{}, nil
-	}
-
-	// Get the list of changed files using git diff
-	cmd := exec.Command("git", "diff", "--name-only", fromCommit, currentCommit)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	files := strings.Split(strings.TrimSpace(string(output)), "\n")
-	// Filter out empty strings
-	var result []string
-	for _, file := range files {
-		if file != "" {
-			result = append(result, file)
-		}
-	}
-
-	return result, nil
-}
-
 func init() {
 	pullCmd.Flags().BoolP("auto", "y", false, "Run post-pull commands without confirmation")
 	pullCmd.Flags().Bool("no-pull", false, "Skip git pull and only run post-pull commands")
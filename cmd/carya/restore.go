@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"carya/internal/chunk"
+	"carya/internal/deps"
+	"carya/internal/restore"
+
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <chunk-id>",
+	Short: "Restore a file to its state before or after a chunk",
+	Long: `Restore the file a chunk belongs to from the snapshot recorded with the chunk.
+By default the file is restored to its state when the chunk ended; use --before to
+restore it to its state before the chunk's first change. The current content of the
+file is saved as a new chunk first, so the restore can itself be undone.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		before, _ := cmd.Flags().GetBool("before")
+		force, _ := cmd.Flags().GetBool("force")
+
+		target := restore.After
+		if before {
+			target = restore.Before
+		}
+
+		repo, chunkStore := openStore()
+		defer chunkStore.Close()
+
+		restorer := restore.New(chunkStore)
+		restorer.SetLinker(deps.NewTracker(chunkStore))
+		restorer.SetRoot(workTreeRoot(repo))
+		id := chunk.ChunkID(args[0])
+
+		broken, err := restorer.Check(chunkStore, id, target, force)
+		if err != nil && !errors.Is(err, restore.ErrDependents) {
+			fmt.Fprintf(os.Stderr, "Error checking dependencies: %v\n", err)
+			os.Exit(1)
+		}
+		if len(broken) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d chunks in other files depend on changes this restore undoes:\n", len(broken))
+			for _, e := range broken {
+				fmt.Fprintf(os.Stderr, "  • %s  depends on %s  (%s)\n", e.Chunk, e.DependsOn, e)
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: Refusing to restore. Use --force to restore anyway.")
+			os.Exit(1)
+		}
+
+		result, err := restorer.Restore(id, target)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error restoring chunk: %v\n", err)
+			os.Exit(1)
+		}
+
+		if !result.Modified {
+			fmt.Printf("%s already matches the state %s chunk %s\n", displayPath(result.Chunk.FilePath), target, result.Chunk.ID)
+			return
+		}
+
+		fmt.Printf("✓ Restored %s to its state %s chunk %s\n", displayPath(result.Chunk.FilePath), target, result.Chunk.ID)
+		fmt.Printf("  Previous content saved as chunk %s\n", result.Safety.ID)
+	},
+}
+
+func init() {
+	restoreCmd.Flags().Bool("before", false, "Restore the file to its state before the chunk")
+	restoreCmd.Flags().Bool("after", false, "Restore the file to its state after the chunk (default)")
+	restoreCmd.Flags().Bool("force", false, "Restore even if chunks in other files depend on the undone changes")
+	restoreCmd.MarkFlagsMutuallyExclusive("before", "after")
+	rootCmd.AddCommand(restoreCmd)
+}
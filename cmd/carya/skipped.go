@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"carya/internal/chunk"
+
+	"github.com/spf13/cobra"
+)
+
+var skippedCmd = &cobra.Command{
+	Use:   "skipped [file]",
+	Short: "List changes to binary and large files",
+	Long: `List changes to files whose contents Carya doesn't track because they are binary
+or larger than the watcher.max_file_size setting. Each change records the file's size
+and SHA-256 hash, so you can still tell when and how often the file changed.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		_, chunkStore := openStore()
+		defer chunkStore.Close()
+
+		var changes []chunk.SkippedChange
+		var err error
+		if len(args) > 0 {
+			path, absErr := filepath.Abs(args[0])
+			if absErr != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", absErr)
+				os.Exit(1)
+			}
+			changes, err = chunkStore.FindSkippedChanges(path)
+		} else {
+			changes, err = chunkStore.GetRecentSkippedChanges(limit)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing skipped changes: %v\n", err)
+			os.Exit(1)
+		}
+
+		if structuredOutput() {
+			out := skippedListOutput{Changes: make([]skippedOutput, 0, len(changes))}
+			for _, sc := range changes {
+				out.Changes = append(out.Changes, skippedOutput{File: sc.FilePath, Reason: sc.Reason, Size: sc.Size, Hash: sc.Hash, Time: sc.Time})
+			}
+			printOutput(out)
+			return
+		}
+
+		if len(changes) == 0 {
+			fmt.Println("No skipped changes recorded.")
+			return
+		}
+		for _, sc := range changes {
+			fmt.Printf("%s  %-9s  %8s  %.12s  %s\n",
+				sc.Time.Format("2006-01-02 15:04:05"), sc.Reason, formatSize(sc.Size), sc.Hash, displayPath(sc.FilePath))
+		}
+	},
+}
+
+// skippedOutput is the structured form of a skipped change.
+type skippedOutput struct {
+	File   string           `json:"file"`
+	Reason chunk.SkipReason `json:"reason"`
+	Size   int64            `json:"size"`
+	Hash   string           `json:"hash"` // SHA-256 of the file's contents
+	Time   time.Time        `json:"time"`
+}
+
+// skippedListOutput is the structured form of a list of skipped changes.
+type skippedListOutput struct {
+	Changes []skippedOutput `json:"changes"`
+}
+
+func init() {
+	skippedCmd.Flags().IntP("limit", "n", 20, "Number of recent changes to list when no file is given")
+	structuredCommand(skippedCmd)
+	rootCmd.AddCommand(skippedCmd)
+}
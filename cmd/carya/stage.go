@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"carya/internal/chunk"
+	"carya/internal/feature"
+	"carya/internal/git"
+	"carya/internal/tui"
+
+	"github.com/spf13/cobra"
+)
+
+var stageCmd = &cobra.Command{
+	Use:   "stage",
+	Short: "Curate uncommitted chunks into commits interactively",
+	Long: `Open an interactive view of the uncommitted chunks to build one or more commits from them.
+
+Mark chunks with x (or press enter to mark individual hunks), gather the marked chunks into
+a new commit with n or into the commit under the cursor with m, split a chunk into its hunks
+with S, reorder chunks and commits with J/K, and write each commit's message with e. c creates
+the commits in order, staging exactly their hunks; changes left out stay in the working tree.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sinceStr, _ := cmd.Flags().GetString("since")
+		featureTag, _ := cmd.Flags().GetString("feature")
+		branch, _ := cmd.Flags().GetString("branch")
+
+		since, err := parseTimeFlag(sinceStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --since: %v\n", err)
+			os.Exit(1)
+		}
+
+		repo, chunkStore := openStore()
+		defer chunkStore.Close()
+
+		topLevel, err := git.TopLevel(repo.RootPath())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s is not in a git repository: %v\n", repo.RootPath(), err)
+			os.Exit(1)
+		}
+
+		q := chunk.Query{Since: since, FeatureTag: feature.Tag(featureTag), Branch: branch}
+		if err := tui.RunStaging(chunkStore, topLevel, q); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running staging view: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	stageCmd.Flags().String("since", "", "Only include chunks started after this time (e.g. 2h, 3d, 2006-01-02)")
+	stageCmd.Flags().String("feature", "", "Only include chunks tagged with this feature")
+	stageCmd.Flags().StringP("branch", "b", "", "Only include chunks made while this git branch was checked out")
+	rootCmd.AddCommand(stageCmd)
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"os"
 
+	"carya/internal/chunk"
+	"carya/internal/daemon"
 	"carya/internal/repository"
+	"carya/internal/store"
 	"carya/internal/tui"
 
 	"github.com/spf13/cobra"
@@ -13,34 +16,57 @@ import (
 var viewCmd = &cobra.Command{
 	Use:   "view",
 	Short: "View tracked chunks and diffs",
-	Long:  `View tracked chunks and diffs in an interactive TUI viewer.`,
+	Long: `View tracked chunks and diffs in an interactive TUI viewer.
+
+While the daemon is running, the viewer follows it: chunks it saves appear as they are
+saved, files it is still collecting changes to are listed as pending, and F saves the
+chunk of the selected file right away.
+
+With --output json or yaml, the chunks are printed with their diffs, newest first, instead
+of opening the viewer.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		dbPath, _ := cmd.Flags().GetString("db")
+		branch, _ := cmd.Flags().GetString("branch")
 
-		// If no db path specified, use the default repository path
+		var chunkStore store.Store
+		var live *daemon.Daemon
+		var root string
 		if dbPath == "" {
-			repo, err := repository.New()
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error initializing repository: %v\n", err)
+			// Without a db path, use the repository's configured store and follow its daemon
+			var repo *repository.Repository
+			repo, chunkStore = openStore()
+			root = workTreeRoot(repo)
+			if d := daemon.New(repo.PIDPath(), repo.LogPath(), repo.SocketPath()); d.IsRunning() {
+				live = d
+			}
+		} else {
+			// Ensure the db file exists
+			if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "Error: Database not found at %s\n", dbPath)
 				os.Exit(1)
 			}
 
-			if !repo.Exists() {
-				fmt.Fprintf(os.Stderr, "Error: Not a Carya repository. Run 'carya init' first.\n")
+			var err error
+			if chunkStore, err = store.OpenFile(dbPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error opening chunk store: %v\n", err)
 				os.Exit(1)
 			}
-
-			dbPath = repo.DBPath()
 		}
+		defer chunkStore.Close()
 
-		// Ensure the db file exists
-		if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-			fmt.Fprintf(os.Stderr, "Error: Database not found at %s\n", dbPath)
-			os.Exit(1)
+		if structuredOutput() {
+			// Print the chunks with their diffs instead of opening the viewer
+			page, err := chunkStore.QueryChunks(chunk.Query{Branch: branch})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error querying chunks: %v\n", err)
+				os.Exit(1)
+			}
+			printOutput(newChunkListOutput(page.Chunks, page.Next, true))
+			return
 		}
 
 		// Run the diff viewer
-		if err := tui.RunDiffViewer(dbPath); err != nil {
+		if err := tui.RunDiffViewer(chunkStore, root, branch, live); err != nil {
 			fmt.Fprintf(os.Stderr, "Error running diff viewer: %v\n", err)
 			os.Exit(1)
 		}
@@ -49,7 +75,10 @@ var viewCmd = &cobra.Command{
 
 func init() {
 	// Add flags
-	viewCmd.Flags().StringP("db", "d", "", "Path to the chunks database (default: .carya/chunks.db)")
+	viewCmd.Flags().StringP("db", "d", "", "Path to a chunks database or JSON store (default: the repository's configured store)")
+	viewCmd.Flags().StringP("branch", "b", "", "Only show chunks made while this git branch was checked out")
+
+	structuredCommand(viewCmd)
 
 	// Add to root command
 	rootCmd.AddCommand(viewCmd)
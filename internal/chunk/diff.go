@@ -0,0 +1,436 @@
+package chunk
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultContextLines is the number of unchanged lines shown around each hunk.
+	DefaultContextLines = 3
+
+	// noNewlineMarker is the line git emits after a line that lacks a trailing newline.
+	noNewlineMarker = `\ No newline at end of file`
+
+	// maxTraceSize bounds the memory used by the Myers trace before falling back
+	// to a plain remove/add diff for pathological inputs.
+	maxTraceSize = 1 << 24
+)
+
+// Hunk represents a single contiguous region of changes in a unified diff.
+type Hunk struct {
+	OldStart int      // First line of the hunk in the old file (1-based, 0 for empty ranges at the top)
+	OldLines int      // Number of old lines covered by the hunk
+	NewStart int      // First line of the hunk in the new file (1-based, 0 for empty ranges at the top)
+	NewLines int      // Number of new lines covered by the hunk
+	Lines    []string // Body lines including their ' ', '+', '-' or '\' prefix
+}
+
+// Header returns the "@@ -a,b +c,d @@" line for the hunk.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", formatRange(h.OldStart, h.OldLines), formatRange(h.NewStart, h.NewLines))
+}
+
+// String renders the hunk header followed by its body lines.
+func (h Hunk) String() string {
+	return h.Header() + "\n" + strings.Join(h.Lines, "\n")
+}
+
+// formatRange renders a hunk range the way GNU diff and git do, omitting a count of one.
+func formatRange(start, count int) string {
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// Diff computes the hunks that turn oldContent into newContent, surrounding each
+// change with up to context unchanged lines. Hunks whose context would overlap are merged.
+func Diff(oldContent, newContent []byte, context int) []Hunk {
+	if context < 0 {
+		context = 0
+	}
+
+	oldLines := splitLinesKeepEnds(string(oldContent))
+	newLines := splitLinesKeepEnds(string(newContent))
+
+	return buildHunks(diffLines(oldLines, newLines), context)
+}
+
+// nullBlobID is the blob id git shows on the index line for a missing side of a diff.
+const nullBlobID = "0000000"
+
+// DiffPath returns path as it appears in diffs: relative to the git work tree root, with
+// "/" separators. Paths outside root, or any path if root is empty, are returned as they are.
+func DiffPath(root, path string) string {
+	if root == "" {
+		return path
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// FileDiff renders a git patch for a change of kind op to the file at path, which was at
+// oldPath before a rename. The paths should be relative to the work tree (see DiffPath).
+func FileDiff(op Op, oldPath, path string, before, after []byte, context int) string {
+	switch op {
+	case OpCreate:
+		return NewFileDiff(path, after, context)
+	case OpDelete:
+		return DeletedFileDiff(path, before, context)
+	case OpRename:
+		return RenameDiff(oldPath, path, before, after, context)
+	}
+	return UnifiedDiff(path, before, after, context)
+}
+
+// UnifiedDiff renders a git patch describing the change from before to after for the
+// file at path, which should be relative to the work tree (see DiffPath).
+func UnifiedDiff(path string, before, after []byte, context int) string {
+	header := fmt.Sprintf("diff --git a/%s b/%s\nindex %s..%s\n", path, path, blobID(before), blobID(after))
+	return header + formatBody("a/"+path, "b/"+path, Diff(before, after, context))
+}
+
+// NewFileDiff renders a git patch creating the file at path with the given content.
+func NewFileDiff(path string, after []byte, context int) string {
+	header := fmt.Sprintf("diff --git a/%s b/%s\nnew file mode 100644\nindex %s..%s\n", path, path, nullBlobID, blobID(after))
+	return header + formatBody("/dev/null", "b/"+path, Diff(nil, after, context))
+}
+
+// DeletedFileDiff renders a git patch deleting the file at path, whose content was before.
+func DeletedFileDiff(path string, before []byte, context int) string {
+	header := fmt.Sprintf("diff --git a/%s b/%s\ndeleted file mode 100644\nindex %s..%s\n", path, path, blobID(before), nullBlobID)
+	return header + formatBody("a/"+path, "/dev/null", Diff(before, nil, context))
+}
+
+// RenameDiff renders a git patch for a file moved from oldPath to newPath, including any
+// changes to its contents.
+func RenameDiff(oldPath, newPath string, before, after []byte, context int) string {
+	hunks := Diff(before, after, context)
+	if len(hunks) == 0 {
+		return fmt.Sprintf("diff --git a/%s b/%s\nsimilarity index 100%%\nrename from %s\nrename to %s\n",
+			oldPath, newPath, oldPath, newPath)
+	}
+
+	header := fmt.Sprintf("diff --git a/%s b/%s\nrename from %s\nrename to %s\nindex %s..%s\n",
+		oldPath, newPath, oldPath, newPath, blobID(before), blobID(after))
+	return header + formatBody("a/"+oldPath, "b/"+newPath, hunks)
+}
+
+// formatBody renders the ---/+++ lines and hunks of a file's patch. Git leaves both out
+// when there are no hunks, as for an empty file being created or deleted.
+func formatBody(oldName, newName string, hunks []Hunk) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("--- %s\n+++ %s\n%s\n", oldName, newName, FormatHunks(hunks))
+}
+
+// blobID returns the abbreviated git blob id of content, as git shows it on the index line.
+func blobID(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return fmt.Sprintf("%x", h.Sum(nil))[:len(nullBlobID)]
+}
+
+// FormatHunks renders hunks as the body of a unified diff.
+func FormatHunks(hunks []Hunk) string {
+	parts := make([]string, len(hunks))
+	for i, h := range hunks {
+		parts[i] = h.String()
+	}
+	return strings.Join(parts, "\n")
+}
+
+// ParseHunks extracts the hunks from a unified diff, ignoring any file headers.
+func ParseHunks(diff string) ([]Hunk, error) {
+	var hunks []Hunk
+	var current *Hunk
+
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			hunks = append(hunks, h)
+			current = &hunks[len(hunks)-1]
+			continue
+		}
+
+		if current == nil || line == "" {
+			continue
+		}
+
+		switch line[0] {
+		case ' ', '+', '-', '\\':
+			current.Lines = append(current.Lines, line)
+		}
+	}
+
+	return hunks, nil
+}
+
+// parseHunkHeader parses a "@@ -a,b +c,d @@" line into an empty hunk.
+func parseHunkHeader(line string) (Hunk, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[0] != "@@" || fields[3] != "@@" {
+		return Hunk{}, fmt.Errorf("malformed hunk header: %q", line)
+	}
+
+	oldStart, oldLines, err := parseRange(strings.TrimPrefix(fields[1], "-"))
+	if err != nil {
+		return Hunk{}, fmt.Errorf("malformed hunk header %q: %w", line, err)
+	}
+	newStart, newLines, err := parseRange(strings.TrimPrefix(fields[2], "+"))
+	if err != nil {
+		return Hunk{}, fmt.Errorf("malformed hunk header %q: %w", line, err)
+	}
+
+	return Hunk{OldStart: oldStart, OldLines: oldLines, NewStart: newStart, NewLines: newLines}, nil
+}
+
+// parseRange parses "start,count" or "start" (implying a count of one).
+func parseRange(s string) (int, int, error) {
+	startStr, countStr, hasCount := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !hasCount {
+		return start, 1, nil
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, count, nil
+}
+
+// diffOp identifies the kind of a single line in an edit script.
+type diffOp int
+
+const (
+	opEqual diffOp = iota
+	opDelete
+	opInsert
+)
+
+// diffLine is a single line of an edit script.
+type diffLine struct {
+	op   diffOp
+	text string // Line text including its terminating newline, if any
+}
+
+// splitLinesKeepEnds splits text into lines, keeping each line's trailing newline
+// so that a final line without one never compares equal to the same text with one.
+func splitLinesKeepEnds(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes an edit script between two sets of lines. Common prefixes and
+// suffixes are trimmed before running Myers' algorithm on the remainder.
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	result := make([]diffLine, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		result = append(result, diffLine{opEqual, line})
+	}
+	result = append(result, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		result = append(result, diffLine{opEqual, line})
+	}
+
+	return result
+}
+
+// myersDiff implements Myers' O(ND) shortest edit script algorithm. If the trace
+// would grow beyond maxTraceSize it degrades to removing all of a and adding all of b.
+func myersDiff(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	limit := n + m
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+
+	// trace[d] holds the furthest reaching x for diagonals -d..d before round d
+	var trace [][]int
+	traceSize := 0
+	found := false
+
+	for d := 0; d <= limit && !found; d++ {
+		traceSize += 2*d + 1
+		if traceSize > maxTraceSize {
+			return replaceAll(a, b)
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover the edit script
+	var reversed []diffLine
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		snapshot := trace[d]
+		at := func(k int) int { return snapshot[k+d] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, diffLine{opEqual, a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			reversed = append(reversed, diffLine{opInsert, b[y-1]})
+			y--
+		} else {
+			reversed = append(reversed, diffLine{opDelete, a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, diffLine{opEqual, a[x-1]})
+		x--
+		y--
+	}
+
+	result := make([]diffLine, len(reversed))
+	for i, line := range reversed {
+		result[len(reversed)-1-i] = line
+	}
+	return result
+}
+
+// replaceAll produces an edit script that removes every line of a and adds every line of b.
+func replaceAll(a, b []string) []diffLine {
+	result := make([]diffLine, 0, len(a)+len(b))
+	for _, line := range a {
+		result = append(result, diffLine{opDelete, line})
+	}
+	for _, line := range b {
+		result = append(result, diffLine{opInsert, line})
+	}
+	return result
+}
+
+// buildHunks groups an edit script into hunks with the given amount of context.
+func buildHunks(script []diffLine, context int) []Hunk {
+	// Mark every line that is a change or within context of one
+	include := make([]bool, len(script))
+	for i, line := range script {
+		if line.op == opEqual {
+			continue
+		}
+		for j := max(0, i-context); j <= min(len(script)-1, i+context); j++ {
+			include[j] = true
+		}
+	}
+
+	var hunks []Hunk
+	oldPos, newPos := 0, 0
+	for i := 0; i < len(script); {
+		if !include[i] {
+			if script[i].op != opInsert {
+				oldPos++
+			}
+			if script[i].op != opDelete {
+				newPos++
+			}
+			i++
+			continue
+		}
+
+		h := Hunk{OldStart: oldPos + 1, NewStart: newPos + 1}
+		for ; i < len(script) && include[i]; i++ {
+			line := script[i]
+			var prefix string
+			switch line.op {
+			case opEqual:
+				prefix = " "
+				h.OldLines++
+				h.NewLines++
+				oldPos++
+				newPos++
+			case opDelete:
+				prefix = "-"
+				h.OldLines++
+				oldPos++
+			case opInsert:
+				prefix = "+"
+				h.NewLines++
+				newPos++
+			}
+
+			text, terminated := strings.CutSuffix(line.text, "\n")
+			h.Lines = append(h.Lines, prefix+text)
+			if !terminated {
+				h.Lines = append(h.Lines, noNewlineMarker)
+			}
+		}
+
+		// Empty ranges point at the line before the insertion or removal point
+		if h.OldLines == 0 {
+			h.OldStart--
+		}
+		if h.NewLines == 0 {
+			h.NewStart--
+		}
+		hunks = append(hunks, h)
+	}
+
+	return hunks
+}
@@ -0,0 +1,246 @@
+package chunk
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffHunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  string
+		after   string
+		context int
+		want    []Hunk
+	}{
+		{
+			name:   "identical",
+			before: "a\nb\n",
+			after:  "a\nb\n",
+			want:   nil,
+		},
+		{
+			name:    "changed line",
+			before:  "a\nb\nc\n",
+			after:   "a\nB\nc\n",
+			context: 1,
+			want:    []Hunk{{OldStart: 1, OldLines: 3, NewStart: 1, NewLines: 3, Lines: []string{" a", "-b", "+B", " c"}}},
+		},
+		{
+			name:    "no context",
+			before:  "a\nb\nc\n",
+			after:   "a\nB\nc\n",
+			context: 0,
+			want:    []Hunk{{OldStart: 2, OldLines: 1, NewStart: 2, NewLines: 1, Lines: []string{"-b", "+B"}}},
+		},
+		{
+			name:   "created",
+			before: "",
+			after:  "a\nb\n",
+			want:   []Hunk{{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 2, Lines: []string{"+a", "+b"}}},
+		},
+		{
+			name:   "emptied",
+			before: "a\n",
+			after:  "",
+			want:   []Hunk{{OldStart: 1, OldLines: 1, NewStart: 0, NewLines: 0, Lines: []string{"-a"}}},
+		},
+		{
+			name:   "newline added at end",
+			before: "a",
+			after:  "a\n",
+			want:   []Hunk{{OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 1, Lines: []string{"-a", noNewlineMarker, "+a"}}},
+		},
+		{
+			name:    "distant changes",
+			before:  "1\n2\n3\n4\n5\n6\n7\n8\n",
+			after:   "one\n2\n3\n4\n5\n6\n7\neight\n",
+			context: 1,
+			want: []Hunk{
+				{OldStart: 1, OldLines: 2, NewStart: 1, NewLines: 2, Lines: []string{"-1", "+one", " 2"}},
+				{OldStart: 7, OldLines: 2, NewStart: 7, NewLines: 2, Lines: []string{" 7", "-8", "+eight"}},
+			},
+		},
+		{
+			name:    "close changes merged",
+			before:  "1\n2\n3\n4\n",
+			after:   "one\n2\n3\nfour\n",
+			context: 1,
+			want: []Hunk{
+				{OldStart: 1, OldLines: 4, NewStart: 1, NewLines: 4, Lines: []string{"-1", "+one", " 2", " 3", "-4", "+four"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff([]byte(tt.before), []byte(tt.after), tt.context)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHunksRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+	}{
+		{"changed line", "a\nb\nc\n", "a\nB\nc\n"},
+		{"created", "", "a\nb\n"},
+		{"deleted", "a\nb\n", ""},
+		{"no newline at end", "a\nb", "a\nc"},
+		{"several hunks", strings.Repeat("x\n", 20) + "y\n", "z\n" + strings.Repeat("x\n", 20)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks := Diff([]byte(tt.before), []byte(tt.after), DefaultContextLines)
+			diff := UnifiedDiff("file.txt", []byte(tt.before), []byte(tt.after), DefaultContextLines)
+
+			got, err := ParseHunks(diff)
+			if err != nil {
+				t.Fatalf("ParseHunks() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, hunks) {
+				t.Errorf("ParseHunks() = %#v, want %#v", got, hunks)
+			}
+		})
+	}
+}
+
+func TestParseHunksMalformed(t *testing.T) {
+	if _, err := ParseHunks("@@ -1,x +1 @@\n-a\n+b\n"); err == nil {
+		t.Error("ParseHunks() accepted a malformed hunk header")
+	}
+}
+
+func TestFileDiffAppliesWithGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	tests := []struct {
+		name    string
+		op      Op
+		oldPath string
+		path    string
+		before  string
+		after   string
+		context int
+	}{
+		{name: "modify", op: OpModify, path: "a.txt", before: "one\ntwo\nthree\n", after: "one\n2\nthree\nfour\n", context: DefaultContextLines},
+		{name: "modify in subdirectory", op: OpModify, path: "dir/sub/a.go", before: "package a\n\nfunc A() {}\n", after: "package a\n\nfunc A() int { return 1 }\n", context: DefaultContextLines},
+		{name: "modify with one context line", op: OpModify, path: "a.txt", before: "1\n2\n3\n4\n5\n6\n7\n8\n9\n", after: "1\n2\nthree\n4\n5\n6\n7\neight\n9\n", context: 1},
+		{name: "newline added at end", op: OpModify, path: "a.txt", before: "a\nb", after: "a\nb\n", context: DefaultContextLines},
+		{name: "newline removed at end", op: OpModify, path: "a.txt", before: "a\nb\n", after: "a\nb", context: DefaultContextLines},
+		{name: "create", op: OpCreate, path: "new/file.txt", after: "hello\nworld\n", context: DefaultContextLines},
+		{name: "create empty", op: OpCreate, path: "empty.txt", context: DefaultContextLines},
+		{name: "delete", op: OpDelete, path: "a.txt", before: "gone\n", context: DefaultContextLines},
+		{name: "delete empty", op: OpDelete, path: "empty.txt", context: DefaultContextLines},
+		{name: "rename", op: OpRename, oldPath: "old.txt", path: "dir/new.txt", before: "same\n", after: "same\n", context: DefaultContextLines},
+		{name: "rename with changes", op: OpRename, oldPath: "old.txt", path: "new.txt", before: "a\nb\nc\n", after: "a\nB\nc\n", context: DefaultContextLines},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			git(t, dir, "init", "-q")
+
+			from := tt.oldPath
+			if from == "" {
+				from = tt.path
+			}
+			if tt.op != OpCreate {
+				writeFile(t, filepath.Join(dir, from), tt.before)
+			}
+
+			patch := FileDiff(tt.op, tt.oldPath, tt.path, []byte(tt.before), []byte(tt.after), tt.context)
+			patchFile := filepath.Join(t.TempDir(), "chunk.patch")
+			writeFile(t, patchFile, patch)
+			git(t, dir, "apply", "--check", patchFile)
+			git(t, dir, "apply", patchFile)
+
+			if tt.op == OpDelete || tt.op == OpRename {
+				if _, err := os.Stat(filepath.Join(dir, from)); !os.IsNotExist(err) {
+					t.Errorf("%s still exists after applying:\n%s", from, patch)
+				}
+			}
+			if tt.op != OpDelete {
+				got, err := os.ReadFile(filepath.Join(dir, tt.path))
+				if err != nil {
+					t.Fatalf("reading %s after applying: %v\n%s", tt.path, err, patch)
+				}
+				if string(got) != tt.after {
+					t.Errorf("%s = %q after applying, want %q\n%s", tt.path, got, tt.after, patch)
+				}
+			}
+		})
+	}
+}
+
+func TestBlobIDMatchesGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	for _, content := range []string{"", "hello\n", "no newline"} {
+		cmd := exec.Command("git", "hash-object", "--stdin")
+		cmd.Stdin = strings.NewReader(content)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git hash-object: %v", err)
+		}
+		if want := strings.TrimSpace(string(out))[:len(nullBlobID)]; blobID([]byte(content)) != want {
+			t.Errorf("blobID(%q) = %s, want %s", content, blobID([]byte(content)), want)
+		}
+	}
+}
+
+func TestDiffPath(t *testing.T) {
+	root := filepath.FromSlash("/work/repo")
+	tests := []struct {
+		root string
+		path string
+		want string
+	}{
+		{root, filepath.FromSlash("/work/repo/a.txt"), "a.txt"},
+		{root, filepath.FromSlash("/work/repo/dir/b.txt"), "dir/b.txt"},
+		{root, filepath.FromSlash("/work/other/c.txt"), filepath.FromSlash("/work/other/c.txt")},
+		{root, filepath.FromSlash("/work/repository/d.txt"), filepath.FromSlash("/work/repository/d.txt")},
+		{"", filepath.FromSlash("/work/repo/a.txt"), filepath.FromSlash("/work/repo/a.txt")},
+	}
+
+	for _, tt := range tests {
+		if got := DiffPath(tt.root, tt.path); got != tt.want {
+			t.Errorf("DiffPath(%q, %q) = %q, want %q", tt.root, tt.path, got, tt.want)
+		}
+	}
+}
+
+// git runs git in dir, failing the test if it fails.
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+// writeFile writes content to path, creating its directory.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
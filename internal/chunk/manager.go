@@ -1,8 +1,11 @@
 package chunk
 
 import (
+	"log"
 	"sync"
 	"time"
+
+	"carya/internal/feature"
 )
 
 //lorme upsum dolor
@@ -15,46 +18,191 @@ type ChunkStore interface {
 	FindChunks(filePath string) ([]Chunk, error)
 	// GetRecentChunks retrieves the most recently created chunks up to the specified limit.
 	GetRecentChunks(limit int) ([]Chunk, error)
+	// GetChunk retrieves a single chunk by its ID.
+	GetChunk(id ChunkID) (*Chunk, error)
+	// FindChunksBetween retrieves all chunks that started within the given time range, oldest first.
+	FindChunksBetween(since, until time.Time) ([]Chunk, error)
+	// QueryChunks retrieves a page of the chunks matching a query, newest first.
+	QueryChunks(q Query) (*Page, error)
+	// DeleteChunks removes the chunks with the given IDs.
+	DeleteChunks(ids []ChunkID) error
 }
 
 // EventEmitter defines the interface for emitting chunk-related events.
+// The manager calls it while holding its lock, so implementations must not block.
 type EventEmitter interface {
-	// EmitChunkCreated notifies listeners that a new chunk has been created.
-	EmitChunkCreated(chunk Chunk)
+	// EmitChunkStarted notifies listeners that a file started collecting changes in a new chunk.
+	EmitChunkStarted(info ActiveChunkInfo)
+	// EmitChunkUpdated notifies listeners that a chunk still collecting changes saw another change.
+	EmitChunkUpdated(info ActiveChunkInfo)
 	// EmitChunkFlushed notifies listeners that chunks have been flushed to storage.
-	EmitChunkFlushed(chunks []Chunk)
+	EmitChunkFlushed(chunks []Chunk, reason FlushReason)
+	// EmitModeChanged notifies listeners that the manager switched between active and idle mode.
+	EmitModeChanged(idle bool)
+}
+
+// FlushReason tells why chunks were flushed to storage.
+type FlushReason string
+
+const (
+	FlushStale  FlushReason = "stale"  // The chunks saw no changes for the flush timeout
+	FlushIdle   FlushReason = "idle"   // The manager went idle and flushed everything
+	FlushManual FlushReason = "manual" // A flush was requested through ForceFlush or FlushAll
+)
+
+// Tagger supplies the feature tag assigned to chunks as they are saved.
+type Tagger interface {
+	// CurrentTag returns the tag of the currently active feature, or the empty tag if there is none.
+	CurrentTag() feature.Tag
+}
+
+// Linker records how chunks depend on earlier chunks as they are saved.
+type Linker interface {
+	// LinkChunk analyzes a saved chunk against earlier chunks and records its dependencies.
+	LinkChunk(chunk Chunk) error
+}
+
+// HeadSource supplies the git context recorded on chunks as they are saved.
+type HeadSource interface {
+	// CurrentHead returns the checked-out branch (empty if HEAD is detached) and the commit HEAD points at.
+	CurrentHead() (branch, commit string)
+}
+
+// Compactor applies the retention policy to stored chunks.
+type Compactor interface {
+	// Compact drops and merges old chunks according to the retention policy.
+	Compact() error
 }
 
 // Manager coordinates chunk creation, storage, and lifecycle management. It uses a ChunkStrategy to determine when to create chunks and manages periodic flushing of stale chunks.
 type Manager struct {
-	mu           sync.RWMutex  // Protects concurrent access to strategy
-	strategy     ChunkStrategy // Strategy for creating chunks
-	store        ChunkStore    // Storage backend for chunks
-	emitter      EventEmitter  // Event emitter for notifications
-	ticker       *time.Ticker  // Timer for periodic flushing
-	stopCh       chan struct{} // Channel to signal shutdown
-	lastActivity time.Time     // Time of last file change
-	isIdle       bool          // Whether system is in idle mode
-	idleThreshold time.Duration // Time before considering system idle
+	mu             sync.RWMutex  // Protects concurrent access to strategy
+	strategy       ChunkStrategy // Strategy for creating chunks
+	store          ChunkStore    // Storage backend for chunks
+	emitter        EventEmitter  // Event emitter for notifications
+	tagger         Tagger        // Optional source of feature tags for new chunks
+	linker         Linker        // Optional recorder of dependencies between chunks
+	heads          HeadSource    // Optional source of the git branch and commit of new chunks
+	compactor      Compactor     // Optional compaction run when the manager goes idle
+	ticker         *time.Ticker  // Timer for periodic flushing
+	stopCh         chan struct{} // Channel to signal shutdown
+	lastActivity   time.Time     // Time of last file change
+	isIdle         bool          // Whether system is in idle mode
+	idleThreshold  time.Duration // Time before considering system idle
 	activeInterval time.Duration // Flush interval when active
-	idleInterval time.Duration // Flush interval when idle
+	idleInterval   time.Duration // Flush interval when idle
+}
+
+// Default timings of a Manager.
+const (
+	DefaultIdleThreshold  = 5 * time.Minute  // Time without file changes before the manager goes idle
+	DefaultActiveInterval = 5 * time.Minute  // Interval between flushes of stale chunks while active
+	DefaultIdleInterval   = 30 * time.Minute // Interval between flushes of stale chunks while idle
+)
+
+// ManagerOptions configures the timings of a Manager.
+type ManagerOptions struct {
+	IdleThreshold  time.Duration // Time without file changes before the manager goes idle
+	ActiveInterval time.Duration // Interval between flushes of stale chunks while active
+	IdleInterval   time.Duration // Interval between flushes of stale chunks while idle
+}
+
+// DefaultManagerOptions returns the options used by NewManager.
+func DefaultManagerOptions() ManagerOptions {
+	return ManagerOptions{
+		IdleThreshold:  DefaultIdleThreshold,
+		ActiveInterval: DefaultActiveInterval,
+		IdleInterval:   DefaultIdleInterval,
+	}
 }
 
 // NewManager creates a new chunk manager with the specified strategy, store, and emitter. The manager will flush stale chunks every 5 minutes when active, and every 30 minutes when idle.
 func NewManager(strategy ChunkStrategy, store ChunkStore, emitter EventEmitter) *Manager {
-	activeInterval := 5 * time.Minute
+	return NewManagerWithOptions(strategy, store, emitter, DefaultManagerOptions())
+}
+
+// NewManagerWithOptions creates a new chunk manager with the given timings.
+// A zero IdleThreshold, ActiveInterval or IdleInterval falls back to its default.
+func NewManagerWithOptions(strategy ChunkStrategy, store ChunkStore, emitter EventEmitter, opts ManagerOptions) *Manager {
+	opts = opts.withDefaults()
 	return &Manager{
 		strategy:       strategy,
 		store:          store,
 		emitter:        emitter,
-		ticker:         time.NewTicker(activeInterval),
+		ticker:         time.NewTicker(opts.ActiveInterval),
 		stopCh:         make(chan struct{}),
 		lastActivity:   time.Now(),
 		isIdle:         false,
-		idleThreshold:  5 * time.Minute,
-		activeInterval: activeInterval,
-		idleInterval:   30 * time.Minute,
+		idleThreshold:  opts.IdleThreshold,
+		activeInterval: opts.ActiveInterval,
+		idleInterval:   opts.IdleInterval,
+	}
+}
+
+// withDefaults returns the options with zero values replaced by their defaults.
+func (opts ManagerOptions) withDefaults() ManagerOptions {
+	if opts.IdleThreshold <= 0 {
+		opts.IdleThreshold = DefaultIdleThreshold
 	}
+	if opts.ActiveInterval <= 0 {
+		opts.ActiveInterval = DefaultActiveInterval
+	}
+	if opts.IdleInterval <= 0 {
+		opts.IdleInterval = DefaultIdleInterval
+	}
+	return opts
+}
+
+// SetOptions changes the timings of the manager, falling back to the defaults as
+// NewManagerWithOptions does. The flush interval of the current mode starts over.
+func (m *Manager) SetOptions(opts ManagerOptions) {
+	opts = opts.withDefaults()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.idleThreshold = opts.IdleThreshold
+	m.activeInterval = opts.ActiveInterval
+	m.idleInterval = opts.IdleInterval
+	if m.isIdle {
+		m.ticker.Reset(m.idleInterval)
+	} else {
+		m.ticker.Reset(m.activeInterval)
+	}
+}
+
+// SetTagger sets the source of feature tags applied to chunks that are saved without one.
+func (m *Manager) SetTagger(tagger Tagger) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tagger = tagger
+}
+
+// SetLinker sets the recorder that links each saved chunk to the earlier chunks it depends on.
+func (m *Manager) SetLinker(linker Linker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.linker = linker
+}
+
+// SetHeadSource sets the source of the git branch and commit recorded on chunks that are
+// saved without them. A nil source stops recording them.
+func (m *Manager) SetHeadSource(heads HeadSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.heads = heads
+}
+
+// SetCompactor sets the compaction run each time the manager switches to idle mode.
+// A nil compactor disables automatic compaction.
+func (m *Manager) SetCompactor(compactor Compactor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.compactor = compactor
 }
 
 // Start begins the manager's background processing, including periodic flushing of stale chunks.
@@ -82,7 +230,30 @@ func (m *Manager) OnFileChange(event FileChangeEvent) {
 		m.switchToActiveMode()
 	}
 
+	if m.emitter == nil {
+		m.strategy.OnFileChange(event)
+		return
+	}
+	before, tracked := m.activeChunkLocked(event.Path)
 	m.strategy.OnFileChange(event)
+	after, tracking := m.activeChunkLocked(event.Path)
+	switch {
+	case tracking && !tracked:
+		m.emitter.EmitChunkStarted(after)
+	case tracking && after != before:
+		m.emitter.EmitChunkUpdated(after)
+	}
+}
+
+// activeChunkLocked returns the summary of the chunk still collecting changes to path, if any.
+// Must be called with m.mu held.
+func (m *Manager) activeChunkLocked(path string) (ActiveChunkInfo, bool) {
+	for _, info := range m.activeChunksLocked() {
+		if info.FilePath == path {
+			return info, true
+		}
+	}
+	return ActiveChunkInfo{}, false
 }
 
 // ForceFlush immediately creates and saves a chunk for the specified file path.
@@ -96,12 +267,12 @@ func (m *Manager) ForceFlush(filePath string) error {
 		return nil
 	}
 
-	if err := m.store.SaveChunk(*chunk); err != nil {
+	if err := m.saveChunkLocked(chunk); err != nil {
 		return err
 	}
 
 	if m.emitter != nil {
-		m.emitter.EmitChunkCreated(*chunk)
+		m.emitter.EmitChunkFlushed([]Chunk{*chunk}, FlushManual)
 	}
 
 	return nil
@@ -117,10 +288,14 @@ func (m *Manager) flushLoop() {
 			timeSinceActivity := time.Since(m.lastActivity)
 
 			// Check if we should switch to idle mode
+			var compactor Compactor
 			if !m.isIdle && timeSinceActivity >= m.idleThreshold {
 				// Aggressive idle flush: flush everything immediately
-				m.flushAllChunksLocked()
+				if _, err := m.flushAllChunksLocked(FlushIdle); err != nil {
+					log.Printf("Failed to flush chunks before going idle: %v", err)
+				}
 				m.switchToIdleMode()
+				compactor = m.compactor
 			} else if !m.isIdle {
 				// Normal active mode: flush stale chunks only
 				m.flushStaleChunksLocked()
@@ -128,6 +303,13 @@ func (m *Manager) flushLoop() {
 			// If already idle, just wait for activity (no flushing needed)
 
 			m.mu.Unlock()
+
+			// Compact outside the lock so file changes aren't held up meanwhile
+			if compactor != nil {
+				if err := compactor.Compact(); err != nil {
+					log.Printf("Compaction failed: %v", err)
+				}
+			}
 		case <-m.stopCh:
 			return
 		}
@@ -135,7 +317,7 @@ func (m *Manager) flushLoop() {
 }
 
 // flushStaleChunksLocked identifies and saves stale chunks to the store.
-// Continues processing even if individual chunks fail to save.
+// Continues processing even if individual chunks fail to save, logging each failure.
 // Must be called with m.mu held.
 func (m *Manager) flushStaleChunksLocked() {
 	chunks := m.strategy.FlushStaleChunks(time.Now())
@@ -143,20 +325,24 @@ func (m *Manager) flushStaleChunksLocked() {
 		return
 	}
 
-	for _, chunk := range chunks {
-		if err := m.store.SaveChunk(chunk); err != nil {
+	var saved []Chunk
+	for i := range chunks {
+		if err := m.saveChunkLocked(&chunks[i]); err != nil {
+			log.Printf("Failed to save chunk %s: %v", chunks[i].ID, err)
 			continue
 		}
+		saved = append(saved, chunks[i])
 	}
 
-	if m.emitter != nil {
-		m.emitter.EmitChunkFlushed(chunks)
+	if m.emitter != nil && len(saved) > 0 {
+		m.emitter.EmitChunkFlushed(saved, FlushStale)
 	}
 }
 
 // flushAllChunksLocked immediately flushes all active chunks to storage.
+// Returns the chunks that were saved and the first save error, if any.
 // Must be called with m.mu held.
-func (m *Manager) flushAllChunksLocked() {
+func (m *Manager) flushAllChunksLocked(reason FlushReason) ([]Chunk, error) {
 	// Check if strategy supports FlushAll
 	type flushAller interface {
 		FlushAll() []Chunk
@@ -164,32 +350,86 @@ func (m *Manager) flushAllChunksLocked() {
 
 	fa, ok := m.strategy.(flushAller)
 	if !ok {
-		return
+		return nil, nil
 	}
 
 	chunks := fa.FlushAll()
 	if len(chunks) == 0 {
-		return
+		return nil, nil
 	}
 
-	for _, chunk := range chunks {
-		if err := m.store.SaveChunk(chunk); err != nil {
+	var saved []Chunk
+	var firstErr error
+	for i := range chunks {
+		if err := m.saveChunkLocked(&chunks[i]); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
 			continue
 		}
+		saved = append(saved, chunks[i])
 	}
 
-	if m.emitter != nil {
-		m.emitter.EmitChunkFlushed(chunks)
+	if m.emitter != nil && len(saved) > 0 {
+		m.emitter.EmitChunkFlushed(saved, reason)
+	}
+
+	return saved, firstErr
+}
+
+// saveChunkLocked tags a chunk with the active feature (unless it already has a tag) and
+// the git context, persists it to the store and records its dependencies. Once the chunk is
+// stored it counts as saved: failing to record its dependencies is only logged, since the
+// chunk is gone from the strategy and they can be rebuilt later.
+// Must be called with m.mu held.
+func (m *Manager) saveChunkLocked(c *Chunk) error {
+	if c.FeatureTag == "" && m.tagger != nil {
+		c.FeatureTag = m.tagger.CurrentTag()
+	}
+	if c.Branch == "" && c.Head == "" && m.heads != nil {
+		c.Branch, c.Head = m.heads.CurrentHead()
+	}
+	if err := m.store.SaveChunk(*c); err != nil {
+		return err
+	}
+	if m.linker != nil {
+		if err := m.linker.LinkChunk(*c); err != nil {
+			log.Printf("Failed to record dependencies of chunk %s: %v", c.ID, err)
+		}
 	}
+	return nil
 }
 
 // FlushAll immediately flushes all active chunks to storage.
-func (m *Manager) FlushAll() error {
+// Returns the chunks that were saved; if some failed to save, the first error is returned too.
+func (m *Manager) FlushAll() ([]Chunk, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.flushAllChunksLocked()
-	return nil
+	return m.flushAllChunksLocked(FlushManual)
+}
+
+// ActiveChunks summarizes the chunks still collecting changes.
+func (m *Manager) ActiveChunks() []ActiveChunkInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.activeChunksLocked()
+}
+
+// activeChunksLocked summarizes the chunks still collecting changes.
+// Must be called with m.mu held.
+func (m *Manager) activeChunksLocked() []ActiveChunkInfo {
+	// Check if strategy supports listing active chunks
+	type activeLister interface {
+		ActiveChunks() []ActiveChunkInfo
+	}
+
+	al, ok := m.strategy.(activeLister)
+	if !ok {
+		return nil
+	}
+	return al.ActiveChunks()
 }
 
 // switchToIdleMode switches the ticker to idle mode (slower interval).
@@ -200,6 +440,9 @@ func (m *Manager) switchToIdleMode() {
 	}
 	m.isIdle = true
 	m.ticker.Reset(m.idleInterval)
+	if m.emitter != nil {
+		m.emitter.EmitModeChanged(true)
+	}
 }
 
 // switchToActiveMode switches the ticker to active mode (faster interval).
@@ -210,4 +453,7 @@ func (m *Manager) switchToActiveMode() {
 	}
 	m.isIdle = false
 	m.ticker.Reset(m.activeInterval)
+	if m.emitter != nil {
+		m.emitter.EmitModeChanged(false)
+	}
 }
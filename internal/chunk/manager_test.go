@@ -0,0 +1,156 @@
+package chunk
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryStore is a ChunkStore that keeps saved chunks in memory, failing every save with
+// err if it is set.
+type memoryStore struct {
+	chunks []Chunk
+	err    error
+}
+
+func (s *memoryStore) SaveChunk(c Chunk) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.chunks = append(s.chunks, c)
+	return nil
+}
+
+func (s *memoryStore) FindChunks(filePath string) ([]Chunk, error) { return nil, nil }
+func (s *memoryStore) GetRecentChunks(limit int) ([]Chunk, error)  { return s.chunks, nil }
+func (s *memoryStore) GetChunk(id ChunkID) (*Chunk, error)         { return nil, errors.New("not found") }
+func (s *memoryStore) QueryChunks(q Query) (*Page, error)          { return &Page{Chunks: s.chunks}, nil }
+func (s *memoryStore) DeleteChunks(ids []ChunkID) error            { return nil }
+func (s *memoryStore) FindChunksBetween(since, until time.Time) ([]Chunk, error) {
+	return s.chunks, nil
+}
+
+// failingLinker is a Linker that counts the chunks it is given, failing with err if it is set.
+type failingLinker struct {
+	linked int
+	err    error
+}
+
+func (l *failingLinker) LinkChunk(c Chunk) error {
+	l.linked++
+	return l.err
+}
+
+// flushRecorder is an EventEmitter that counts the flushed chunks it hears about.
+type flushRecorder struct {
+	flushed int
+}
+
+func (r *flushRecorder) EmitChunkStarted(info ActiveChunkInfo) {}
+func (r *flushRecorder) EmitChunkUpdated(info ActiveChunkInfo) {}
+func (r *flushRecorder) EmitChunkFlushed(chunks []Chunk, reason FlushReason) {
+	r.flushed += len(chunks)
+}
+func (r *flushRecorder) EmitModeChanged(idle bool) {}
+
+// captureLog sends the standard logger's output to the returned buffer for the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+// newTestManager returns a manager whose strategy holds modified chunks for paths, ready to
+// be flushed as stale.
+func newTestManager(store ChunkStore, emitter EventEmitter, paths ...string) *Manager {
+	strategy := NewUnifiedStrategyWithOptions(StrategyOptions{FlushTimeout: time.Minute})
+	m := NewManager(strategy, store, emitter)
+	past := time.Now().Add(-time.Hour)
+	for _, path := range paths {
+		m.OnFileChange(FileChangeEvent{Path: path, Contents: []byte("one\n"), Time: past})
+		m.OnFileChange(FileChangeEvent{Path: path, Contents: []byte("two\n"), Time: past.Add(time.Second)})
+	}
+	return m
+}
+
+func TestManagerFlushStaleErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		saveErr     error
+		linkErr     error
+		wantStored  int
+		wantEmitted int
+		wantLog     string // Substring of the log output; empty if nothing is logged
+	}{
+		{name: "saved", wantStored: 2, wantEmitted: 2},
+		{
+			name:        "linking fails",
+			linkErr:     errors.New("graph unavailable"),
+			wantStored:  2,
+			wantEmitted: 2,
+			wantLog:     "Failed to record dependencies of chunk /repo/a.go",
+		},
+		{
+			name:    "saving fails",
+			saveErr: errors.New("disk full"),
+			wantLog: "Failed to save chunk /repo/a.go",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			store := &memoryStore{err: tt.saveErr}
+			linker := &failingLinker{err: tt.linkErr}
+			emitter := &flushRecorder{}
+			m := newTestManager(store, emitter, "/repo/a.go", "/repo/b.go")
+			defer m.Stop()
+			m.SetLinker(linker)
+
+			m.mu.Lock()
+			m.flushStaleChunksLocked()
+			m.mu.Unlock()
+
+			if len(store.chunks) != tt.wantStored || emitter.flushed != tt.wantEmitted {
+				t.Errorf("stored %d and emitted %d chunks, want %d and %d", len(store.chunks), emitter.flushed, tt.wantStored, tt.wantEmitted)
+			}
+			if linker.linked != tt.wantStored {
+				t.Errorf("linked %d chunks, want %d", linker.linked, tt.wantStored)
+			}
+			if tt.wantLog != "" && !strings.Contains(logs.String(), tt.wantLog) {
+				t.Errorf("log = %q, want it to contain %q", logs.String(), tt.wantLog)
+			}
+			if tt.wantLog == "" && strings.Contains(logs.String(), "Failed") {
+				t.Errorf("log = %q, want no failures", logs.String())
+			}
+			if active := m.ActiveChunks(); len(active) != 0 {
+				t.Errorf("%d chunks still active after the flush, want none", len(active))
+			}
+		})
+	}
+}
+
+func TestManagerLinkFailureKeepsChunkSaved(t *testing.T) {
+	captureLog(t)
+	store := &memoryStore{}
+	m := newTestManager(store, nil, "/repo/a.go", "/repo/b.go")
+	defer m.Stop()
+	m.SetLinker(&failingLinker{err: errors.New("graph unavailable")})
+
+	if err := m.ForceFlush("/repo/a.go"); err != nil {
+		t.Errorf("ForceFlush() error = %v, want the chunk saved regardless", err)
+	}
+	saved, err := m.FlushAll()
+	if err != nil || len(saved) != 1 {
+		t.Errorf("FlushAll() = %d chunks, %v, want the remaining chunk saved", len(saved), err)
+	}
+	if len(store.chunks) != 2 {
+		t.Errorf("stored %d chunks, want 2", len(store.chunks))
+	}
+}
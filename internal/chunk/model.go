@@ -1,17 +1,50 @@
 package chunk
 
-import "time"
+import (
+	"time"
+
+	"carya/internal/feature"
+)
 
 // Chunk represents a discrete unit of file changes tracked by Carya (diff, timing information, and metadata about changes)
 type Chunk struct {
-	ID        ChunkID   // Unique identifier for this chunk
-	FilePath  string    // Path to the file this chunk represents
-	Diff      string    // The actual diff content
-	StartTime time.Time // When the chunk period started
-	EndTime   time.Time // When the chunk period ended
-	// FeatureTag feature.Tag // where we will implement feature tagging
-	Hash   ChunkHash // Hash of the chunk content for integrity
-	Manual bool      // Whether this chunk was manually created
+	ID         ChunkID     // Unique identifier for this chunk
+	FilePath   string      // Path to the file this chunk represents
+	Diff       string      // The actual diff content
+	StartTime  time.Time   // When the chunk period started
+	EndTime    time.Time   // When the chunk period ended
+	FeatureTag feature.Tag // Feature this chunk belongs to (empty if untagged)
+	Hash       ChunkHash   // Hash of the chunk content for integrity
+	Manual     bool        // Whether this chunk was manually created
+	Op         Op          // What happened to the file: modified, created, deleted or renamed
+	OldPath    string      // Previous path of the file for renamed chunks
+	Branch     string      // Git branch checked out when the chunk was saved (empty if HEAD was detached)
+	Head       string      // Git commit HEAD pointed at when the chunk was saved
+	Committed  string      // Git commit found to contain the chunk's changes (empty until they are committed)
+	Before     []byte      // Snapshot of the file when the chunk started (nil if not loaded or unknown)
+	After      []byte      // Snapshot of the file when the chunk ended (nil if not loaded or unknown)
+}
+
+// Op describes what happened to a file in a chunk or file change event.
+type Op string
+
+const (
+	// OpModify means the contents of an existing file changed.
+	OpModify Op = "modify"
+	// OpCreate means the file was created.
+	OpCreate Op = "create"
+	// OpDelete means the file was deleted.
+	OpDelete Op = "delete"
+	// OpRename means the file was moved from OldPath, possibly with changes to its contents.
+	OpRename Op = "rename"
+)
+
+// String returns the operation name, treating the zero value as OpModify.
+func (o Op) String() string {
+	if o == "" {
+		return string(OpModify)
+	}
+	return string(o)
 }
 
 // FileChange represents a single file modification event with its timestamp and content.
@@ -25,3 +58,23 @@ type ChunkID string
 
 // ChunkHash represents a hash of chunk content for integrity verification.
 type ChunkHash string
+
+// SkipReason describes why the contents of a changed file were not tracked.
+type SkipReason string
+
+const (
+	// SkipBinary means the file's contents don't look like text.
+	SkipBinary SkipReason = "binary"
+	// SkipTooLarge means the file is larger than the maximum tracked file size.
+	SkipTooLarge SkipReason = "too-large"
+)
+
+// SkippedChange records a change to a file whose contents were not tracked, so there is
+// still a trace that the file changed.
+type SkippedChange struct {
+	FilePath string     // Path to the file that changed
+	Size     int64      // Size of the file after the change
+	Hash     string     // SHA-256 of the file contents after the change
+	Reason   SkipReason // Why the contents were not tracked
+	Time     time.Time  // When the change was seen
+}
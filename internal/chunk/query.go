@@ -0,0 +1,108 @@
+package chunk
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"carya/internal/feature"
+)
+
+// Query selects chunks by time, path, origin, feature, branch and diff contents. Zero-valued
+// fields don't restrict the results.
+type Query struct {
+	Since      time.Time   // Only chunks started at or after this time
+	Until      time.Time   // Only chunks started at or before this time
+	PathGlob   string      // Only chunks whose path (or previous path) matches this glob, see MatchPath
+	Manual     *bool       // Only manual (true) or automatic (false) chunks
+	FeatureTag feature.Tag // Only chunks tagged with this feature
+	Branch     string      // Only chunks saved while this git branch was checked out
+	Text       string      // Only chunks whose diff contains this text, ignoring case
+	After      Cursor      // Continue after the last chunk of a previous page
+	Limit      int         // Maximum number of chunks per page (0 for no limit)
+}
+
+// Cursor marks the position after the last chunk of a page of query results.
+// Its contents are private to the store that returned it.
+type Cursor string
+
+// Page is one page of query results, newest first.
+type Page struct {
+	Chunks []Chunk // Chunks on this page
+	Next   Cursor  // Cursor for the next page; empty if this is the last one
+}
+
+// Matches reports whether c satisfies every filter of the query except the cursor and limit.
+func (q Query) Matches(c Chunk) bool {
+	if !q.Since.IsZero() && c.StartTime.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && c.StartTime.After(q.Until) {
+		return false
+	}
+	if q.PathGlob != "" && !MatchPath(q.PathGlob, c.FilePath) && (c.OldPath == "" || !MatchPath(q.PathGlob, c.OldPath)) {
+		return false
+	}
+	if q.Manual != nil && c.Manual != *q.Manual {
+		return false
+	}
+	if q.FeatureTag != "" && c.FeatureTag != q.FeatureTag {
+		return false
+	}
+	if q.Branch != "" && c.Branch != q.Branch {
+		return false
+	}
+	if q.Text != "" && !strings.Contains(strings.ToLower(c.Diff), strings.ToLower(q.Text)) {
+		return false
+	}
+	return true
+}
+
+// MatchPath reports whether file, or a directory containing it, matches the glob pattern.
+// Patterns use path.Match syntax per path element, plus "**" matching any number of
+// elements. Like a .gitignore entry, a pattern without a slash matches at any depth:
+// "*.go" matches every Go file and "vendor" everything inside any vendor directory.
+func MatchPath(pattern, file string) bool {
+	pattern, file = filepath.ToSlash(pattern), filepath.ToSlash(file)
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	fileParts := strings.Split(strings.Trim(file, "/"), "/")
+	if strings.HasPrefix(pattern, "/") != strings.HasPrefix(file, "/") && !strings.HasPrefix(pattern, "**/") {
+		return false
+	}
+
+	// A match of a leading part of the path is a match of a directory holding the file
+	for n := len(fileParts); n > 0; n-- {
+		if matchParts(patternParts, fileParts[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchParts matches path elements against pattern elements, where "**" stands for
+// zero or more elements.
+func matchParts(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(parts); skip++ {
+				if matchParts(pattern[1:], parts[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], parts[0]); err != nil || !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
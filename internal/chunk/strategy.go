@@ -20,6 +20,16 @@ type ChunkStrategy interface {
 // FileChangeEvent represents a file modification event with its metadata.
 type FileChangeEvent struct {
 	Path     string    // Full path to the changed file
-	Contents []byte    // Current contents of the file
+	Contents []byte    // Current contents of the file (nil for deletions)
 	Time     time.Time // When the change occurred
+	Op       Op        // Kind of change (the zero value means OpModify)
+	OldPath  string    // Previous path of the file for OpRename
+}
+
+// ActiveChunkInfo summarizes a chunk that is still collecting changes.
+type ActiveChunkInfo struct {
+	FilePath   string    // Path to the file being tracked
+	Op         Op        // What has happened to the file so far
+	StartTime  time.Time // When the first change was seen
+	LastUpdate time.Time // When the latest change was seen
 }
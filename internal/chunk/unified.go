@@ -4,7 +4,8 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"log"
-	"strings"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 )
@@ -12,13 +13,47 @@ import (
 const (
 	// DefaultFlushTimeout is the default time after which inactive chunks are flushed.
 	DefaultFlushTimeout = 15 * time.Minute
+
+	// DefaultRenameWindow is how long a deletion waits for a creation with the same content
+	// before it is recorded as a deletion rather than a rename.
+	DefaultRenameWindow = 2 * time.Second
 )
 
 // UnifiedStrategy implements a chunking strategy that groups file changes by time periods.
 type UnifiedStrategy struct {
-	mu           sync.RWMutex            // Protects concurrent access
-	activeChunks map[string]*activeChunk // Active chunks by file path
-	flushTimeout time.Duration           // Time before chunks are considered stale
+	mu             sync.RWMutex              // Protects concurrent access
+	activeChunks   map[string]*activeChunk   // Active chunks by file path
+	flushTimeout   time.Duration             // Time before chunks are considered stale
+	contextLines   int                       // Unchanged lines shown around each diff hunk
+	baseline       BaselineProvider          // Source of a file's content before its first change
+	pendingDeletes map[string]*pendingDelete // Deletions waiting to be paired with a creation, by path
+	renameWindow   time.Duration             // How long a deletion waits for a matching creation
+	root           string                    // Git work tree root that paths in diffs are relative to
+}
+
+// BaselineProvider supplies the last known content of a file, used as the starting
+// point when a new chunk begins tracking it.
+type BaselineProvider interface {
+	// Baseline returns the content of the file before its latest change and whether it is known.
+	Baseline(path string) ([]byte, bool)
+}
+
+// StrategyOptions configures a UnifiedStrategy.
+type StrategyOptions struct {
+	FlushTimeout time.Duration    // Time before chunks are considered stale
+	ContextLines int              // Unchanged lines shown around each diff hunk
+	Baseline     BaselineProvider // Optional source of file contents before their first change
+	RenameWindow time.Duration    // How long a deletion waits for a matching creation
+	Root         string           // Git work tree root that paths in diffs are relative to; empty keeps them absolute
+}
+
+// DefaultStrategyOptions returns the options used by NewUnifiedStrategy.
+func DefaultStrategyOptions() StrategyOptions {
+	return StrategyOptions{
+		FlushTimeout: DefaultFlushTimeout,
+		ContextLines: DefaultContextLines,
+		RenameWindow: DefaultRenameWindow,
+	}
 }
 
 // activeChunk tracks an in-progress chunk for a file.
@@ -30,216 +65,471 @@ type activeChunk struct {
 	latestContent  []byte    // Latest file content for diff generation
 }
 
+// pendingDelete tracks a deleted file until it is paired with a creation or recorded as a deletion.
+type pendingDelete struct {
+	time    time.Time    // When the file was deleted
+	content []byte       // Last known content of the file
+	hash    string       // Hash of content, used to pair the deletion with a creation
+	active  *activeChunk // Chunk that was in progress for the file, if any
+}
+
 // NewUnifiedStrategy creates a new unified chunking strategy with default settings.
 func NewUnifiedStrategy() *UnifiedStrategy {
+	return NewUnifiedStrategyWithOptions(DefaultStrategyOptions())
+}
+
+// NewUnifiedStrategyWithOptions creates a new unified chunking strategy with the given options.
+// A zero FlushTimeout, RenameWindow or ContextLines falls back to its default; git can't
+// apply hunks without context lines.
+func NewUnifiedStrategyWithOptions(opts StrategyOptions) *UnifiedStrategy {
+	opts = opts.withDefaults()
 	return &UnifiedStrategy{
-		activeChunks: make(map[string]*activeChunk),
-		flushTimeout: DefaultFlushTimeout,
+		activeChunks:   make(map[string]*activeChunk),
+		flushTimeout:   opts.FlushTimeout,
+		contextLines:   opts.ContextLines,
+		baseline:       opts.Baseline,
+		pendingDeletes: make(map[string]*pendingDelete),
+		renameWindow:   opts.RenameWindow,
+		root:           opts.Root,
+	}
+}
+
+// withDefaults returns the options with zero values replaced by their defaults.
+func (opts StrategyOptions) withDefaults() StrategyOptions {
+	if opts.FlushTimeout <= 0 {
+		opts.FlushTimeout = DefaultFlushTimeout
 	}
+	if opts.ContextLines <= 0 {
+		opts.ContextLines = DefaultContextLines
+	}
+	if opts.RenameWindow <= 0 {
+		opts.RenameWindow = DefaultRenameWindow
+	}
+	return opts
+}
+
+// SetOptions changes the flush timeout, context lines and rename window of the strategy,
+// falling back to the defaults as NewUnifiedStrategyWithOptions does. Chunks in progress
+// are flushed and diffed with the new values; the baseline and root are kept.
+func (s *UnifiedStrategy) SetOptions(opts StrategyOptions) {
+	opts = opts.withDefaults()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.flushTimeout = opts.FlushTimeout
+	s.contextLines = opts.ContextLines
+	s.renameWindow = opts.RenameWindow
 }
 
 // OnFileChange processes a file change event, creating or updating chunks as needed.
+// Deletions are held back for RenameWindow so a following creation with the same
+// content can be recorded as a rename instead.
 func (s *UnifiedStrategy) OnFileChange(event FileChangeEvent) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	switch event.Op {
+	case OpDelete:
+		s.onDelete(event)
+	case OpCreate:
+		s.onCreate(event)
+	case OpRename:
+		s.onRename(event.OldPath, event)
+	default:
+		s.onModify(event)
+	}
+}
+
+// onModify records new contents for a file that already existed.
+// Must be called with s.mu held.
+func (s *UnifiedStrategy) onModify(event FileChangeEvent) {
+	s.reviveDeleted(event.Path)
+
 	contentHash := s.hashContent(event.Contents)
+	contentCopy := copyContent(event.Contents)
 
 	active, exists := s.activeChunks[event.Path]
 	if !exists {
-		// Create a copy of the content to avoid retention issues
-		contentCopy := make([]byte, len(event.Contents))
-		copy(contentCopy, event.Contents)
-
-		s.activeChunks[event.Path] = &activeChunk{
-			chunk: &Chunk{
-				ID:        ChunkID(fmt.Sprintf("%s-%d", event.Path, event.Time.Unix())),
-				FilePath:  event.Path,
-				StartTime: event.Time,
-				EndTime:   event.Time,
-				Hash:      ChunkHash(contentHash),
-				Manual:    false,
-			},
-			lastUpdate:     event.Time,
-			initialHash:    contentHash,
-			initialContent: contentCopy,
-			latestContent:  contentCopy,
+		// Diff against the last known state of the file when we have one, so the
+		// change that triggered this event is part of the chunk
+		initialContent := contentCopy
+		if s.baseline != nil {
+			if baseline, ok := s.baseline.Baseline(event.Path); ok {
+				initialContent = baseline
+			}
 		}
+
+		s.startChunk(event, initialContent, OpModify, "")
 		log.Printf("Started tracking changes: %s", event.Path)
 		return
 	}
 
-	if active.initialHash == contentHash {
+	if string(active.chunk.Hash) == contentHash {
 		log.Printf("Ignoring unchanged file: %s", event.Path)
 		return
 	}
 
-	// Create a copy of the latest content
-	contentCopy := make([]byte, len(event.Contents))
-	copy(contentCopy, event.Contents)
-
-	active.chunk.EndTime = event.Time
-	active.lastUpdate = event.Time
-	active.chunk.Hash = ChunkHash(contentHash)
-	active.latestContent = contentCopy
+	s.updateChunk(active, event.Time, contentCopy)
 	log.Printf("Updated chunk: %s (hash changed)", event.Path)
 }
 
-// FlushStaleChunks returns chunks that haven't been updated within the flush timeout.
-func (s *UnifiedStrategy) FlushStaleChunks(now time.Time) []Chunk {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// onCreate records a newly created file, pairing it with a recent deletion of a file
+// with the same content as a rename.
+// Must be called with s.mu held.
+func (s *UnifiedStrategy) onCreate(event FileChangeEvent) {
+	// Editors often save by replacing the file, which shows up as delete + create
+	if _, deleted := s.pendingDeletes[event.Path]; deleted {
+		s.onModify(event)
+		return
+	}
+	if _, exists := s.activeChunks[event.Path]; exists {
+		s.onModify(event)
+		return
+	}
 
-	var flushed []Chunk
-	for path, active := range s.activeChunks {
-		if now.Sub(active.lastUpdate) >= s.flushTimeout {
-			active.chunk.Diff = s.generateDiff(active)
-			flushed = append(flushed, *active.chunk)
-			delete(s.activeChunks, path)
+	if oldPath, ok := s.renameSource(event); ok {
+		s.onRename(oldPath, event)
+		return
+	}
+
+	// A file we have a baseline for existed before, so this is an ordinary modification
+	if s.baseline != nil {
+		if baseline, ok := s.baseline.Baseline(event.Path); ok && len(baseline) > 0 {
+			s.onModify(event)
+			return
 		}
 	}
 
-	return flushed
+	s.startChunk(event, nil, OpCreate, "")
+	log.Printf("Started tracking new file: %s", event.Path)
 }
 
-// FlushAll immediately flushes all active chunks regardless of age.
-func (s *UnifiedStrategy) FlushAll() []Chunk {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// renameSource picks the recent deletion a creation is paired with as a rename. Of the
+// deleted files with the same content, one with the same name wins, then the one deleted
+// most recently. Empty files are never paired, since any two of them look alike.
+// Must be called with s.mu held.
+func (s *UnifiedStrategy) renameSource(event FileChangeEvent) (string, bool) {
+	if len(event.Contents) == 0 {
+		return "", false
+	}
+	contentHash := s.hashContent(event.Contents)
+	name := filepath.Base(event.Path)
 
-	var flushed []Chunk
-	for path, active := range s.activeChunks {
-		active.chunk.Diff = s.generateDiff(active)
-		flushed = append(flushed, *active.chunk)
-		delete(s.activeChunks, path)
+	var best string
+	var bestPending *pendingDelete
+	for oldPath, pending := range s.pendingDeletes {
+		if len(pending.content) == 0 || pending.hash != contentHash || event.Time.Sub(pending.time) > s.renameWindow {
+			continue
+		}
+		if bestPending == nil || betterRenameSource(oldPath, pending, best, bestPending, name) {
+			best, bestPending = oldPath, pending
+		}
 	}
+	return best, bestPending != nil
+}
 
-	return flushed
+// betterRenameSource reports whether the deletion of path is a better match for a file
+// created under name than the deletion of other. Ties are broken by path, so the choice
+// doesn't depend on map order.
+func betterRenameSource(path string, pending *pendingDelete, other string, otherPending *pendingDelete, name string) bool {
+	if sameName, otherSameName := filepath.Base(path) == name, filepath.Base(other) == name; sameName != otherSameName {
+		return sameName
+	}
+	if !pending.time.Equal(otherPending.time) {
+		return pending.time.After(otherPending.time)
+	}
+	return path < other
 }
 
-// ForceFlush immediately creates a chunk for the specified file path.
-func (s *UnifiedStrategy) ForceFlush(filePath string) *Chunk {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// onDelete holds back the deletion of a file until it is paired with a creation or expires.
+// Must be called with s.mu held.
+func (s *UnifiedStrategy) onDelete(event FileChangeEvent) {
+	pending := &pendingDelete{time: event.Time}
+
+	if active, exists := s.activeChunks[event.Path]; exists {
+		pending.active = active
+		pending.content = active.latestContent
+		delete(s.activeChunks, event.Path)
+	} else if s.baseline != nil {
+		if baseline, ok := s.baseline.Baseline(event.Path); ok {
+			pending.content = baseline
+		}
+	}
+	pending.hash = s.hashContent(pending.content)
+
+	s.pendingDeletes[event.Path] = pending
+	log.Printf("File deleted: %s", event.Path)
+}
+
+// onRename moves the chunk for oldPath to the path of the event, or starts a rename chunk.
+// Must be called with s.mu held.
+func (s *UnifiedStrategy) onRename(oldPath string, event FileChangeEvent) {
+	pending, deleted := s.pendingDeletes[oldPath]
+	delete(s.pendingDeletes, oldPath)
+
+	active, exists := s.activeChunks[oldPath]
+	delete(s.activeChunks, oldPath)
+	if deleted && pending.active != nil {
+		active, exists = pending.active, true
+	}
 
-	active, exists := s.activeChunks[filePath]
 	if !exists {
-		return nil
+		var initialContent []byte
+		if deleted {
+			initialContent = pending.content
+		} else if s.baseline != nil {
+			initialContent, _ = s.baseline.Baseline(oldPath)
+		}
+		s.startChunk(event, initialContent, OpRename, oldPath)
+		log.Printf("File renamed: %s -> %s", oldPath, event.Path)
+		return
 	}
 
-	active.chunk.Manual = true
-	active.chunk.Diff = s.generateDiff(active)
-	chunk := *active.chunk
-	delete(s.activeChunks, filePath)
+	// Carry the in-progress chunk over to the new path
+	c := active.chunk
+	switch {
+	case c.Op == OpCreate:
+		// Still a new file, just under a different name
+	case c.Op == OpRename:
+		// Keep the original path when a file is renamed twice
+	default:
+		c.Op = OpRename
+		c.OldPath = oldPath
+	}
+	if c.OldPath == event.Path {
+		// Renamed back to where it started
+		c.Op = OpModify
+		c.OldPath = ""
+	}
+	c.FilePath = event.Path
+	c.ID = ChunkID(fmt.Sprintf("%s-%d", event.Path, c.StartTime.Unix()))
 
-	return &chunk
+	s.updateChunk(active, event.Time, copyContent(event.Contents))
+	s.activeChunks[event.Path] = active
+	log.Printf("File renamed: %s -> %s", oldPath, event.Path)
 }
 
-// hashContent generates a SHA256 hash of the given content.
-func (s *UnifiedStrategy) hashContent(content []byte) string {
-	hash := sha256.Sum256(content)
-	return fmt.Sprintf("%x", hash)
+// reviveDeleted cancels a pending deletion of path because the file exists again,
+// restoring the chunk that was in progress when it was deleted.
+// Must be called with s.mu held.
+func (s *UnifiedStrategy) reviveDeleted(path string) {
+	pending, deleted := s.pendingDeletes[path]
+	if !deleted {
+		return
+	}
+	delete(s.pendingDeletes, path)
+	if pending.active != nil {
+		s.activeChunks[path] = pending.active
+	}
 }
 
-// generateDiff creates a unified diff representation for a chunk.
-func (s *UnifiedStrategy) generateDiff(active *activeChunk) string {
-	chunk := active.chunk
-
-	// Create header
-	header := fmt.Sprintf("diff --git a/%s b/%s\nindex %s..%s\n--- a/%s\n+++ b/%s\n",
-		chunk.FilePath,
-		chunk.FilePath,
-		active.initialHash[:8],
-		string(chunk.Hash)[:8],
-		chunk.FilePath,
-		chunk.FilePath)
+// startChunk begins tracking a file whose content before the event was initialContent.
+// Must be called with s.mu held.
+func (s *UnifiedStrategy) startChunk(event FileChangeEvent, initialContent []byte, op Op, oldPath string) {
+	contentCopy := copyContent(event.Contents)
+	contentHash := s.hashContent(contentCopy)
+
+	s.activeChunks[event.Path] = &activeChunk{
+		chunk: &Chunk{
+			ID:        ChunkID(fmt.Sprintf("%s-%d", event.Path, event.Time.Unix())),
+			FilePath:  event.Path,
+			StartTime: event.Time,
+			EndTime:   event.Time,
+			Hash:      ChunkHash(contentHash),
+			Manual:    false,
+			Op:        op,
+			OldPath:   oldPath,
+		},
+		lastUpdate:     event.Time,
+		initialHash:    s.hashContent(initialContent),
+		initialContent: initialContent,
+		latestContent:  contentCopy,
+	}
+}
 
-	// Generate line-by-line diff
-	oldLines := splitLines(string(active.initialContent))
-	newLines := splitLines(string(active.latestContent))
+// updateChunk records new contents for an active chunk.
+func (s *UnifiedStrategy) updateChunk(active *activeChunk, at time.Time, content []byte) {
+	active.chunk.EndTime = at
+	active.chunk.Hash = ChunkHash(s.hashContent(content))
+	active.lastUpdate = at
+	active.latestContent = content
+}
 
-	diff := computeSimpleDiff(oldLines, newLines)
+// flushPendingDeletes turns deletions older than the rename window (or all of them, if
+// force is set) into chunks. A file that was created and deleted within the same chunk
+// leaves nothing behind.
+// Must be called with s.mu held.
+func (s *UnifiedStrategy) flushPendingDeletes(now time.Time, force bool) []Chunk {
+	var flushed []Chunk
+	for path, pending := range s.pendingDeletes {
+		if !force && now.Sub(pending.time) < s.renameWindow {
+			continue
+		}
+		delete(s.pendingDeletes, path)
 
-	return header + diff
+		if c, ok := s.deleteChunk(path, pending); ok {
+			flushed = append(flushed, c)
+		}
+	}
+	return flushed
 }
 
-// splitLines splits text into lines, preserving empty lines
-func splitLines(text string) []string {
-	if text == "" {
-		return []string{}
+// deleteChunk builds the chunk recording the deletion of the file at path.
+func (s *UnifiedStrategy) deleteChunk(path string, pending *pendingDelete) (Chunk, bool) {
+	c := Chunk{
+		ID:        ChunkID(fmt.Sprintf("%s-%d", path, pending.time.Unix())),
+		FilePath:  path,
+		StartTime: pending.time,
+		EndTime:   pending.time,
+		Op:        OpDelete,
+		Before:    pending.content,
 	}
-	lines := []string{}
-	start := 0
-	for i := 0; i < len(text); i++ {
-		if text[i] == '\n' {
-			lines = append(lines, text[start:i])
-			start = i + 1
+
+	if pending.active != nil {
+		started := pending.active.chunk
+		if started.Op == OpCreate {
+			return Chunk{}, false
+		}
+		c.ID = started.ID
+		c.StartTime = started.StartTime
+		c.Manual = started.Manual
+		c.Before = pending.active.initialContent
+		if started.Op == OpRename {
+			// The file is gone from its original path
+			c.FilePath = started.OldPath
 		}
 	}
-	if start < len(text) {
-		lines = append(lines, text[start:])
+
+	c.Hash = ChunkHash(s.hashContent(nil))
+	c.Diff = DeletedFileDiff(DiffPath(s.root, c.FilePath), c.Before, s.contextLines)
+	return c, true
+}
+
+// copyContent copies file content so the chunk doesn't retain the caller's buffer.
+func copyContent(content []byte) []byte {
+	if content == nil {
+		return nil
 	}
-	return lines
+	contentCopy := make([]byte, len(content))
+	copy(contentCopy, content)
+	return contentCopy
 }
 
-// computeSimpleDiff creates a simple unified diff between two sets of lines
-func computeSimpleDiff(oldLines, newLines []string) string {
-	var result []string
+// FlushStaleChunks returns chunks that haven't been updated within the flush timeout.
+func (s *UnifiedStrategy) FlushStaleChunks(now time.Time) []Chunk {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Simple implementation: show all removed lines, then all added lines
-	// This isn't a true LCS diff but works for basic visualization
-	maxLen := len(oldLines)
-	if len(newLines) > maxLen {
-		maxLen = len(newLines)
+	var flushed []Chunk
+	for path, active := range s.activeChunks {
+		if now.Sub(active.lastUpdate) >= s.flushTimeout {
+			if c, ok := s.finalize(active); ok {
+				flushed = append(flushed, c)
+			}
+			delete(s.activeChunks, path)
+		}
 	}
 
-	if maxLen == 0 {
-		return ""
-	}
+	return append(flushed, s.flushPendingDeletes(now, false)...)
+}
 
-	// Create a simple hunk header
-	result = append(result, fmt.Sprintf("@@ -%d,%d +%d,%d @@", 1, len(oldLines), 1, len(newLines)))
+// FlushAll immediately flushes all active chunks regardless of age.
+func (s *UnifiedStrategy) FlushAll() []Chunk {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Find common prefix
-	commonPrefix := 0
-	for commonPrefix < len(oldLines) && commonPrefix < len(newLines) && oldLines[commonPrefix] == newLines[commonPrefix] {
-		commonPrefix++
+	var flushed []Chunk
+	for path, active := range s.activeChunks {
+		if c, ok := s.finalize(active); ok {
+			flushed = append(flushed, c)
+		}
+		delete(s.activeChunks, path)
 	}
 
-	// Find common suffix
-	commonSuffix := 0
-	for commonSuffix < (len(oldLines)-commonPrefix) && commonSuffix < (len(newLines)-commonPrefix) &&
-		oldLines[len(oldLines)-1-commonSuffix] == newLines[len(newLines)-1-commonSuffix] {
-		commonSuffix++
-	}
+	return append(flushed, s.flushPendingDeletes(time.Now(), true)...)
+}
+
+// ActiveChunks summarizes the chunks still collecting changes, ordered by file path.
+func (s *UnifiedStrategy) ActiveChunks() []ActiveChunkInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	// Show some context before changes
-	contextStart := commonPrefix - 3
-	if contextStart < 0 {
-		contextStart = 0
+	infos := make([]ActiveChunkInfo, 0, len(s.activeChunks))
+	for path, active := range s.activeChunks {
+		infos = append(infos, ActiveChunkInfo{
+			FilePath:   path,
+			Op:         active.chunk.Op,
+			StartTime:  active.chunk.StartTime,
+			LastUpdate: active.lastUpdate,
+		})
 	}
-	for i := contextStart; i < commonPrefix; i++ {
-		result = append(result, " "+oldLines[i])
+	for path, pending := range s.pendingDeletes {
+		infos = append(infos, ActiveChunkInfo{
+			FilePath:   path,
+			Op:         OpDelete,
+			StartTime:  pending.time,
+			LastUpdate: pending.time,
+		})
 	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].FilePath < infos[j].FilePath })
+
+	return infos
+}
 
-	// Show removed lines
-	for i := commonPrefix; i < len(oldLines)-commonSuffix; i++ {
-		result = append(result, "-"+oldLines[i])
+// ForceFlush immediately creates a chunk for the specified file path.
+func (s *UnifiedStrategy) ForceFlush(filePath string) *Chunk {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pending, deleted := s.pendingDeletes[filePath]; deleted {
+		delete(s.pendingDeletes, filePath)
+		c, ok := s.deleteChunk(filePath, pending)
+		if !ok {
+			return nil
+		}
+		c.Manual = true
+		return &c
 	}
 
-	// Show added lines
-	for i := commonPrefix; i < len(newLines)-commonSuffix; i++ {
-		result = append(result, "+"+newLines[i])
+	active, exists := s.activeChunks[filePath]
+	if !exists {
+		return nil
 	}
 
-	// Show some context after changes
-	contextEnd := len(oldLines) - commonSuffix + 3
-	if contextEnd > len(oldLines) {
-		contextEnd = len(oldLines)
+	active.chunk.Manual = true
+	c, ok := s.finalize(active)
+	delete(s.activeChunks, filePath)
+	if !ok {
+		return nil
 	}
-	for i := len(oldLines) - commonSuffix; i < contextEnd; i++ {
-		result = append(result, " "+oldLines[i])
+
+	return &c
+}
+
+// finalize fills in the diff and snapshots of an active chunk. It returns false if
+// the file ended up identical to where it started, in which case there is nothing to save
+// (a renamed file is always saved, since its path changed).
+func (s *UnifiedStrategy) finalize(active *activeChunk) (Chunk, bool) {
+	if active.initialHash == string(active.chunk.Hash) && active.chunk.Op != OpRename {
+		return Chunk{}, false
 	}
 
-	return strings.Join(result, "\n")
+	active.chunk.Diff = s.generateDiff(active)
+	active.chunk.Before = active.initialContent
+	active.chunk.After = active.latestContent
+	return *active.chunk, true
+}
+
+// hashContent generates a SHA256 hash of the given content.
+func (s *UnifiedStrategy) hashContent(content []byte) string {
+	hash := sha256.Sum256(content)
+	return fmt.Sprintf("%x", hash)
+}
+
+// generateDiff creates a git patch for a chunk.
+func (s *UnifiedStrategy) generateDiff(active *activeChunk) string {
+	c := active.chunk
+	return FileDiff(c.Op, DiffPath(s.root, c.OldPath), DiffPath(s.root, c.FilePath),
+		active.initialContent, active.latestContent, s.contextLines)
 }
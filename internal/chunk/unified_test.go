@@ -0,0 +1,191 @@
+package chunk
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// fakeBaseline is a BaselineProvider backed by a map of file contents.
+type fakeBaseline map[string]string
+
+func (b fakeBaseline) Baseline(path string) ([]byte, bool) {
+	content, ok := b[path]
+	return []byte(content), ok
+}
+
+// flushedChunk is the part of a flushed chunk the strategy tests compare.
+type flushedChunk struct {
+	Op       Op
+	FilePath string
+	OldPath  string
+}
+
+func TestUnifiedDeleteAndRename(t *testing.T) {
+	// step is a file event arriving the given number of milliseconds into the test
+	type step struct {
+		op       Op
+		path     string
+		contents string
+		at       int
+	}
+
+	tests := []struct {
+		name     string
+		baseline fakeBaseline
+		steps    []step
+		want     []flushedChunk // Sorted by path
+	}{
+		{
+			name:     "delete",
+			baseline: fakeBaseline{"/repo/a.go": "package a\n"},
+			steps:    []step{{op: OpDelete, path: "/repo/a.go"}},
+			want:     []flushedChunk{{Op: OpDelete, FilePath: "/repo/a.go"}},
+		},
+		{
+			name:     "delete and create with the same content is a rename",
+			baseline: fakeBaseline{"/repo/a.go": "package a\n"},
+			steps: []step{
+				{op: OpDelete, path: "/repo/a.go"},
+				{op: OpCreate, path: "/repo/b.go", contents: "package a\n", at: 100},
+			},
+			want: []flushedChunk{{Op: OpRename, FilePath: "/repo/b.go", OldPath: "/repo/a.go"}},
+		},
+		{
+			name:     "creation after the rename window",
+			baseline: fakeBaseline{"/repo/a.go": "package a\n"},
+			steps: []step{
+				{op: OpDelete, path: "/repo/a.go"},
+				{op: OpCreate, path: "/repo/b.go", contents: "package a\n", at: 3000},
+			},
+			want: []flushedChunk{{Op: OpDelete, FilePath: "/repo/a.go"}, {Op: OpCreate, FilePath: "/repo/b.go"}},
+		},
+		{
+			name:     "different content",
+			baseline: fakeBaseline{"/repo/a.go": "package a\n"},
+			steps: []step{
+				{op: OpDelete, path: "/repo/a.go"},
+				{op: OpCreate, path: "/repo/b.go", contents: "package b\n", at: 100},
+			},
+			want: []flushedChunk{{Op: OpDelete, FilePath: "/repo/a.go"}, {Op: OpCreate, FilePath: "/repo/b.go"}},
+		},
+		{
+			name:     "replaced in place by an editor",
+			baseline: fakeBaseline{"/repo/a.go": "package a\n"},
+			steps: []step{
+				{op: OpDelete, path: "/repo/a.go"},
+				{op: OpCreate, path: "/repo/a.go", contents: "package a // saved\n", at: 10},
+			},
+			want: []flushedChunk{{Op: OpModify, FilePath: "/repo/a.go"}},
+		},
+		{
+			name: "same name wins over a more recent deletion",
+			baseline: fakeBaseline{
+				"/repo/x/util.go":  "package util\n",
+				"/repo/y/other.go": "package util\n",
+			},
+			steps: []step{
+				{op: OpDelete, path: "/repo/x/util.go"},
+				{op: OpDelete, path: "/repo/y/other.go", at: 50},
+				{op: OpCreate, path: "/repo/z/util.go", contents: "package util\n", at: 100},
+			},
+			want: []flushedChunk{
+				{Op: OpDelete, FilePath: "/repo/y/other.go"},
+				{Op: OpRename, FilePath: "/repo/z/util.go", OldPath: "/repo/x/util.go"},
+			},
+		},
+		{
+			name: "most recent deletion wins",
+			baseline: fakeBaseline{
+				"/repo/a.go": "package a\n",
+				"/repo/b.go": "package a\n",
+				"/repo/c.go": "package a\n",
+			},
+			steps: []step{
+				{op: OpDelete, path: "/repo/b.go"},
+				{op: OpDelete, path: "/repo/c.go", at: 50},
+				{op: OpDelete, path: "/repo/a.go", at: 20},
+				{op: OpCreate, path: "/repo/d.go", contents: "package a\n", at: 100},
+			},
+			want: []flushedChunk{
+				{Op: OpDelete, FilePath: "/repo/a.go"},
+				{Op: OpDelete, FilePath: "/repo/b.go"},
+				{Op: OpRename, FilePath: "/repo/d.go", OldPath: "/repo/c.go"},
+			},
+		},
+		{
+			name:     "empty files are not paired",
+			baseline: fakeBaseline{"/repo/empty": ""},
+			steps: []step{
+				{op: OpDelete, path: "/repo/empty"},
+				{op: OpCreate, path: "/repo/new", at: 100},
+			},
+			// The new file is still empty, so it has no changes of its own
+			want: []flushedChunk{{Op: OpDelete, FilePath: "/repo/empty"}},
+		},
+		{
+			name: "created and deleted before flushing",
+			steps: []step{
+				{op: OpCreate, path: "/repo/tmp.go", contents: "package tmp\n"},
+				{op: OpDelete, path: "/repo/tmp.go", at: 100},
+			},
+		},
+		{
+			name:     "renamed twice",
+			baseline: fakeBaseline{"/repo/a.go": "package a\n"},
+			steps: []step{
+				{op: OpDelete, path: "/repo/a.go"},
+				{op: OpCreate, path: "/repo/b.go", contents: "package a\n", at: 100},
+				{op: OpDelete, path: "/repo/b.go", at: 200},
+				{op: OpCreate, path: "/repo/c.go", contents: "package a\n", at: 300},
+			},
+			want: []flushedChunk{{Op: OpRename, FilePath: "/repo/c.go", OldPath: "/repo/a.go"}},
+		},
+	}
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultStrategyOptions()
+			opts.Baseline = tt.baseline
+			s := NewUnifiedStrategyWithOptions(opts)
+			for _, st := range tt.steps {
+				event := FileChangeEvent{Path: st.path, Time: start.Add(time.Duration(st.at) * time.Millisecond), Op: st.op}
+				if st.op != OpDelete {
+					event.Contents = []byte(st.contents)
+				}
+				s.OnFileChange(event)
+			}
+
+			var got []flushedChunk
+			for _, c := range s.FlushAll() {
+				got = append(got, flushedChunk{Op: c.Op, FilePath: c.FilePath, OldPath: c.OldPath})
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i].FilePath < got[j].FilePath })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flushed %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedRenamePairingIsDeterministic(t *testing.T) {
+	// Deletions at the same moment are paired in path order, whatever the map order
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		opts := DefaultStrategyOptions()
+		opts.Baseline = fakeBaseline{"/repo/c.go": "x\n", "/repo/a.go": "x\n", "/repo/b.go": "x\n"}
+		s := NewUnifiedStrategyWithOptions(opts)
+		for _, path := range []string{"/repo/c.go", "/repo/a.go", "/repo/b.go"} {
+			s.OnFileChange(FileChangeEvent{Path: path, Time: start, Op: OpDelete})
+		}
+		s.OnFileChange(FileChangeEvent{Path: "/repo/d.go", Contents: []byte("x\n"), Time: start, Op: OpCreate})
+
+		for _, c := range s.FlushAll() {
+			if c.Op == OpRename && c.OldPath != "/repo/a.go" {
+				t.Fatalf("d.go paired with %s, want a.go", c.OldPath)
+			}
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Package config loads Carya's settings. Each setting has a built-in default that the
+// user's configuration file, the repository's .carya/config and CARYA_* environment
+// variables override in turn.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"carya/internal/engine"
+	"carya/internal/housekeeping"
+	"carya/internal/retention"
+	"carya/internal/store"
+	"carya/internal/watcher"
+)
+
+const (
+	// FileName is the name of the configuration file in the .carya directory and in the
+	// user's configuration directory.
+	FileName = "config"
+	// EnvPrefix starts the names of the environment variables overriding settings: the
+	// variable for engine.flush_timeout is CARYA_ENGINE_FLUSH_TIMEOUT.
+	EnvPrefix = "CARYA_"
+)
+
+// Origins of a setting's value.
+const (
+	OriginDefault     = "default"     // Built-in default
+	OriginUser        = "user"        // User's configuration file
+	OriginRepository  = "repository"  // Repository's .carya/config
+	OriginEnvironment = "environment" // CARYA_* environment variable
+)
+
+// Config holds the settings of a repository.
+type Config struct {
+	Engine       engine.Options   // engine.*: chunking timings and diffs
+	Watcher      watcher.Options  // watcher.*: which changes are recorded and when
+	Store        store.Config     // store.*: where the history is kept
+	Housekeeping Housekeeping     // housekeeping.*: post-pull and post-checkout commands
+	Retention    retention.Policy // retention.*: which old chunks are kept
+	origins      map[string]string
+}
+
+// Housekeeping holds the settings of the housekeeping commands.
+type Housekeeping struct {
+	AutoApprovePostPull     bool // Run post-pull commands without asking
+	AutoApprovePostCheckout bool // Run post-checkout commands without asking
+}
+
+// Setting is the value of a key and where it came from.
+type Setting struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Origin      string `json:"origin"` // One of the Origin constants, or the legacy file the value was read from
+	Description string `json:"description"`
+}
+
+// KeyError is an invalid setting.
+type KeyError struct {
+	Source string // File and line, or environment variable, the setting was read from; empty if given directly
+	Key    string // Key of the setting
+	Err    error  // What is wrong with it
+}
+
+func (e *KeyError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("%s: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Source, e.Key, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	errUnknownKey     = errors.New("unknown key (see 'carya config list')")
+	errRepositoryOnly = errors.New("can only be set in a repository's .carya/config")
+)
+
+// Default returns the built-in settings.
+func Default() *Config {
+	c := &Config{
+		Engine:    engine.DefaultOptions(),
+		Watcher:   watcher.DefaultOptions(),
+		Retention: retention.DefaultPolicy(),
+		origins:   make(map[string]string),
+	}
+	for _, k := range keys {
+		c.origins[k.Name] = OriginDefault
+	}
+	return c
+}
+
+// Load returns the settings of the repository whose .carya directory is caryaDir, which
+// may not exist. Settings in the user's configuration file override the defaults; the
+// older store.json, housekeeping.json and retention.json files override those, and the
+// repository's .carya/config and the environment override everything before them.
+func Load(caryaDir string) (*Config, error) {
+	c := Default()
+
+	user, err := UserFile()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.apply(user, OriginUser); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyLegacy(caryaDir); err != nil {
+		return nil, err
+	}
+
+	repo, err := RepositoryFile(caryaDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.apply(repo, OriginRepository); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// apply sets the values of a configuration file.
+func (c *Config) apply(f *File, origin string) error {
+	entries, err := f.entries()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		k, err := f.lookup(e.key)
+		if err != nil {
+			return &KeyError{Source: f.location(e.line), Key: e.key, Err: errors.Unwrap(err)}
+		}
+		if err := k.set(c, e.value); err != nil {
+			return &KeyError{Source: f.location(e.line), Key: k.Name, Err: err}
+		}
+		c.origins[k.Name] = origin
+	}
+	return nil
+}
+
+// applyLegacy sets the values kept in the store.json, housekeeping.json and
+// retention.json files of earlier versions, or detects the storage backend if there is
+// no store.json.
+func (c *Config) applyLegacy(caryaDir string) error {
+	storeConfig, err := store.LoadConfig(caryaDir)
+	if err != nil {
+		return err
+	}
+	c.Store = storeConfig
+	if _, err := os.Stat(filepath.Join(caryaDir, store.ConfigFile)); err == nil {
+		c.origins["store.backend"] = store.ConfigFile
+	}
+
+	var hk housekeeping.Config
+	if err := readLegacy(caryaDir, housekeeping.ConfigFile, &hk); err != nil {
+		return err
+	}
+	if hk.AutoApprovePostPull {
+		c.Housekeeping.AutoApprovePostPull = true
+		c.origins["housekeeping.auto_approve_post_pull"] = housekeeping.ConfigFile
+	}
+	if hk.AutoApprovePostCheckout {
+		c.Housekeeping.AutoApprovePostCheckout = true
+		c.origins["housekeeping.auto_approve_post_checkout"] = housekeeping.ConfigFile
+	}
+
+	// Fields missing from retention.json keep their defaults
+	var policy struct {
+		KeepDays    *int                   `json:"keep_days"`
+		Thin        *retention.Granularity `json:"thin"`
+		DropPushed  *bool                  `json:"drop_pushed"`
+		AutoCompact *bool                  `json:"auto_compact"`
+	}
+	if err := readLegacy(caryaDir, retention.PolicyFile, &policy); err != nil {
+		return err
+	}
+	if policy.KeepDays != nil {
+		c.Retention.KeepDays = *policy.KeepDays
+		c.origins["retention.keep_days"] = retention.PolicyFile
+	}
+	if policy.Thin != nil {
+		c.Retention.Thin = *policy.Thin
+		c.origins["retention.thin"] = retention.PolicyFile
+	}
+	if policy.DropPushed != nil {
+		c.Retention.DropPushed = *policy.DropPushed
+		c.origins["retention.drop_pushed"] = retention.PolicyFile
+	}
+	if policy.AutoCompact != nil {
+		c.Retention.AutoCompact = *policy.AutoCompact
+		c.origins["retention.auto_compact"] = retention.PolicyFile
+	}
+	if err := c.Retention.Validate(); err != nil {
+		return fmt.Errorf("invalid %s: %w", retention.PolicyFile, err)
+	}
+	return nil
+}
+
+// readLegacy parses the JSON file name in caryaDir into v, leaving v alone if there is
+// no such file.
+func readLegacy(caryaDir, name string, v interface{}) error {
+	data, err := os.ReadFile(filepath.Join(caryaDir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+// applyEnv sets the values of the CARYA_* environment variables.
+func (c *Config) applyEnv() error {
+	for _, k := range keys {
+		value, ok := os.LookupEnv(k.EnvVar())
+		if !ok {
+			continue
+		}
+		if err := k.set(c, value); err != nil {
+			return &KeyError{Source: "$" + k.EnvVar(), Key: k.Name, Err: err}
+		}
+		c.origins[k.Name] = OriginEnvironment
+	}
+	return nil
+}
+
+// Get returns the value of key.
+func (c *Config) Get(key string) (Setting, error) {
+	k, err := lookupKey(key)
+	if err != nil {
+		return Setting{}, err
+	}
+	return c.setting(k), nil
+}
+
+// Origin returns where the value of key came from.
+func (c *Config) Origin(key string) string {
+	return c.origins[strings.ToLower(key)]
+}
+
+// Settings returns the value of every key, sorted by key.
+func (c *Config) Settings() []Setting {
+	settings := make([]Setting, 0, len(keys))
+	for i := range keys {
+		settings = append(settings, c.setting(&keys[i]))
+	}
+	return settings
+}
+
+// setting returns the value of k.
+func (c *Config) setting(k *Key) Setting {
+	return Setting{Key: k.Name, Value: k.get(c), Origin: c.origins[k.Name], Description: k.Description}
+}
+
+// lookupKey returns the key named name, ignoring case.
+func lookupKey(name string) (*Key, error) {
+	name = strings.ToLower(name)
+	for i := range keys {
+		if keys[i].Name == name {
+			return &keys[i], nil
+		}
+	}
+	return nil, &KeyError{Key: name, Err: errUnknownKey}
+}
@@ -0,0 +1,353 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupDirs points the user's configuration directory at a temporary one, clears the
+// CARYA_* environment variables and writes the user and repository configuration files
+// with the given contents, skipping empty ones. It returns the .carya directory.
+func setupDirs(t *testing.T, user, repo string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	for _, k := range keys {
+		t.Setenv(k.EnvVar(), "")
+		os.Unsetenv(k.EnvVar())
+	}
+
+	caryaDir := filepath.Join(t.TempDir(), ".carya")
+	if err := os.MkdirAll(caryaDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if user != "" {
+		path, err := UserPath()
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeConfig(t, path, user)
+	}
+	if repo != "" {
+		writeConfig(t, RepositoryPath(caryaDir), repo)
+	}
+	return caryaDir
+}
+
+// writeConfig writes content to path, creating its directory.
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadLayering(t *testing.T) {
+	tests := []struct {
+		name       string
+		user       string
+		repo       string
+		env        map[string]string
+		key        string
+		wantValue  string
+		wantOrigin string
+	}{
+		{
+			name:       "default",
+			key:        "engine.flush_timeout",
+			wantValue:  "15m0s",
+			wantOrigin: OriginDefault,
+		},
+		{
+			name:       "user overrides default",
+			user:       "[engine]\nflush_timeout = 20s\n",
+			key:        "engine.flush_timeout",
+			wantValue:  "20s",
+			wantOrigin: OriginUser,
+		},
+		{
+			name:       "repository overrides user",
+			user:       "[engine]\nflush_timeout = 20s\n",
+			repo:       "[engine]\nflush_timeout = 30s\n",
+			key:        "engine.flush_timeout",
+			wantValue:  "30s",
+			wantOrigin: OriginRepository,
+		},
+		{
+			name:       "environment overrides repository",
+			user:       "[engine]\nflush_timeout = 20s\n",
+			repo:       "[engine]\nflush_timeout = 30s\n",
+			env:        map[string]string{"CARYA_ENGINE_FLUSH_TIMEOUT": "40s"},
+			key:        "engine.flush_timeout",
+			wantValue:  "40s",
+			wantOrigin: OriginEnvironment,
+		},
+		{
+			name:       "other keys keep their origin",
+			user:       "[engine]\nflush_timeout = 20s\n",
+			key:        "engine.context_lines",
+			wantValue:  "3",
+			wantOrigin: OriginDefault,
+		},
+		{
+			name:       "last value in a file wins",
+			repo:       "[watcher]\nquiet_window = 1s\n[watcher]\nquiet_window = 2s\n",
+			key:        "watcher.quiet_window",
+			wantValue:  "2s",
+			wantOrigin: OriginRepository,
+		},
+		{
+			name:       "keys ignore case",
+			repo:       "[Watcher]\nMax_File_Size = 2m\n",
+			key:        "WATCHER.MAX_FILE_SIZE",
+			wantValue:  "2M",
+			wantOrigin: OriginRepository,
+		},
+		{
+			name:       "quoted list",
+			repo:       "[watcher]\nignore = \"dist/, *.tmp\"\n",
+			key:        "watcher.ignore",
+			wantValue:  "dist/, *.tmp",
+			wantOrigin: OriginRepository,
+		},
+		{
+			name:       "empty list",
+			env:        map[string]string{"CARYA_WATCHER_IGNORE": ""},
+			key:        "watcher.ignore",
+			wantValue:  "",
+			wantOrigin: OriginEnvironment,
+		},
+		{
+			name:       "retention",
+			user:       "[retention]\nthin = Hour\n",
+			key:        "retention.thin",
+			wantValue:  "hour",
+			wantOrigin: OriginUser,
+		},
+		{
+			name:       "repository-only key",
+			repo:       "[store]\nbackend = JSON\n",
+			key:        "store.backend",
+			wantValue:  "json",
+			wantOrigin: OriginRepository,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caryaDir := setupDirs(t, tt.user, tt.repo)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			c, err := Load(caryaDir)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			s, err := c.Get(tt.key)
+			if err != nil {
+				t.Fatalf("Get(%q) error = %v", tt.key, err)
+			}
+			if s.Value != tt.wantValue || s.Origin != tt.wantOrigin {
+				t.Errorf("Get(%q) = %q from %s, want %q from %s", tt.key, s.Value, s.Origin, tt.wantValue, tt.wantOrigin)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		repo    string
+		env     map[string]string
+		legacy  string // Contents of retention.json, if any
+		wantErr string // Substring of the error
+		keyErr  error  // Error a *KeyError wraps, if any
+	}{
+		{
+			name:    "unknown key",
+			repo:    "[engine]\nflush_timeout = 1s\nno_such_key = 1\n",
+			wantErr: "config:3: engine.no_such_key",
+			keyErr:  errUnknownKey,
+		},
+		{
+			name:    "invalid duration",
+			repo:    "[engine]\nflush_timeout = soon\n",
+			wantErr: "config:2: engine.flush_timeout: invalid duration",
+		},
+		{
+			name:    "zero duration",
+			repo:    "[engine]\nflush_timeout = 0s\n",
+			wantErr: "must be longer than zero",
+		},
+		{
+			name:    "no context lines",
+			repo:    "[engine]\ncontext_lines = 0\n",
+			wantErr: "engine.context_lines: must be at least 1, got 0",
+		},
+		{
+			name:    "invalid boolean",
+			repo:    "[housekeeping]\nauto_approve_post_pull = maybe\n",
+			wantErr: "invalid boolean",
+		},
+		{
+			name:    "zero file size",
+			repo:    "[watcher]\nmax_file_size = 0\n",
+			wantErr: "must be larger than zero",
+		},
+		{
+			name:    "unknown backend",
+			repo:    "[store]\nbackend = postgres\n",
+			wantErr: "unknown storage backend",
+		},
+		{
+			name:    "invalid thinning period",
+			repo:    "[retention]\nthin = week\n",
+			wantErr: "retention.thin: invalid thinning period",
+		},
+		{
+			name:    "negative keep days",
+			repo:    "[retention]\nkeep_days = -1\n",
+			wantErr: "retention.keep_days: must be at least 0, got -1",
+		},
+		{
+			name:    "invalid legacy retention policy",
+			legacy:  `{"thin": "week"}`,
+			wantErr: "invalid retention.json",
+		},
+		{
+			name:    "repository-only key in the user file",
+			user:    "[store]\nbackend = json\n",
+			wantErr: "config:2: store.backend",
+			keyErr:  errRepositoryOnly,
+		},
+		{
+			name:    "setting outside a section",
+			repo:    "flush_timeout = 1s\n",
+			wantErr: "config:1: flush_timeout is not in a [section]",
+		},
+		{
+			name:    "invalid section header",
+			repo:    "[engine\n",
+			wantErr: "config:1: invalid section header",
+		},
+		{
+			name:    "line without a value",
+			repo:    "[engine]\nflush_timeout\n",
+			wantErr: "config:2: expected \"name = value\"",
+		},
+		{
+			name:    "unterminated quote",
+			repo:    "[watcher]\nignore = \"dist/\n",
+			wantErr: "config:2: watcher.ignore: invalid quoted value",
+		},
+		{
+			name:    "invalid environment variable",
+			env:     map[string]string{"CARYA_WATCHER_QUIET_WINDOW": "fast"},
+			wantErr: "$CARYA_WATCHER_QUIET_WINDOW: watcher.quiet_window: invalid duration",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caryaDir := setupDirs(t, tt.user, tt.repo)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if tt.legacy != "" {
+				writeConfig(t, filepath.Join(caryaDir, "retention.json"), tt.legacy)
+			}
+
+			_, err := Load(caryaDir)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want one containing %q", err, tt.wantErr)
+			}
+			if tt.keyErr != nil && !errors.Is(err, tt.keyErr) {
+				t.Errorf("Load() error = %v, want one wrapping %q", err, tt.keyErr)
+			}
+		})
+	}
+}
+
+func TestLoadLegacyFiles(t *testing.T) {
+	caryaDir := setupDirs(t, "", "[store]\nbackend = json\n[retention]\nthin = hour\n")
+	writeConfig(t, filepath.Join(caryaDir, "store.json"), `{"backend": "bolt"}`)
+	writeConfig(t, filepath.Join(caryaDir, "housekeeping.json"), `{"auto_approve_post_pull": true}`)
+	writeConfig(t, filepath.Join(caryaDir, "retention.json"), `{"keep_days": 7, "thin": "none"}`)
+
+	c, err := Load(caryaDir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	// The repository's config overrides store.json, which still overrides the defaults
+	if s, _ := c.Get("store.backend"); s.Value != "json" || s.Origin != OriginRepository {
+		t.Errorf("store.backend = %q from %s, want json from %s", s.Value, s.Origin, OriginRepository)
+	}
+	if s, _ := c.Get("housekeeping.auto_approve_post_pull"); s.Value != "true" || s.Origin != "housekeeping.json" {
+		t.Errorf("housekeeping.auto_approve_post_pull = %q from %s, want true from housekeeping.json", s.Value, s.Origin)
+	}
+
+	// Fields of retention.json override the defaults, and the repository's config overrides them
+	tests := []struct {
+		key        string
+		wantValue  string
+		wantOrigin string
+	}{
+		{key: "retention.keep_days", wantValue: "7", wantOrigin: "retention.json"},
+		{key: "retention.thin", wantValue: "hour", wantOrigin: OriginRepository},
+		{key: "retention.drop_pushed", wantValue: "true", wantOrigin: OriginDefault},
+	}
+	for _, tt := range tests {
+		if s, _ := c.Get(tt.key); s.Value != tt.wantValue || s.Origin != tt.wantOrigin {
+			t.Errorf("%s = %q from %s, want %q from %s", tt.key, s.Value, s.Origin, tt.wantValue, tt.wantOrigin)
+		}
+	}
+	if c.Retention.KeepDays != 7 || c.Retention.Thin != "hour" || !c.Retention.DropPushed {
+		t.Errorf("Retention = %+v, want 7 days thinned per hour, dropping pushed chunks", c.Retention)
+	}
+}
+
+func TestSizes(t *testing.T) {
+	tests := []struct {
+		value   string
+		size    int64
+		format  string
+		wantErr bool
+	}{
+		{value: "0", size: 0, format: "0"},
+		{value: "100", size: 100, format: "100"},
+		{value: "512K", size: 512 << 10, format: "512K"},
+		{value: "512kb", size: 512 << 10, format: "512K"},
+		{value: "10M", size: 10 << 20, format: "10M"},
+		{value: "1024K", size: 1 << 20, format: "1M"},
+		{value: "2G", size: 2 << 30, format: "2G"},
+		{value: "1.5M", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "big", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		size, err := ParseSize(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSize(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if size != tt.size {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.value, size, tt.size)
+		}
+		if got := FormatSize(size); got != tt.format {
+			t.Errorf("FormatSize(%d) = %q, want %q", size, got, tt.format)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// File is a configuration file. Like git's config files, it holds "name = value" lines
+// grouped under [section] headers, making up keys such as engine.flush_timeout; blank
+// lines and lines starting with # or ; are ignored. A File keeps the lines it was read
+// from, so setting a value leaves the rest of the file as the user wrote it.
+type File struct {
+	Path  string   // Where the file is read from and saved to
+	user  bool     // Whether this is the user's config, where repository-only keys are rejected
+	lines []string // Lines of the file, without line endings
+}
+
+// entry is a setting read from a line of a File.
+type entry struct {
+	key   string // Section and name, lower case
+	value string // Value, unquoted
+	line  int    // Index of the line in File.lines
+}
+
+// UserPath returns the path of the user's configuration file, in $XDG_CONFIG_HOME or
+// ~/.config.
+func UserPath() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "carya", FileName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user configuration directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "carya", FileName), nil
+}
+
+// RepositoryPath returns the path of the configuration file in a .carya directory.
+func RepositoryPath(caryaDir string) string {
+	return filepath.Join(caryaDir, FileName)
+}
+
+// UserFile reads the user's configuration file, which may not exist yet.
+func UserFile() (*File, error) {
+	path, err := UserPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f.user = true
+	return f, nil
+}
+
+// RepositoryFile reads the configuration file of the repository whose .carya directory is
+// caryaDir, which may not exist yet.
+func RepositoryFile(caryaDir string) (*File, error) {
+	return ReadFile(RepositoryPath(caryaDir))
+}
+
+// ReadFile reads the configuration file at path. A missing file reads as an empty one.
+func ReadFile(path string) (*File, error) {
+	f := &File{Path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	f.lines = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if text == "" {
+		f.lines = nil
+	}
+	return f, nil
+}
+
+// entries parses the settings of the file in order, reporting the first malformed line.
+func (f *File) entries() ([]entry, error) {
+	var entries []entry
+	section := ""
+	for i, raw := range f.lines {
+		line := strings.TrimSpace(raw)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			name, ok := parseSection(line)
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: invalid section header %q", f.Path, i+1, line)
+			}
+			section = name
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || name == "" || strings.ContainsAny(name, " \t.") {
+			return nil, fmt.Errorf("%s:%d: expected \"name = value\", got %q", f.Path, i+1, line)
+		}
+		if section == "" {
+			return nil, fmt.Errorf("%s:%d: %s is not in a [section]", f.Path, i+1, name)
+		}
+
+		value, err := unquote(strings.TrimSpace(value))
+		if err != nil {
+			return nil, &KeyError{Source: f.location(i), Key: section + "." + name, Err: err}
+		}
+		entries = append(entries, entry{key: section + "." + name, value: value, line: i})
+	}
+	return entries, nil
+}
+
+// location describes line i of the file in error messages.
+func (f *File) location(i int) string {
+	return fmt.Sprintf("%s:%d", f.Path, i+1)
+}
+
+// Set validates value for key and sets it in the file, replacing any value the file
+// already had for the key. The change is kept in memory until Save.
+func (f *File) Set(key, value string) error {
+	k, err := f.lookup(key)
+	if err != nil {
+		return err
+	}
+	if err := k.set(Default(), value); err != nil {
+		return &KeyError{Key: k.Name, Err: err}
+	}
+
+	entries, err := f.entries()
+	if err != nil {
+		return err
+	}
+	section, name, _ := strings.Cut(k.Name, ".")
+	line := "\t" + name + " = " + quote(value)
+
+	// Replace the first occurrence of the key and drop the others
+	var lines []int
+	for _, e := range entries {
+		if e.key == k.Name {
+			lines = append(lines, e.line)
+		}
+	}
+	if len(lines) > 0 {
+		f.lines[lines[0]] = line
+		for i := len(lines) - 1; i > 0; i-- {
+			f.lines = append(f.lines[:lines[i]], f.lines[lines[i]+1:]...)
+		}
+		return nil
+	}
+
+	if end, ok := f.sectionEnd(section); ok {
+		f.lines = append(f.lines[:end], append([]string{line}, f.lines[end:]...)...)
+		return nil
+	}
+	if len(f.lines) > 0 && strings.TrimSpace(f.lines[len(f.lines)-1]) != "" {
+		f.lines = append(f.lines, "")
+	}
+	f.lines = append(f.lines, "["+section+"]", line)
+	return nil
+}
+
+// Unset removes key from the file, reporting whether the file set it.
+func (f *File) Unset(key string) (bool, error) {
+	k, err := f.lookup(key)
+	if err != nil {
+		return false, err
+	}
+	entries, err := f.entries()
+	if err != nil {
+		return false, err
+	}
+
+	removed := false
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].key == k.Name {
+			f.lines = append(f.lines[:entries[i].line], f.lines[entries[i].line+1:]...)
+			removed = true
+		}
+	}
+	return removed, nil
+}
+
+// lookup returns the key named key, rejecting unknown keys and repository-only keys in
+// the user's config.
+func (f *File) lookup(key string) (*Key, error) {
+	k, err := lookupKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if f.user && k.RepositoryOnly {
+		return nil, &KeyError{Key: k.Name, Err: errRepositoryOnly}
+	}
+	return k, nil
+}
+
+// sectionEnd returns the index just after the last setting of the last [section] header
+// named section.
+func (f *File) sectionEnd(section string) (int, bool) {
+	end, found := 0, false
+	in := false
+	for i, raw := range f.lines {
+		line := strings.TrimSpace(raw)
+		if strings.HasPrefix(line, "[") {
+			name, _ := parseSection(line)
+			in = name == section
+			if in {
+				end, found = i+1, true
+			}
+			continue
+		}
+		if in && line != "" && line[0] != '#' && line[0] != ';' {
+			end = i + 1
+		}
+	}
+	return end, found
+}
+
+// Save writes the file, creating its directory if needed.
+func (f *File) Save() error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(f.Path), err)
+	}
+	data := strings.Join(f.lines, "\n")
+	if data != "" {
+		data += "\n"
+	}
+	if err := os.WriteFile(f.Path, []byte(data), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+// parseSection returns the lower-case name in a "[section]" header line.
+func parseSection(line string) (string, bool) {
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return "", false
+	}
+	name := strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+	if name == "" || strings.ContainsAny(name, " \t.[]") {
+		return "", false
+	}
+	return name, true
+}
+
+// quote returns value as written in a file, in double quotes if it would otherwise not
+// read back as the same value.
+func quote(value string) string {
+	if value == "" || strings.TrimSpace(value) != value || strings.ContainsAny(value, "\"#;\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// unquote returns the value written in a file, removing the double quotes around it if
+// it has them.
+func unquote(value string) (string, error) {
+	if !strings.HasPrefix(value, "\"") {
+		return value, nil
+	}
+	unquoted, err := strconv.Unquote(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted value %s", value)
+	}
+	return unquoted, nil
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSet(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		key   string
+		value string
+		want  string
+	}{
+		{
+			name:  "new file",
+			key:   "engine.flush_timeout",
+			value: "30s",
+			want:  "[engine]\n\tflush_timeout = 30s\n",
+		},
+		{
+			name:  "replace keeps comments and other keys",
+			input: "# My settings\n[engine]\n  flush_timeout = 1m ; old\n\trename_window = 5s\n",
+			key:   "engine.flush_timeout",
+			value: "30s",
+			want:  "# My settings\n[engine]\n\tflush_timeout = 30s\n\trename_window = 5s\n",
+		},
+		{
+			name:  "append to the existing section",
+			input: "[engine]\n\trename_window = 5s\n# trailing comment\n\n[watcher]\n\tquiet_window = 1s\n",
+			key:   "engine.flush_timeout",
+			value: "30s",
+			want:  "[engine]\n\trename_window = 5s\n\tflush_timeout = 30s\n# trailing comment\n\n[watcher]\n\tquiet_window = 1s\n",
+		},
+		{
+			name:  "new section after a blank line",
+			input: "[engine]\n\trename_window = 5s\n",
+			key:   "watcher.quiet_window",
+			value: "1s",
+			want:  "[engine]\n\trename_window = 5s\n\n[watcher]\n\tquiet_window = 1s\n",
+		},
+		{
+			name:  "duplicates are dropped",
+			input: "[engine]\n\tflush_timeout = 1m\n[engine]\n\tflush_timeout = 2m\n",
+			key:   "ENGINE.FLUSH_TIMEOUT",
+			value: "3m",
+			want:  "[engine]\n\tflush_timeout = 3m\n[engine]\n",
+		},
+		{
+			name:  "value that needs quotes",
+			key:   "watcher.ignore",
+			value: "#build#, dist/",
+			want:  "[watcher]\n\tignore = \"#build#, dist/\"\n",
+		},
+		{
+			name:  "empty value",
+			key:   "watcher.ignore",
+			value: "",
+			want:  "[watcher]\n\tignore = \"\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), FileName)
+			if tt.input != "" {
+				writeConfig(t, path, tt.input)
+			}
+			f, err := ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+
+			if err := f.Set(tt.key, tt.value); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			if err := f.Save(); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("file after Set(%q, %q) =\n%q\nwant\n%q", tt.key, tt.value, got, tt.want)
+			}
+
+			// The saved value reads back unchanged
+			f, err = ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			c := Default()
+			if err := c.apply(f, OriginRepository); err != nil {
+				t.Fatalf("reading back: %v", err)
+			}
+			want := Default()
+			k, _ := lookupKey(tt.key)
+			if err := k.set(want, tt.value); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := k.get(c), k.get(want); got != want {
+				t.Errorf("%s reads back as %q, want %q", tt.key, got, want)
+			}
+		})
+	}
+}
+
+func TestFileSetRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		user  bool
+		key   string
+		value string
+		err   error
+	}{
+		{name: "unknown key", key: "engine.nothing", value: "1", err: errUnknownKey},
+		{name: "repository-only key in the user file", user: true, key: "store.backend", value: "json", err: errRepositoryOnly},
+		{name: "invalid value", key: "engine.context_lines", value: "0"},
+		{name: "invalid duration", key: "watcher.max_delay", value: "later"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &File{Path: filepath.Join(t.TempDir(), FileName), user: tt.user}
+			err := f.Set(tt.key, tt.value)
+			var keyErr *KeyError
+			if !errors.As(err, &keyErr) {
+				t.Fatalf("Set(%q, %q) error = %v, want a *KeyError", tt.key, tt.value, err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("Set(%q, %q) error = %v, want one wrapping %q", tt.key, tt.value, err, tt.err)
+			}
+			if len(f.lines) != 0 {
+				t.Errorf("Set() changed the file to %q after failing", f.lines)
+			}
+		})
+	}
+}
+
+func TestFileUnset(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		key         string
+		wantRemoved bool
+		want        string
+	}{
+		{
+			name:        "remove",
+			input:       "[engine]\n\tflush_timeout = 1m\n\trename_window = 5s\n",
+			key:         "engine.flush_timeout",
+			wantRemoved: true,
+			want:        "[engine]\n\trename_window = 5s\n",
+		},
+		{
+			name:        "remove every occurrence",
+			input:       "[engine]\n\tflush_timeout = 1m\n# note\n[engine]\n\tflush_timeout = 2m\n",
+			key:         "engine.flush_timeout",
+			wantRemoved: true,
+			want:        "[engine]\n# note\n[engine]\n",
+		},
+		{
+			name:        "not set",
+			input:       "[engine]\n\trename_window = 5s\n",
+			key:         "engine.flush_timeout",
+			wantRemoved: false,
+			want:        "[engine]\n\trename_window = 5s\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), FileName)
+			writeConfig(t, path, tt.input)
+			f, err := ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+
+			removed, err := f.Unset(tt.key)
+			if err != nil {
+				t.Fatalf("Unset() error = %v", err)
+			}
+			if removed != tt.wantRemoved {
+				t.Errorf("Unset() = %v, want %v", removed, tt.wantRemoved)
+			}
+			if err := f.Save(); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("file after Unset(%q) =\n%q\nwant\n%q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFileLineEndings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	writeConfig(t, path, "[engine]\r\n\tflush_timeout = 1m\r\n")
+	f, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	entries, err := f.entries()
+	if err != nil {
+		t.Fatalf("entries() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].key != "engine.flush_timeout" || entries[0].value != "1m" {
+		t.Errorf("entries() = %+v, want engine.flush_timeout = 1m", entries)
+	}
+}
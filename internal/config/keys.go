@@ -0,0 +1,216 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"carya/internal/retention"
+	"carya/internal/store"
+)
+
+// Key is a setting that can be configured.
+type Key struct {
+	Name           string // Section and name, such as engine.flush_timeout
+	Description    string // What the setting controls
+	RepositoryOnly bool   // Whether the key only makes sense for one repository, so the user config can't set it
+
+	set func(c *Config, value string) error // Parses value into c
+	get func(c *Config) string              // Formats the value in c
+}
+
+// EnvVar returns the name of the environment variable overriding the key.
+func (k *Key) EnvVar() string {
+	return EnvVar(k.Name)
+}
+
+// EnvVar returns the name of the environment variable overriding key.
+func EnvVar(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// keys are all the settings, sorted by name.
+var keys = []Key{
+	durationKey("engine.active_flush_interval", "How often stale chunks are saved while files are changing",
+		func(c *Config) *time.Duration { return &c.Engine.Manager.ActiveInterval }),
+	intKey("engine.context_lines", "Unchanged lines shown around each diff hunk (at least 1, so chunks can be staged with git apply)", 1,
+		func(c *Config) *int { return &c.Engine.Strategy.ContextLines }),
+	durationKey("engine.flush_timeout", "Time without changes to a file after which its chunk is saved",
+		func(c *Config) *time.Duration { return &c.Engine.Strategy.FlushTimeout }),
+	durationKey("engine.idle_flush_interval", "How often stale chunks are saved while idle",
+		func(c *Config) *time.Duration { return &c.Engine.Manager.IdleInterval }),
+	durationKey("engine.idle_threshold", "Time without file changes before the engine goes idle",
+		func(c *Config) *time.Duration { return &c.Engine.Manager.IdleThreshold }),
+	durationKey("engine.rename_window", "How long a deletion waits for a matching new file to be recorded as a rename",
+		func(c *Config) *time.Duration { return &c.Engine.Strategy.RenameWindow }),
+	boolKey("housekeeping.auto_approve_post_checkout", "Run post-checkout commands without asking",
+		func(c *Config) *bool { return &c.Housekeeping.AutoApprovePostCheckout }),
+	boolKey("housekeeping.auto_approve_post_pull", "Run post-pull commands without asking",
+		func(c *Config) *bool { return &c.Housekeeping.AutoApprovePostPull }),
+	boolKey("retention.auto_compact", "Let the daemon compact old chunks when it goes idle",
+		func(c *Config) *bool { return &c.Retention.AutoCompact }),
+	boolKey("retention.drop_pushed", "Drop old chunks whose changes are in the upstream branch",
+		func(c *Config) *bool { return &c.Retention.DropPushed }),
+	intKey("retention.keep_days", "Chunks from the last this many days are never compacted", 0,
+		func(c *Config) *int { return &c.Retention.KeepDays }),
+	{
+		Name:        "retention.thin",
+		Description: "Period older chunks of a file are merged over (none, hour or day)",
+		set: func(c *Config, value string) error {
+			thin := retention.Granularity(strings.ToLower(strings.TrimSpace(value)))
+			if err := thin.Validate(); err != nil {
+				return err
+			}
+			c.Retention.Thin = thin
+			return nil
+		},
+		get: func(c *Config) string { return string(c.Retention.Thin) },
+	},
+	{
+		Name:           "store.backend",
+		Description:    "Storage backend of the history (sqlite, bolt or json); 'carya db convert' moves the history between them",
+		RepositoryOnly: true,
+		set: func(c *Config, value string) error {
+			backend := store.Backend(strings.ToLower(value))
+			if err := backend.Validate(); err != nil {
+				return err
+			}
+			c.Store.Backend = backend
+			return nil
+		},
+		get: func(c *Config) string { return string(c.Store.Backend) },
+	},
+	listKey("watcher.ignore", "Comma-separated patterns ignored unless an ignore file re-includes them (.git/ and .carya/ always are)",
+		func(c *Config) *[]string { return &c.Watcher.Ignore }),
+	durationKey("watcher.max_delay", "Longest a file's changes are held back while it keeps changing",
+		func(c *Config) *time.Duration { return &c.Watcher.MaxDelay }),
+	{
+		Name:        "watcher.max_file_size",
+		Description: "Files larger than this are recorded as changed without tracking their contents",
+		set: func(c *Config, value string) error {
+			size, err := ParseSize(value)
+			if err != nil {
+				return err
+			}
+			if size == 0 {
+				return fmt.Errorf("must be larger than zero")
+			}
+			c.Watcher.MaxFileSize = size
+			return nil
+		},
+		get: func(c *Config) string { return FormatSize(c.Watcher.MaxFileSize) },
+	},
+	durationKey("watcher.quiet_window", "How long a file must stay unchanged before its changes are recorded",
+		func(c *Config) *time.Duration { return &c.Watcher.QuietWindow }),
+}
+
+// durationKey returns a key holding a positive duration.
+func durationKey(name, description string, field func(*Config) *time.Duration) Key {
+	return Key{
+		Name:        name,
+		Description: description,
+		set: func(c *Config, value string) error {
+			d, err := time.ParseDuration(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("invalid duration %q (use e.g. 500ms, 30s or 15m)", value)
+			}
+			if d <= 0 {
+				return fmt.Errorf("must be longer than zero, got %s", value)
+			}
+			*field(c) = d
+			return nil
+		},
+		get: func(c *Config) string { return field(c).String() },
+	}
+}
+
+// intKey returns a key holding a number no smaller than min.
+func intKey(name, description string, min int, field func(*Config) *int) Key {
+	return Key{
+		Name:        name,
+		Description: description,
+		set: func(c *Config, value string) error {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("invalid number %q", value)
+			}
+			if n < min {
+				return fmt.Errorf("must be at least %d, got %d", min, n)
+			}
+			*field(c) = n
+			return nil
+		},
+		get: func(c *Config) string { return strconv.Itoa(*field(c)) },
+	}
+}
+
+// boolKey returns a key holding true or false.
+func boolKey(name, description string, field func(*Config) *bool) Key {
+	return Key{
+		Name:        name,
+		Description: description,
+		set: func(c *Config, value string) error {
+			b, err := strconv.ParseBool(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("invalid boolean %q (use true or false)", value)
+			}
+			*field(c) = b
+			return nil
+		},
+		get: func(c *Config) string { return strconv.FormatBool(*field(c)) },
+	}
+}
+
+// listKey returns a key holding a comma-separated list. An empty value is an empty list.
+func listKey(name, description string, field func(*Config) *[]string) Key {
+	return Key{
+		Name:        name,
+		Description: description,
+		set: func(c *Config, value string) error {
+			items := []string{}
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			*field(c) = items
+			return nil
+		},
+		get: func(c *Config) string { return strings.Join(*field(c), ", ") },
+	}
+}
+
+// sizeUnits are the suffixes understood by ParseSize, largest first.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}}
+
+// ParseSize parses a size in bytes, optionally with a K, M or G suffix (e.g. "512K", "10M").
+func ParseSize(value string) (int64, error) {
+	number, unit := strings.TrimSuffix(strings.ToUpper(value), "B"), int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(number, u.suffix) {
+			number, unit = strings.TrimSuffix(number, u.suffix), u.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (use e.g. 512K, 10M or 1G)", value)
+	}
+	return n * unit, nil
+}
+
+// FormatSize formats a size in bytes the way ParseSize reads it, using the largest suffix
+// that divides it exactly.
+func FormatSize(size int64) string {
+	for _, u := range sizeUnits {
+		if size != 0 && size%u.bytes == 0 {
+			return strconv.FormatInt(size/u.bytes, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(size, 10)
+}
@@ -0,0 +1,321 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Command names a request the daemon answers on its control socket.
+type Command string
+
+const (
+	CommandStatus Command = "status" // Report daemon state
+	CommandFlush  Command = "flush"  // Save all active chunks
+	CommandActive Command = "active" // List chunks still collecting changes
+	CommandPause  Command = "pause"  // Stop recording file changes
+	CommandResume Command = "resume" // Start recording file changes again
+	CommandReload Command = "reload" // Re-read configuration and ignore rules
+	CommandStop   Command = "stop"   // Flush and shut down
+
+	CommandFlushFile Command = "flush-file" // Save the active chunk of the file at Request.Path
+	CommandEvents    Command = "events"     // Stream chunk activity until the client disconnects
+)
+
+// ErrNotRunning is returned by Call when no daemon is listening on the socket.
+var ErrNotRunning = errors.New("daemon is not running")
+
+const (
+	dialTimeout    = 2 * time.Second  // How long to wait for the daemon to accept a connection
+	requestTimeout = 60 * time.Second // How long to wait for the daemon to answer
+)
+
+// Request is sent by a client as a single line of JSON.
+type Request struct {
+	Command Command `json:"command"`
+	Path    string  `json:"path,omitempty"` // File the command applies to, for CommandFlushFile
+}
+
+// Response is sent back by the daemon as a single line of JSON.
+type Response struct {
+	OK    bool            `json:"ok"`
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// Status describes a running daemon.
+type Status struct {
+	PID          int       `json:"pid"`
+	StartedAt    time.Time `json:"started_at"`
+	WatchDir     string    `json:"watch_dir"`
+	Paused       bool      `json:"paused"`
+	ActiveChunks int       `json:"active_chunks"`
+}
+
+// ActiveChunk describes a chunk that is still collecting changes.
+type ActiveChunk struct {
+	FilePath   string    `json:"file_path"`
+	Op         string    `json:"op"`
+	StartTime  time.Time `json:"start_time"`
+	LastUpdate time.Time `json:"last_update"`
+}
+
+// FlushedChunk describes a chunk saved by a flush.
+type FlushedChunk struct {
+	ID       string `json:"id"`
+	FilePath string `json:"file_path"`
+}
+
+// HandlerFunc answers a control command. The returned value is sent to the client as JSON.
+type HandlerFunc func(req Request) (interface{}, error)
+
+// StreamFunc answers a control command with a stream of values, passing each one to send,
+// which sends it to the client as a line of JSON. It returns once done is closed, which
+// happens when the client disconnects or the server shuts down, or once send fails.
+type StreamFunc func(req Request, send func(v interface{}) error, done <-chan struct{}) error
+
+// Server answers control commands on a Unix domain socket.
+type Server struct {
+	socketPath string                  // Path of the Unix socket
+	handlers   map[Command]HandlerFunc // Handlers by command
+	streams    map[Command]StreamFunc  // Streaming handlers by command
+	listener   net.Listener            // Listener accepting client connections
+	wg         sync.WaitGroup          // Tracks connections being served
+	closing    chan struct{}           // Closed when the server shuts down, ending streams
+	closeOnce  sync.Once               // Shuts the server down only once
+}
+
+// NewServer creates a control server for the socket at socketPath.
+func NewServer(socketPath string) *Server {
+	return &Server{
+		socketPath: socketPath,
+		handlers:   make(map[Command]HandlerFunc),
+		streams:    make(map[Command]StreamFunc),
+		closing:    make(chan struct{}),
+	}
+}
+
+// Handle registers the handler for a command.
+func (s *Server) Handle(cmd Command, handler HandlerFunc) {
+	s.handlers[cmd] = handler
+}
+
+// HandleStream registers the streaming handler for a command.
+func (s *Server) HandleStream(cmd Command, handler StreamFunc) {
+	s.streams[cmd] = handler
+}
+
+// Listen creates the socket, replacing a stale one left behind by a daemon that didn't shut down cleanly,
+// and starts serving requests in the background.
+func (s *Server) Listen() error {
+	if err := os.Remove(s.socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", s.socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.socketPath, err)
+	}
+	if err := os.Chmod(s.socketPath, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+
+	s.listener = listener
+	go s.acceptLoop()
+	return nil
+}
+
+// Close stops accepting connections, waits for in-flight requests to be answered and removes the socket.
+// Closing a server again does nothing.
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	var err error
+	s.closeOnce.Do(func() {
+		err = s.listener.Close()
+		close(s.closing)
+	})
+	s.wg.Wait()
+	return err
+}
+
+// acceptLoop accepts client connections until the listener is closed.
+func (s *Server) acceptLoop() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Control socket error: %v", err)
+			}
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serve(conn)
+		}()
+	}
+}
+
+// serve answers a single request on conn.
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	var req Request
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &req)
+	}
+	if stream, ok := s.streams[req.Command]; ok && err == nil {
+		s.serveStream(conn, req, stream)
+		return
+	}
+
+	var resp Response
+	if err != nil {
+		resp.Error = fmt.Sprintf("invalid request: %v", err)
+	} else if handler, ok := s.handlers[req.Command]; !ok {
+		resp.Error = fmt.Sprintf("unknown command %q", req.Command)
+	} else if data, err := handler(req); err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.OK = true
+		if data != nil {
+			if resp.Data, err = json.Marshal(data); err != nil {
+				resp.OK = false
+				resp.Error = fmt.Sprintf("failed to encode response: %v", err)
+			}
+		}
+	}
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		log.Printf("Failed to answer %s request: %v", req.Command, err)
+	}
+}
+
+// serveStream acknowledges a streaming request on conn and then passes values from the
+// handler on to the client until either side is done.
+func (s *Server) serveStream(conn net.Conn, req Request, stream StreamFunc) {
+	enc := json.NewEncoder(conn)
+	send := func(v interface{}) error {
+		conn.SetWriteDeadline(time.Now().Add(requestTimeout))
+		return enc.Encode(v)
+	}
+	conn.SetDeadline(time.Time{})
+	if err := send(Response{OK: true}); err != nil {
+		log.Printf("Failed to answer %s request: %v", req.Command, err)
+		return
+	}
+
+	// The client sends nothing more, so a read only returns once it disconnects
+	gone := make(chan struct{})
+	go func() {
+		conn.Read(make([]byte, 1))
+		close(gone)
+	}()
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-gone:
+		case <-s.closing:
+		}
+		close(done)
+	}()
+
+	if err := stream(req, send, done); err != nil {
+		log.Printf("Stopped streaming %s: %v", req.Command, err)
+	}
+}
+
+// Call sends a command to the daemon listening on socketPath and decodes the response data
+// into result (which may be nil). Returns ErrNotRunning if no daemon is listening, and the
+// daemon's error if the command failed.
+func Call(socketPath string, cmd Command, result interface{}) error {
+	return Send(socketPath, Request{Command: cmd}, result)
+}
+
+// Send is like Call, but sends a complete request.
+func Send(socketPath string, req Request, result interface{}) error {
+	conn, err := net.DialTimeout("unix", socketPath, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.OK {
+		return errors.New(resp.Error)
+	}
+
+	if result != nil && len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, result); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// Stream receives the values a daemon streams in answer to a request.
+type Stream struct {
+	conn net.Conn      // Connection to the daemon
+	dec  *json.Decoder // Decodes the values as they arrive
+}
+
+// Subscribe sends a streaming request to the daemon listening on socketPath and returns the
+// stream of its answers. Returns ErrNotRunning if no daemon is listening, and the daemon's
+// error if it refused the request.
+func Subscribe(socketPath string, req Request) (*Stream, error) {
+	conn, err := net.DialTimeout("unix", socketPath, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	dec := json.NewDecoder(conn)
+	var resp Response
+	if err := dec.Decode(&resp); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.OK {
+		conn.Close()
+		return nil, errors.New(resp.Error)
+	}
+
+	conn.SetDeadline(time.Time{})
+	return &Stream{conn: conn, dec: dec}, nil
+}
+
+// Next waits for the next value and decodes it into v. Returns io.EOF once the daemon
+// ends the stream.
+func (s *Stream) Next(v interface{}) error {
+	return s.dec.Decode(v)
+}
+
+// Close disconnects from the daemon, which ends the stream.
+func (s *Stream) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,193 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestServer starts a server on a socket in a temporary directory with handlers that
+// mimic the daemon's, and returns the socket path. Each value sent on events is streamed
+// to subscribers of CommandEvents, and ended receives a value when a stream ends.
+func newTestServer(t *testing.T, events <-chan string, ended chan<- struct{}) (*Server, string) {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+	server := NewServer(socketPath)
+
+	var mu sync.Mutex
+	paused := false
+	server.Handle(CommandStatus, func(Request) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return Status{PID: 42, WatchDir: "/repo", Paused: paused}, nil
+	})
+	server.Handle(CommandPause, func(Request) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		paused = true
+		return nil, nil
+	})
+	server.Handle(CommandResume, func(Request) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		paused = false
+		return nil, nil
+	})
+	server.Handle(CommandFlushFile, func(req Request) (interface{}, error) {
+		if req.Path != "/repo/main.go" {
+			return nil, fmt.Errorf("no active chunk for %s", req.Path)
+		}
+		return []FlushedChunk{{ID: "abc", FilePath: req.Path}}, nil
+	})
+	server.HandleStream(CommandEvents, func(req Request, send func(v interface{}) error, done <-chan struct{}) error {
+		defer func() { ended <- struct{}{} }()
+		for {
+			select {
+			case event := <-events:
+				if err := send(event); err != nil {
+					return err
+				}
+			case <-done:
+				return nil
+			}
+		}
+	})
+
+	if err := server.Listen(); err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server, socketPath
+}
+
+func TestControlCommands(t *testing.T) {
+	_, socketPath := newTestServer(t, nil, nil)
+
+	tests := []struct {
+		name       string
+		req        Request
+		wantErr    string // Substring of the error, if the command fails
+		wantPaused bool   // Whether status reports the daemon paused afterwards
+	}{
+		{name: "status", req: Request{Command: CommandStatus}},
+		{name: "pause", req: Request{Command: CommandPause}, wantPaused: true},
+		{name: "paused status", req: Request{Command: CommandStatus}, wantPaused: true},
+		{name: "resume", req: Request{Command: CommandResume}},
+		{name: "flush file", req: Request{Command: CommandFlushFile, Path: "/repo/main.go"}},
+		{name: "flush file without a chunk", req: Request{Command: CommandFlushFile, Path: "/repo/other.go"}, wantErr: "no active chunk for /repo/other.go"},
+		{name: "unknown command", req: Request{Command: "rewind"}, wantErr: `unknown command "rewind"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data json.RawMessage
+			err := Send(socketPath, tt.req, &data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Send(%s) error = %v, want one containing %q", tt.req.Command, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Send(%s) error = %v", tt.req.Command, err)
+			}
+
+			if tt.req.Command == CommandFlushFile {
+				var flushed []FlushedChunk
+				if err := json.Unmarshal(data, &flushed); err != nil || len(flushed) != 1 || flushed[0].FilePath != tt.req.Path {
+					t.Errorf("flush-file answered %s, want the chunk of %s", data, tt.req.Path)
+				}
+			}
+
+			var status Status
+			if err := Call(socketPath, CommandStatus, &status); err != nil {
+				t.Fatalf("Call(status) error = %v", err)
+			}
+			if status.PID != 42 || status.WatchDir != "/repo" || status.Paused != tt.wantPaused {
+				t.Errorf("status = %+v, want pid 42 watching /repo with paused %v", status, tt.wantPaused)
+			}
+		})
+	}
+}
+
+func TestControlInvalidRequest(t *testing.T) {
+	_, socketPath := newTestServer(t, nil, nil)
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintln(conn, "not json")
+
+	var resp Response
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &resp)
+	}
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	if resp.OK || !strings.HasPrefix(resp.Error, "invalid request") {
+		t.Errorf("response = %+v, want an invalid request error", resp)
+	}
+}
+
+func TestControlNotRunning(t *testing.T) {
+	err := Call(filepath.Join(t.TempDir(), "control.sock"), CommandStatus, nil)
+	if !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Call() error = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestControlEvents(t *testing.T) {
+	events := make(chan string)
+	ended := make(chan struct{}, 1)
+	server, socketPath := newTestServer(t, events, ended)
+
+	// Values arrive in order until the client disconnects
+	stream, err := Subscribe(socketPath, Request{Command: CommandEvents})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	for _, want := range []string{"started", "flushed"} {
+		events <- want
+		var got string
+		if err := stream.Next(&got); err != nil || got != want {
+			t.Fatalf("Next() = %q, %v, want %q", got, err, want)
+		}
+	}
+	stream.Close()
+	waitEnded(t, ended, "the client disconnected")
+
+	// Shutting the server down ends the stream too
+	stream, err = Subscribe(socketPath, Request{Command: CommandEvents})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer stream.Close()
+	server.Close()
+	waitEnded(t, ended, "the server closed")
+	var got string
+	if err := stream.Next(&got); err == nil {
+		t.Errorf("Next() after the server closed = %q, want an error", got)
+	}
+}
+
+// waitEnded waits for the stream handler to report that it returned after what happened.
+func waitEnded(t *testing.T, ended <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ended:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("stream still running after %s", what)
+	}
+}
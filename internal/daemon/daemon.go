@@ -1,25 +1,32 @@
 package daemon
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
-// Daemon manages a background process with PID file
+// stopTimeout is how long Stop waits for the daemon process to exit
+const stopTimeout = 10 * time.Second
+
+// Daemon manages a background process with PID file and control socket
 type Daemon struct {
-	pidFile string
-	logFile string
+	pidFile    string
+	logFile    string
+	socketFile string
 }
 
 // New creates a new daemon manager
-func New(pidFile, logFile string) *Daemon {
+func New(pidFile, logFile, socketFile string) *Daemon {
 	return &Daemon{
-		pidFile: pidFile,
-		logFile: logFile,
+		pidFile:    pidFile,
+		logFile:    logFile,
+		socketFile: socketFile,
 	}
 }
 
@@ -59,6 +66,31 @@ func (d *Daemon) RemovePID() error {
 	return os.Remove(d.pidFile)
 }
 
+// ReleasePID removes the PID file if it still holds the current process's PID,
+// so a daemon shutting down never removes the PID file of its successor
+func (d *Daemon) ReleasePID() error {
+	pid, err := d.ReadPID()
+	if err != nil || pid != os.Getpid() {
+		return nil
+	}
+	return d.RemovePID()
+}
+
+// Call sends a command to the running daemon over its control socket and decodes the result
+func (d *Daemon) Call(cmd Command, result interface{}) error {
+	return Call(d.socketFile, cmd, result)
+}
+
+// Send sends a complete request to the running daemon and decodes the result
+func (d *Daemon) Send(req Request, result interface{}) error {
+	return Send(d.socketFile, req, result)
+}
+
+// Subscribe sends a streaming request to the running daemon and returns the stream of its answers
+func (d *Daemon) Subscribe(req Request) (*Stream, error) {
+	return Subscribe(d.socketFile, req)
+}
+
 // Start starts the daemon in background mode
 func (d *Daemon) Start(args []string) error {
 	if d.IsRunning() {
@@ -89,23 +121,44 @@ func (d *Daemon) Start(args []string) error {
 	return startProcess(cmd, logFile)
 }
 
-// Stop stops the running daemon
-func (d *Daemon) Stop() error {
+// Stop asks the running daemon to flush its chunks and shut down, falling back to a
+// termination signal if it doesn't answer on its control socket. It waits for the process
+// to exit and returns the chunks flushed on the way out (nil after the fallback).
+func (d *Daemon) Stop() ([]FlushedChunk, error) {
 	pid, err := d.ReadPID()
 	if err != nil {
-		return fmt.Errorf("daemon is not running or PID file not found: %w", err)
+		return nil, fmt.Errorf("daemon is not running or PID file not found: %w", err)
 	}
 
-	if err := stopProcess(pid); err != nil {
-		return err
+	var flushed []FlushedChunk
+	if err := d.Call(CommandStop, &flushed); err != nil {
+		if !errors.Is(err, ErrNotRunning) {
+			return nil, err
+		}
+		if err := stopProcess(pid); err != nil {
+			return nil, err
+		}
 	}
 
-	// Remove PID file
-	if err := d.RemovePID(); err != nil {
-		return fmt.Errorf("failed to remove PID file: %w", err)
+	deadline := time.Now().Add(stopTimeout)
+	for isProcessRunning(pid) {
+		if time.Now().After(deadline) {
+			return flushed, fmt.Errorf("daemon (PID %d) did not exit within %s", pid, stopTimeout)
+		}
+		time.Sleep(50 * time.Millisecond)
 	}
 
-	return nil
+	// The daemon removes its own PID file; clean up if it couldn't
+	if err := d.RemovePID(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return flushed, fmt.Errorf("failed to remove PID file: %w", err)
+	}
+
+	return flushed, nil
+}
+
+// GetSocketPath returns the path to the control socket
+func (d *Daemon) GetSocketPath() string {
+	return d.socketFile
 }
 
 // GetLogPath returns the path to the log file
@@ -0,0 +1,358 @@
+// Package deps finds dependencies between chunks: a chunk depends on an earlier chunk
+// when it edits lines that chunk changed, or when it uses a symbol that chunk introduced.
+// Dependencies decide which chunks have to be committed or reverted together.
+package deps
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"carya/internal/chunk"
+)
+
+// Kind describes why one chunk depends on another.
+type Kind string
+
+const (
+	// KindOverlap means the chunk edits lines (or the context around lines) an earlier
+	// chunk changed, so its hunks only apply on top of that chunk.
+	KindOverlap Kind = "overlap"
+	// KindSymbol means the chunk uses a symbol declared by an earlier chunk. The hunks
+	// apply without it, but the result probably won't build.
+	KindSymbol Kind = "symbol"
+)
+
+// Edge records that Chunk depends on DependsOn.
+type Edge struct {
+	Chunk     chunk.ChunkID // The dependent (later) chunk
+	DependsOn chunk.ChunkID // The prerequisite (earlier) chunk
+	Kind      Kind          // Why the dependency exists
+	Detail    string        // Human-readable detail, e.g. the overlapping lines or shared symbols
+}
+
+// String returns a short description of the edge's reason.
+func (e Edge) String() string {
+	if e.Detail == "" {
+		return string(e.Kind)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Detail)
+}
+
+// lineRange is a half-open range of 1-based line numbers.
+type lineRange struct {
+	start, end int
+}
+
+// Analyze returns the dependencies of c on the earlier chunks. Earlier chunks may be
+// given in any order and may repeat; c itself and chunks that ended after it are ignored.
+func Analyze(c chunk.Chunk, earlier []chunk.Chunk) []Edge {
+	hunks, err := chunk.ParseHunks(c.Diff)
+	if err != nil {
+		return nil
+	}
+
+	var candidates []chunk.Chunk
+	seen := make(map[chunk.ChunkID]bool)
+	for _, e := range earlier {
+		if e.ID == c.ID || e.EndTime.After(c.EndTime) || seen[e.ID] {
+			continue
+		}
+		seen[e.ID] = true
+		candidates = append(candidates, e)
+	}
+
+	// Newest first, so line ranges can be traced back through each earlier chunk in turn
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].EndTime.After(candidates[j].EndTime)
+	})
+
+	var edges []Edge
+	linked := make(map[chunk.ChunkID]bool)
+	add := func(found []Edge) {
+		for _, e := range found {
+			if !linked[e.DependsOn] {
+				linked[e.DependsOn] = true
+				edges = append(edges, e)
+			}
+		}
+	}
+
+	add(wholeFileEdges(c, candidates))
+	if len(hunks) > 0 {
+		add(overlapEdges(c, hunks, candidates))
+		add(symbolEdges(c, hunks, candidates))
+	}
+
+	return edges
+}
+
+// wholeFileEdges links a chunk that deletes or renames a file to the latest earlier chunk
+// of that file, which has to be in place for the file to be removed or moved.
+func wholeFileEdges(c chunk.Chunk, candidates []chunk.Chunk) []Edge {
+	path, detail := c.FilePath, "deletes the file"
+	switch c.Op {
+	case chunk.OpDelete:
+	case chunk.OpRename:
+		path, detail = c.OldPath, "renames the file"
+	default:
+		return nil
+	}
+
+	for _, prev := range candidates {
+		if prev.FilePath == path && prev.Op != chunk.OpDelete {
+			return []Edge{{Chunk: c.ID, DependsOn: prev.ID, Kind: KindOverlap, Detail: detail}}
+		}
+		if prev.FilePath == path || prev.OldPath == path {
+			// Deleted or moved away before, so the file c removes came from elsewhere
+			return nil
+		}
+	}
+	return nil
+}
+
+// overlapEdges traces the lines c's hunks touch back through the earlier chunks of the
+// same file, linking c to every chunk that changed one of those lines.
+func overlapEdges(c chunk.Chunk, hunks []chunk.Hunk, candidates []chunk.Chunk) []Edge {
+	var ranges []lineRange
+	for _, h := range hunks {
+		end := h.OldStart + h.OldLines
+		if h.OldLines == 0 {
+			// Pure insertion after line OldStart: it depends on the line it follows
+			end = h.OldStart + 1
+		}
+		ranges = append(ranges, lineRange{start: h.OldStart, end: end})
+	}
+
+	// The lines before c's change belong to the file at its old path if c renamed it
+	path := c.FilePath
+	if c.Op == chunk.OpRename {
+		path = c.OldPath
+	}
+
+	var edges []Edge
+	for _, prev := range candidates {
+		if len(ranges) == 0 {
+			break
+		}
+		if prev.FilePath != path {
+			continue
+		}
+		if prev.Op == chunk.OpDelete {
+			// The file didn't exist before c's change, so there is nothing further back
+			break
+		}
+		prevHunks, err := chunk.ParseHunks(prev.Diff)
+		if err != nil {
+			// Without hunks the line numbers can no longer be traced
+			break
+		}
+
+		touched := changedLines(prevHunks)
+		var remaining []lineRange
+		var overlapping []string
+		for _, r := range ranges {
+			if intersects(r, touched) {
+				overlapping = append(overlapping, formatRange(r))
+				continue
+			}
+			// Lines that prev didn't change are traced further back; lines it did change
+			// are covered by prev's own dependencies
+			remaining = append(remaining, mapToOld(r, prevHunks))
+		}
+
+		if len(overlapping) > 0 {
+			edges = append(edges, Edge{
+				Chunk:     c.ID,
+				DependsOn: prev.ID,
+				Kind:      KindOverlap,
+				Detail:    "lines " + strings.Join(overlapping, ", "),
+			})
+		}
+		ranges = remaining
+
+		if prev.Op == chunk.OpRename {
+			path = prev.OldPath
+		}
+	}
+
+	return edges
+}
+
+// changedLines returns the lines in the new version of the file that the hunks added,
+// plus the lines on both sides of each deletion.
+func changedLines(hunks []chunk.Hunk) map[int]bool {
+	touched := make(map[int]bool)
+	for _, h := range hunks {
+		newLine := h.NewStart
+		if h.NewLines == 0 {
+			newLine++
+		}
+		for _, line := range h.Lines {
+			if line == "" {
+				continue
+			}
+			switch line[0] {
+			case '+':
+				touched[newLine] = true
+				newLine++
+			case '-':
+				touched[newLine-1] = true
+				touched[newLine] = true
+			case ' ':
+				newLine++
+			}
+		}
+	}
+	return touched
+}
+
+// mapToOld converts a range of lines in the new version of a file to the version before
+// the hunks were applied. The range must not contain any line the hunks changed.
+func mapToOld(r lineRange, hunks []chunk.Hunk) lineRange {
+	delta := 0
+	for _, h := range hunks {
+		newLine := h.NewStart
+		if h.NewLines == 0 {
+			newLine++
+		}
+		for _, line := range h.Lines {
+			if line == "" {
+				continue
+			}
+			switch line[0] {
+			case '+':
+				if newLine < r.start {
+					delta++
+				}
+				newLine++
+			case '-':
+				if newLine <= r.start {
+					delta--
+				}
+			case ' ':
+				newLine++
+			}
+		}
+	}
+	return lineRange{start: r.start - delta, end: r.end - delta}
+}
+
+// intersects reports whether any line in r is in lines.
+func intersects(r lineRange, lines map[int]bool) bool {
+	for line := r.start; line < r.end; line++ {
+		if lines[line] {
+			return true
+		}
+	}
+	return false
+}
+
+// formatRange formats a line range for display.
+func formatRange(r lineRange) string {
+	if r.end-r.start <= 1 {
+		return fmt.Sprintf("%d", r.start)
+	}
+	return fmt.Sprintf("%d-%d", r.start, r.end-1)
+}
+
+var (
+	// declarationPatterns match lines declaring a named symbol in common languages
+	declarationPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^\s*func\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^\s*(?:type|var|const|let)\s+([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^\s*(?:(?:export|pub|public|private|protected|static|async|abstract)\s+)*(?:def|class|function|fn|struct|enum|interface|trait)\s+([A-Za-z_]\w*)`),
+	}
+	identifierPattern = regexp.MustCompile(`[A-Za-z_]\w*`)
+)
+
+// ignoredSymbols are declared so often that sharing one says nothing about a dependency
+var ignoredSymbols = map[string]bool{"main": true, "init": true, "new": true, "err": true}
+
+// symbolEdges links c to the most recent earlier chunk introducing each symbol c's added lines use.
+func symbolEdges(c chunk.Chunk, hunks []chunk.Hunk, candidates []chunk.Chunk) []Edge {
+	used := make(map[string]bool)
+	for _, line := range hunkLines(hunks, '+') {
+		for _, ident := range identifierPattern.FindAllString(line, -1) {
+			used[ident] = true
+		}
+	}
+	for symbol := range introducedSymbols(hunks) {
+		delete(used, symbol)
+	}
+	if len(used) == 0 {
+		return nil
+	}
+
+	symbolsByChunk := make(map[chunk.ChunkID][]string)
+	var order []chunk.ChunkID
+	for _, prev := range candidates {
+		prevHunks, err := chunk.ParseHunks(prev.Diff)
+		if err != nil {
+			continue
+		}
+		for symbol := range introducedSymbols(prevHunks) {
+			if !used[symbol] {
+				continue
+			}
+			// Candidates are newest first, so the first chunk found introduced the version in use
+			delete(used, symbol)
+			if symbolsByChunk[prev.ID] == nil {
+				order = append(order, prev.ID)
+			}
+			symbolsByChunk[prev.ID] = append(symbolsByChunk[prev.ID], symbol)
+		}
+	}
+
+	var edges []Edge
+	for _, id := range order {
+		symbols := symbolsByChunk[id]
+		sort.Strings(symbols)
+		edges = append(edges, Edge{
+			Chunk:     c.ID,
+			DependsOn: id,
+			Kind:      KindSymbol,
+			Detail:    strings.Join(symbols, ", "),
+		})
+	}
+	return edges
+}
+
+// introducedSymbols returns the symbols declared in added lines but not in removed lines.
+func introducedSymbols(hunks []chunk.Hunk) map[string]bool {
+	symbols := declaredSymbols(hunkLines(hunks, '+'))
+	for symbol := range declaredSymbols(hunkLines(hunks, '-')) {
+		delete(symbols, symbol)
+	}
+	return symbols
+}
+
+// declaredSymbols returns the symbols declared by the given lines.
+func declaredSymbols(lines []string) map[string]bool {
+	symbols := make(map[string]bool)
+	for _, line := range lines {
+		for _, pattern := range declarationPatterns {
+			if m := pattern.FindStringSubmatch(line); m != nil {
+				if len(m[1]) >= 3 && !ignoredSymbols[strings.ToLower(m[1])] {
+					symbols[m[1]] = true
+				}
+				break
+			}
+		}
+	}
+	return symbols
+}
+
+// hunkLines returns the content of the hunk lines with the given prefix ('+' or '-').
+func hunkLines(hunks []chunk.Hunk, prefix byte) []string {
+	var lines []string
+	for _, h := range hunks {
+		for _, line := range h.Lines {
+			if len(line) > 0 && line[0] == prefix {
+				lines = append(lines, line[1:])
+			}
+		}
+	}
+	return lines
+}
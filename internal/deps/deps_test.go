@@ -0,0 +1,176 @@
+package deps
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"carya/internal/chunk"
+)
+
+// numbered returns a file of n lines holding their line numbers, with the given lines replaced.
+func numbered(n int, replaced map[int]string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		line, ok := replaced[i]
+		if !ok {
+			line = fmt.Sprint(i)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// testChunk returns a chunk changing path from before to after, ending the given number
+// of minutes into the test.
+func testChunk(id string, op chunk.Op, oldPath, path, before, after string, minute int) chunk.Chunk {
+	end := time.Date(2024, 1, 1, 12, minute, 0, 0, time.UTC)
+	return chunk.Chunk{
+		ID:        chunk.ChunkID(id),
+		FilePath:  path,
+		OldPath:   oldPath,
+		Op:        op,
+		Diff:      chunk.UnifiedDiff(path, []byte(before), []byte(after), 1),
+		StartTime: end.Add(-time.Minute),
+		EndTime:   end,
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	file := numbered(20, nil)
+	fiveChanged := numbered(20, map[int]string{5: "five"})
+	inserted := "a\nb\nc\n" + file
+	tenChanged := numbered(20, map[int]string{10: "ten"})
+
+	tests := []struct {
+		name    string
+		c       chunk.Chunk
+		earlier []chunk.Chunk
+		want    []Edge
+	}{
+		{
+			name:    "edits a changed line",
+			c:       testChunk("b", chunk.OpModify, "", "f.txt", fiveChanged, numbered(20, map[int]string{5: "FIVE"}), 2),
+			earlier: []chunk.Chunk{testChunk("a", chunk.OpModify, "", "f.txt", file, fiveChanged, 1)},
+			want:    []Edge{{Chunk: "b", DependsOn: "a", Kind: KindOverlap, Detail: "lines 4-6"}},
+		},
+		{
+			name:    "edits a distant line",
+			c:       testChunk("b", chunk.OpModify, "", "f.txt", fiveChanged, numbered(20, map[int]string{5: "five", 15: "fifteen"}), 2),
+			earlier: []chunk.Chunk{testChunk("a", chunk.OpModify, "", "f.txt", file, fiveChanged, 1)},
+		},
+		{
+			name:    "edits another file",
+			c:       testChunk("b", chunk.OpModify, "", "g.txt", fiveChanged, numbered(20, map[int]string{5: "FIVE"}), 2),
+			earlier: []chunk.Chunk{testChunk("a", chunk.OpModify, "", "f.txt", file, fiveChanged, 1)},
+		},
+		{
+			name: "traced back through an insertion",
+			c:    testChunk("c", chunk.OpModify, "", "f.txt", "a\nb\nc\n"+tenChanged, "a\nb\nc\n"+numbered(20, map[int]string{10: "TEN"}), 3),
+			earlier: []chunk.Chunk{
+				testChunk("a", chunk.OpModify, "", "f.txt", file, tenChanged, 1),
+				testChunk("b", chunk.OpModify, "", "f.txt", tenChanged, "a\nb\nc\n"+tenChanged, 2),
+			},
+			want: []Edge{{Chunk: "c", DependsOn: "a", Kind: KindOverlap, Detail: "lines 9-11"}},
+		},
+		{
+			name: "insertion right after a changed line",
+			c:    testChunk("b", chunk.OpModify, "", "f.txt", inserted, "a\nb\nc\nd\n"+file, 2),
+			earlier: []chunk.Chunk{
+				testChunk("a", chunk.OpModify, "", "f.txt", file, inserted, 1),
+			},
+			want: []Edge{{Chunk: "b", DependsOn: "a", Kind: KindOverlap, Detail: "lines 3-4"}},
+		},
+		{
+			name:    "later chunks are ignored",
+			c:       testChunk("b", chunk.OpModify, "", "f.txt", fiveChanged, numbered(20, map[int]string{5: "FIVE"}), 1),
+			earlier: []chunk.Chunk{testChunk("a", chunk.OpModify, "", "f.txt", file, fiveChanged, 2)},
+		},
+		{
+			name: "delete depends on the last chunk of the file",
+			c:    testChunk("c", chunk.OpDelete, "", "f.txt", "new\n", "", 3),
+			earlier: []chunk.Chunk{
+				testChunk("a", chunk.OpModify, "", "g.txt", "x\n", "y\n", 1),
+				testChunk("b", chunk.OpCreate, "", "f.txt", "", "new\n", 2),
+			},
+			want: []Edge{{Chunk: "c", DependsOn: "b", Kind: KindOverlap, Detail: "deletes the file"}},
+		},
+		{
+			name: "recreated file doesn't depend on the deleted one",
+			c:    testChunk("c", chunk.OpModify, "", "f.txt", "new\n", "newer\n", 3),
+			earlier: []chunk.Chunk{
+				testChunk("a", chunk.OpModify, "", "f.txt", "old\n", "older\n", 1),
+				testChunk("b", chunk.OpDelete, "", "f.txt", "older\n", "", 2),
+			},
+		},
+		{
+			name: "rename depends on the last chunk of the old path",
+			c:    testChunk("b", chunk.OpRename, "old.txt", "new.txt", "content\n", "content\n", 2),
+			earlier: []chunk.Chunk{
+				testChunk("a", chunk.OpModify, "", "old.txt", "text\n", "content\n", 1),
+			},
+			want: []Edge{{Chunk: "b", DependsOn: "a", Kind: KindOverlap, Detail: "renames the file"}},
+		},
+		{
+			name: "edit after a rename is traced to the old path",
+			c:    testChunk("c", chunk.OpModify, "", "new.txt", fiveChanged, numbered(20, map[int]string{5: "FIVE"}), 3),
+			earlier: []chunk.Chunk{
+				testChunk("a", chunk.OpModify, "", "old.txt", file, fiveChanged, 1),
+				testChunk("b", chunk.OpRename, "old.txt", "new.txt", fiveChanged, fiveChanged, 2),
+			},
+			want: []Edge{{Chunk: "c", DependsOn: "a", Kind: KindOverlap, Detail: "lines 4-6"}},
+		},
+		{
+			name: "uses a symbol",
+			c:    testChunk("b", chunk.OpModify, "", "main.go", "package main\n", "package main\n\nvar x = parseThing()\n", 2),
+			earlier: []chunk.Chunk{
+				testChunk("a", chunk.OpModify, "", "util.go", "package main\n", "package main\n\nfunc parseThing() int { return 1 }\n", 1),
+			},
+			want: []Edge{{Chunk: "b", DependsOn: "a", Kind: KindSymbol, Detail: "parseThing"}},
+		},
+		{
+			name: "uses the latest declaration of a symbol",
+			c:    testChunk("c", chunk.OpModify, "", "main.go", "package main\n", "package main\n\nvar x Widget\n", 3),
+			earlier: []chunk.Chunk{
+				testChunk("a", chunk.OpModify, "", "a.go", "package main\n", "package main\n\ntype Widget int\n", 1),
+				testChunk("b", chunk.OpModify, "", "b.go", "package main\n", "package main\n\ntype Widget struct{}\n", 2),
+			},
+			want: []Edge{{Chunk: "c", DependsOn: "b", Kind: KindSymbol, Detail: "Widget"}},
+		},
+		{
+			name: "common and short symbols are ignored",
+			c:    testChunk("b", chunk.OpModify, "", "main.go", "package main\n", "package main\n\nvar y = ab() + main()\n", 2),
+			earlier: []chunk.Chunk{
+				testChunk("a", chunk.OpModify, "", "a.go", "package main\n", "package main\n\nfunc ab() int { return 1 }\nfunc main() {}\n", 1),
+			},
+		},
+		{
+			name: "symbol declared by the chunk itself",
+			c:    testChunk("b", chunk.OpModify, "", "main.go", "package main\n", "package main\n\nfunc helper() {}\n\nvar z = helper\n", 2),
+			earlier: []chunk.Chunk{
+				testChunk("a", chunk.OpModify, "", "a.go", "package main\n", "package main\n\nfunc helper() {}\n", 1),
+			},
+		},
+		{
+			name: "overlap and symbol on the same chunk make one edge",
+			c:    testChunk("b", chunk.OpModify, "", "a.go", "package main\n\nfunc helperFunc() {}\n", "package main\n\nfunc helperFunc() {}\n\nvar v = helperFunc\n", 2),
+			earlier: []chunk.Chunk{
+				testChunk("a", chunk.OpModify, "", "a.go", "package main\n", "package main\n\nfunc helperFunc() {}\n", 1),
+			},
+			want: []Edge{{Chunk: "b", DependsOn: "a", Kind: KindOverlap, Detail: "lines 3"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Earlier chunks may repeat, and c itself is skipped
+			earlier := append(append([]chunk.Chunk{tt.c}, tt.earlier...), tt.earlier...)
+			got := Analyze(tt.c, earlier)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package deps
+
+import (
+	"fmt"
+	"sort"
+
+	"carya/internal/chunk"
+)
+
+// recentWindow is how many recent chunks are searched for symbols a new chunk uses.
+const recentWindow = 500
+
+// Graph provides the recorded dependency edges.
+type Graph interface {
+	// FindDependencies retrieves the edges from a chunk to the chunks it depends on.
+	FindDependencies(id chunk.ChunkID) ([]Edge, error)
+	// FindDependents retrieves the edges from chunks that depend on the given chunk.
+	FindDependents(id chunk.ChunkID) ([]Edge, error)
+}
+
+// Store persists chunks and the dependency edges between them.
+type Store interface {
+	Graph
+	// FindChunks retrieves all chunks for a specific file path.
+	FindChunks(filePath string) ([]chunk.Chunk, error)
+	// GetRecentChunks retrieves the most recently created chunks up to the specified limit.
+	GetRecentChunks(limit int) ([]chunk.Chunk, error)
+	// SaveDependencies replaces the recorded dependencies of a chunk.
+	SaveDependencies(id chunk.ChunkID, edges []Edge) error
+}
+
+// Tracker records the dependencies of chunks as they are saved. It implements chunk.Linker.
+type Tracker struct {
+	store Store // Storage backend for chunks and their dependencies
+}
+
+// NewTracker creates a new tracker backed by the given store.
+func NewTracker(store Store) *Tracker {
+	return &Tracker{store: store}
+}
+
+// LinkChunk analyzes a saved chunk against the earlier chunks in the store and records its dependencies.
+func (t *Tracker) LinkChunk(c chunk.Chunk) error {
+	sameFile, err := t.store.FindChunks(c.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to load chunks for %s: %w", c.FilePath, err)
+	}
+	if c.OldPath != "" {
+		oldFile, err := t.store.FindChunks(c.OldPath)
+		if err != nil {
+			return fmt.Errorf("failed to load chunks for %s: %w", c.OldPath, err)
+		}
+		sameFile = append(sameFile, oldFile...)
+	}
+	recent, err := t.store.GetRecentChunks(recentWindow)
+	if err != nil {
+		return fmt.Errorf("failed to load recent chunks: %w", err)
+	}
+
+	return t.store.SaveDependencies(c.ID, Analyze(c, append(sameFile, recent...)))
+}
+
+// ChunkGraph provides the recorded dependency edges and the chunks they connect.
+type ChunkGraph interface {
+	Graph
+	// GetChunk retrieves a chunk by its ID.
+	GetChunk(id chunk.ChunkID) (*chunk.Chunk, error)
+}
+
+// Prerequisites returns the edges to every chunk the given chunks depend on, directly or
+// transitively, that is not itself one of the given chunks.
+func Prerequisites(g Graph, ids []chunk.ChunkID) ([]Edge, error) {
+	return walk(ids, g.FindDependencies, func(e Edge) chunk.ChunkID { return e.DependsOn }, nil)
+}
+
+// UncommittedPrerequisites is like Prerequisites, but leaves out chunks that are already in
+// a git commit, and the chunks reached only through them: their changes are in HEAD, so
+// they don't need to be staged again.
+func UncommittedPrerequisites(g ChunkGraph, ids []chunk.ChunkID) ([]Edge, error) {
+	committed := func(id chunk.ChunkID) bool {
+		c, err := g.GetChunk(id)
+		// A chunk that can no longer be loaded is still reported as missing
+		return err == nil && c.Committed != ""
+	}
+	return walk(ids, g.FindDependencies, func(e Edge) chunk.ChunkID { return e.DependsOn }, committed)
+}
+
+// Dependents returns the edges from every chunk that depends on the given chunks, directly
+// or transitively, that is not itself one of the given chunks.
+func Dependents(g Graph, ids []chunk.ChunkID) ([]Edge, error) {
+	return walk(ids, g.FindDependents, func(e Edge) chunk.ChunkID { return e.Chunk }, nil)
+}
+
+// walk follows edges breadth-first from the given chunks, returning the edges that lead
+// outside the starting set. Each chunk reached is reported once. Chunks for which skip
+// returns true are neither reported nor walked through; a nil skip keeps every chunk.
+func walk(ids []chunk.ChunkID, next func(chunk.ChunkID) ([]Edge, error), target func(Edge) chunk.ChunkID,
+	skip func(chunk.ChunkID) bool) ([]Edge, error) {
+	visited := make(map[chunk.ChunkID]bool)
+	for _, id := range ids {
+		visited[id] = true
+	}
+
+	var found []Edge
+	queue := append([]chunk.ChunkID(nil), ids...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		edges, err := next(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range edges {
+			t := target(e)
+			if visited[t] {
+				continue
+			}
+			visited[t] = true
+			if skip != nil && skip(t) {
+				continue
+			}
+			found = append(found, e)
+			queue = append(queue, t)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Kind < found[j].Kind })
+	return found, nil
+}
+
+// HasKind reports whether any of the edges is of the given kind.
+func HasKind(edges []Edge, kind Kind) bool {
+	for _, e := range edges {
+		if e.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
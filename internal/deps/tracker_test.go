@@ -0,0 +1,228 @@
+package deps
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"carya/internal/chunk"
+)
+
+// fakeGraph is an in-memory ChunkGraph and Store.
+type fakeGraph struct {
+	edges     []Edge
+	chunks    map[chunk.ChunkID]chunk.Chunk
+	committed map[chunk.ChunkID]bool
+	saved     map[chunk.ChunkID][]Edge
+}
+
+func (g *fakeGraph) FindDependencies(id chunk.ChunkID) ([]Edge, error) {
+	var found []Edge
+	for _, e := range g.edges {
+		if e.Chunk == id {
+			found = append(found, e)
+		}
+	}
+	return found, nil
+}
+
+func (g *fakeGraph) FindDependents(id chunk.ChunkID) ([]Edge, error) {
+	var found []Edge
+	for _, e := range g.edges {
+		if e.DependsOn == id {
+			found = append(found, e)
+		}
+	}
+	return found, nil
+}
+
+func (g *fakeGraph) GetChunk(id chunk.ChunkID) (*chunk.Chunk, error) {
+	c, ok := g.chunks[id]
+	if !ok && !g.committed[id] {
+		return nil, errors.New("chunk not found")
+	}
+	c.ID = id
+	if g.committed[id] {
+		c.Committed = "abc123"
+	}
+	return &c, nil
+}
+
+func (g *fakeGraph) FindChunks(filePath string) ([]chunk.Chunk, error) {
+	var found []chunk.Chunk
+	for _, c := range g.chunks {
+		if c.FilePath == filePath {
+			found = append(found, c)
+		}
+	}
+	return found, nil
+}
+
+func (g *fakeGraph) GetRecentChunks(limit int) ([]chunk.Chunk, error) {
+	var recent []chunk.Chunk
+	for _, c := range g.chunks {
+		recent = append(recent, c)
+	}
+	return recent, nil
+}
+
+func (g *fakeGraph) SaveDependencies(id chunk.ChunkID, edges []Edge) error {
+	if g.saved == nil {
+		g.saved = make(map[chunk.ChunkID][]Edge)
+	}
+	g.saved[id] = edges
+	return nil
+}
+
+// edgeIDs returns the chunks the edges lead to, sorted.
+func edgeIDs(edges []Edge, target func(Edge) chunk.ChunkID) []chunk.ChunkID {
+	ids := []chunk.ChunkID{}
+	for _, e := range edges {
+		ids = append(ids, target(e))
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestWalk(t *testing.T) {
+	// d depends on c and b, c on b, b on a (a symbol dependency), and e on d; f and g
+	// depend on each other
+	edges := []Edge{
+		{Chunk: "b", DependsOn: "a", Kind: KindSymbol},
+		{Chunk: "c", DependsOn: "b", Kind: KindOverlap},
+		{Chunk: "d", DependsOn: "c", Kind: KindOverlap},
+		{Chunk: "d", DependsOn: "b", Kind: KindOverlap},
+		{Chunk: "e", DependsOn: "d", Kind: KindOverlap},
+		{Chunk: "f", DependsOn: "g", Kind: KindOverlap},
+		{Chunk: "g", DependsOn: "f", Kind: KindOverlap},
+	}
+	dependsOn := func(e Edge) chunk.ChunkID { return e.DependsOn }
+	dependent := func(e Edge) chunk.ChunkID { return e.Chunk }
+
+	tests := []struct {
+		name      string
+		walk      func(g *fakeGraph, ids []chunk.ChunkID) ([]Edge, error)
+		target    func(Edge) chunk.ChunkID
+		ids       []chunk.ChunkID
+		committed []chunk.ChunkID
+		want      []chunk.ChunkID
+	}{
+		{
+			name:   "prerequisites",
+			walk:   func(g *fakeGraph, ids []chunk.ChunkID) ([]Edge, error) { return Prerequisites(g, ids) },
+			target: dependsOn,
+			ids:    []chunk.ChunkID{"d"},
+			want:   []chunk.ChunkID{"a", "b", "c"},
+		},
+		{
+			name:   "prerequisites leave out the given chunks",
+			walk:   func(g *fakeGraph, ids []chunk.ChunkID) ([]Edge, error) { return Prerequisites(g, ids) },
+			target: dependsOn,
+			ids:    []chunk.ChunkID{"d", "b"},
+			want:   []chunk.ChunkID{"a", "c"},
+		},
+		{
+			name:   "no prerequisites",
+			walk:   func(g *fakeGraph, ids []chunk.ChunkID) ([]Edge, error) { return Prerequisites(g, ids) },
+			target: dependsOn,
+			ids:    []chunk.ChunkID{"a"},
+			want:   []chunk.ChunkID{},
+		},
+		{
+			name:   "cycle",
+			walk:   func(g *fakeGraph, ids []chunk.ChunkID) ([]Edge, error) { return Prerequisites(g, ids) },
+			target: dependsOn,
+			ids:    []chunk.ChunkID{"f"},
+			want:   []chunk.ChunkID{"g"},
+		},
+		{
+			name:   "dependents",
+			walk:   func(g *fakeGraph, ids []chunk.ChunkID) ([]Edge, error) { return Dependents(g, ids) },
+			target: dependent,
+			ids:    []chunk.ChunkID{"b"},
+			want:   []chunk.ChunkID{"c", "d", "e"},
+		},
+		{
+			name:      "uncommitted prerequisites",
+			walk:      func(g *fakeGraph, ids []chunk.ChunkID) ([]Edge, error) { return UncommittedPrerequisites(g, ids) },
+			target:    dependsOn,
+			ids:       []chunk.ChunkID{"e"},
+			committed: []chunk.ChunkID{"b"},
+			want:      []chunk.ChunkID{"c", "d"},
+		},
+		{
+			name:      "committed chunks hide the chunks behind them",
+			walk:      func(g *fakeGraph, ids []chunk.ChunkID) ([]Edge, error) { return UncommittedPrerequisites(g, ids) },
+			target:    dependsOn,
+			ids:       []chunk.ChunkID{"e"},
+			committed: []chunk.ChunkID{"d"},
+			want:      []chunk.ChunkID{},
+		},
+		{
+			name:   "missing chunks are still prerequisites",
+			walk:   func(g *fakeGraph, ids []chunk.ChunkID) ([]Edge, error) { return UncommittedPrerequisites(g, ids) },
+			target: dependsOn,
+			ids:    []chunk.ChunkID{"c"},
+			want:   []chunk.ChunkID{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &fakeGraph{edges: edges, chunks: map[chunk.ChunkID]chunk.Chunk{}, committed: map[chunk.ChunkID]bool{}}
+			for _, id := range []chunk.ChunkID{"b", "c", "d", "e"} {
+				g.chunks[id] = chunk.Chunk{ID: id}
+			}
+			for _, id := range tt.committed {
+				g.committed[id] = true
+			}
+
+			found, err := tt.walk(g, tt.ids)
+			if err != nil {
+				t.Fatalf("walk error = %v", err)
+			}
+			if got := edgeIDs(found, tt.target); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("walk from %v = %v, want %v", tt.ids, got, tt.want)
+			}
+			// Overlaps sort before symbol dependencies
+			for i := 1; i < len(found); i++ {
+				if found[i-1].Kind > found[i].Kind {
+					t.Errorf("edges %+v are not sorted by kind", found)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestHasKind(t *testing.T) {
+	edges := []Edge{{Kind: KindSymbol}}
+	if !HasKind(edges, KindSymbol) {
+		t.Error("HasKind(symbol) = false")
+	}
+	if HasKind(edges, KindOverlap) || HasKind(nil, KindOverlap) {
+		t.Error("HasKind(overlap) = true")
+	}
+}
+
+func TestTrackerLinkChunk(t *testing.T) {
+	file := numbered(10, nil)
+	fourChanged := numbered(10, map[int]string{4: "four"})
+	g := &fakeGraph{chunks: map[chunk.ChunkID]chunk.Chunk{
+		"a": testChunk("a", chunk.OpModify, "", "old.txt", file, fourChanged, 1),
+		"b": testChunk("b", chunk.OpModify, "", "other.txt", file, fourChanged, 2),
+	}}
+
+	// The renamed file's history is found under its old path
+	c := testChunk("c", chunk.OpRename, "old.txt", "new.txt", fourChanged, numbered(10, map[int]string{4: "FOUR"}), 3)
+	g.chunks["c"] = c
+	if err := NewTracker(g).LinkChunk(c); err != nil {
+		t.Fatalf("LinkChunk() error = %v", err)
+	}
+
+	want := []Edge{{Chunk: "c", DependsOn: "a", Kind: KindOverlap, Detail: "renames the file"}}
+	if got := g.saved["c"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("saved dependencies = %+v, want %+v", got, want)
+	}
+}
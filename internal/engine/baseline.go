@@ -0,0 +1,37 @@
+package engine
+
+import (
+	"carya/internal/chunk"
+	"carya/internal/git"
+)
+
+// storeBaseline supplies the content a file had before its latest change, taken from
+// the most recent saved chunk for the file or, failing that, from the git index.
+type storeBaseline struct {
+	store chunk.ChunkStore // Storage backend holding previous chunk snapshots
+	index *git.IndexReader // Reads the git index of the work tree (nil runs git show for each file)
+}
+
+// Baseline returns the last known content of the file at path. A file whose latest
+// chunk deleted it or moved it away is known to be empty.
+func (b *storeBaseline) Baseline(path string) ([]byte, bool) {
+	if chunks, err := b.store.FindChunks(path); err == nil && len(chunks) > 0 {
+		latest := chunks[0]
+		if latest.Op == chunk.OpDelete || (latest.Op == chunk.OpRename && latest.OldPath == path) {
+			return []byte{}, true
+		}
+		if full, err := b.store.GetChunk(latest.ID); err == nil && full.After != nil {
+			return full.After, true
+		}
+	}
+
+	if b.index != nil {
+		if content, err := b.index.Content(path); err == nil {
+			return content, true
+		}
+	} else if content, err := git.IndexContent(path); err == nil {
+		return content, true
+	}
+
+	return nil, false
+}
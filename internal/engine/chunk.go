@@ -4,6 +4,9 @@ package engine
 
 import (
 	"carya/internal/chunk"
+	"carya/internal/deps"
+	"carya/internal/git"
+	"carya/internal/retention"
 	"carya/internal/store"
 	"log"
 	"time"
@@ -12,39 +15,71 @@ import (
 // Engine is the main coordination component of Carya that manages chunk creation,
 // storage, and file change processing.
 type Engine struct {
-	chunkManager *chunk.Manager   // Manages chunk lifecycle and creation
-	store        chunk.ChunkStore // Storage backend for chunks
+	chunkManager *chunk.Manager         // Manages chunk lifecycle and creation
+	strategy     *chunk.UnifiedStrategy // Groups file changes into the manager's chunks
+	baseline     *storeBaseline         // Supplies the strategy with file contents before their first change
+	store        chunk.ChunkStore       // Storage backend for chunks
+	heads        *git.HeadTracker       // Follows the git HEAD recorded on chunks (nil until TrackHead)
+	events       *Bus                   // Publishes chunk activity to subscribers
 }
 
-// SimpleEventEmitter provides basic logging-based event emission for chunk events.
-type SimpleEventEmitter struct{}
+// Options configures the chunking of an Engine.
+type Options struct {
+	Strategy chunk.StrategyOptions // When chunks are cut and how their diffs are written
+	Manager  chunk.ManagerOptions  // When stale chunks are flushed
+}
 
-// EmitChunkCreated logs when a new chunk is created.
-func (e *SimpleEventEmitter) EmitChunkCreated(c chunk.Chunk) {
-	log.Printf("Chunk created: %s for file %s", c.ID, c.FilePath)
+// DefaultOptions returns the options used by NewEngine.
+func DefaultOptions() Options {
+	return Options{
+		Strategy: chunk.DefaultStrategyOptions(),
+		Manager:  chunk.DefaultManagerOptions(),
+	}
 }
 
-// EmitChunkFlushed logs when chunks are flushed to storage.
-func (e *SimpleEventEmitter) EmitChunkFlushed(chunks []chunk.Chunk) {
-	log.Printf("Flushed %d chunks", len(chunks))
+// NewEngine creates a new Carya engine storing chunks in chunkStore.
+// It initializes the chunk manager with a unified strategy that publishes its events on the engine's bus.
+func NewEngine(chunkStore store.Store) *Engine {
+	return NewEngineWithOptions(chunkStore, DefaultOptions())
 }
 
-// NewEngine creates a new Carya engine with SQLite storage at the specified path.
-// It initializes the chunk manager with a unified strategy and simple event emitter.
-func NewEngine(storePath string) (*Engine, error) {
-	chunkStore, err := store.NewSQLiteStore(storePath)
-	if err != nil {
-		return nil, err
+// NewEngineWithOptions creates a new Carya engine storing chunks in chunkStore, chunking
+// changes with the given options.
+func NewEngineWithOptions(chunkStore store.Store, opts Options) *Engine {
+	baseline := &storeBaseline{store: chunkStore}
+	if opts.Strategy.Root != "" {
+		// Baselines are looked up while the manager holds its lock, so avoid starting a
+		// git process for each of them
+		if index, err := git.NewIndexReader(opts.Strategy.Root); err == nil {
+			baseline.index = index
+		}
 	}
-
-	strategy := chunk.NewUnifiedStrategy()
-	emitter := &SimpleEventEmitter{}
-	manager := chunk.NewManager(strategy, chunkStore, emitter)
+	opts.Strategy.Baseline = baseline
+	strategy := chunk.NewUnifiedStrategyWithOptions(opts.Strategy)
+	events := NewBus()
+	manager := chunk.NewManagerWithOptions(strategy, chunkStore, &busEmitter{bus: events}, opts.Manager)
+	manager.SetTagger(chunkStore)
+	manager.SetLinker(deps.NewTracker(chunkStore))
 
 	return &Engine{
 		chunkManager: manager,
+		strategy:     strategy,
+		baseline:     baseline,
 		store:        chunkStore,
-	}, nil
+		events:       events,
+	}
+}
+
+// SetOptions changes how the running engine chunks changes. The strategy's baseline and
+// root are kept.
+func (e *Engine) SetOptions(opts Options) {
+	e.strategy.SetOptions(opts.Strategy)
+	e.chunkManager.SetOptions(opts.Manager)
+}
+
+// Events returns the bus on which the engine publishes chunk activity.
+func (e *Engine) Events() *Bus {
+	return e.events
 }
 
 // Start begins the engine's background processing, including chunk management.
@@ -54,7 +89,14 @@ func (e *Engine) Start() {
 
 // Stop gracefully shuts down the engine and all its components.
 func (e *Engine) Stop() {
+	if e.heads != nil {
+		e.heads.Stop()
+	}
 	e.chunkManager.Stop()
+	e.events.Close()
+	if e.baseline.index != nil {
+		e.baseline.index.Close()
+	}
 }
 
 // OnFileChange processes a file change event by creating a FileChangeEvent
@@ -64,17 +106,85 @@ func (e *Engine) OnFileChange(path string, contents []byte) {
 		Path:     path,
 		Contents: contents,
 		Time:     time.Now(),
+		Op:       chunk.OpModify,
 	}
 	e.chunkManager.OnFileChange(event)
 }
 
+// OnFileCreate processes the creation of a file, which may turn out to be the
+// second half of a rename.
+func (e *Engine) OnFileCreate(path string, contents []byte) {
+	e.chunkManager.OnFileChange(chunk.FileChangeEvent{
+		Path:     path,
+		Contents: contents,
+		Time:     time.Now(),
+		Op:       chunk.OpCreate,
+	})
+}
+
+// OnFileDelete processes the deletion of a file, which may turn out to be the
+// first half of a rename.
+func (e *Engine) OnFileDelete(path string) {
+	e.chunkManager.OnFileChange(chunk.FileChangeEvent{
+		Path: path,
+		Time: time.Now(),
+		Op:   chunk.OpDelete,
+	})
+}
+
 // ForceFlush immediately creates and saves a chunk for the specified file path.
 // Returns an error if the chunk cannot be created or saved.
 func (e *Engine) ForceFlush(filePath string) error {
 	return e.chunkManager.ForceFlush(filePath)
 }
 
-// FlushAll immediately flushes all active chunks to storage.
-func (e *Engine) FlushAll() error {
+// FlushAll immediately flushes all active chunks to storage and returns the saved chunks.
+func (e *Engine) FlushAll() ([]chunk.Chunk, error) {
 	return e.chunkManager.FlushAll()
 }
+
+// ActiveChunks summarizes the chunks still collecting changes.
+func (e *Engine) ActiveChunks() []chunk.ActiveChunkInfo {
+	return e.chunkManager.ActiveChunks()
+}
+
+// SetRetention applies a retention policy to the chunks of the git work tree at root.
+// If the policy enables automatic compaction, old chunks are compacted each time the
+// engine goes idle; otherwise automatic compaction is turned off.
+func (e *Engine) SetRetention(root string, policy retention.Policy) {
+	s, ok := e.store.(retention.Store)
+	if !ok || !policy.AutoCompact {
+		e.chunkManager.SetCompactor(nil)
+		return
+	}
+	e.chunkManager.SetCompactor(retention.NewCompactor(s, root, policy))
+}
+
+// OnFileSkipped records a change to a file whose contents are not tracked because
+// it is binary or too large.
+func (e *Engine) OnFileSkipped(path string, size int64, hash string, reason chunk.SkipReason) {
+	type skipRecorder interface {
+		SaveSkippedChange(sc chunk.SkippedChange) error
+	}
+
+	log.Printf("Skipped %s file: %s (%d bytes)", reason, path, size)
+	recorder, ok := e.store.(skipRecorder)
+	if !ok {
+		return
+	}
+	err := recorder.SaveSkippedChange(chunk.SkippedChange{
+		FilePath: path,
+		Size:     size,
+		Hash:     hash,
+		Reason:   reason,
+		Time:     time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed to record skipped change to %s: %v", path, err)
+	}
+}
+
+// OnWatcherError reports a failure of the file watcher to the engine's subscribers.
+func (e *Engine) OnWatcherError(err error) {
+	e.events.Publish(Event{Type: EventWatcherError, Error: err.Error()})
+}
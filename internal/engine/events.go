@@ -0,0 +1,186 @@
+package engine
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"carya/internal/chunk"
+)
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+const (
+	EventChunkStarted EventType = "chunk_started" // A file started collecting changes in a new chunk
+	EventChunkUpdated EventType = "chunk_updated" // A chunk still collecting changes saw another change
+	EventChunkFlushed EventType = "chunk_flushed" // Chunks were saved because they went stale or the engine went idle
+	EventManualFlush  EventType = "manual_flush"  // Chunks were saved on request
+	EventIdle         EventType = "idle"          // No file changed for a while; flushing slows down
+	EventActive       EventType = "active"        // A file changed after the engine was idle
+	EventWatcherError EventType = "watcher_error" // The file watcher failed
+)
+
+// DefaultEventBuffer is the number of events a subscription holds before further events are dropped.
+const DefaultEventBuffer = 64
+
+// Event describes something that happened to the chunks of the engine.
+type Event struct {
+	Type   EventType              `json:"type"`
+	Time   time.Time              `json:"time"`
+	Active *chunk.ActiveChunkInfo `json:"active,omitempty"` // Chunk collecting changes, for EventChunkStarted and EventChunkUpdated
+	Chunks []chunk.Chunk          `json:"chunks,omitempty"` // Saved chunks, for EventChunkFlushed and EventManualFlush
+	Reason chunk.FlushReason      `json:"reason,omitempty"` // Why the chunks were saved
+	Error  string                 `json:"error,omitempty"`  // What went wrong, for EventWatcherError
+}
+
+// Bus delivers events to any number of subscribers. Publishing never blocks: a subscriber
+// that doesn't keep up misses the events that don't fit in its buffer.
+type Bus struct {
+	mu   sync.RWMutex           // Protects subs
+	subs map[*Subscription]bool // Open subscriptions
+}
+
+// Subscription receives the events published on a Bus until it is closed.
+type Subscription struct {
+	bus     *Bus               // Bus the subscription belongs to
+	events  chan Event         // Buffered channel the events are delivered to
+	types   map[EventType]bool // Types of events delivered; all if empty
+	dropped atomic.Uint64      // Number of events missed because the buffer was full
+	once    sync.Once          // Guards closing events
+}
+
+// NewBus creates an event bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]bool)}
+}
+
+// Subscribe returns a subscription receiving the events of the given types, or of all
+// types if none are given. Up to buffer events are held until they are received; a buffer
+// of zero or less uses DefaultEventBuffer.
+func (b *Bus) Subscribe(buffer int, types ...EventType) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultEventBuffer
+	}
+	s := &Subscription{
+		bus:    b,
+		events: make(chan Event, buffer),
+		types:  make(map[EventType]bool),
+	}
+	for _, t := range types {
+		s.types[t] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subs[s] = true
+	return s
+}
+
+// Handle calls fn in a separate goroutine for each event of the given types, or of all
+// types if none are given, until the returned subscription is closed.
+func (b *Bus) Handle(fn func(Event), types ...EventType) *Subscription {
+	s := b.Subscribe(0, types...)
+	go func() {
+		for event := range s.events {
+			fn(event)
+		}
+	}()
+	return s
+}
+
+// Publish delivers an event to every subscriber interested in its type without waiting
+// for any of them. A zero Time is set to the current time.
+func (b *Bus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for s := range b.subs {
+		if len(s.types) > 0 && !s.types[event.Type] {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Close closes every open subscription.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = make(map[*Subscription]bool)
+	b.mu.Unlock()
+
+	for s := range subs {
+		s.once.Do(func() { close(s.events) })
+	}
+}
+
+// Events returns the channel the events are delivered to. It is closed when the
+// subscription or its bus is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns the number of events missed because the subscriber didn't keep up.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close stops delivering events to the subscription and closes its channel.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	delete(s.bus.subs, s)
+	s.bus.mu.Unlock()
+
+	s.once.Do(func() { close(s.events) })
+}
+
+// busEmitter logs the events of the chunk manager and publishes them on a Bus.
+type busEmitter struct {
+	bus *Bus // Bus the events are published on
+}
+
+// EmitChunkStarted publishes EventChunkStarted.
+func (e *busEmitter) EmitChunkStarted(info chunk.ActiveChunkInfo) {
+	e.bus.Publish(Event{Type: EventChunkStarted, Time: info.LastUpdate, Active: &info})
+}
+
+// EmitChunkUpdated publishes EventChunkUpdated.
+func (e *busEmitter) EmitChunkUpdated(info chunk.ActiveChunkInfo) {
+	e.bus.Publish(Event{Type: EventChunkUpdated, Time: info.LastUpdate, Active: &info})
+}
+
+// EmitChunkFlushed logs the flushed chunks and publishes EventManualFlush for flushes on
+// request and EventChunkFlushed otherwise.
+func (e *busEmitter) EmitChunkFlushed(chunks []chunk.Chunk, reason chunk.FlushReason) {
+	if len(chunks) == 1 {
+		log.Printf("Chunk created: %s for file %s", chunks[0].ID, chunks[0].FilePath)
+	} else {
+		log.Printf("Flushed %d chunks", len(chunks))
+	}
+
+	eventType := EventChunkFlushed
+	if reason == chunk.FlushManual {
+		eventType = EventManualFlush
+	}
+	e.bus.Publish(Event{Type: eventType, Chunks: chunks, Reason: reason})
+}
+
+// EmitModeChanged publishes EventIdle or EventActive.
+func (e *busEmitter) EmitModeChanged(idle bool) {
+	if idle {
+		e.bus.Publish(Event{Type: EventIdle})
+	} else {
+		e.bus.Publish(Event{Type: EventActive})
+	}
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+
+	"carya/internal/chunk"
+	"carya/internal/git"
+)
+
+// commitMarker is implemented by stores that record which chunks have been committed.
+type commitMarker interface {
+	FindChunks(filePath string) ([]chunk.Chunk, error)
+	GetChunk(id chunk.ChunkID) (*chunk.Chunk, error)
+	MarkCommitted(ids []chunk.ChunkID, commit string) error
+}
+
+// TrackHead records the git branch and commit on each chunk of the work tree at root,
+// following HEAD as it changes. When HEAD moves, the active chunks are saved first so they
+// keep the context they were made in, and chunks whose changes are in the commits HEAD
+// moved to are marked as committed.
+func (e *Engine) TrackHead(root string) error {
+	tracker, err := git.NewHeadTracker(root, func(old, new git.Head) {
+		e.onHeadChange(root, old, new)
+	})
+	if err != nil {
+		return err
+	}
+	e.heads = tracker
+	e.chunkManager.SetHeadSource(tracker)
+	return nil
+}
+
+// onHeadChange flushes the active chunks and marks the chunks committed between old and new.
+func (e *Engine) onHeadChange(root string, old, new git.Head) {
+	log.Printf("HEAD moved from %s to %s", describeHead(old), describeHead(new))
+	if flushed, err := e.chunkManager.FlushAll(); err != nil {
+		log.Printf("Failed to flush chunks before HEAD moved: %v", err)
+	} else if len(flushed) > 0 {
+		log.Printf("Flushed %d chunks made on %s", len(flushed), describeHead(old))
+	}
+
+	marker, ok := e.store.(commitMarker)
+	if !ok || new.Commit == "" || new.Commit == old.Commit {
+		return
+	}
+	commits := new.Commit
+	if old.Commit != "" {
+		commits = old.Commit + ".." + new.Commit
+	}
+	marked, err := MarkCommitted(marker, root, commits, new.Commit)
+	if err != nil {
+		log.Printf("Failed to mark committed chunks: %v", err)
+		return
+	}
+	if marked > 0 {
+		log.Printf("Marked %d chunks as committed in %s", marked, shortCommit(new.Commit))
+	}
+}
+
+// MarkCommitted finds the chunks whose changes are in the given commits (a revision range
+// such as "a..b") and marks them as committed in commit. A chunk counts as committed when
+// one of the commits gives its file the content the chunk left it with; the earlier
+// uncommitted chunks of that file are then committed too. Returns how many chunks were marked.
+func MarkCommitted(s commitMarker, root, commits, commit string) (int, error) {
+	history, err := git.BlobHistory(root, commits)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read history of %s: %w", commits, err)
+	}
+
+	paths := make([]string, 0, len(history))
+	for rel := range history {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	var ids []chunk.ChunkID
+	for _, rel := range paths {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		chunks, err := s.FindChunks(path)
+		if err != nil {
+			return 0, err
+		}
+		sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].StartTime.After(chunks[j].StartTime) })
+
+		// Newest first, find the last chunk whose result was committed
+		for i, c := range chunks {
+			if c.FilePath != path || c.Committed != "" {
+				continue
+			}
+			if !committedContent(s, c, history[rel]) {
+				continue
+			}
+			for _, earlier := range chunks[i:] {
+				if earlier.Committed == "" {
+					ids = append(ids, earlier.ID)
+				}
+			}
+			break
+		}
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := s.MarkCommitted(ids, commit); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// committedContent reports whether the file content a chunk resulted in is among blobs.
+func committedContent(s commitMarker, c chunk.Chunk, blobs map[string]bool) bool {
+	if c.Op == chunk.OpDelete {
+		return blobs[git.DeletedBlobID]
+	}
+	full, err := s.GetChunk(c.ID)
+	if err != nil || full.After == nil {
+		return false
+	}
+	return blobs[git.BlobID(full.After)]
+}
+
+// describeHead names the branch and commit of a head for log messages.
+func describeHead(head git.Head) string {
+	switch {
+	case head.Branch == "":
+		return "detached " + shortCommit(head.Commit)
+	case head.Commit == "":
+		return head.Branch
+	}
+	return head.Branch + " at " + shortCommit(head.Commit)
+}
+
+// shortCommit abbreviates a commit hash for log messages.
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
@@ -0,0 +1,51 @@
+// Package feature defines feature tags, which group the chunks belonging to one
+// piece of work (for example "auth-refactor") so they can be viewed and committed together.
+package feature
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Tag identifies the feature a chunk belongs to. The empty tag means untagged.
+type Tag string
+
+// Status describes where a feature is in its lifecycle.
+type Status string
+
+const (
+	// StatusActive marks the feature new chunks are currently tagged with. At most one feature is active.
+	StatusActive Status = "active"
+	// StatusOpen marks a feature that is still in progress but not currently active.
+	StatusOpen Status = "open"
+	// StatusClosed marks a finished feature. Its chunks keep their tag.
+	StatusClosed Status = "closed"
+)
+
+// Feature is a named unit of work that chunks can be tagged with.
+type Feature struct {
+	Tag         Tag       // Unique name of the feature
+	Description string    // Optional human-readable description
+	Status      Status    // Lifecycle status
+	CreatedAt   time.Time // When the feature was started
+	ClosedAt    time.Time // When the feature was closed (zero if not closed)
+}
+
+// ValidateTag checks that name can be used as a feature tag: it must be non-empty,
+// at most 64 characters, and contain only letters, digits, '-', '_', '.' or '/'.
+func ValidateTag(name string) error {
+	if name == "" {
+		return fmt.Errorf("feature name cannot be empty")
+	}
+	if len(name) > 64 {
+		return fmt.Errorf("feature name %q is longer than 64 characters", name)
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_./", r) {
+			return fmt.Errorf("feature name %q contains invalid character %q", name, r)
+		}
+	}
+	return nil
+}
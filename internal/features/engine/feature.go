@@ -1,8 +1,11 @@
 package engine
 
 import (
+	"carya/internal/config"
 	"carya/internal/engine"
+	"carya/internal/git"
 	"carya/internal/repository"
+	"carya/internal/store"
 )
 
 // EngineFeature manages the main engine functionality
@@ -25,13 +28,27 @@ func (ef *EngineFeature) Description() string {
 	return "Main engine for chunk management and storage"
 }
 
-// Initialize sets up the engine
+// Initialize sets up the engine with the repository's configuration
 func (ef *EngineFeature) Initialize(repo *repository.Repository) error {
-	eng, err := engine.NewEngine(repo.DBPath())
+	cfg, err := config.Load(repo.CaryaPath())
 	if err != nil {
 		return err
 	}
-	ef.engine = eng
+	return ef.InitializeWithConfig(repo, cfg)
+}
+
+// InitializeWithConfig sets up the engine with the store backend and timings of cfg
+func (ef *EngineFeature) InitializeWithConfig(repo *repository.Repository, cfg *config.Config) error {
+	chunkStore, err := store.OpenWithConfig(repo.CaryaPath(), cfg.Store)
+	if err != nil {
+		return err
+	}
+	opts := cfg.Engine
+	opts.Strategy.Root = repo.RootPath()
+	if topLevel, err := git.TopLevel(repo.RootPath()); err == nil {
+		opts.Strategy.Root = topLevel
+	}
+	ef.engine = engine.NewEngineWithOptions(chunkStore, opts)
 	return nil
 }
 
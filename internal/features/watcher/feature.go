@@ -35,9 +35,14 @@ func (wf *WatcherFeature) Initialize(repo *repository.Repository) error {
 
 // InitializeWithEngine sets up the file watcher with a specific engine
 func (wf *WatcherFeature) InitializeWithEngine(repo *repository.Repository, eng *engine.Engine) error {
+	return wf.InitializeWithOptions(repo, eng, watcher.DefaultOptions())
+}
+
+// InitializeWithOptions sets up the file watcher with a specific engine and watcher options
+func (wf *WatcherFeature) InitializeWithOptions(repo *repository.Repository, eng *engine.Engine, opts watcher.Options) error {
 	wf.repo = repo
 
-	fileWatcher, err := watcher.New(eng)
+	fileWatcher, err := watcher.NewWithOptions(eng, opts)
 	if err != nil {
 		return err
 	}
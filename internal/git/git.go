@@ -0,0 +1,322 @@
+// Package git wraps the git command line for the operations Carya performs on
+// the underlying repository, such as inspecting HEAD and building commits from chunks.
+package git
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"carya/internal/chunk"
+)
+
+// run executes git with the given arguments in dir and returns its trimmed stdout.
+// An empty dir runs git in the current working directory.
+func run(dir string, args ...string) (string, error) {
+	return runWithInput(dir, "", args...)
+}
+
+// runWithInput executes git with the given stdin and returns its trimmed stdout.
+// Errors include git's stderr output so callers can surface it to the user.
+func runWithInput(dir, input string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// TopLevel returns the absolute path of the top-level directory of the git work tree containing dir.
+func TopLevel(dir string) (string, error) {
+	return run(dir, "rev-parse", "--show-toplevel")
+}
+
+// FileHash returns the git hash of a file
+func FileHash(dir, path string) (string, error) {
+	return run(dir, "hash-object", path)
+}
+
+// IndexContent returns the content of the file at path as currently staged in the git index.
+// The file's directory may no longer exist, as when it was just removed or moved away.
+func IndexContent(path string) ([]byte, error) {
+	dir := filepath.Dir(path)
+	for {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("no directory of %s exists", path)
+		}
+		dir = parent
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("git", "show", ":./"+filepath.ToSlash(rel))
+	cmd.Dir = dir
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git show: %w", err)
+	}
+	return output, nil
+}
+
+// HeadCommit returns the current HEAD commit hash
+func HeadCommit(dir string) (string, error) {
+	return run(dir, "rev-parse", "HEAD")
+}
+
+// ChangedFiles returns the list of files changed between a commit and HEAD
+func ChangedFiles(dir, fromCommit string) ([]string, error) {
+	currentCommit, err := HeadCommit(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// If commits are the same, no changes occurred
+	if fromCommit == currentCommit {
+		return []string{}, nil
+	}
+
+	output, err := run(dir, "diff", "--name-only", fromCommit, currentCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter out empty strings
+	var result []string
+	for _, file := range strings.Split(output, "\n") {
+		if file != "" {
+			result = append(result, file)
+		}
+	}
+
+	return result, nil
+}
+
+// HasStagedChanges reports whether the index differs from HEAD.
+func HasStagedChanges(dir string) (bool, error) {
+	cmd := exec.Command("git", "diff", "--cached", "--quiet")
+	cmd.Dir = dir
+	err := cmd.Run()
+	if err == nil {
+		return false, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return true, nil
+	}
+	return false, fmt.Errorf("git diff: %w", err)
+}
+
+// BuildPatch renders hunks as a patch for the file at relPath (relative to the
+// work tree root) that can be fed to git apply.
+func BuildPatch(relPath string, hunks []chunk.Hunk) string {
+	relPath = strings.TrimPrefix(relPath, "./")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\n", relPath, relPath)
+	fmt.Fprintf(&b, "--- a/%s\n", relPath)
+	fmt.Fprintf(&b, "+++ b/%s\n", relPath)
+	b.WriteString(chunk.FormatHunks(hunks))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// BuildNewFilePatch renders hunks as a patch creating relPath.
+func BuildNewFilePatch(relPath string, hunks []chunk.Hunk) string {
+	relPath = strings.TrimPrefix(relPath, "./")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\n", relPath, relPath)
+	b.WriteString("new file mode 100644\n")
+	b.WriteString("--- /dev/null\n")
+	fmt.Fprintf(&b, "+++ b/%s\n", relPath)
+	b.WriteString(chunk.FormatHunks(hunks))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// BuildRenamePatch renders a patch moving oldRelPath to newRelPath and applying hunks
+// to its contents. With no hunks the file is moved unchanged.
+func BuildRenamePatch(oldRelPath, newRelPath string, hunks []chunk.Hunk) string {
+	oldRelPath = strings.TrimPrefix(oldRelPath, "./")
+	newRelPath = strings.TrimPrefix(newRelPath, "./")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\n", oldRelPath, newRelPath)
+	if len(hunks) == 0 {
+		b.WriteString("similarity index 100%\n")
+	}
+	fmt.Fprintf(&b, "rename from %s\n", oldRelPath)
+	fmt.Fprintf(&b, "rename to %s\n", newRelPath)
+	if len(hunks) > 0 {
+		fmt.Fprintf(&b, "--- a/%s\n", oldRelPath)
+		fmt.Fprintf(&b, "+++ b/%s\n", newRelPath)
+		b.WriteString(chunk.FormatHunks(hunks))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// RemoveFromIndex removes relPath from the index only, leaving the working tree untouched.
+// It does nothing if the path is not in the index.
+func RemoveFromIndex(dir, relPath string) error {
+	_, err := run(dir, "rm", "--cached", "--quiet", "--ignore-unmatch", "--", relPath)
+	return err
+}
+
+// ApplyToIndex applies a patch to the index only, leaving the working tree untouched.
+func ApplyToIndex(dir, patch string) error {
+	_, err := runWithInput(dir, patch, "apply", "--cached", "-")
+	return err
+}
+
+// ResetIndex resets the index to match HEAD without touching the working tree.
+func ResetIndex(dir string) error {
+	_, err := run(dir, "reset", "-q")
+	return err
+}
+
+// Commit records the staged changes with the given message and returns the new commit hash.
+func Commit(dir, message string) (string, error) {
+	if _, err := run(dir, "commit", "-q", "-m", message); err != nil {
+		return "", err
+	}
+	return HeadCommit(dir)
+}
+
+// CommitWithEditor records the staged changes, letting git open the user's editor
+// for the commit message, and returns the new commit hash.
+func CommitWithEditor(dir string) (string, error) {
+	cmd := exec.Command("git", "commit")
+	cmd.Dir = dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git commit: %w", err)
+	}
+	return HeadCommit(dir)
+}
+
+// ConfigPath returns the value of a path-valued git config key as seen from dir, with
+// "~" expanded. Returns an error if the key is not set.
+func ConfigPath(dir, key string) (string, error) {
+	return run(dir, "config", "--path", "--get", key)
+}
+
+// GitPath returns the absolute path of a file inside the git directory of the repository
+// containing dir, such as "info/exclude".
+func GitPath(dir, path string) (string, error) {
+	out, err := run(dir, "rev-parse", "--git-path", path)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(out) {
+		out = filepath.Join(dir, out)
+	}
+	return out, nil
+}
+
+// Upstream returns the name of the branch HEAD's branch is pushed to, such as "origin/main".
+// Returns an error if it has none.
+func Upstream(dir string) (string, error) {
+	return run(dir, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+}
+
+// BlobID returns the ID git gives a blob with the given content.
+func BlobID(content []byte) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "blob %d\x00", len(content))
+	hash.Write(content)
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// DeletedBlobID is the blob ID BlobHistory records for a commit that deletes a file.
+const DeletedBlobID = "0000000000000000000000000000000000000000"
+
+// BlobHistory returns the IDs of every version of every file in the history of ref,
+// keyed by the file's path relative to the work tree root.
+func BlobHistory(dir, ref string) (map[string]map[string]bool, error) {
+	output, err := run(dir, "log", "--format=", "--raw", "--no-abbrev", "--no-renames", ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lines look like ":100644 100644 <old blob> <new blob> M\t<path>"
+	history := make(map[string]map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		meta, path, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) < 5 || !strings.HasPrefix(line, ":") {
+			continue
+		}
+		if history[path] == nil {
+			history[path] = make(map[string]bool)
+		}
+		history[path][fields[3]] = true
+	}
+	return history, nil
+}
+
+// TreeBlobs returns the blob IDs of the files in the tree of ref, keyed by the file's
+// path relative to the work tree root.
+func TreeBlobs(dir, ref string) (map[string]string, error) {
+	output, err := run(dir, "ls-tree", "-r", "-z", "--full-tree", ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries look like "<mode> blob <blob>\t<path>"
+	blobs := make(map[string]string)
+	for _, entry := range strings.Split(output, "\x00") {
+		meta, path, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 || fields[1] != "blob" {
+			continue
+		}
+		blobs[path] = fields[2]
+	}
+	return blobs, nil
+}
+
+// IsAncestor reports whether commit is in the history of ref.
+func IsAncestor(dir, commit, ref string) (bool, error) {
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", commit, ref)
+	cmd.Dir = dir
+	err := cmd.Run()
+	if err == nil {
+		return true, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return false, fmt.Errorf("git merge-base: %w", err)
+}
@@ -0,0 +1,159 @@
+package git
+
+import (
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Head describes what a work tree has checked out.
+type Head struct {
+	Branch string // Short name of the checked-out branch; empty if HEAD is detached
+	Commit string // Commit HEAD points at; empty before the first commit
+}
+
+// ReadHead returns the branch and commit checked out in the work tree containing dir.
+func ReadHead(dir string) (Head, error) {
+	var head Head
+	if _, err := run(dir, "rev-parse", "--git-dir"); err != nil {
+		return head, err
+	}
+	// Both fail quietly for a detached HEAD and an unborn branch respectively
+	head.Branch, _ = run(dir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	head.Commit, _ = run(dir, "rev-parse", "--verify", "--quiet", "HEAD")
+	return head, nil
+}
+
+// HeadTracker keeps the Head of a work tree up to date. It watches HEAD and its reflog in
+// the git directory, so checkouts, commits and resets are noticed as they happen.
+type HeadTracker struct {
+	dir       string              // Directory inside the work tree
+	fsWatcher *fsnotify.Watcher   // Watches the directories holding HEAD and logs/HEAD
+	heads     map[string]bool     // Paths of HEAD and logs/HEAD
+	onChange  func(old, new Head) // Optional callback for changes of the head
+	mu        sync.Mutex          // Protects head
+	head      Head                // Last head read
+	stopCh    chan struct{}       // Channel to signal shutdown
+}
+
+// NewHeadTracker reads the Head of the work tree containing dir and starts watching it.
+// onChange, if not nil, is called with the previous and the new Head whenever it changes;
+// until it returns, CurrentHead still reports the previous one.
+func NewHeadTracker(dir string, onChange func(old, new Head)) (*HeadTracker, error) {
+	head, err := ReadHead(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	t := &HeadTracker{
+		dir:       dir,
+		fsWatcher: fsWatcher,
+		heads:     make(map[string]bool),
+		onChange:  onChange,
+		head:      head,
+		stopCh:    make(chan struct{}),
+	}
+	for _, name := range []string{"HEAD", "logs/HEAD"} {
+		path, err := GitPath(dir, name)
+		if err != nil {
+			fsWatcher.Close()
+			return nil, err
+		}
+		t.heads[filepath.Clean(path)] = true
+	}
+	t.watchDirectories()
+
+	go t.watchLoop()
+	return t, nil
+}
+
+// watchDirectories adds the directories holding HEAD and logs/HEAD to the watch list.
+// The logs directory only appears with the first commit, so adding it may fail until then.
+func (t *HeadTracker) watchDirectories() {
+	for path := range t.heads {
+		t.fsWatcher.Add(filepath.Dir(path))
+	}
+}
+
+// holdsHead reports whether dir is a directory holding HEAD or logs/HEAD.
+func (t *HeadTracker) holdsHead(dir string) bool {
+	for path := range t.heads {
+		if filepath.Dir(path) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// watchLoop rereads the head each time git writes HEAD or appends to its reflog.
+func (t *HeadTracker) watchLoop() {
+	for {
+		select {
+		case event, ok := <-t.fsWatcher.Events:
+			if !ok {
+				return
+			}
+			name := filepath.Clean(event.Name)
+			if event.Op&fsnotify.Create != 0 && t.holdsHead(name) {
+				// logs/HEAD may have been written before the new directory was watched
+				t.watchDirectories()
+				t.refresh()
+			}
+			if t.heads[name] {
+				t.refresh()
+			}
+
+		case err, ok := <-t.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("Head tracker ERROR:", err)
+
+		case <-t.stopCh:
+			return
+		}
+	}
+}
+
+// refresh rereads the head and reports a change to onChange.
+func (t *HeadTracker) refresh() {
+	head, err := ReadHead(t.dir)
+	if err != nil {
+		log.Printf("Failed to read git HEAD: %v", err)
+		return
+	}
+
+	t.mu.Lock()
+	old := t.head
+	t.mu.Unlock()
+	if head == old {
+		return
+	}
+
+	if t.onChange != nil {
+		t.onChange(old, head)
+	}
+	t.mu.Lock()
+	t.head = head
+	t.mu.Unlock()
+}
+
+// CurrentHead returns the checked-out branch and the commit HEAD points at.
+func (t *HeadTracker) CurrentHead() (branch, commit string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.head.Branch, t.head.Commit
+}
+
+// Stop stops watching the git directory.
+func (t *HeadTracker) Stop() {
+	close(t.stopCh)
+	t.fsWatcher.Close()
+}
@@ -0,0 +1,171 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IndexReader reads the contents files have in the git index of a work tree. The blob ids
+// of all staged files are listed once and listed again only after the index file changes,
+// and the blobs are read through a single long-running git cat-file process, so a lookup
+// doesn't start any new process.
+type IndexReader struct {
+	root      string            // Top level of the work tree
+	indexPath string            // Path of the index file
+	mu        sync.Mutex        // Protects the fields below
+	modTime   time.Time         // Modification time of the index file when blobs was listed
+	size      int64             // Size of the index file when blobs was listed
+	blobs     map[string]string // Blob ids of staged files, by slash-separated path relative to root
+	cat       *exec.Cmd         // Running git cat-file --batch, nil until needed
+	catIn     io.WriteCloser    // Standard input of cat
+	catOut    *bufio.Reader     // Standard output of cat
+}
+
+// NewIndexReader creates a reader for the index of the work tree whose top level is root.
+func NewIndexReader(root string) (*IndexReader, error) {
+	indexPath, err := GitPath(root, "index")
+	if err != nil {
+		return nil, err
+	}
+	return &IndexReader{root: root, indexPath: indexPath}, nil
+}
+
+// Content returns the content of the file at path as currently staged in the index.
+func (r *IndexReader) Content(path string) ([]byte, error) {
+	rel, err := filepath.Rel(r.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%s is outside the work tree %s", path, r.root)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.refreshLocked(); err != nil {
+		return nil, err
+	}
+	id, ok := r.blobs[filepath.ToSlash(rel)]
+	if !ok {
+		return nil, fmt.Errorf("%s is not in the index", filepath.ToSlash(rel))
+	}
+	content, err := r.readBlobLocked(id)
+	if err != nil {
+		// Start over with a new process next time, rather than reading a half-consumed reply
+		r.stopLocked()
+		return nil, err
+	}
+	return content, nil
+}
+
+// Close stops the git cat-file process.
+func (r *IndexReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stopLocked()
+	return nil
+}
+
+// refreshLocked lists the staged blobs again if the index file changed since they were
+// listed. Must be called with r.mu held.
+func (r *IndexReader) refreshLocked() error {
+	fi, err := os.Stat(r.indexPath)
+	if err != nil {
+		// A repository without commits or staged files has no index yet
+		r.blobs, r.modTime, r.size = map[string]string{}, time.Time{}, 0
+		return nil
+	}
+	if r.blobs != nil && fi.ModTime().Equal(r.modTime) && fi.Size() == r.size {
+		return nil
+	}
+
+	cmd := exec.Command("git", "ls-files", "--stage", "-z")
+	cmd.Dir = r.root
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("git ls-files: %w", err)
+	}
+
+	blobs := make(map[string]string)
+	for _, entry := range strings.Split(string(output), "\x00") {
+		// Each entry is "<mode> <blob id> <stage>\t<path>"; only regular and executable
+		// files that aren't in conflict have a content to use
+		info, path, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) != 3 || fields[2] != "0" || !strings.HasPrefix(fields[0], "100") {
+			continue
+		}
+		blobs[path] = fields[1]
+	}
+	r.blobs, r.modTime, r.size = blobs, fi.ModTime(), fi.Size()
+	return nil
+}
+
+// readBlobLocked reads the content of the blob with the given id, starting git cat-file
+// if it isn't running. Must be called with r.mu held.
+func (r *IndexReader) readBlobLocked(id string) ([]byte, error) {
+	if r.cat == nil {
+		if err := r.startLocked(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := io.WriteString(r.catIn, id+"\n"); err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	header, err := r.catOut.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+
+	// The reply is "<id> <type> <size>\n<content>\n", or "<id> missing\n"
+	fields := strings.Fields(header)
+	if len(fields) != 3 || fields[1] != "blob" {
+		return nil, fmt.Errorf("git cat-file: unexpected reply %q", strings.TrimSpace(header))
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: unexpected reply %q", strings.TrimSpace(header))
+	}
+	content := make([]byte, size+1)
+	if _, err := io.ReadFull(r.catOut, content); err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	return content[:size], nil
+}
+
+// startLocked starts git cat-file --batch. Must be called with r.mu held.
+func (r *IndexReader) startLocked() error {
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Dir = r.root
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("git cat-file: %w", err)
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("git cat-file: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("git cat-file: %w", err)
+	}
+	r.cat, r.catIn, r.catOut = cmd, in, bufio.NewReader(out)
+	return nil
+}
+
+// stopLocked stops git cat-file if it is running. Must be called with r.mu held.
+func (r *IndexReader) stopLocked() {
+	if r.cat == nil {
+		return
+	}
+	r.catIn.Close()
+	r.cat.Wait()
+	r.cat, r.catIn, r.catOut = nil, nil, nil
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"carya/internal/repository"
 )
 
 type Command struct {
@@ -35,17 +37,16 @@ func NewConfig() *Config {
 }
 
 func GetConfigPath() (string, error) {
-	wd, err := os.Getwd()
+	repo, err := repository.New()
 	if err != nil {
-		return "", fmt.Errorf("failed to get working directory: %w", err)
+		return "", err
 	}
 
-	caryaDir := filepath.Join(wd, ".carya")
-	if _, err := os.Stat(caryaDir); os.IsNotExist(err) {
+	if !repo.Exists() {
 		return "", fmt.Errorf(".carya directory not found - run 'carya init' first")
 	}
 
-	return filepath.Join(caryaDir, ConfigFile), nil
+	return filepath.Join(repo.CaryaPath(), ConfigFile), nil
 }
 
 func LoadConfig() (*Config, error) {
@@ -7,14 +7,21 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"carya/internal/repository"
 )
 
 type Executor struct {
 	config *Config
+	root   string // Repository root that detection and relative working directories start from
 }
 
 func NewExecutor(config *Config) *Executor {
-	return &Executor{config: config}
+	root := "."
+	if repo, err := repository.New(); err == nil {
+		root = repo.RootPath()
+	}
+	return &Executor{config: config, root: root}
 }
 
 func (e *Executor) ExecuteCategory(category string, autoApprove bool) error {
@@ -28,7 +35,7 @@ func (e *Executor) ExecuteCategoryWithChangedFiles(category string, changedFiles
 	}
 
 	// Get autodetected commands and filter based on changed files
-	detector := NewDetector(".")
+	detector := NewDetector(e.root)
 	autoCommands, err := detector.GetSuggestedCommands(category)
 	if err == nil && len(changedFiles) > 0 {
 		// Filter autodetected commands based on changed files
@@ -161,12 +168,8 @@ func matchesDetectFile(filePath, detectFile string) bool {
 
 func (e *Executor) executeCommand(cmd Command) error {
 	workingDir := cmd.WorkingDir
-	if workingDir == "" || workingDir == "." {
-		wd, err := os.Getwd()
-		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
-		}
-		workingDir = wd
+	if !filepath.IsAbs(workingDir) {
+		workingDir = filepath.Join(e.root, workingDir)
 	}
 
 	parts := strings.Fields(cmd.Command)
@@ -0,0 +1,257 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"carya/internal/git"
+)
+
+// Names of the per-directory ignore files. Patterns in .caryaignore take precedence
+// over .gitignore in the same directory.
+const (
+	GitignoreFile   = ".gitignore"
+	CaryaignoreFile = ".caryaignore"
+)
+
+// RequiredPatterns are always ignored: they can't be configured away, and negated
+// patterns in ignore files don't re-include them.
+var RequiredPatterns = []string{".git/", ".carya/"}
+
+// required are the parsed RequiredPatterns.
+var required = parseLines(RequiredPatterns)
+
+// DefaultPatterns are ignored unless an ignore file re-includes them.
+var DefaultPatterns = []string{"node_modules/", ".vscode/", ".idea/"}
+
+// Matcher decides whether paths under a root directory are ignored, following git's rules:
+// the global excludes file, .git/info/exclude, and the .gitignore and .caryaignore files of
+// every directory from the root down, with later and deeper patterns taking precedence.
+// Per-directory files are read lazily and cached until invalidated.
+type Matcher struct {
+	root     string               // Absolute root directory
+	mu       sync.RWMutex         // Protects the fields below
+	defaults []string             // Patterns ignored in addition to RequiredPatterns unless re-included
+	global   []Pattern            // Defaults, global excludes and .git/info/exclude; RequiredPatterns are matched apart
+	dirs     map[string][]Pattern // Patterns of each directory's ignore files, by relative directory
+	extra    []string             // Absolute paths of ignore files outside the tree (global excludes, info/exclude)
+}
+
+// NewMatcher creates a matcher for the tree rooted at root.
+func NewMatcher(root string) *Matcher {
+	return NewMatcherWithDefaults(root, DefaultPatterns)
+}
+
+// NewMatcherWithDefaults creates a matcher for the tree rooted at root that ignores the
+// given patterns in place of DefaultPatterns.
+func NewMatcherWithDefaults(root string, defaults []string) *Matcher {
+	m := &Matcher{root: root, defaults: defaults}
+	m.Reload()
+	return m
+}
+
+// SetDefaults replaces the patterns ignored in place of DefaultPatterns and reloads.
+func (m *Matcher) SetDefaults(defaults []string) {
+	m.mu.Lock()
+	m.defaults = defaults
+	m.mu.Unlock()
+	m.Reload()
+}
+
+// Reload discards all cached patterns and re-reads the global ignore files.
+func (m *Matcher) Reload() {
+	m.mu.RLock()
+	defaults := m.defaults
+	m.mu.RUnlock()
+
+	global := parseLines(defaults)
+	extra := externalIgnoreFiles(m.root)
+	for _, path := range extra {
+		global = append(global, readPatterns(path, "")...)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.global = global
+	m.extra = extra
+	m.dirs = make(map[string][]Pattern)
+}
+
+// Invalidate drops cached patterns affected by a change to the file at path. It returns
+// true if path is an ignore file, in which case later matches see its new contents.
+func (m *Matcher) Invalidate(path string) bool {
+	name := filepath.Base(path)
+	if name == GitignoreFile || name == CaryaignoreFile {
+		rel, ok := m.rel(filepath.Dir(path))
+		if !ok {
+			return false
+		}
+		m.mu.Lock()
+		delete(m.dirs, rel)
+		m.mu.Unlock()
+		return true
+	}
+
+	m.mu.RLock()
+	external := false
+	for _, extra := range m.extra {
+		if extra == path {
+			external = true
+		}
+	}
+	m.mu.RUnlock()
+
+	if external {
+		m.Reload()
+	}
+	return external
+}
+
+// ExternalFiles returns the ignore files that live outside the tree, such as
+// .git/info/exclude and the global excludes file.
+func (m *Matcher) ExternalFiles() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]string(nil), m.extra...)
+}
+
+// Ignored reports whether the path is ignored. Paths outside the root are always ignored,
+// and so is everything inside an ignored directory.
+func (m *Matcher) Ignored(path string, isDir bool) bool {
+	rel, ok := m.rel(path)
+	if !ok {
+		return true
+	}
+	if rel == "" {
+		return false
+	}
+
+	// A file can't be re-included if one of its parent directories is ignored
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		if isRequired(dir, true) || m.matches(dir, true) {
+			return true
+		}
+	}
+	return isRequired(rel, isDir) || m.matches(rel, isDir)
+}
+
+// isRequired reports whether rel matches one of the RequiredPatterns.
+func isRequired(rel string, isDir bool) bool {
+	for _, p := range required {
+		if p.Match(rel, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLines parses patterns relative to the root, skipping blank ones.
+func parseLines(lines []string) []Pattern {
+	var patterns []Pattern
+	for _, line := range lines {
+		if p, ok := ParsePattern(line, ""); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// matches applies all patterns that can affect rel; the last matching pattern decides.
+func (m *Matcher) matches(rel string, isDir bool) bool {
+	ignored := false
+	apply := func(patterns []Pattern) {
+		for _, p := range patterns {
+			if p.Match(rel, isDir) {
+				ignored = !p.Negated()
+			}
+		}
+	}
+
+	m.mu.RLock()
+	apply(m.global)
+	m.mu.RUnlock()
+
+	// Ignore files of the root and every directory above rel, shallowest first
+	dir := ""
+	apply(m.dirPatterns(dir))
+	for _, part := range strings.Split(rel, "/")[:strings.Count(rel, "/")] {
+		dir = strings.TrimPrefix(dir+"/"+part, "/")
+		apply(m.dirPatterns(dir))
+	}
+
+	return ignored
+}
+
+// dirPatterns returns the patterns of the ignore files in the relative directory dir,
+// reading them on first use.
+func (m *Matcher) dirPatterns(dir string) []Pattern {
+	m.mu.RLock()
+	patterns, ok := m.dirs[dir]
+	m.mu.RUnlock()
+	if ok {
+		return patterns
+	}
+
+	abs := filepath.Join(m.root, filepath.FromSlash(dir))
+	patterns = append(readPatterns(filepath.Join(abs, GitignoreFile), dir),
+		readPatterns(filepath.Join(abs, CaryaignoreFile), dir)...)
+
+	m.mu.Lock()
+	m.dirs[dir] = patterns
+	m.mu.Unlock()
+	return patterns
+}
+
+// rel converts path to a "/"-separated path relative to the root. It returns false
+// if path is outside the root.
+func (m *Matcher) rel(path string) (string, bool) {
+	rel, err := filepath.Rel(m.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if rel == "." {
+		return "", true
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// readPatterns reads the patterns of an ignore file, returning nil if it doesn't exist.
+func readPatterns(path, base string) []Pattern {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	return ParsePatterns(file, base)
+}
+
+// externalIgnoreFiles returns the paths of the global excludes file and .git/info/exclude
+// for the tree at root, lowest precedence first. The files don't need to exist.
+func externalIgnoreFiles(root string) []string {
+	var files []string
+
+	global, err := git.ConfigPath(root, "core.excludesFile")
+	if err != nil || global == "" {
+		if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+			global = filepath.Join(xdg, "git", "ignore")
+		} else if home, err := os.UserHomeDir(); err == nil {
+			global = filepath.Join(home, ".config", "git", "ignore")
+		}
+	}
+	if global != "" {
+		files = append(files, global)
+	}
+
+	exclude, err := git.GitPath(root, "info/exclude")
+	if err != nil {
+		exclude = filepath.Join(root, ".git", "info", "exclude")
+	}
+	return append(files, exclude)
+}
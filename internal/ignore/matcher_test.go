@@ -0,0 +1,198 @@
+package ignore
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// newTestTree creates a git work tree holding the given files, with the user's global
+// ignore files out of the way.
+func newTestTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	root := t.TempDir()
+	if _, err := exec.LookPath("git"); err == nil {
+		cmd := exec.Command("git", "init", "-q")
+		cmd.Dir = root
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git init: %v\n%s", err, out)
+		}
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestMatcherIgnored(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		defaults []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{name: "not ignored", path: "main.go", want: false},
+		{name: "root is never ignored", path: "", isDir: true, want: false},
+		{name: "default pattern", path: "node_modules", isDir: true, want: true},
+		{name: "inside default pattern", path: "node_modules/pkg/index.js", want: true},
+		{name: "custom defaults replace the built-in ones", defaults: []string{"dist/"}, path: "node_modules", isDir: true, want: false},
+		{name: "custom default", defaults: []string{"dist/"}, path: "dist", isDir: true, want: true},
+		{name: "required .git", path: ".git/config", want: true},
+		{name: "required .carya", path: ".carya", isDir: true, want: true},
+		{name: "required nested .git", path: "vendor/lib/.git", isDir: true, want: true},
+		{name: "required without defaults", defaults: []string{}, path: ".carya/store.json", want: true},
+		{
+			name:  "gitignore",
+			files: map[string]string{".gitignore": "*.log\n"},
+			path:  "logs/debug.log",
+			want:  true,
+		},
+		{
+			name:  "negation re-includes",
+			files: map[string]string{".gitignore": "*.log\n!keep.log\n"},
+			path:  "keep.log",
+			want:  false,
+		},
+		{
+			name:  "negation re-includes a default",
+			files: map[string]string{".gitignore": "!node_modules/\n"},
+			path:  "node_modules",
+			isDir: true,
+			want:  false,
+		},
+		{
+			name:  "negation can't re-include .carya",
+			files: map[string]string{".gitignore": "!.carya/\n!.carya/**\n"},
+			path:  ".carya/store.json",
+			want:  true,
+		},
+		{
+			name:  "negation can't re-include .git",
+			files: map[string]string{".caryaignore": "!.git/\n"},
+			path:  ".git",
+			isDir: true,
+			want:  true,
+		},
+		{
+			name:  "file in ignored directory can't be re-included",
+			files: map[string]string{".gitignore": "build/\n!build/keep.txt\n"},
+			path:  "build/keep.txt",
+			want:  true,
+		},
+		{
+			name:  "nested gitignore",
+			files: map[string]string{"src/.gitignore": "*.o\n"},
+			path:  "src/a/x.o",
+			want:  true,
+		},
+		{
+			name:  "nested gitignore doesn't apply outside its directory",
+			files: map[string]string{"src/.gitignore": "*.o\n"},
+			path:  "lib/x.o",
+			want:  false,
+		},
+		{
+			name:  "deeper file overrides",
+			files: map[string]string{".gitignore": "*.gen\n", "src/.gitignore": "!*.gen\n"},
+			path:  "src/a.gen",
+			want:  false,
+		},
+		{
+			name:  "caryaignore takes precedence over gitignore",
+			files: map[string]string{".gitignore": "!notes.txt\n", ".caryaignore": "notes.txt\n"},
+			path:  "notes.txt",
+			want:  true,
+		},
+		{
+			name:  "info/exclude",
+			files: map[string]string{".git/info/exclude": "secret.txt\n"},
+			path:  "secret.txt",
+			want:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := tt.files[".git/info/exclude"]; ok {
+				if _, err := exec.LookPath("git"); err != nil {
+					t.Skip("git is not installed")
+				}
+			}
+			root := newTestTree(t, tt.files)
+
+			defaults := tt.defaults
+			if defaults == nil {
+				defaults = DefaultPatterns
+			}
+			m := NewMatcherWithDefaults(root, defaults)
+			if got := m.Ignored(filepath.Join(root, filepath.FromSlash(tt.path)), tt.isDir); got != tt.want {
+				t.Errorf("Ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcherOutsideRoot(t *testing.T) {
+	root := newTestTree(t, nil)
+	m := NewMatcher(root)
+	if !m.Ignored(filepath.Join(filepath.Dir(root), "elsewhere.txt"), false) {
+		t.Error("a path outside the root is not ignored")
+	}
+}
+
+func TestMatcherInvalidate(t *testing.T) {
+	root := newTestTree(t, map[string]string{".gitignore": "a.txt\n"})
+	m := NewMatcher(root)
+	path := filepath.Join(root, "b.txt")
+	if m.Ignored(path, false) {
+		t.Fatal("b.txt is ignored before the .gitignore changed")
+	}
+
+	gitignore := filepath.Join(root, GitignoreFile)
+	if err := os.WriteFile(gitignore, []byte("b.txt\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if m.Ignored(path, false) {
+		t.Fatal("the .gitignore was re-read before being invalidated")
+	}
+	if !m.Invalidate(gitignore) {
+		t.Fatal("Invalidate() didn't recognize the .gitignore")
+	}
+	if !m.Ignored(path, false) {
+		t.Error("b.txt is not ignored after the .gitignore changed")
+	}
+	if m.Invalidate(filepath.Join(root, "b.txt")) {
+		t.Error("Invalidate() reported an ordinary file as an ignore file")
+	}
+}
+
+func TestMatcherSetDefaults(t *testing.T) {
+	root := newTestTree(t, nil)
+	m := NewMatcher(root)
+	dist := filepath.Join(root, "dist")
+	if m.Ignored(dist, true) {
+		t.Fatal("dist is ignored before it is a default")
+	}
+
+	m.SetDefaults([]string{"dist/"})
+	if !m.Ignored(dist, true) {
+		t.Error("dist is not ignored after SetDefaults")
+	}
+	if m.Ignored(filepath.Join(root, "node_modules"), true) {
+		t.Error("node_modules is still ignored after SetDefaults replaced it")
+	}
+}
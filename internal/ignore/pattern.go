@@ -0,0 +1,134 @@
+// Package ignore implements gitignore pattern matching: negation, anchored and
+// directory-only patterns, "**" globs, and per-directory ignore files.
+package ignore
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"strings"
+)
+
+// Pattern is a single parsed line of an ignore file.
+type Pattern struct {
+	segments []string // Glob segments of the pattern, split on "/"
+	negate   bool     // Pattern started with "!" and re-includes matches
+	dirOnly  bool     // Pattern ended with "/" and only matches directories
+	anchored bool     // Pattern contained a "/" and is matched against the full path from its base
+	base     string   // Directory the pattern is relative to, "" for the root
+}
+
+// ParsePattern parses one line of an ignore file whose directory is base (relative to
+// the root, using "/" separators). It returns false for blank lines and comments.
+func ParsePattern(line, base string) (Pattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	line = trimTrailingSpaces(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Pattern{}, false
+	}
+
+	p := Pattern{base: strings.Trim(base, "/")}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if strings.Contains(line, "/") {
+		p.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	if line == "" {
+		return Pattern{}, false
+	}
+
+	p.segments = strings.Split(line, "/")
+	return p, true
+}
+
+// ParsePatterns parses every pattern in an ignore file whose directory is base.
+func ParsePatterns(r io.Reader, base string) []Pattern {
+	var patterns []Pattern
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if p, ok := ParsePattern(scanner.Text(), base); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// Negated reports whether the pattern re-includes the paths it matches.
+func (p Pattern) Negated() bool {
+	return p.negate
+}
+
+// Match reports whether the pattern matches relPath (relative to the root, using "/"
+// separators). isDir tells whether the path is a directory.
+func (p Pattern) Match(relPath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	if p.base != "" {
+		if !strings.HasPrefix(relPath, p.base+"/") {
+			return false
+		}
+		relPath = relPath[len(p.base)+1:]
+	}
+
+	if !p.anchored {
+		// Patterns without a slash match the name of a file or directory at any depth
+		ok, _ := path.Match(p.segments[0], path.Base(relPath))
+		return ok
+	}
+
+	return matchSegments(p.segments, strings.Split(relPath, "/"))
+}
+
+// matchSegments matches glob segments against path segments, where a "**" segment
+// matches zero or more path segments.
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				// A trailing "/**" matches everything inside, but not the directory itself
+				return len(parts) > 0
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(rest, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// trimTrailingSpaces removes trailing spaces unless they are escaped with a backslash.
+func trimTrailingSpaces(line string) string {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-2] + " "
+	}
+	return line
+}
@@ -0,0 +1,88 @@
+package ignore
+
+import "testing"
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		base    string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		// Names without a slash match at any depth
+		{pattern: "*.log", path: "debug.log", want: true},
+		{pattern: "*.log", path: "logs/debug.log", want: true},
+		{pattern: "*.log", path: "debug.txt", want: false},
+		{pattern: "build", path: "src/build", isDir: true, want: true},
+
+		// A trailing slash only matches directories
+		{pattern: "build/", path: "build", isDir: true, want: true},
+		{pattern: "build/", path: "build", isDir: false, want: false},
+
+		// A slash anchors the pattern to its base
+		{pattern: "/build", path: "build", want: true},
+		{pattern: "/build", path: "src/build", want: false},
+		{pattern: "doc/*.md", path: "doc/a.md", want: true},
+		{pattern: "doc/*.md", path: "doc/sub/a.md", want: false},
+		{pattern: "doc/*.md", path: "src/doc/a.md", want: false},
+
+		// "**" matches any number of directories
+		{pattern: "**/temp", path: "temp", want: true},
+		{pattern: "**/temp", path: "a/b/temp", want: true},
+		{pattern: "a/**/z", path: "a/z", want: true},
+		{pattern: "a/**/z", path: "a/b/c/z", want: true},
+		{pattern: "a/**", path: "a/b", want: true},
+		{pattern: "a/**", path: "a", isDir: true, want: false},
+
+		// Patterns from a nested ignore file are relative to its directory
+		{pattern: "*.o", base: "src", path: "src/x.o", want: true},
+		{pattern: "*.o", base: "src", path: "x.o", want: false},
+		{pattern: "/gen", base: "src", path: "src/gen", want: true},
+		{pattern: "/gen", base: "src", path: "src/sub/gen", want: false},
+
+		// Escapes
+		{pattern: `\#file`, path: "#file", want: true},
+		{pattern: `\!file`, path: "!file", want: true},
+		{pattern: `trailing\ `, path: "trailing ", want: true},
+	}
+
+	for _, tt := range tests {
+		p, ok := ParsePattern(tt.pattern, tt.base)
+		if !ok {
+			t.Errorf("ParsePattern(%q) rejected the pattern", tt.pattern)
+			continue
+		}
+		if got := p.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("pattern %q (base %q).Match(%q, %v) = %v, want %v", tt.pattern, tt.base, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		line    string
+		ok      bool
+		negated bool
+	}{
+		{line: "", ok: false},
+		{line: "   ", ok: false},
+		{line: "# comment", ok: false},
+		{line: "/", ok: false},
+		{line: "*.log", ok: true},
+		{line: "!keep.log", ok: true, negated: true},
+		{line: `\!literal`, ok: true, negated: false},
+		{line: "dir/\r", ok: true},
+	}
+
+	for _, tt := range tests {
+		p, ok := ParsePattern(tt.line, "")
+		if ok != tt.ok {
+			t.Errorf("ParsePattern(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if ok && p.Negated() != tt.negated {
+			t.Errorf("ParsePattern(%q).Negated() = %v, want %v", tt.line, p.Negated(), tt.negated)
+		}
+	}
+}
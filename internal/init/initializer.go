@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 
@@ -47,7 +48,7 @@ func (i *Initializer) isFeatureEnabled(featureKey string) bool {
 
 // ensureGitignore ensures .carya/ is in .gitignore
 func (i *Initializer) ensureGitignore() error {
-	gitignorePath := ".gitignore"
+	gitignorePath := filepath.Join(i.repo.RootPath(), ".gitignore")
 	caryaEntry := ".carya/"
 
 	// Check if .gitignore exists
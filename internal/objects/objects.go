@@ -0,0 +1,174 @@
+// Package objects stores file contents as zlib-compressed blobs named by the SHA-256
+// of their contents, so identical snapshots are stored once no matter how many chunks
+// refer to them.
+package objects
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNotFound is returned when a blob is not in the store.
+var ErrNotFound = errors.New("object not found")
+
+// Store keeps blobs in a directory, fanned out into subdirectories by the first two
+// characters of their hash like git's loose objects.
+type Store struct {
+	dir string // Root directory of the store
+}
+
+// New creates a store rooted at dir. The directory is created on first write.
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Hash returns the hex SHA-256 of content, which is the name it is stored under.
+func Hash(content []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+// Put stores content and returns its hash. Content that is already stored is not written
+// again, but its modification time is refreshed so a concurrent Prune keeps it.
+func (s *Store) Put(content []byte) (string, error) {
+	hash := Hash(content)
+	path := s.path(hash)
+
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return hash, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	if _, err := w.Write(content); err != nil {
+		return "", fmt.Errorf("failed to compress object: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress object: %w", err)
+	}
+
+	// Write to a temporary file first, so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+hash[2:])
+	if err != nil {
+		return "", fmt.Errorf("failed to write object %s: %w", hash, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(compressed.Bytes()); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write object %s: %w", hash, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write object %s: %w", hash, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to write object %s: %w", hash, err)
+	}
+	return hash, nil
+}
+
+// Get returns the content stored under hash, verifying that it matches the hash.
+func (s *Store) Get(hash string) ([]byte, error) {
+	if len(hash) < 3 {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, hash)
+	}
+
+	file, err := os.Open(s.path(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r, err := zlib.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+	if Hash(content) != hash {
+		return nil, fmt.Errorf("object %s is corrupt", hash)
+	}
+	return content, nil
+}
+
+// Has reports whether content with the given hash is stored.
+func (s *Store) Has(hash string) bool {
+	if len(hash) < 3 {
+		return false
+	}
+	_, err := os.Stat(s.path(hash))
+	return err == nil
+}
+
+// Object describes a stored blob.
+type Object struct {
+	Hash    string    // Hash of the blob's content
+	Size    int64     // Compressed size on disk
+	ModTime time.Time // When the blob was last written or refreshed by Put
+}
+
+// List returns every blob in the store.
+func (s *Store) List() ([]Object, error) {
+	var objects []Object
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && path == s.dir {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		prefix, rest := filepath.Base(filepath.Dir(path)), d.Name()
+		if len(prefix) != 2 || len(rest) != sha256.Size*2-2 {
+			// Temporary files and anything else that isn't an object
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Hash: prefix + rest, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
+
+// Remove deletes the blob stored under hash. Removing a missing blob is not an error.
+// The fan-out directory is kept even when it ends up empty: there are at most 256 of
+// them, and removing one could pull it out from under a concurrent Put.
+func (s *Store) Remove(hash string) error {
+	if len(hash) < 3 {
+		return nil
+	}
+	if err := os.Remove(s.path(hash)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path returns the file a blob is stored in.
+func (s *Store) path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash[2:])
+}
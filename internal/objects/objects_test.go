@@ -0,0 +1,204 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPutGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{name: "text", content: []byte("package main\n")},
+		{name: "empty", content: []byte{}},
+		{name: "binary", content: []byte{0, 1, 2, 0xff, 0}},
+	}
+
+	s := New(filepath.Join(t.TempDir(), "objects"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := s.Put(tt.content)
+			if err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			if hash != Hash(tt.content) {
+				t.Errorf("Put() = %s, want %s", hash, Hash(tt.content))
+			}
+			if !s.Has(hash) {
+				t.Errorf("Has(%s) = false after Put", hash)
+			}
+
+			got, err := s.Get(hash)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if string(got) != string(tt.content) {
+				t.Errorf("Get() = %q, want %q", got, tt.content)
+			}
+		})
+	}
+}
+
+func TestPutRefreshesExisting(t *testing.T) {
+	s := New(t.TempDir())
+	hash, err := s.Put([]byte("content"))
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	old := time.Now().Add(-24 * time.Hour)
+	if err := os.Chtimes(s.path(hash), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Put([]byte("content")); err != nil {
+		t.Fatalf("second Put() error = %v", err)
+	}
+	objects, err := s.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(objects) != 1 || objects[0].Hash != hash || !objects[0].ModTime.After(old) {
+		t.Errorf("List() = %+v, want only %s with a refreshed modification time", objects, hash)
+	}
+}
+
+func TestGetErrors(t *testing.T) {
+	s := New(t.TempDir())
+	hash, err := s.Put([]byte("original"))
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	// Store other content under the hash of the original
+	other, err := s.Put([]byte("tampered"))
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := os.Rename(s.path(other), s.path(hash)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		hash     string
+		notFound bool
+	}{
+		{name: "missing", hash: Hash([]byte("never stored")), notFound: true},
+		{name: "too short", hash: "ab", notFound: true},
+		{name: "corrupt", hash: hash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Get(tt.hash)
+			if err == nil {
+				t.Fatal("Get() error = nil")
+			}
+			if errors.Is(err, ErrNotFound) != tt.notFound {
+				t.Errorf("Get() error = %v, want ErrNotFound %v", err, tt.notFound)
+			}
+		})
+	}
+}
+
+func TestListAndRemove(t *testing.T) {
+	s := New(t.TempDir())
+	var hashes []string
+	for _, content := range []string{"a", "b", "c"} {
+		hash, err := s.Put([]byte(content))
+		if err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	// A temporary file left behind by an interrupted Put isn't an object
+	if err := os.WriteFile(filepath.Join(filepath.Dir(s.path(hashes[0])), ".tmp-leftover"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Remove(hashes[1]); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := s.Remove(hashes[1]); err != nil {
+		t.Errorf("Remove() of a missing object error = %v", err)
+	}
+	if s.Has(hashes[1]) {
+		t.Errorf("Has(%s) = true after Remove", hashes[1])
+	}
+
+	objects, err := s.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	listed := make(map[string]bool)
+	for _, obj := range objects {
+		listed[obj.Hash] = true
+	}
+	if len(objects) != 2 || !listed[hashes[0]] || !listed[hashes[2]] {
+		t.Errorf("List() = %+v, want %s and %s", objects, hashes[0], hashes[2])
+	}
+
+	empty, err := New(filepath.Join(t.TempDir(), "missing")).List()
+	if err != nil || len(empty) != 0 {
+		t.Errorf("List() of a store never written to = %v, %v, want nothing", empty, err)
+	}
+}
+
+// samePrefix returns n different contents whose hashes share their fan-out directory.
+func samePrefix(n int) [][]byte {
+	var contents [][]byte
+	for i := 0; len(contents) < n; i++ {
+		content := []byte(fmt.Sprint(i))
+		if Hash(content)[:2] == "00" {
+			contents = append(contents, content)
+		}
+	}
+	return contents
+}
+
+func TestPutWhileRemoving(t *testing.T) {
+	s := New(t.TempDir())
+	contents := samePrefix(2)
+	removed, kept := contents[0], contents[1]
+
+	// Removing the only object of a fan-out directory must not break a Put into it
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			hash, err := s.Put(removed)
+			if err == nil {
+				err = s.Remove(hash)
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			hash, err := s.Put(kept)
+			if err == nil {
+				err = s.Remove(hash)
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent Put and Remove: %v", err)
+	}
+}
@@ -1,28 +1,68 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"carya/internal/git"
 )
 
+// DirName is the name of the directory holding a repository's data.
+const DirName = ".carya"
+
+// ErrNotFound is returned by Find for a directory that is neither inside a Carya repository
+// nor inside a git work tree.
+var ErrNotFound = errors.New("not a Carya repository or git work tree (or any of the parent directories)")
+
 // Repository represents a Carya repository
 type Repository struct {
 	rootPath  string
 	caryaPath string
 }
 
-// New creates a new repository instance for the current working directory
+// New returns the repository containing the current working directory, see Find
 func New() (*Repository, error) {
 	wd, err := os.Getwd()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get working directory: %w", err)
 	}
+	return Find(wd)
+}
+
+// Find returns the repository containing dir: the nearest directory at or above dir that
+// holds a .carya directory. Without one, the repository is rooted at the top level of the
+// git work tree containing dir, where it can be created; outside git, ErrNotFound is returned.
+func Find(dir string) (*Repository, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
 
+	for current := dir; ; {
+		if info, err := os.Stat(filepath.Join(current, DirName)); err == nil && info.IsDir() {
+			return at(current), nil
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+
+	if topLevel, err := git.TopLevel(dir); err == nil {
+		return at(topLevel), nil
+	}
+	return nil, fmt.Errorf("%s: %w", dir, ErrNotFound)
+}
+
+// at returns the repository rooted at root
+func at(root string) *Repository {
 	return &Repository{
-		rootPath:  wd,
-		caryaPath: filepath.Join(wd, ".carya"),
-	}, nil
+		rootPath:  root,
+		caryaPath: filepath.Join(root, DirName),
+	}
 }
 
 // EnsureExists creates the .carya directory if it doesn't exist
@@ -63,3 +103,8 @@ func (r *Repository) PIDPath() string {
 func (r *Repository) LogPath() string {
 	return filepath.Join(r.caryaPath, "carya.log")
 }
+
+// SocketPath returns the path to the daemon control socket
+func (r *Repository) SocketPath() string {
+	return filepath.Join(r.caryaPath, "carya.sock")
+}
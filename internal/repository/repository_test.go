@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	tests := []struct {
+		name  string
+		dirs  []string // Directories to create, relative to the test directory
+		files []string // Files to create
+		git   string   // Directory to make a git work tree, if any
+		from  string   // Directory Find starts from
+		want  string   // Expected root; empty if none is found
+	}{
+		{
+			name: "carya directory in the start directory",
+			dirs: []string{"project/.carya"},
+			from: "project",
+			want: "project",
+		},
+		{
+			name: "carya directory above",
+			dirs: []string{"project/.carya", "project/src/components"},
+			from: "project/src/components",
+			want: "project",
+		},
+		{
+			name: "nearest carya directory wins",
+			dirs: []string{"project/.carya", "project/nested/.carya", "project/nested/src"},
+			from: "project/nested/src",
+			want: "project/nested",
+		},
+		{
+			name:  "carya file is not a repository",
+			dirs:  []string{"project/.carya", "project/src"},
+			files: []string{"project/src/.carya"},
+			from:  "project/src",
+			want:  "project",
+		},
+		{
+			name: "carya directory above the git top level",
+			dirs: []string{".carya", "project/src"},
+			git:  "project",
+			from: "project/src",
+			want: ".",
+		},
+		{
+			name: "git top level without a carya directory",
+			dirs: []string{"project/src/components"},
+			git:  "project",
+			from: "project/src/components",
+			want: "project",
+		},
+		{
+			name: "neither",
+			dirs: []string{"project/src"},
+			from: "project/src",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := filepath.EvalSymlinks(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Keep git from finding a work tree around the test directory
+			t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(base))
+
+			for _, dir := range tt.dirs {
+				if err := os.MkdirAll(filepath.Join(base, dir), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, file := range tt.files {
+				if err := os.WriteFile(filepath.Join(base, file), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.git != "" {
+				cmd := exec.Command("git", "init", "-q", filepath.Join(base, tt.git))
+				if out, err := cmd.CombinedOutput(); err != nil {
+					t.Fatalf("git init: %v\n%s", err, out)
+				}
+			}
+
+			repo, err := Find(filepath.Join(base, tt.from))
+			if tt.want == "" {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("Find() = %v, %v, want ErrNotFound", repo, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			want := filepath.Join(base, tt.want)
+			if repo.RootPath() != want || repo.CaryaPath() != filepath.Join(want, DirName) {
+				t.Errorf("Find() = root %s, carya %s, want root %s", repo.RootPath(), repo.CaryaPath(), want)
+			}
+		})
+	}
+}
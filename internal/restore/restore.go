@@ -0,0 +1,227 @@
+// Package restore writes file snapshots recorded in chunks back to the working tree,
+// saving a safety snapshot of the current file first so every restore can be undone.
+package restore
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/deps"
+)
+
+// ErrDependents is returned by Check when chunks in other files depend on the changes a
+// restore would undo.
+var ErrDependents = errors.New("chunks in other files depend on changes the restore undoes")
+
+// Target selects which snapshot of a chunk to restore.
+type Target int
+
+const (
+	// After restores the file to its state when the chunk ended.
+	After Target = iota
+	// Before restores the file to its state before the chunk's first change.
+	Before
+)
+
+// String returns a human-readable name for the target.
+func (t Target) String() string {
+	if t == Before {
+		return "before"
+	}
+	return "after"
+}
+
+// Result describes a completed restore.
+type Result struct {
+	Chunk    chunk.Chunk // The chunk whose snapshot was restored
+	Target   Target      // Which snapshot was written
+	Safety   chunk.Chunk // Chunk recording the file's state before the restore
+	Modified bool        // False if the file already matched the snapshot
+}
+
+// Restorer restores files from chunk snapshots held in a chunk store.
+type Restorer struct {
+	store  chunk.ChunkStore // Storage backend holding chunks and their snapshots
+	linker chunk.Linker     // Optional recorder of dependencies for safety chunks
+	root   string           // Git work tree that paths in safety chunk diffs are relative to
+}
+
+// New creates a new restorer backed by the given store.
+func New(store chunk.ChunkStore) *Restorer {
+	return &Restorer{store: store}
+}
+
+// SetLinker sets the recorder that links safety chunks to the chunks they depend on.
+func (r *Restorer) SetLinker(linker chunk.Linker) {
+	r.linker = linker
+}
+
+// SetRoot sets the git work tree that paths in the diffs of safety chunks are relative to.
+func (r *Restorer) SetRoot(root string) {
+	r.root = root
+}
+
+// Discarded returns the chunks whose changes a restore would undo: the chunks of the same
+// file that ended after the restored state, including the chunk itself when restoring Before.
+func (r *Restorer) Discarded(id chunk.ChunkID, target Target) ([]chunk.Chunk, error) {
+	c, err := r.store.GetChunk(id)
+	if err != nil {
+		return nil, err
+	}
+
+	sameFile, err := r.store.FindChunks(c.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var discarded []chunk.Chunk
+	for _, other := range sameFile {
+		if other.ID == c.ID {
+			if target == Before {
+				discarded = append(discarded, other)
+			}
+			continue
+		}
+		if other.EndTime.After(c.EndTime) {
+			discarded = append(discarded, other)
+		}
+	}
+	return discarded, nil
+}
+
+// BrokenDependents returns the edges from chunks in other files that depend, directly or
+// transitively, on the changes a restore would undo, as recorded in g.
+func (r *Restorer) BrokenDependents(g deps.Graph, id chunk.ChunkID, target Target) ([]deps.Edge, error) {
+	discarded, err := r.Discarded(id, target)
+	if err != nil || len(discarded) == 0 {
+		return nil, err
+	}
+
+	// Later chunks of the restored file are undone along with it, so every remaining
+	// dependent belongs to another file
+	ids := make([]chunk.ChunkID, len(discarded))
+	for i, c := range discarded {
+		ids[i] = c.ID
+	}
+	return deps.Dependents(g, ids)
+}
+
+// Check returns the edges BrokenDependents finds for a restore, along with ErrDependents
+// if there are any and the restore isn't forced.
+func (r *Restorer) Check(g deps.Graph, id chunk.ChunkID, target Target, force bool) ([]deps.Edge, error) {
+	broken, err := r.BrokenDependents(g, id, target)
+	if err != nil {
+		return nil, err
+	}
+	if len(broken) > 0 && !force {
+		return broken, ErrDependents
+	}
+	return broken, nil
+}
+
+// Restore writes the selected snapshot of the chunk with the given ID to its file.
+// The file's current content is saved as a manual chunk first, so the restore itself
+// shows up in history and can be reverted like any other change.
+func (r *Restorer) Restore(id chunk.ChunkID, target Target) (*Result, error) {
+	c, err := r.store.GetChunk(id)
+	if err != nil {
+		return nil, err
+	}
+
+	path, snapshot, absent := c.FilePath, c.After, false
+	from := path
+	switch {
+	case target == After && c.Op == chunk.OpDelete:
+		absent = true
+	case target == Before && c.Op == chunk.OpCreate:
+		absent = true
+	case target == Before && c.Op == chunk.OpRename:
+		// Move the file back to where it was before the chunk
+		path, snapshot = c.OldPath, c.Before
+	case target == Before:
+		snapshot = c.Before
+	}
+	if snapshot == nil && !absent {
+		return nil, fmt.Errorf("chunk %s has no stored %s snapshot", id, target)
+	}
+
+	current, err := os.ReadFile(from)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read current file: %w", err)
+	}
+	exists := err == nil
+
+	result := &Result{Chunk: *c, Target: target}
+	if from == path && ((absent && !exists) || (!absent && exists && string(current) == string(snapshot))) {
+		return result, nil
+	}
+
+	now := time.Now()
+	safety := chunk.Chunk{
+		ID:        chunk.ChunkID(fmt.Sprintf("%s-restore-%d", path, now.UnixNano())),
+		FilePath:  path,
+		StartTime: now,
+		EndTime:   now,
+		Hash:      chunk.ChunkHash(fmt.Sprintf("%x", sha256.Sum256(snapshot))),
+		Manual:    true,
+		Op:        chunk.OpModify,
+		Before:    current,
+		After:     snapshot,
+	}
+	switch {
+	case absent:
+		safety.Op = chunk.OpDelete
+	case from != path:
+		safety.Op = chunk.OpRename
+		safety.OldPath = from
+	case !exists:
+		safety.Op = chunk.OpCreate
+	}
+	safety.Diff = chunk.FileDiff(safety.Op, chunk.DiffPath(r.root, from), chunk.DiffPath(r.root, path),
+		current, snapshot, chunk.DefaultContextLines)
+	if err := r.store.SaveChunk(safety); err != nil {
+		return nil, fmt.Errorf("failed to save safety snapshot: %w", err)
+	}
+	if r.linker != nil {
+		if err := r.linker.LinkChunk(safety); err != nil {
+			return nil, fmt.Errorf("failed to record dependencies of safety snapshot: %w", err)
+		}
+	}
+
+	if !absent {
+		if err := writeFile(path, snapshot); err != nil {
+			return nil, err
+		}
+	}
+	if absent || from != path {
+		if err := os.Remove(from); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove file: %w", err)
+		}
+	}
+
+	result.Safety = safety
+	result.Modified = true
+	return result, nil
+}
+
+// writeFile replaces the file at path with content, keeping its permissions if it exists.
+func writeFile(path string, content []byte) error {
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, content, mode); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package restore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/deps"
+)
+
+// fakeStore is an in-memory chunk store and dependency graph. When a chunk is saved it
+// records what the chunk's file held on disk at that moment.
+type fakeStore struct {
+	chunks  []chunk.Chunk
+	edges   []deps.Edge
+	onDisk  map[chunk.ChunkID]string // Content of each saved chunk's file when it was saved
+	missing map[chunk.ChunkID]bool   // Saved chunks whose file didn't exist when they were saved
+}
+
+func (s *fakeStore) SaveChunk(c chunk.Chunk) error {
+	path := c.FilePath
+	if c.Op == chunk.OpRename {
+		path = c.OldPath
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		s.missing[c.ID] = true
+	}
+	s.onDisk[c.ID] = string(content)
+	s.chunks = append(s.chunks, c)
+	return nil
+}
+
+func (s *fakeStore) FindChunks(filePath string) ([]chunk.Chunk, error) {
+	var found []chunk.Chunk
+	for _, c := range s.chunks {
+		if c.FilePath == filePath {
+			found = append(found, c)
+		}
+	}
+	return found, nil
+}
+
+func (s *fakeStore) GetRecentChunks(limit int) ([]chunk.Chunk, error) {
+	return s.chunks, nil
+}
+
+func (s *fakeStore) GetChunk(id chunk.ChunkID) (*chunk.Chunk, error) {
+	for _, c := range s.chunks {
+		if c.ID == id {
+			return &c, nil
+		}
+	}
+	return nil, errors.New("chunk not found")
+}
+
+func (s *fakeStore) FindChunksBetween(since, until time.Time) ([]chunk.Chunk, error) {
+	return s.chunks, nil
+}
+
+func (s *fakeStore) QueryChunks(q chunk.Query) (*chunk.Page, error) {
+	return &chunk.Page{Chunks: s.chunks}, nil
+}
+
+func (s *fakeStore) DeleteChunks(ids []chunk.ChunkID) error {
+	return errors.New("not implemented")
+}
+
+func (s *fakeStore) FindDependencies(id chunk.ChunkID) ([]deps.Edge, error) {
+	var found []deps.Edge
+	for _, e := range s.edges {
+		if e.Chunk == id {
+			found = append(found, e)
+		}
+	}
+	return found, nil
+}
+
+func (s *fakeStore) FindDependents(id chunk.ChunkID) ([]deps.Edge, error) {
+	var found []deps.Edge
+	for _, e := range s.edges {
+		if e.DependsOn == id {
+			found = append(found, e)
+		}
+	}
+	return found, nil
+}
+
+// newFakeStore returns a store holding chunks, which ended in the order given.
+func newFakeStore(chunks ...chunk.Chunk) *fakeStore {
+	s := &fakeStore{onDisk: make(map[chunk.ChunkID]string), missing: make(map[chunk.ChunkID]bool)}
+	end := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, c := range chunks {
+		c.StartTime = end.Add(time.Duration(i) * time.Minute)
+		c.EndTime = c.StartTime.Add(30 * time.Second)
+		s.chunks = append(s.chunks, c)
+	}
+	return s
+}
+
+func TestRestore(t *testing.T) {
+	tests := []struct {
+		name      string
+		c         chunk.Chunk // Paths are relative to the test directory
+		target    Target
+		current   map[string]string // Files on disk before the restore
+		want      map[string]string // Files on disk after the restore; "" if absent
+		wantOp    chunk.Op          // Op of the safety chunk
+		unchanged bool              // The file already matches; no safety chunk is saved
+	}{
+		{
+			name:    "after",
+			c:       chunk.Chunk{ID: "a", FilePath: "f.txt", Op: chunk.OpModify, Before: []byte("one\n"), After: []byte("two\n")},
+			target:  After,
+			current: map[string]string{"f.txt": "three\n"},
+			want:    map[string]string{"f.txt": "two\n"},
+			wantOp:  chunk.OpModify,
+		},
+		{
+			name:    "before",
+			c:       chunk.Chunk{ID: "a", FilePath: "f.txt", Op: chunk.OpModify, Before: []byte("one\n"), After: []byte("two\n")},
+			target:  Before,
+			current: map[string]string{"f.txt": "two\n"},
+			want:    map[string]string{"f.txt": "one\n"},
+			wantOp:  chunk.OpModify,
+		},
+		{
+			name:      "already restored",
+			c:         chunk.Chunk{ID: "a", FilePath: "f.txt", Op: chunk.OpModify, Before: []byte("one\n"), After: []byte("two\n")},
+			target:    After,
+			current:   map[string]string{"f.txt": "two\n"},
+			want:      map[string]string{"f.txt": "two\n"},
+			unchanged: true,
+		},
+		{
+			name:    "deleted file comes back",
+			c:       chunk.Chunk{ID: "a", FilePath: "f.txt", Op: chunk.OpModify, Before: []byte("one\n"), After: []byte("two\n")},
+			target:  After,
+			current: map[string]string{},
+			want:    map[string]string{"f.txt": "two\n"},
+			wantOp:  chunk.OpCreate,
+		},
+		{
+			name:    "before a create removes the file",
+			c:       chunk.Chunk{ID: "a", FilePath: "f.txt", Op: chunk.OpCreate, After: []byte("new\n")},
+			target:  Before,
+			current: map[string]string{"f.txt": "newer\n"},
+			want:    map[string]string{"f.txt": ""},
+			wantOp:  chunk.OpDelete,
+		},
+		{
+			name:    "before a rename moves the file back",
+			c:       chunk.Chunk{ID: "a", FilePath: "new.txt", OldPath: "old.txt", Op: chunk.OpRename, Before: []byte("one\n"), After: []byte("one\n")},
+			target:  Before,
+			current: map[string]string{"new.txt": "edited\n"},
+			want:    map[string]string{"new.txt": "", "old.txt": "one\n"},
+			wantOp:  chunk.OpRename,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			c := tt.c
+			c.FilePath = filepath.Join(dir, c.FilePath)
+			if c.OldPath != "" {
+				c.OldPath = filepath.Join(dir, c.OldPath)
+			}
+			for name, content := range tt.current {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			current, _ := os.ReadFile(c.FilePath)
+
+			s := newFakeStore(c)
+			r := New(s)
+			r.SetRoot(dir)
+			result, err := r.Restore(c.ID, tt.target)
+			if err != nil {
+				t.Fatalf("Restore() error = %v", err)
+			}
+
+			for name, want := range tt.want {
+				got, _ := os.ReadFile(filepath.Join(dir, name))
+				if string(got) != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if tt.unchanged {
+				if result.Modified || len(s.chunks) != 1 {
+					t.Errorf("Restore() modified = %v with %d chunks saved, want the file left alone", result.Modified, len(s.chunks)-1)
+				}
+				return
+			}
+
+			// The safety chunk holds the old content and was saved before it was overwritten
+			safety := result.Safety
+			if !result.Modified || safety.ID == "" || !safety.Manual || safety.Op != tt.wantOp {
+				t.Fatalf("Restore() = %+v, want a modified file and a manual %s safety chunk", result, tt.wantOp)
+			}
+			if string(safety.Before) != string(current) {
+				t.Errorf("safety chunk before = %q, want %q", safety.Before, current)
+			}
+			if got, ok := s.onDisk[safety.ID]; !ok || string(got) != string(current) || s.missing[safety.ID] != (current == nil) {
+				t.Errorf("file held %q when the safety chunk was saved, want %q", got, current)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	// b and c change f.txt after a; d in another file depends on c, and e on d
+	s := newFakeStore(
+		chunk.Chunk{ID: "a", FilePath: "/repo/f.txt", Op: chunk.OpModify},
+		chunk.Chunk{ID: "b", FilePath: "/repo/f.txt", Op: chunk.OpModify},
+		chunk.Chunk{ID: "c", FilePath: "/repo/f.txt", Op: chunk.OpModify},
+		chunk.Chunk{ID: "d", FilePath: "/repo/g.txt", Op: chunk.OpModify},
+		chunk.Chunk{ID: "e", FilePath: "/repo/h.txt", Op: chunk.OpModify},
+	)
+	s.edges = []deps.Edge{
+		{Chunk: "c", DependsOn: "b", Kind: deps.KindOverlap},
+		{Chunk: "d", DependsOn: "c", Kind: deps.KindSymbol},
+		{Chunk: "e", DependsOn: "d", Kind: deps.KindSymbol},
+	}
+
+	tests := []struct {
+		name       string
+		id         chunk.ChunkID
+		target     Target
+		force      bool
+		wantBroken []chunk.ChunkID
+		wantErr    error
+	}{
+		{name: "dependents refuse the restore", id: "a", target: After, wantBroken: []chunk.ChunkID{"d", "e"}, wantErr: ErrDependents},
+		{name: "forced", id: "a", target: After, force: true, wantBroken: []chunk.ChunkID{"d", "e"}},
+		{name: "before the chunk the dependents use", id: "c", target: Before, wantBroken: []chunk.ChunkID{"d", "e"}, wantErr: ErrDependents},
+		{name: "after the last chunk", id: "c", target: After},
+		{name: "chunk of another file", id: "e", target: Before},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broken, err := New(s).Check(s, tt.id, tt.target, tt.force)
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("Check() error = %v, want %v", err, tt.wantErr)
+			}
+			var got []chunk.ChunkID
+			for _, e := range broken {
+				got = append(got, e.Chunk)
+			}
+			if !reflect.DeepEqual(got, tt.wantBroken) {
+				t.Errorf("Check() broken = %v, want %v", got, tt.wantBroken)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package retention
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/feature"
+	"carya/internal/git"
+)
+
+// Store is the chunk storage compaction works on.
+type Store interface {
+	// FindChunksBetween retrieves all chunks that started within the given time range, oldest first.
+	FindChunksBetween(since, until time.Time) ([]chunk.Chunk, error)
+	// GetChunk retrieves a single chunk by its ID, including its file snapshots.
+	GetChunk(id chunk.ChunkID) (*chunk.Chunk, error)
+	// DeleteChunks removes the chunks with the given IDs.
+	DeleteChunks(ids []chunk.ChunkID) error
+	// ReplaceChunks saves merged in place of the replaced chunks.
+	ReplaceChunks(merged chunk.Chunk, replaced []chunk.ChunkID) error
+}
+
+// Merge replaces several chunks of a file with a single chunk spanning all of them.
+type Merge struct {
+	Into     chunk.Chunk     // The merged chunk; it keeps the ID of the latest replaced chunk
+	Replaces []chunk.ChunkID // The chunks it replaces, oldest first
+}
+
+// Plan lists what a compaction changes.
+type Plan struct {
+	Upstream string        // Upstream branch pushed chunks were looked up in, empty if not checked
+	Pushed   []chunk.Chunk // Chunks dropped because their result is in the upstream branch
+	Unneeded []chunk.Chunk // Chunks dropped because together they leave the file unchanged
+	Merges   []Merge       // Groups of chunks merged into one
+	root     string        // Git work tree that paths in merged diffs are relative to
+}
+
+// Removed returns how many chunks the plan removes.
+func (p *Plan) Removed() int {
+	removed := len(p.Pushed) + len(p.Unneeded)
+	for _, m := range p.Merges {
+		removed += len(m.Replaces) - 1
+	}
+	return removed
+}
+
+// PlanCompaction works out how to apply the policy to the chunks in s at time now.
+// root is the git work tree, used to find chunks contained in the upstream branch.
+func PlanCompaction(s Store, root string, policy Policy, now time.Time) (*Plan, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	cutoff := now.AddDate(0, 0, -policy.KeepDays)
+	candidates, err := s.FindChunksBetween(time.Time{}, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load old chunks: %w", err)
+	}
+
+	var old []chunk.Chunk
+	for _, c := range candidates {
+		if c.EndTime.Before(cutoff) {
+			old = append(old, c)
+		}
+	}
+
+	plan := &Plan{root: root}
+	if policy.DropPushed && len(old) > 0 {
+		if old, err = plan.dropPushed(s, root, old); err != nil {
+			return nil, err
+		}
+	}
+	if policy.Thin != ThinNone {
+		if err := plan.thin(s, old, policy.Thin); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// dropPushed moves the chunks whose changes are in the upstream branch to p.Pushed and
+// returns the others: chunks committed in a commit the upstream branch contains, and
+// chunks that left their file as it is at the tip of the upstream branch. Matching an
+// older version of the file isn't enough, or a local revert to it would count as pushed.
+// Without an upstream branch, nothing is dropped.
+func (p *Plan) dropPushed(s Store, root string, chunks []chunk.Chunk) ([]chunk.Chunk, error) {
+	upstream, err := git.Upstream(root)
+	if err != nil {
+		return chunks, nil
+	}
+	tip, err := git.TreeBlobs(root, upstream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read files of %s: %w", upstream, err)
+	}
+	p.Upstream = upstream
+
+	contained := make(map[string]bool) // Whether upstream contains each commit seen so far
+	var kept []chunk.Chunk
+	for _, c := range chunks {
+		if c.Committed != "" {
+			in, ok := contained[c.Committed]
+			if !ok {
+				// A commit that no longer exists, as after a rebase, doesn't count
+				in, _ = git.IsAncestor(root, c.Committed, upstream)
+				contained[c.Committed] = in
+			}
+			if in {
+				p.Pushed = append(p.Pushed, c)
+				continue
+			}
+		}
+
+		rel, err := filepath.Rel(root, c.FilePath)
+		blob, ok := tip[filepath.ToSlash(rel)]
+		if err != nil || c.Op == chunk.OpDelete || !ok {
+			kept = append(kept, c)
+			continue
+		}
+
+		full, err := s.GetChunk(c.ID)
+		if err != nil {
+			return nil, err
+		}
+		if full.After != nil && git.BlobID(full.After) == blob {
+			p.Pushed = append(p.Pushed, c)
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept, nil
+}
+
+// groupKey identifies the chunks of a file merged together when thinning.
+type groupKey struct {
+	tag    feature.Tag // Chunks of different features are never merged
+	branch string      // Nor chunks made on different branches
+	bucket time.Time   // Start of the hour or day the chunks started in
+}
+
+// thin merges the runs of consecutive chunks of each file that share a feature and a
+// branch and started in the same hour or day. A chunk of another feature or branch in
+// between ends a run, since merging across it would fold its changes into the merged
+// diff or reorder them.
+func (p *Plan) thin(s Store, chunks []chunk.Chunk, period Granularity) error {
+	files := make(map[string][]chunk.Chunk)
+	var paths []string
+	for _, c := range chunks {
+		if files[c.FilePath] == nil {
+			paths = append(paths, c.FilePath)
+		}
+		files[c.FilePath] = append(files[c.FilePath], c)
+	}
+
+	for _, path := range paths {
+		history := files[path]
+		sort.SliceStable(history, func(i, j int) bool { return history[i].StartTime.Before(history[j].StartTime) })
+
+		run := []chunk.Chunk{history[0]}
+		key := groupKey{tag: history[0].FeatureTag, branch: history[0].Branch, bucket: bucketStart(history[0].StartTime, period)}
+		for _, c := range history[1:] {
+			next := groupKey{tag: c.FeatureTag, branch: c.Branch, bucket: bucketStart(c.StartTime, period)}
+			if next == key {
+				run = append(run, c)
+				continue
+			}
+			if err := p.merge(s, run); err != nil {
+				return err
+			}
+			run, key = []chunk.Chunk{c}, next
+		}
+		if err := p.merge(s, run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// merge plans replacing a group of chunks of one file, oldest first, by a single chunk
+// going from the state before the first to the state after the last. Single chunks and
+// groups with renames or missing snapshots are left alone.
+func (p *Plan) merge(s Store, group []chunk.Chunk) error {
+	if len(group) < 2 {
+		return nil
+	}
+	for _, c := range group {
+		if c.Op == chunk.OpRename {
+			return nil
+		}
+	}
+
+	first, err := s.GetChunk(group[0].ID)
+	if err != nil {
+		return err
+	}
+	last, err := s.GetChunk(group[len(group)-1].ID)
+	if err != nil {
+		return err
+	}
+
+	op := chunk.OpModify
+	created, deleted := first.Op == chunk.OpCreate, last.Op == chunk.OpDelete
+	switch {
+	case created && deleted:
+		// The file came and went; nothing of it remains
+		p.Unneeded = append(p.Unneeded, group...)
+		return nil
+	case created:
+		op = chunk.OpCreate
+	case deleted:
+		op = chunk.OpDelete
+	}
+
+	before, after := first.Before, last.After
+	if (op != chunk.OpCreate && before == nil) || (op != chunk.OpDelete && after == nil) {
+		return nil
+	}
+	if op == chunk.OpModify && bytes.Equal(before, after) {
+		p.Unneeded = append(p.Unneeded, group...)
+		return nil
+	}
+
+	merged := chunk.Chunk{
+		ID:         last.ID,
+		FilePath:   last.FilePath,
+		Diff:       chunk.FileDiff(op, "", chunk.DiffPath(p.root, last.FilePath), before, after, chunk.DefaultContextLines),
+		StartTime:  first.StartTime,
+		EndTime:    last.EndTime,
+		FeatureTag: last.FeatureTag,
+		Hash:       chunk.ChunkHash(fmt.Sprintf("%x", sha256.Sum256(after))),
+		Op:         op,
+		Branch:     last.Branch,
+		Head:       last.Head,
+		Committed:  last.Committed,
+		Before:     before,
+		After:      after,
+	}
+	ids := make([]chunk.ChunkID, 0, len(group))
+	for _, c := range group {
+		merged.Manual = merged.Manual || c.Manual
+		if c.Committed == "" {
+			// Only committed if all of its changes are
+			merged.Committed = ""
+		}
+		ids = append(ids, c.ID)
+	}
+
+	p.Merges = append(p.Merges, Merge{Into: merged, Replaces: ids})
+	return nil
+}
+
+// bucketStart returns the start of the hour or day t falls in.
+func bucketStart(t time.Time, period Granularity) time.Time {
+	t = t.Local()
+	if period == ThinHour {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.Local)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// Apply carries out a plan.
+func Apply(s Store, plan *Plan) error {
+	var dropped []chunk.ChunkID
+	for _, c := range append(plan.Pushed, plan.Unneeded...) {
+		dropped = append(dropped, c.ID)
+	}
+	if len(dropped) > 0 {
+		if err := s.DeleteChunks(dropped); err != nil {
+			return fmt.Errorf("failed to delete chunks: %w", err)
+		}
+	}
+
+	for _, m := range plan.Merges {
+		if err := s.ReplaceChunks(m.Into, m.Replaces); err != nil {
+			return fmt.Errorf("failed to merge chunks into %s: %w", m.Into.ID, err)
+		}
+	}
+	return nil
+}
+
+// Compactor applies a retention policy on demand. It implements chunk.Compactor.
+type Compactor struct {
+	store  Store  // Chunk storage to compact
+	root   string // Git work tree the chunks belong to
+	policy Policy // Policy to apply
+}
+
+// NewCompactor creates a compactor applying policy to the chunks in store.
+func NewCompactor(store Store, root string, policy Policy) *Compactor {
+	return &Compactor{store: store, root: root, policy: policy}
+}
+
+// Compact plans and applies a compaction.
+func (c *Compactor) Compact() error {
+	plan, err := PlanCompaction(c.store, c.root, c.policy, time.Now())
+	if err != nil {
+		return err
+	}
+	if plan.Removed() == 0 {
+		return nil
+	}
+	if err := Apply(c.store, plan); err != nil {
+		return err
+	}
+
+	log.Printf("Compacted chunks: dropped %d pushed and %d unneeded, merged %d groups (%d chunks removed)",
+		len(plan.Pushed), len(plan.Unneeded), len(plan.Merges), plan.Removed())
+	return nil
+}
@@ -0,0 +1,246 @@
+package retention
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/feature"
+)
+
+// fakeStore is an in-memory Store. FindChunksBetween returns its chunks in the order
+// they were added, ignoring the time range.
+type fakeStore struct {
+	chunks []chunk.Chunk
+}
+
+func (s *fakeStore) FindChunksBetween(since, until time.Time) ([]chunk.Chunk, error) {
+	return append([]chunk.Chunk(nil), s.chunks...), nil
+}
+
+func (s *fakeStore) GetChunk(id chunk.ChunkID) (*chunk.Chunk, error) {
+	for _, c := range s.chunks {
+		if c.ID == id {
+			return &c, nil
+		}
+	}
+	return nil, errors.New("chunk not found")
+}
+
+func (s *fakeStore) DeleteChunks(ids []chunk.ChunkID) error {
+	return errors.New("not implemented")
+}
+
+func (s *fakeStore) ReplaceChunks(merged chunk.Chunk, replaced []chunk.ChunkID) error {
+	return errors.New("not implemented")
+}
+
+// step is a chunk of the test file taking it from version before to version after.
+type step struct {
+	id      string
+	tag     feature.Tag
+	branch  string
+	minutes int // Minutes after noon on the first day the chunk started
+	before  int
+	after   int
+}
+
+// version returns the content of the test file at version n.
+func version(n int) []byte {
+	return []byte(fmt.Sprintf("version %d\n", n))
+}
+
+func TestThinMergesContiguousRuns(t *testing.T) {
+	type merge struct {
+		ids    []chunk.ChunkID
+		before int
+		after  int
+	}
+
+	tests := []struct {
+		name  string
+		steps []step // In the order the store returns them
+		want  []merge
+	}{
+		{
+			name: "interleaved branches",
+			steps: []step{
+				{id: "b3", branch: "b", minutes: 3, before: 2, after: 3},
+				{id: "a1", branch: "a", minutes: 1, before: 0, after: 1},
+				{id: "a5", branch: "a", minutes: 5, before: 4, after: 5},
+				{id: "a2", branch: "a", minutes: 2, before: 1, after: 2},
+				{id: "b6", branch: "b", minutes: 6, before: 5, after: 6},
+				{id: "a4", branch: "a", minutes: 4, before: 3, after: 4},
+			},
+			want: []merge{
+				{ids: []chunk.ChunkID{"a1", "a2"}, before: 0, after: 2},
+				{ids: []chunk.ChunkID{"a4", "a5"}, before: 3, after: 5},
+			},
+		},
+		{
+			name: "another feature ends the run",
+			steps: []step{
+				{id: "x1", tag: "x", minutes: 1, before: 0, after: 1},
+				{id: "x2", tag: "x", minutes: 2, before: 1, after: 2},
+				{id: "y3", tag: "y", minutes: 3, before: 2, after: 3},
+				{id: "x4", tag: "x", minutes: 4, before: 3, after: 4},
+			},
+			want: []merge{
+				{ids: []chunk.ChunkID{"x1", "x2"}, before: 0, after: 2},
+			},
+		},
+		{
+			name: "another day ends the run",
+			steps: []step{
+				{id: "d1", minutes: 1, before: 0, after: 1},
+				{id: "d2", minutes: 24 * 60, before: 1, after: 2},
+				{id: "d3", minutes: 24*60 + 1, before: 2, after: 3},
+			},
+			want: []merge{
+				{ids: []chunk.ChunkID{"d2", "d3"}, before: 1, after: 3},
+			},
+		},
+	}
+
+	noon := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	policy := Policy{KeepDays: 30, Thin: ThinDay}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakeStore{}
+			for _, st := range tt.steps {
+				start := noon.Add(time.Duration(st.minutes) * time.Minute)
+				s.chunks = append(s.chunks, chunk.Chunk{
+					ID:         chunk.ChunkID(st.id),
+					FilePath:   "/repo/f.txt",
+					StartTime:  start,
+					EndTime:    start.Add(30 * time.Second),
+					FeatureTag: st.tag,
+					Branch:     st.branch,
+					Op:         chunk.OpModify,
+					Before:     version(st.before),
+					After:      version(st.after),
+				})
+			}
+
+			plan, err := PlanCompaction(s, "/repo", policy, noon.AddDate(0, 0, 60))
+			if err != nil {
+				t.Fatalf("PlanCompaction() error = %v", err)
+			}
+			if len(plan.Merges) != len(tt.want) {
+				t.Fatalf("PlanCompaction() merges = %+v, want %d merges", plan.Merges, len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := plan.Merges[i]
+				if !reflect.DeepEqual(got.Replaces, want.ids) {
+					t.Errorf("merge %d replaces %v, want %v", i, got.Replaces, want.ids)
+				}
+				if string(got.Into.Before) != string(version(want.before)) || string(got.Into.After) != string(version(want.after)) {
+					t.Errorf("merge %d goes from %q to %q, want %q to %q",
+						i, got.Into.Before, got.Into.After, version(want.before), version(want.after))
+				}
+			}
+		})
+	}
+}
+
+// runGit runs git in dir and returns its trimmed output, failing the test if it fails.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commitVersion commits version n of f.txt in the work tree and returns the commit.
+func commitVersion(t *testing.T, root string, n int) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(root, "f.txt"), version(n), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, root, "add", "f.txt")
+	runGit(t, root, "commit", "-q", "-m", fmt.Sprintf("version %d", n))
+	return runGit(t, root, "rev-parse", "HEAD")
+}
+
+func TestDropPushed(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	// Versions 1 and 2 are pushed, version 3 is only committed locally
+	remote, root := t.TempDir(), t.TempDir()
+	runGit(t, remote, "init", "-q", "--bare")
+	runGit(t, root, "init", "-q", "-b", "main")
+	runGit(t, root, "remote", "add", "origin", remote)
+	first := commitVersion(t, root, 1)
+	runGit(t, root, "push", "-q", "-u", "origin", "main")
+	commitVersion(t, root, 2)
+	runGit(t, root, "push", "-q")
+	local := commitVersion(t, root, 3)
+
+	tests := []struct {
+		name      string
+		id        string
+		committed string
+		after     int
+		pushed    bool
+	}{
+		{name: "pushed commit", id: "a", committed: first, after: 1, pushed: true},
+		{name: "content at the upstream tip", id: "b", after: 2, pushed: true},
+		{name: "revert to an older pushed version", id: "c", after: 1, pushed: false},
+		{name: "unpushed commit", id: "d", committed: local, after: 3, pushed: false},
+		{name: "commit that no longer exists", id: "e", committed: strings.Repeat("0", 40), after: 4, pushed: false},
+	}
+
+	noon := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	s := &fakeStore{}
+	for i, tt := range tests {
+		start := noon.Add(time.Duration(i) * time.Minute)
+		s.chunks = append(s.chunks, chunk.Chunk{
+			ID:        chunk.ChunkID(tt.id),
+			FilePath:  filepath.Join(root, "f.txt"),
+			StartTime: start,
+			EndTime:   start.Add(30 * time.Second),
+			Op:        chunk.OpModify,
+			Committed: tt.committed,
+			Before:    version(0),
+			After:     version(tt.after),
+		})
+	}
+
+	plan, err := PlanCompaction(s, root, Policy{KeepDays: 30, Thin: ThinNone, DropPushed: true}, noon.AddDate(0, 0, 60))
+	if err != nil {
+		t.Fatalf("PlanCompaction() error = %v", err)
+	}
+	if plan.Upstream != "origin/main" {
+		t.Errorf("PlanCompaction() upstream = %q, want origin/main", plan.Upstream)
+	}
+	pushed := make(map[chunk.ChunkID]bool)
+	for _, c := range plan.Pushed {
+		pushed[c.ID] = true
+	}
+	for _, tt := range tests {
+		if got := pushed[chunk.ChunkID(tt.id)]; got != tt.pushed {
+			t.Errorf("%s: pushed = %v, want %v", tt.name, got, tt.pushed)
+		}
+	}
+}
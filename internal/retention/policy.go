@@ -0,0 +1,53 @@
+// Package retention decides which old chunks to keep. Recent chunks are kept as they are;
+// older chunks already contained in a pushed commit are dropped, and the rest are thinned
+// out by merging each file's chunks per hour or day.
+package retention
+
+import "fmt"
+
+// PolicyFile is the name of the retention policy file earlier versions kept in the .carya
+// directory. The retention.* settings replace it, but its values are still read.
+const PolicyFile = "retention.json"
+
+// Granularity is the period old chunks of a file are merged over.
+type Granularity string
+
+const (
+	ThinNone Granularity = "none" // Keep every chunk
+	ThinHour Granularity = "hour" // Keep one chunk per file per hour
+	ThinDay  Granularity = "day"  // Keep one chunk per file per day
+)
+
+// Policy configures which chunks are kept.
+type Policy struct {
+	KeepDays    int         `json:"keep_days"`    // Chunks newer than this many days are never touched
+	Thin        Granularity `json:"thin"`         // Period older chunks of a file are merged over
+	DropPushed  bool        `json:"drop_pushed"`  // Drop older chunks whose result is in the upstream branch
+	AutoCompact bool        `json:"auto_compact"` // Let the daemon compact when it goes idle
+}
+
+// DefaultPolicy returns the policy used when no retention.* setting is configured.
+func DefaultPolicy() Policy {
+	return Policy{
+		KeepDays:   30,
+		Thin:       ThinDay,
+		DropPushed: true,
+	}
+}
+
+// Validate reports the first invalid setting of the policy.
+func (p Policy) Validate() error {
+	if p.KeepDays < 0 {
+		return fmt.Errorf("keep_days must not be negative, got %d", p.KeepDays)
+	}
+	return p.Thin.Validate()
+}
+
+// Validate reports whether g is a known granularity.
+func (g Granularity) Validate() error {
+	switch g {
+	case ThinNone, ThinHour, ThinDay:
+		return nil
+	}
+	return fmt.Errorf("invalid thinning period %q (use none, hour or day)", g)
+}
@@ -0,0 +1,133 @@
+// Package staging stages chunks, or some of their hunks, into the git index and commits
+// them, leaving the working tree untouched.
+package staging
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"carya/internal/chunk"
+	"carya/internal/git"
+)
+
+// Item is a chunk, or some of the hunks of its diff, to stage.
+type Item struct {
+	Chunk chunk.Chunk // Chunk whose changes are staged
+	Hunks []int       // Indexes of the hunks of the chunk's diff to stage; nil stages all of them
+}
+
+// Whole returns items staging every hunk of each chunk.
+func Whole(chunks []chunk.Chunk) []Item {
+	items := make([]Item, len(chunks))
+	for i, c := range chunks {
+		items[i] = Item{Chunk: c}
+	}
+	return items
+}
+
+// Commit stages the items (in order) into the git index of the work tree containing dir
+// and commits them with message, letting git open the user's editor if message is empty.
+// If any item fails to apply, the index is reset and nothing is committed.
+func Commit(dir string, items []Item, message string) (string, error) {
+	topLevel, err := git.TopLevel(dir)
+	if err != nil {
+		return "", err
+	}
+
+	staged, err := git.HasStagedChanges(topLevel)
+	if err != nil {
+		return "", err
+	}
+	if staged {
+		return "", fmt.Errorf("the git index already has staged changes; commit or unstage them first")
+	}
+
+	applied := 0
+	for _, item := range items {
+		c := item.Chunk
+		hunks, err := chunk.ParseHunks(c.Diff)
+		if err != nil {
+			return "", fmt.Errorf("chunk %s: %w", c.ID, err)
+		}
+		if item.Hunks != nil {
+			if hunks, err = selectHunks(hunks, item.Hunks); err != nil {
+				return "", fmt.Errorf("chunk %s: %w", c.ID, err)
+			}
+		}
+		if len(hunks) == 0 && c.Op != chunk.OpDelete && c.Op != chunk.OpRename {
+			continue
+		}
+
+		relPath, err := RelPath(topLevel, c.FilePath)
+		if err != nil {
+			return "", fmt.Errorf("chunk %s: %w", c.ID, err)
+		}
+
+		switch c.Op {
+		case chunk.OpDelete:
+			err = git.RemoveFromIndex(topLevel, relPath)
+		case chunk.OpRename:
+			oldRelPath, relErr := RelPath(topLevel, c.OldPath)
+			if relErr != nil {
+				return "", fmt.Errorf("chunk %s: %w", c.ID, relErr)
+			}
+			err = git.ApplyToIndex(topLevel, git.BuildRenamePatch(oldRelPath, relPath, hunks))
+		case chunk.OpCreate:
+			err = git.ApplyToIndex(topLevel, git.BuildNewFilePatch(relPath, hunks))
+		default:
+			err = git.ApplyToIndex(topLevel, git.BuildPatch(relPath, hunks))
+		}
+		if err != nil {
+			git.ResetIndex(topLevel)
+			return "", fmt.Errorf("chunk %s does not apply to the index (is an earlier chunk of %s missing from the selection?): %w",
+				c.ID, relPath, err)
+		}
+		applied++
+	}
+
+	if applied == 0 {
+		return "", fmt.Errorf("the selected chunks contain no changes")
+	}
+
+	var hash string
+	if message != "" {
+		hash, err = git.Commit(topLevel, message)
+	} else {
+		hash, err = git.CommitWithEditor(topLevel)
+	}
+	if err != nil {
+		git.ResetIndex(topLevel)
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// selectHunks returns the hunks at the given indexes, in the order they appear in the diff.
+func selectHunks(hunks []chunk.Hunk, indexes []int) ([]chunk.Hunk, error) {
+	sorted := append([]int(nil), indexes...)
+	sort.Ints(sorted)
+
+	selected := make([]chunk.Hunk, 0, len(sorted))
+	for i, index := range sorted {
+		if index < 0 || index >= len(hunks) {
+			return nil, fmt.Errorf("diff has no hunk %d", index+1)
+		}
+		if i > 0 && sorted[i-1] == index {
+			continue
+		}
+		selected = append(selected, hunks[index])
+	}
+	return selected, nil
+}
+
+// RelPath returns path relative to the git top-level directory, using "/" separators.
+func RelPath(topLevel, path string) (string, error) {
+	relPath, err := filepath.Rel(topLevel, path)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return "", fmt.Errorf("%s is outside the git repository", path)
+	}
+	return filepath.ToSlash(relPath), nil
+}
@@ -0,0 +1,192 @@
+package staging
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"carya/internal/chunk"
+	"carya/internal/git"
+)
+
+// numbered returns a file of n lines holding their line numbers, with the given lines replaced.
+func numbered(n int, replaced map[int]string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		line, ok := replaced[i]
+		if !ok {
+			line = fmt.Sprint(i)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// runGit runs git in dir and returns its trimmed output, failing the test if it fails.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newRepo creates a git work tree with a commit holding files, and returns its top level.
+func newRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, root, "init", "-q")
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGit(t, root, "add", "-A")
+	runGit(t, root, "commit", "-q", "-m", "initial")
+	return root
+}
+
+// change returns a chunk taking the file at name in root from before to after.
+func change(root string, op chunk.Op, oldName, name, before, after string) chunk.Chunk {
+	path := filepath.Join(root, name)
+	var oldPath, oldDiffPath string
+	if oldName != "" {
+		oldPath = filepath.Join(root, oldName)
+		oldDiffPath = chunk.DiffPath(root, oldPath)
+	}
+	return chunk.Chunk{
+		ID:       chunk.ChunkID(op.String() + " " + name),
+		FilePath: path,
+		OldPath:  oldPath,
+		Op:       op,
+		Diff:     chunk.FileDiff(op, oldDiffPath, chunk.DiffPath(root, path), []byte(before), []byte(after), 1),
+	}
+}
+
+func TestCommit(t *testing.T) {
+	file := numbered(10, nil)
+	twoChanged := numbered(10, map[int]string{2: "two"})
+	nineChanged := numbered(10, map[int]string{9: "nine"})
+	bothChanged := numbered(10, map[int]string{2: "two", 9: "nine"})
+
+	tests := []struct {
+		name  string
+		items func(root string) []Item
+		want  map[string]string // Contents of files in the new commit; "" if the file is gone
+	}{
+		{
+			name: "whole chunks",
+			items: func(root string) []Item {
+				return Whole([]chunk.Chunk{
+					change(root, chunk.OpModify, "", "a.txt", file, twoChanged),
+					change(root, chunk.OpModify, "", "a.txt", twoChanged, bothChanged),
+					change(root, chunk.OpCreate, "", "c.txt", "", "new\n"),
+					change(root, chunk.OpDelete, "", "b.txt", "b\n", ""),
+				})
+			},
+			want: map[string]string{"a.txt": bothChanged, "b.txt": "", "c.txt": "new\n"},
+		},
+		{
+			name: "selected hunks",
+			items: func(root string) []Item {
+				return []Item{{Chunk: change(root, chunk.OpModify, "", "a.txt", file, bothChanged), Hunks: []int{1}}}
+			},
+			want: map[string]string{"a.txt": nineChanged, "b.txt": "b\n"},
+		},
+		{
+			name: "rename",
+			items: func(root string) []Item {
+				return Whole([]chunk.Chunk{change(root, chunk.OpRename, "a.txt", "moved.txt", file, twoChanged)})
+			},
+			want: map[string]string{"a.txt": "", "moved.txt": twoChanged},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := newRepo(t, map[string]string{"a.txt": file, "b.txt": "b\n"})
+			// The working tree holds other changes, which must stay out of the commit
+			if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("edited\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			hash, err := Commit(root, tt.items(root), "staged chunks")
+			if err != nil {
+				t.Fatalf("Commit() error = %v", err)
+			}
+			if head := runGit(t, root, "rev-parse", "HEAD"); hash != head {
+				t.Errorf("Commit() = %s, want HEAD %s", hash, head)
+			}
+			for name, want := range tt.want {
+				got, _ := exec.Command("git", "-C", root, "show", "HEAD:"+name).Output()
+				if string(got) != want {
+					t.Errorf("HEAD:%s = %q, want %q", name, got, want)
+				}
+			}
+			if content, _ := os.ReadFile(filepath.Join(root, "a.txt")); string(content) != "edited\n" {
+				t.Errorf("working tree a.txt = %q, want it untouched", content)
+			}
+		})
+	}
+}
+
+func TestCommitRestoresIndexOnFailure(t *testing.T) {
+	file := numbered(10, nil)
+	root := newRepo(t, map[string]string{"a.txt": file, "b.txt": "b\n"})
+	head := runGit(t, root, "rev-parse", "HEAD")
+
+	// The first chunk applies; the second expects content the file never had
+	items := Whole([]chunk.Chunk{
+		change(root, chunk.OpModify, "", "b.txt", "b\n", "bee\n"),
+		change(root, chunk.OpModify, "", "a.txt", numbered(10, map[int]string{5: "five"}), numbered(10, map[int]string{5: "FIVE"})),
+	})
+	_, err := Commit(root, items, "staged chunks")
+	if err == nil || !strings.Contains(err.Error(), "does not apply to the index") {
+		t.Fatalf("Commit() error = %v, want one saying the chunk does not apply", err)
+	}
+
+	if got := runGit(t, root, "rev-parse", "HEAD"); got != head {
+		t.Errorf("HEAD = %s, want it left at %s", got, head)
+	}
+	staged, err := git.HasStagedChanges(root)
+	if err != nil {
+		t.Fatalf("HasStagedChanges() error = %v", err)
+	}
+	if staged {
+		t.Errorf("index still has staged changes: %s", runGit(t, root, "diff", "--cached", "--stat"))
+	}
+}
+
+func TestCommitRefusesStagedChanges(t *testing.T) {
+	root := newRepo(t, map[string]string{"b.txt": "b\n"})
+	if err := os.WriteFile(filepath.Join(root, "b.txt"), []byte("staged\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, root, "add", "b.txt")
+
+	items := Whole([]chunk.Chunk{change(root, chunk.OpModify, "", "b.txt", "b\n", "bee\n")})
+	if _, err := Commit(root, items, "staged chunks"); err == nil || !strings.Contains(err.Error(), "already has staged changes") {
+		t.Errorf("Commit() error = %v, want one about staged changes", err)
+	}
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/deps"
+	"carya/internal/feature"
+)
+
+// Store is implemented by every storage backend: chunks with their file snapshots,
+// features, dependencies between chunks and changes to skipped files.
+type Store interface {
+	chunk.ChunkStore
+	chunk.Tagger
+	deps.Store
+
+	// MarkCommitted records that the changes of each chunk are contained in a git commit.
+	MarkCommitted(ids []chunk.ChunkID, commit string) error
+	// ReplaceChunks saves merged in place of the replaced chunks, moving their dependencies to it.
+	ReplaceChunks(merged chunk.Chunk, replaced []chunk.ChunkID) error
+
+	// CreateFeature persists a new feature. Returns an error if a feature with the same tag exists.
+	CreateFeature(f feature.Feature) error
+	// GetFeature retrieves a feature by tag. Returns ErrFeatureNotFound if it doesn't exist.
+	GetFeature(tag feature.Tag) (*feature.Feature, error)
+	// ListFeatures retrieves all features, newest first.
+	ListFeatures() ([]feature.Feature, error)
+	// ActiveFeature retrieves the currently active feature, or nil if no feature is active.
+	ActiveFeature() (*feature.Feature, error)
+	// SetActiveFeature makes a feature the active one; an empty tag deactivates all features.
+	SetActiveFeature(tag feature.Tag) error
+	// CloseFeature marks a feature as closed.
+	CloseFeature(tag feature.Tag) error
+	// TagChunks assigns a tag to each chunk; an empty tag removes their tags.
+	TagChunks(ids []chunk.ChunkID, tag feature.Tag) error
+	// FindChunksByFeature retrieves all chunks tagged with a feature, oldest first.
+	FindChunksByFeature(tag feature.Tag) ([]chunk.Chunk, error)
+
+	// ListDependencies retrieves every recorded dependency edge.
+	ListDependencies() ([]deps.Edge, error)
+
+	// SaveSkippedChange records a change to a file whose contents are not tracked.
+	SaveSkippedChange(sc chunk.SkippedChange) error
+	// GetRecentSkippedChanges retrieves the most recent skipped changes up to the limit.
+	GetRecentSkippedChanges(limit int) ([]chunk.SkippedChange, error)
+	// FindSkippedChanges retrieves the skipped changes recorded for a file, oldest first.
+	FindSkippedChanges(filePath string) ([]chunk.SkippedChange, error)
+
+	// ObjectRefs counts the references from chunks to each object in the object store.
+	ObjectRefs() (map[string]int, error)
+	// PruneObjects removes objects no chunk refers to that are older than the grace period.
+	PruneObjects(grace time.Duration) (*PruneResult, error)
+
+	// Close releases the store.
+	Close() error
+}
+
+// ErrSQLiteUnavailable is returned when opening a SQLite store in a build without cgo.
+var ErrSQLiteUnavailable = errors.New("this build of Carya has no SQLite support (it was built without cgo)")
+
+// Backend names a storage implementation.
+type Backend string
+
+const (
+	BackendSQLite Backend = "sqlite" // SQLite database in chunks.db; needs a build with cgo
+	BackendBolt   Backend = "bolt"   // bbolt key-value database in chunks.bolt, in pure Go
+	BackendJSON   Backend = "json"   // Plain JSON file in chunks.json, for diffing with ordinary tools
+)
+
+const (
+	// ConfigFile is the name of the storage configuration file in the .carya directory.
+	ConfigFile = "store.json"
+	// SQLiteFile is the name of the SQLite backend's database in the .carya directory.
+	SQLiteFile = "chunks.db"
+	// BoltFile is the name of the bolt backend's database in the .carya directory.
+	BoltFile = "chunks.bolt"
+	// JSONFile is the name of the JSON backend's file in the .carya directory.
+	JSONFile = "chunks.json"
+)
+
+// Config selects the storage backend of a repository.
+type Config struct {
+	Backend Backend `json:"backend"` // Storage implementation to use
+}
+
+// Validate reports whether b is a known backend.
+func (b Backend) Validate() error {
+	switch b {
+	case BackendSQLite, BackendBolt, BackendJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown storage backend %q (use sqlite, bolt or json)", b)
+}
+
+// Available reports whether this build of Carya can open stores of the backend.
+func (b Backend) Available() error {
+	if b == BackendSQLite && !sqliteAvailable {
+		return ErrSQLiteUnavailable
+	}
+	return b.Validate()
+}
+
+// Path returns the file the backend keeps its data in within the .carya directory.
+func (b Backend) Path(caryaDir string) string {
+	switch b {
+	case BackendBolt:
+		return filepath.Join(caryaDir, BoltFile)
+	case BackendJSON:
+		return filepath.Join(caryaDir, JSONFile)
+	}
+	return filepath.Join(caryaDir, SQLiteFile)
+}
+
+// DefaultBackend returns the backend of a repository without a storage configuration:
+// the one whose file is already in the .carya directory, or else SQLite if this build
+// supports it and bolt if it doesn't.
+func DefaultBackend(caryaDir string) Backend {
+	for _, b := range []Backend{BackendSQLite, BackendBolt, BackendJSON} {
+		if _, err := os.Stat(b.Path(caryaDir)); err == nil {
+			return b
+		}
+	}
+	if sqliteAvailable {
+		return BackendSQLite
+	}
+	return BackendBolt
+}
+
+// LoadConfig reads the storage configuration from the .carya directory, defaulting to
+// DefaultBackend if there is no configuration file.
+func LoadConfig(caryaDir string) (Config, error) {
+	config := Config{Backend: DefaultBackend(caryaDir)}
+
+	data, err := os.ReadFile(filepath.Join(caryaDir, ConfigFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("failed to read storage configuration: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse %s: %w", ConfigFile, err)
+	}
+	if err := config.Backend.Validate(); err != nil {
+		return config, fmt.Errorf("invalid %s: %w", ConfigFile, err)
+	}
+	return config, nil
+}
+
+// SaveConfig writes the storage configuration to the .carya directory.
+func SaveConfig(caryaDir string, config Config) error {
+	if err := config.Backend.Validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(caryaDir, ConfigFile), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", ConfigFile, err)
+	}
+	return nil
+}
+
+// Open opens the store of the repository whose .carya directory is caryaDir, using the
+// configured backend.
+func Open(caryaDir string) (Store, error) {
+	config, err := LoadConfig(caryaDir)
+	if err != nil {
+		return nil, err
+	}
+	return OpenWithConfig(caryaDir, config)
+}
+
+// OpenWithConfig opens the store of the repository whose .carya directory is caryaDir,
+// using the backend of config.
+func OpenWithConfig(caryaDir string, config Config) (Store, error) {
+	return OpenBackend(config.Backend, config.Backend.Path(caryaDir))
+}
+
+// OpenFile opens the store kept in path, choosing the backend by its extension:
+// .json files use the JSON backend, .bolt files bolt and anything else SQLite.
+func OpenFile(path string) (Store, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return OpenBackend(BackendJSON, path)
+	case ".bolt":
+		return OpenBackend(BackendBolt, path)
+	}
+	return OpenBackend(BackendSQLite, path)
+}
+
+// OpenBackend opens the store of the given backend kept in path.
+func OpenBackend(backend Backend, path string) (Store, error) {
+	var s Store
+	var err error
+	switch backend {
+	case BackendSQLite:
+		if err := backend.Available(); err != nil {
+			return nil, fmt.Errorf("%w: can't open %s", err, path)
+		}
+		s, err = NewSQLiteStore(path)
+	case BackendBolt:
+		s, err = NewBoltStore(path)
+	case BackendJSON:
+		s, err = NewJSONStore(path)
+	default:
+		err = backend.Validate()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
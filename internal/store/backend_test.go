@@ -0,0 +1,301 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/deps"
+	"carya/internal/feature"
+)
+
+// backends lists every storage backend, in the order Copy tests chain them.
+var backends = []Backend{BackendSQLite, BackendBolt, BackendJSON}
+
+// openTestStore opens an empty store of the backend in a temporary directory, skipping
+// the test if the backend isn't available in this build.
+func openTestStore(t *testing.T, backend Backend) Store {
+	t.Helper()
+	if err := backend.Available(); err != nil {
+		t.Skip(err)
+	}
+	s, err := OpenBackend(backend, backend.Path(t.TempDir()))
+	if err != nil {
+		t.Fatalf("OpenBackend(%s) error = %v", backend, err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// history holds a small history of every kind of record a store keeps.
+type history struct {
+	chunks   []chunk.Chunk // Oldest first
+	features []feature.Feature
+	edges    []deps.Edge
+	skipped  []chunk.SkippedChange // Oldest first
+}
+
+// testHistory returns a history with chunks of each op, snapshots included.
+func testHistory() history {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	newChunk := func(id string, op chunk.Op, oldPath, path string, minutes int, before, after []byte) chunk.Chunk {
+		start := base.Add(time.Duration(minutes) * time.Minute)
+		return chunk.Chunk{
+			ID:        chunk.ChunkID(id),
+			FilePath:  path,
+			OldPath:   oldPath,
+			Diff:      "diff of " + id,
+			StartTime: start,
+			EndTime:   start.Add(30 * time.Second),
+			Hash:      chunk.ChunkHash("hash-" + id),
+			Op:        op,
+			Branch:    "main",
+			Head:      "abc123",
+			Before:    before,
+			After:     after,
+		}
+	}
+
+	chunks := []chunk.Chunk{
+		newChunk("a", chunk.OpCreate, "", "/repo/main.go", 0, nil, []byte("package main\n")),
+		newChunk("b", chunk.OpModify, "", "/repo/main.go", 1, []byte("package main\n"), []byte("package main\n\nfunc main() {}\n")),
+		newChunk("c", chunk.OpRename, "/repo/old.txt", "/repo/new.txt", 2, []byte("text\n"), []byte("text\n")),
+		newChunk("d", chunk.OpDelete, "", "/repo/new.txt", 3, []byte("text\n"), nil),
+	}
+	chunks[1].FeatureTag = "login"
+	chunks[1].Manual = true
+	chunks[2].Committed = "def456"
+
+	return history{
+		chunks: chunks,
+		features: []feature.Feature{
+			{Tag: "login", Description: "Sign in", Status: feature.StatusOpen, CreatedAt: base},
+			{Tag: "search", Status: feature.StatusClosed, CreatedAt: base.Add(time.Hour), ClosedAt: base.Add(2 * time.Hour)},
+		},
+		edges: []deps.Edge{
+			{Chunk: "b", DependsOn: "a", Kind: deps.KindOverlap, Detail: "lines 1"},
+			{Chunk: "d", DependsOn: "c", Kind: deps.KindOverlap, Detail: "deletes the file"},
+			{Chunk: "d", DependsOn: "b", Kind: deps.KindSymbol, Detail: "main"},
+		},
+		skipped: []chunk.SkippedChange{
+			{FilePath: "/repo/logo.png", Size: 2048, Hash: "png", Reason: chunk.SkipBinary, Time: base},
+			{FilePath: "/repo/data.bin", Size: 1 << 30, Hash: "bin", Reason: chunk.SkipTooLarge, Time: base.Add(time.Minute)},
+		},
+	}
+}
+
+// save writes the history to s.
+func (h history) save(t *testing.T, s Store) {
+	t.Helper()
+	for _, f := range h.features {
+		if err := s.CreateFeature(f); err != nil {
+			t.Fatalf("CreateFeature(%s) error = %v", f.Tag, err)
+		}
+	}
+	for _, c := range h.chunks {
+		if err := s.SaveChunk(c); err != nil {
+			t.Fatalf("SaveChunk(%s) error = %v", c.ID, err)
+		}
+	}
+	byChunk := make(map[chunk.ChunkID][]deps.Edge)
+	for _, e := range h.edges {
+		byChunk[e.Chunk] = append(byChunk[e.Chunk], e)
+	}
+	for id, edges := range byChunk {
+		if err := s.SaveDependencies(id, edges); err != nil {
+			t.Fatalf("SaveDependencies(%s) error = %v", id, err)
+		}
+	}
+	for _, sc := range h.skipped {
+		if err := s.SaveSkippedChange(sc); err != nil {
+			t.Fatalf("SaveSkippedChange(%s) error = %v", sc.FilePath, err)
+		}
+	}
+}
+
+// check fails the test unless s holds exactly the history.
+func (h history) check(t *testing.T, s Store) {
+	t.Helper()
+	for _, want := range h.chunks {
+		got, err := s.GetChunk(want.ID)
+		if err != nil {
+			t.Errorf("GetChunk(%s) error = %v", want.ID, err)
+			continue
+		}
+		if !reflect.DeepEqual(normalizeChunk(*got), normalizeChunk(want)) {
+			t.Errorf("GetChunk(%s) = %+v, want %+v", want.ID, *got, want)
+		}
+	}
+	recent, err := s.GetRecentChunks(-1)
+	if err != nil || len(recent) != len(h.chunks) {
+		t.Errorf("GetRecentChunks() = %d chunks, %v, want %d", len(recent), err, len(h.chunks))
+	}
+
+	features, err := s.ListFeatures()
+	if err != nil {
+		t.Fatalf("ListFeatures() error = %v", err)
+	}
+	byTag := make(map[feature.Tag]feature.Feature)
+	for _, f := range features {
+		byTag[f.Tag] = f
+	}
+	for _, want := range h.features {
+		got := byTag[want.Tag]
+		if got.Description != want.Description || got.Status != want.Status ||
+			!got.CreatedAt.Equal(want.CreatedAt) || !got.ClosedAt.Equal(want.ClosedAt) {
+			t.Errorf("feature %s = %+v, want %+v", want.Tag, got, want)
+		}
+	}
+	if len(features) != len(h.features) {
+		t.Errorf("ListFeatures() = %d features, want %d", len(features), len(h.features))
+	}
+
+	edges, err := s.ListDependencies()
+	if err != nil {
+		t.Fatalf("ListDependencies() error = %v", err)
+	}
+	if !sameEdges(edges, h.edges) {
+		t.Errorf("ListDependencies() = %+v, want %+v", edges, h.edges)
+	}
+
+	skipped, err := s.GetRecentSkippedChanges(-1)
+	if err != nil {
+		t.Fatalf("GetRecentSkippedChanges() error = %v", err)
+	}
+	if len(skipped) != len(h.skipped) {
+		t.Fatalf("GetRecentSkippedChanges() = %+v, want %+v", skipped, h.skipped)
+	}
+	for i, want := range h.skipped {
+		// Newest first
+		got := skipped[len(skipped)-1-i]
+		if got.FilePath != want.FilePath || got.Size != want.Size || got.Hash != want.Hash ||
+			got.Reason != want.Reason || !got.Time.Equal(want.Time) {
+			t.Errorf("skipped change %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+// normalizeChunk returns c with its times in UTC, so chunks read back from a store compare
+// equal to the ones saved.
+func normalizeChunk(c chunk.Chunk) chunk.Chunk {
+	c.StartTime = c.StartTime.UTC()
+	c.EndTime = c.EndTime.UTC()
+	return c
+}
+
+// sameEdges reports whether got and want hold the same edges in any order.
+func sameEdges(got, want []deps.Edge) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := make(map[deps.Edge]int)
+	for _, e := range want {
+		seen[e]++
+	}
+	for _, e := range got {
+		if seen[e] == 0 {
+			return false
+		}
+		seen[e]--
+	}
+	return true
+}
+
+func TestBackendRoundTrip(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(string(backend), func(t *testing.T) {
+			s := openTestStore(t, backend)
+			h := testHistory()
+			h.save(t, s)
+			h.check(t, s)
+
+			found, err := s.FindChunks("/repo/main.go")
+			if err != nil {
+				t.Fatalf("FindChunks() error = %v", err)
+			}
+			if got := chunkIDs(found); !sameIDs(got, []chunk.ChunkID{"a", "b"}) {
+				t.Errorf("FindChunks(main.go) = %v, want a and b", got)
+			}
+			between, err := s.FindChunksBetween(h.chunks[1].StartTime, h.chunks[2].StartTime)
+			if err != nil {
+				t.Fatalf("FindChunksBetween() error = %v", err)
+			}
+			if got := chunkIDs(between); !reflect.DeepEqual(got, []chunk.ChunkID{"b", "c"}) {
+				t.Errorf("FindChunksBetween() = %v, want [b c]", got)
+			}
+
+			dependencies, err := s.FindDependencies("d")
+			if err != nil || !sameEdges(dependencies, h.edges[1:]) {
+				t.Errorf("FindDependencies(d) = %+v, %v, want %+v", dependencies, err, h.edges[1:])
+			}
+			dependents, err := s.FindDependents("b")
+			if err != nil || !sameEdges(dependents, h.edges[2:]) {
+				t.Errorf("FindDependents(b) = %+v, %v, want %+v", dependents, err, h.edges[2:])
+			}
+
+			// Deleting a chunk drops it and the edges to and from it
+			if err := s.DeleteChunks([]chunk.ChunkID{"b"}); err != nil {
+				t.Fatalf("DeleteChunks() error = %v", err)
+			}
+			if _, err := s.GetChunk("b"); !errors.Is(err, ErrChunkNotFound) {
+				t.Errorf("GetChunk(b) after delete error = %v, want ErrChunkNotFound", err)
+			}
+			edges, err := s.ListDependencies()
+			if err != nil || !sameEdges(edges, h.edges[1:2]) {
+				t.Errorf("ListDependencies() after delete = %+v, %v, want %+v", edges, err, h.edges[1:2])
+			}
+		})
+	}
+}
+
+// sameIDs reports whether got and want hold the same IDs in any order.
+func sameIDs(got, want []chunk.ChunkID) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := make(map[chunk.ChunkID]bool)
+	for _, id := range want {
+		seen[id] = true
+	}
+	for _, id := range got {
+		if !seen[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCopy(t *testing.T) {
+	h := testHistory()
+	stores := make([]Store, len(backends))
+	for i, backend := range backends {
+		stores[i] = openTestStore(t, backend)
+	}
+	h.save(t, stores[0])
+
+	// sqlite to bolt to json
+	for i := 1; i < len(stores); i++ {
+		result, err := Copy(stores[i], stores[i-1])
+		if err != nil {
+			t.Fatalf("Copy(%s, %s) error = %v", backends[i], backends[i-1], err)
+		}
+		want := CopyResult{Chunks: len(h.chunks), Features: len(h.features), Dependencies: len(h.edges), Skipped: len(h.skipped)}
+		if *result != want {
+			t.Errorf("Copy(%s, %s) = %+v, want %+v", backends[i], backends[i-1], *result, want)
+		}
+		h.check(t, stores[i])
+
+		// Records keep the order they were saved in
+		srcChunks, _ := stores[i-1].GetRecentChunks(-1)
+		dstChunks, _ := stores[i].GetRecentChunks(-1)
+		if !reflect.DeepEqual(chunkIDs(dstChunks), chunkIDs(srcChunks)) {
+			t.Errorf("%s lists chunks %v, want %v as in %s", backends[i], chunkIDs(dstChunks), chunkIDs(srcChunks), backends[i-1])
+		}
+	}
+
+	if _, err := Copy(stores[1], stores[0]); err == nil {
+		t.Error("Copy() into a store that holds chunks succeeded")
+	}
+}
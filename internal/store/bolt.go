@@ -0,0 +1,805 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/deps"
+	"carya/internal/feature"
+	"carya/internal/objects"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltFormatVersion is the version of the bolt store's layout. Like a newer SQLite
+// schema, a database with a newer version is refused rather than risk losing data.
+const boltFormatVersion = 2
+
+// boltLockTimeout is how long a call waits for another process to release the database.
+const boltLockTimeout = 10 * time.Second
+
+// Buckets of the bolt store. Values are JSON records shared with the JSON store.
+var (
+	boltMeta       = []byte("meta")            // "version" → format version
+	boltChunks     = []byte("chunks")          // chunk ID → boltChunk
+	boltOrder      = []byte("chunk_order")     // sequence → chunk ID, in the order chunks were first saved
+	boltFeatures   = []byte("features")        // tag → jsonFeature
+	boltDeps       = []byte("dependencies")    // chunk ID, NUL, prerequisite ID → jsonEdge
+	boltDependents = []byte("dependents")      // prerequisite ID, NUL, chunk ID → nothing
+	boltSkipped    = []byte("skipped_changes") // sequence → jsonSkipped
+)
+
+// BoltStore keeps chunks, features, dependencies and skipped changes in a bbolt database,
+// an embedded key-value store written in pure Go, so Carya can be built without cgo.
+// File snapshots live in the object store next to the database, as with SQLiteStore.
+//
+// bbolt locks its file for as long as it is open, so the database is only opened for the
+// duration of each call. The daemon and commands run alongside it then take turns, waiting
+// up to boltLockTimeout for each other.
+type BoltStore struct {
+	mu       sync.Mutex     // Serializes calls within the process
+	filePath string         // Path of the database file
+	objects  *objects.Store // Blob store holding file snapshots, next to the database
+}
+
+// boltChunk is a chunk as stored in the bolt database.
+type boltChunk struct {
+	jsonChunk
+	Seq uint64 `json:"seq"` // Key of the chunk in the order bucket
+}
+
+// NewBoltStore opens the bolt store kept in filePath, creating the database if it doesn't
+// exist. File snapshots are kept in the objects directory next to it.
+func NewBoltStore(filePath string) (*BoltStore, error) {
+	s := &BoltStore{
+		filePath: filePath,
+		objects:  objects.New(filepath.Join(filepath.Dir(filePath), "objects")),
+	}
+	err := s.update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltMeta, boltChunks, boltOrder, boltFeatures, boltDeps, boltDependents, boltSkipped} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		meta := tx.Bucket(boltMeta)
+		version := boltFormatVersion
+		if stored := meta.Get([]byte("version")); stored != nil {
+			var err error
+			if version, err = strconv.Atoi(string(stored)); err != nil {
+				return fmt.Errorf("failed to read format version of %s: %w", s.filePath, err)
+			}
+		}
+		if version > boltFormatVersion {
+			return fmt.Errorf("%w: %s has format version %d, but this version of Carya only supports up to %d",
+				ErrSchemaTooNew, s.filePath, version, boltFormatVersion)
+		}
+		return meta.Put([]byte("version"), []byte(strconv.Itoa(boltFormatVersion)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// open opens the database, waiting for other processes to close it.
+func (s *BoltStore) open(readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(s.filePath, 0644, &bolt.Options{Timeout: boltLockTimeout, ReadOnly: readOnly})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("%s is locked by another process: %w", s.filePath, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", s.filePath, err)
+	}
+	return db, nil
+}
+
+// view runs fn in a read-only transaction.
+func (s *BoltStore) view(fn func(tx *bolt.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := s.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// update runs fn in a read-write transaction, which is rolled back if fn fails.
+func (s *BoltStore) update(fn func(tx *bolt.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	if err := db.Update(fn); err != nil {
+		db.Close()
+		return err
+	}
+	return db.Close()
+}
+
+// seqKey encodes a sequence number so keys sort in numeric order.
+func seqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// edgeKey joins two chunk IDs into the key of a dependency edge.
+func edgeKey(from, to chunk.ChunkID) []byte {
+	return []byte(string(from) + "\x00" + string(to))
+}
+
+// splitEdgeKey returns the chunk IDs joined by edgeKey.
+func splitEdgeKey(key []byte) (chunk.ChunkID, chunk.ChunkID) {
+	from, to, _ := bytes.Cut(key, []byte{0})
+	return chunk.ChunkID(from), chunk.ChunkID(to)
+}
+
+// getChunkRecord returns the stored chunk with the given ID, or nil.
+func getChunkRecord(tx *bolt.Tx, id chunk.ChunkID) (*boltChunk, error) {
+	value := tx.Bucket(boltChunks).Get([]byte(id))
+	if value == nil {
+		return nil, nil
+	}
+	var record boltChunk
+	if err := json.Unmarshal(value, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode chunk %s: %w", id, err)
+	}
+	return &record, nil
+}
+
+// putChunkRecord stores a chunk record. A new chunk is appended to the order of chunks;
+// a chunk replacing one with the same ID keeps its place.
+func putChunkRecord(tx *bolt.Tx, r jsonChunk) error {
+	existing, err := getChunkRecord(tx, r.ID)
+	if err != nil {
+		return err
+	}
+
+	record := boltChunk{jsonChunk: r}
+	if existing != nil {
+		record.Seq = existing.Seq
+	} else {
+		order := tx.Bucket(boltOrder)
+		if record.Seq, err = order.NextSequence(); err != nil {
+			return err
+		}
+		if err := order.Put(seqKey(record.Seq), []byte(r.ID)); err != nil {
+			return err
+		}
+	}
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode chunk %s: %w", r.ID, err)
+	}
+	return tx.Bucket(boltChunks).Put([]byte(r.ID), value)
+}
+
+// SaveChunk persists a chunk and its file snapshots, replacing any existing chunk with the same ID.
+func (s *BoltStore) SaveChunk(c chunk.Chunk) error {
+	record, err := newJSONChunk(s.objects, c)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		return putChunkRecord(tx, record)
+	})
+}
+
+// deleteBoltChunk removes a chunk and the dependency edges to and from it.
+func deleteBoltChunk(tx *bolt.Tx, id chunk.ChunkID) error {
+	record, err := getChunkRecord(tx, id)
+	if err != nil || record == nil {
+		return err
+	}
+	if err := tx.Bucket(boltOrder).Delete(seqKey(record.Seq)); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltChunks).Delete([]byte(id)); err != nil {
+		return err
+	}
+
+	edges, err := listEdges(tx, boltDeps, id)
+	if err != nil {
+		return err
+	}
+	dependents, err := listEdges(tx, boltDependents, id)
+	if err != nil {
+		return err
+	}
+	for _, e := range append(edges, dependents...) {
+		if err := deleteEdge(tx, e.Chunk, e.DependsOn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteChunks removes chunks and the dependency edges to and from them.
+// Their snapshots stay in the object store until PruneObjects finds them unreferenced.
+func (s *BoltStore) DeleteChunks(ids []chunk.ChunkID) error {
+	return s.update(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			if err := deleteBoltChunk(tx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MarkCommitted records that the changes of each chunk are contained in a git commit.
+// No chunk is changed if any of them doesn't exist.
+func (s *BoltStore) MarkCommitted(ids []chunk.ChunkID, commit string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return setBoltChunks(tx, ids, func(r *jsonChunk) { r.Committed = commit })
+	})
+}
+
+// ReplaceChunks saves merged in place of the replaced chunks, which are removed. Dependency
+// edges to and from the replaced chunks are moved to merged.
+func (s *BoltStore) ReplaceChunks(merged chunk.Chunk, replaced []chunk.ChunkID) error {
+	record, err := newJSONChunk(s.objects, merged)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(tx *bolt.Tx) error {
+		if err := putChunkRecord(tx, record); err != nil {
+			return err
+		}
+		for _, id := range replaced {
+			if id == merged.ID {
+				continue
+			}
+
+			// Redirect edges, keeping an existing edge over one that would duplicate it
+			edges, err := listEdges(tx, boltDeps, id)
+			if err != nil {
+				return err
+			}
+			dependents, err := listEdges(tx, boltDependents, id)
+			if err != nil {
+				return err
+			}
+			for _, e := range append(edges, dependents...) {
+				if err := deleteEdge(tx, e.Chunk, e.DependsOn); err != nil {
+					return err
+				}
+				if e.Chunk == id {
+					e.Chunk = merged.ID
+				}
+				if e.DependsOn == id {
+					e.DependsOn = merged.ID
+				}
+				if e.Chunk == e.DependsOn || tx.Bucket(boltDeps).Get(edgeKey(e.Chunk, e.DependsOn)) != nil {
+					continue
+				}
+				if err := putEdge(tx, e); err != nil {
+					return err
+				}
+			}
+
+			if err := deleteBoltChunk(tx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// forEachChunk calls fn with each stored chunk in the order they were first saved, or the
+// reverse order if newestFirst is set, until fn returns false or an error.
+func forEachChunk(tx *bolt.Tx, newestFirst bool, fn func(r boltChunk) (bool, error)) error {
+	chunks := tx.Bucket(boltChunks)
+	cursor := tx.Bucket(boltOrder).Cursor()
+
+	first, next := cursor.First, cursor.Next
+	if newestFirst {
+		first, next = cursor.Last, cursor.Prev
+	}
+	for k, id := first(); k != nil; k, id = next() {
+		value := chunks.Get(id)
+		if value == nil {
+			continue
+		}
+		var record boltChunk
+		if err := json.Unmarshal(value, &record); err != nil {
+			return fmt.Errorf("failed to decode chunk %s: %w", id, err)
+		}
+		if more, err := fn(record); err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectBoltChunks returns the chunks (without snapshots) whose records satisfy match,
+// oldest or newest first, up to limit (all of them if limit is negative).
+func (s *BoltStore) selectBoltChunks(newestFirst bool, limit int, match func(r boltChunk) bool) ([]chunk.Chunk, error) {
+	var chunks []chunk.Chunk
+	err := s.view(func(tx *bolt.Tx) error {
+		return forEachChunk(tx, newestFirst, func(r boltChunk) (bool, error) {
+			if limit >= 0 && len(chunks) >= limit {
+				return false, nil
+			}
+			if match == nil || match(r) {
+				chunks = append(chunks, r.toChunk())
+			}
+			return true, nil
+		})
+	})
+	return chunks, err
+}
+
+// FindChunks retrieves all chunks for a specific file path, including renames away from it,
+// ordered by creation time (newest first).
+func (s *BoltStore) FindChunks(filePath string) ([]chunk.Chunk, error) {
+	return s.selectBoltChunks(true, -1, func(r boltChunk) bool {
+		return r.FilePath == filePath || r.OldPath == filePath
+	})
+}
+
+// GetRecentChunks retrieves the most recently created chunks up to the specified limit.
+func (s *BoltStore) GetRecentChunks(limit int) ([]chunk.Chunk, error) {
+	return s.selectBoltChunks(true, limit, nil)
+}
+
+// GetChunk retrieves a single chunk by ID, including its file snapshots.
+// Returns ErrChunkNotFound if no chunk has that ID.
+func (s *BoltStore) GetChunk(id chunk.ChunkID) (*chunk.Chunk, error) {
+	var record *boltChunk
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		record, err = getChunkRecord(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, fmt.Errorf("%w: %s", ErrChunkNotFound, id)
+	}
+	return record.withSnapshots(s.objects)
+}
+
+// FindChunksBetween retrieves all chunks that started within [since, until], ordered
+// by start time (oldest first). A zero since or until leaves that end of the range open.
+func (s *BoltStore) FindChunksBetween(since, until time.Time) ([]chunk.Chunk, error) {
+	chunks, err := s.selectBoltChunks(false, -1, nil)
+	if err != nil {
+		return nil, err
+	}
+	return filterChunksBetween(chunks, since, until), nil
+}
+
+// QueryChunks returns the page of chunks matching q, newest first.
+func (s *BoltStore) QueryChunks(q chunk.Query) (*chunk.Page, error) {
+	chunks, err := s.selectBoltChunks(true, -1, func(r boltChunk) bool {
+		return q.Matches(r.toChunk())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pageChunks(chunks, q)
+}
+
+// getFeatureRecord returns the stored feature with the given tag, or nil.
+func getFeatureRecord(tx *bolt.Tx, tag feature.Tag) (*jsonFeature, error) {
+	value := tx.Bucket(boltFeatures).Get([]byte(tag))
+	if value == nil {
+		return nil, nil
+	}
+	var record jsonFeature
+	if err := json.Unmarshal(value, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode feature %s: %w", tag, err)
+	}
+	return &record, nil
+}
+
+// putFeatureRecord stores a feature record, replacing any with the same tag.
+func putFeatureRecord(tx *bolt.Tx, r jsonFeature) error {
+	value, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode feature %s: %w", r.Tag, err)
+	}
+	return tx.Bucket(boltFeatures).Put([]byte(r.Tag), value)
+}
+
+// listFeatureRecords returns every stored feature, ordered by tag.
+func listFeatureRecords(tx *bolt.Tx) ([]jsonFeature, error) {
+	var records []jsonFeature
+	err := tx.Bucket(boltFeatures).ForEach(func(k, v []byte) error {
+		var record jsonFeature
+		if err := json.Unmarshal(v, &record); err != nil {
+			return fmt.Errorf("failed to decode feature %s: %w", k, err)
+		}
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+// CreateFeature persists a new feature. Returns an error if a feature with the same tag exists.
+func (s *BoltStore) CreateFeature(f feature.Feature) error {
+	if err := feature.ValidateTag(string(f.Tag)); err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		existing, err := getFeatureRecord(tx, f.Tag)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return fmt.Errorf("failed to create feature %s: it already exists", f.Tag)
+		}
+		return putFeatureRecord(tx, jsonFeature{
+			Tag:         f.Tag,
+			Description: f.Description,
+			Status:      f.Status,
+			CreatedAt:   f.CreatedAt,
+			ClosedAt:    f.ClosedAt,
+		})
+	})
+}
+
+// GetFeature retrieves a feature by tag. Returns ErrFeatureNotFound if it doesn't exist.
+func (s *BoltStore) GetFeature(tag feature.Tag) (*feature.Feature, error) {
+	var record *jsonFeature
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		record, err = getFeatureRecord(tx, tag)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, fmt.Errorf("%w: %s", ErrFeatureNotFound, tag)
+	}
+	f := record.toFeature()
+	return &f, nil
+}
+
+// ListFeatures retrieves all features, newest first.
+func (s *BoltStore) ListFeatures() ([]feature.Feature, error) {
+	var features []feature.Feature
+	err := s.view(func(tx *bolt.Tx) error {
+		records, err := listFeatureRecords(tx)
+		for _, r := range records {
+			features = append(features, r.toFeature())
+		}
+		return err
+	})
+	sort.SliceStable(features, func(i, j int) bool {
+		return features[i].CreatedAt.After(features[j].CreatedAt)
+	})
+	return features, err
+}
+
+// ActiveFeature retrieves the currently active feature, or nil if no feature is active.
+func (s *BoltStore) ActiveFeature() (*feature.Feature, error) {
+	var active *feature.Feature
+	err := s.view(func(tx *bolt.Tx) error {
+		records, err := listFeatureRecords(tx)
+		for _, r := range records {
+			if r.Status == feature.StatusActive {
+				f := r.toFeature()
+				active = &f
+				break
+			}
+		}
+		return err
+	})
+	return active, err
+}
+
+// CurrentTag returns the tag of the active feature, or the empty tag if none is active.
+// It implements chunk.Tagger so new chunks are tagged with the active feature.
+func (s *BoltStore) CurrentTag() feature.Tag {
+	active, err := s.ActiveFeature()
+	if err != nil || active == nil {
+		return ""
+	}
+	return active.Tag
+}
+
+// SetActiveFeature makes the feature with the given tag the active one, moving any
+// previously active feature back to open. An empty tag deactivates all features.
+func (s *BoltStore) SetActiveFeature(tag feature.Tag) error {
+	return s.update(func(tx *bolt.Tx) error {
+		var target *jsonFeature
+		if tag != "" {
+			var err error
+			if target, err = getFeatureRecord(tx, tag); err != nil {
+				return err
+			}
+			if target == nil {
+				return fmt.Errorf("%w: %s", ErrFeatureNotFound, tag)
+			}
+		}
+
+		records, err := listFeatureRecords(tx)
+		if err != nil {
+			return err
+		}
+		for _, r := range records {
+			if r.Status == feature.StatusActive && r.Tag != tag {
+				r.Status = feature.StatusOpen
+				if err := putFeatureRecord(tx, r); err != nil {
+					return err
+				}
+			}
+		}
+		if target != nil {
+			target.Status, target.ClosedAt = feature.StatusActive, time.Time{}
+			return putFeatureRecord(tx, *target)
+		}
+		return nil
+	})
+}
+
+// CloseFeature marks a feature as closed. Its chunks keep their tag.
+func (s *BoltStore) CloseFeature(tag feature.Tag) error {
+	return s.update(func(tx *bolt.Tx) error {
+		f, err := getFeatureRecord(tx, tag)
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			return fmt.Errorf("%w: %s", ErrFeatureNotFound, tag)
+		}
+		f.Status, f.ClosedAt = feature.StatusClosed, time.Now()
+		return putFeatureRecord(tx, *f)
+	})
+}
+
+// TagChunks assigns the given tag to each chunk. An empty tag removes the chunks' tags.
+// No chunk is changed if any of them doesn't exist.
+func (s *BoltStore) TagChunks(ids []chunk.ChunkID, tag feature.Tag) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return setBoltChunks(tx, ids, func(r *jsonChunk) { r.FeatureTag = tag })
+	})
+}
+
+// setBoltChunks applies set to the record of each chunk, failing if any of them doesn't exist.
+func setBoltChunks(tx *bolt.Tx, ids []chunk.ChunkID, set func(r *jsonChunk)) error {
+	for _, id := range ids {
+		record, err := getChunkRecord(tx, id)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return fmt.Errorf("%w: %s", ErrChunkNotFound, id)
+		}
+		set(&record.jsonChunk)
+		if err := putChunkRecord(tx, record.jsonChunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindChunksByFeature retrieves all chunks tagged with a feature, oldest first.
+func (s *BoltStore) FindChunksByFeature(tag feature.Tag) ([]chunk.Chunk, error) {
+	return s.selectBoltChunks(false, -1, func(r boltChunk) bool { return r.FeatureTag == tag })
+}
+
+// listEdges returns the edges stored under keys starting with id in the given bucket:
+// the dependencies of id in the dependencies bucket, its dependents in the dependents
+// bucket. Edges are sorted by the other chunk's ID.
+func listEdges(tx *bolt.Tx, bucket []byte, id chunk.ChunkID) ([]deps.Edge, error) {
+	var edges []deps.Edge
+	prefix := []byte(string(id) + "\x00")
+	cursor := tx.Bucket(bucket).Cursor()
+	for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+		from, to := splitEdgeKey(k)
+		if bytes.Equal(bucket, boltDependents) {
+			from, to = to, from
+		}
+		e, err := getEdge(tx, from, to)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			edges = append(edges, *e)
+		}
+	}
+	return edges, nil
+}
+
+// getEdge returns the edge from a chunk to a prerequisite, or nil.
+func getEdge(tx *bolt.Tx, id, dependsOn chunk.ChunkID) (*deps.Edge, error) {
+	value := tx.Bucket(boltDeps).Get(edgeKey(id, dependsOn))
+	if value == nil {
+		return nil, nil
+	}
+	var record jsonEdge
+	if err := json.Unmarshal(value, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode dependency of %s on %s: %w", id, dependsOn, err)
+	}
+	return &deps.Edge{Chunk: record.Chunk, DependsOn: record.DependsOn, Kind: record.Kind, Detail: record.Detail}, nil
+}
+
+// putEdge stores an edge and its entry in the dependents index, replacing any edge between
+// the same chunks.
+func putEdge(tx *bolt.Tx, e deps.Edge) error {
+	value, err := json.Marshal(jsonEdge{Chunk: e.Chunk, DependsOn: e.DependsOn, Kind: e.Kind, Detail: e.Detail})
+	if err != nil {
+		return fmt.Errorf("failed to encode dependency of %s on %s: %w", e.Chunk, e.DependsOn, err)
+	}
+	if err := tx.Bucket(boltDeps).Put(edgeKey(e.Chunk, e.DependsOn), value); err != nil {
+		return err
+	}
+	return tx.Bucket(boltDependents).Put(edgeKey(e.DependsOn, e.Chunk), nil)
+}
+
+// deleteEdge removes an edge and its entry in the dependents index.
+func deleteEdge(tx *bolt.Tx, id, dependsOn chunk.ChunkID) error {
+	if err := tx.Bucket(boltDeps).Delete(edgeKey(id, dependsOn)); err != nil {
+		return err
+	}
+	return tx.Bucket(boltDependents).Delete(edgeKey(dependsOn, id))
+}
+
+// SaveDependencies replaces the recorded dependencies of a chunk with the given edges.
+func (s *BoltStore) SaveDependencies(id chunk.ChunkID, edges []deps.Edge) error {
+	return s.update(func(tx *bolt.Tx) error {
+		existing, err := listEdges(tx, boltDeps, id)
+		if err != nil {
+			return err
+		}
+		for _, e := range existing {
+			if err := deleteEdge(tx, e.Chunk, e.DependsOn); err != nil {
+				return err
+			}
+		}
+
+		// Like the SQLite store, a later edge to the same chunk replaces an earlier one
+		for _, e := range edges {
+			e.Chunk = id
+			if err := putEdge(tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FindDependencies retrieves the edges from a chunk to the chunks it depends on.
+func (s *BoltStore) FindDependencies(id chunk.ChunkID) ([]deps.Edge, error) {
+	var edges []deps.Edge
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		edges, err = listEdges(tx, boltDeps, id)
+		return err
+	})
+	return edges, err
+}
+
+// FindDependents retrieves the edges from chunks that depend on the given chunk.
+func (s *BoltStore) FindDependents(id chunk.ChunkID) ([]deps.Edge, error) {
+	var edges []deps.Edge
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		edges, err = listEdges(tx, boltDependents, id)
+		return err
+	})
+	return edges, err
+}
+
+// ListDependencies retrieves every recorded dependency edge, sorted by dependent chunk
+// and then prerequisite.
+func (s *BoltStore) ListDependencies() ([]deps.Edge, error) {
+	var edges []deps.Edge
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDeps).ForEach(func(k, v []byte) error {
+			var record jsonEdge
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("failed to decode dependency %q: %w", k, err)
+			}
+			edges = append(edges, deps.Edge{Chunk: record.Chunk, DependsOn: record.DependsOn, Kind: record.Kind, Detail: record.Detail})
+			return nil
+		})
+	})
+	return edges, err
+}
+
+// SaveSkippedChange records a change to a file whose contents are not tracked.
+func (s *BoltStore) SaveSkippedChange(sc chunk.SkippedChange) error {
+	value, err := json.Marshal(jsonSkipped(sc))
+	if err != nil {
+		return fmt.Errorf("failed to encode skipped change to %s: %w", sc.FilePath, err)
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		skipped := tx.Bucket(boltSkipped)
+		seq, err := skipped.NextSequence()
+		if err != nil {
+			return err
+		}
+		return skipped.Put(seqKey(seq), value)
+	})
+}
+
+// selectSkippedChanges returns the skipped changes satisfying match, oldest or newest
+// first, up to limit (all of them if limit is negative).
+func (s *BoltStore) selectSkippedChanges(newestFirst bool, limit int, match func(sc chunk.SkippedChange) bool) ([]chunk.SkippedChange, error) {
+	var changes []chunk.SkippedChange
+	err := s.view(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltSkipped).Cursor()
+		first, next := cursor.First, cursor.Next
+		if newestFirst {
+			first, next = cursor.Last, cursor.Prev
+		}
+		for k, v := first(); k != nil && (limit < 0 || len(changes) < limit); k, v = next() {
+			var record jsonSkipped
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("failed to decode skipped change: %w", err)
+			}
+			if sc := chunk.SkippedChange(record); match == nil || match(sc) {
+				changes = append(changes, sc)
+			}
+		}
+		return nil
+	})
+	return changes, err
+}
+
+// GetRecentSkippedChanges retrieves the most recently recorded skipped changes up to the specified limit.
+func (s *BoltStore) GetRecentSkippedChanges(limit int) ([]chunk.SkippedChange, error) {
+	return s.selectSkippedChanges(true, limit, nil)
+}
+
+// FindSkippedChanges retrieves the skipped changes recorded for a file, oldest first.
+func (s *BoltStore) FindSkippedChanges(filePath string) ([]chunk.SkippedChange, error) {
+	return s.selectSkippedChanges(false, -1, func(sc chunk.SkippedChange) bool {
+		return sc.FilePath == filePath
+	})
+}
+
+// ObjectRefs counts the references from chunks to each object in the object store.
+func (s *BoltStore) ObjectRefs() (map[string]int, error) {
+	refs := make(map[string]int)
+	err := s.view(func(tx *bolt.Tx) error {
+		return forEachChunk(tx, false, func(r boltChunk) (bool, error) {
+			for _, hash := range []string{r.BeforeHash, r.AfterHash} {
+				if hash != "" {
+					refs[hash]++
+				}
+			}
+			return true, nil
+		})
+	})
+	return refs, err
+}
+
+// PruneObjects removes objects no chunk refers to. Objects written within the grace period
+// are kept, since the chunk referring to them may not have been saved yet.
+func (s *BoltStore) PruneObjects(grace time.Duration) (*PruneResult, error) {
+	refs, err := s.ObjectRefs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count object references: %w", err)
+	}
+	return pruneObjects(s.objects, refs, grace)
+}
+
+// Close releases the store. The database is only open during calls, so there is nothing to close.
+func (s *BoltStore) Close() error {
+	return nil
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"carya/internal/chunk"
+	"carya/internal/deps"
+)
+
+// CopyResult counts the records copied by Copy.
+type CopyResult struct {
+	Chunks       int // Number of chunks copied, with their snapshots
+	Features     int // Number of features copied
+	Dependencies int // Number of dependency edges copied
+	Skipped      int // Number of skipped changes copied
+}
+
+// Copy copies the whole history in src to dst, which must be empty: chunks with their
+// snapshots, features, dependencies and skipped changes. Records are written in the order
+// src first saved them, so dst lists them in the same order.
+func Copy(dst, src Store) (*CopyResult, error) {
+	existing, err := dst.GetRecentChunks(1)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, errors.New("failed to copy history: the target store already holds chunks")
+	}
+
+	result := &CopyResult{}
+	chunks, err := src.GetRecentChunks(-1)
+	if err != nil {
+		return result, fmt.Errorf("failed to list chunks: %w", err)
+	}
+	for _, c := range reverseChunks(chunks) {
+		full, err := src.GetChunk(c.ID)
+		if err != nil {
+			return result, err
+		}
+		if err := dst.SaveChunk(*full); err != nil {
+			return result, fmt.Errorf("failed to copy chunk %s: %w", c.ID, err)
+		}
+		result.Chunks++
+	}
+
+	features, err := src.ListFeatures()
+	if err != nil {
+		return result, fmt.Errorf("failed to list features: %w", err)
+	}
+	for i := len(features) - 1; i >= 0; i-- {
+		if err := dst.CreateFeature(features[i]); err != nil {
+			return result, err
+		}
+		result.Features++
+	}
+
+	edges, err := src.ListDependencies()
+	if err != nil {
+		return result, fmt.Errorf("failed to list dependencies: %w", err)
+	}
+	var order []chunk.ChunkID
+	byChunk := make(map[chunk.ChunkID][]deps.Edge)
+	for _, e := range edges {
+		if _, ok := byChunk[e.Chunk]; !ok {
+			order = append(order, e.Chunk)
+		}
+		byChunk[e.Chunk] = append(byChunk[e.Chunk], e)
+	}
+	for _, id := range order {
+		if err := dst.SaveDependencies(id, byChunk[id]); err != nil {
+			return result, fmt.Errorf("failed to copy dependencies of %s: %w", id, err)
+		}
+		result.Dependencies += len(byChunk[id])
+	}
+
+	skipped, err := src.GetRecentSkippedChanges(-1)
+	if err != nil {
+		return result, fmt.Errorf("failed to list skipped changes: %w", err)
+	}
+	for i := len(skipped) - 1; i >= 0; i-- {
+		if err := dst.SaveSkippedChange(skipped[i]); err != nil {
+			return result, fmt.Errorf("failed to copy skipped change to %s: %w", skipped[i].FilePath, err)
+		}
+		result.Skipped++
+	}
+	return result, nil
+}
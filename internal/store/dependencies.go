@@ -0,0 +1,102 @@
+package store
+
+import (
+	"database/sql"
+
+	"carya/internal/chunk"
+	"carya/internal/deps"
+)
+
+// createDependencyTables creates the table holding dependency edges between chunks if it doesn't exist.
+func createDependencyTables(tx *sql.Tx) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS chunk_dependencies (
+			chunk_id TEXT NOT NULL,
+			depends_on TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			detail TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (chunk_id, depends_on)
+		);
+		CREATE INDEX IF NOT EXISTS idx_chunk_dependencies_depends_on ON chunk_dependencies(depends_on);
+	`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// SaveDependencies replaces the recorded dependencies of a chunk with the given edges.
+func (s *SQLiteStore) SaveDependencies(id chunk.ChunkID, edges []deps.Edge) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM chunk_dependencies WHERE chunk_id = ?`, id); err != nil {
+		return err
+	}
+
+	for _, e := range edges {
+		query := `
+			INSERT OR REPLACE INTO chunk_dependencies (chunk_id, depends_on, kind, detail)
+			VALUES (?, ?, ?, ?)
+		`
+		if _, err := tx.Exec(query, id, e.DependsOn, e.Kind, e.Detail); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// FindDependencies retrieves the edges from a chunk to the chunks it depends on.
+func (s *SQLiteStore) FindDependencies(id chunk.ChunkID) ([]deps.Edge, error) {
+	return s.queryDependencies(`
+		SELECT chunk_id, depends_on, kind, detail
+		FROM chunk_dependencies
+		WHERE chunk_id = ?
+		ORDER BY depends_on
+	`, id)
+}
+
+// FindDependents retrieves the edges from chunks that depend on the given chunk.
+func (s *SQLiteStore) FindDependents(id chunk.ChunkID) ([]deps.Edge, error) {
+	return s.queryDependencies(`
+		SELECT chunk_id, depends_on, kind, detail
+		FROM chunk_dependencies
+		WHERE depends_on = ?
+		ORDER BY chunk_id
+	`, id)
+}
+
+// ListDependencies retrieves every recorded dependency edge.
+func (s *SQLiteStore) ListDependencies() ([]deps.Edge, error) {
+	return s.queryDependencies(`
+		SELECT chunk_id, depends_on, kind, detail
+		FROM chunk_dependencies
+		ORDER BY chunk_id, depends_on
+	`)
+}
+
+// queryDependencies runs a query selecting dependency edges and scans the results.
+func (s *SQLiteStore) queryDependencies(query string, args ...interface{}) ([]deps.Edge, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanDependencies(rows)
+}
+
+// scanDependencies converts SQL rows into a slice of dependency edges.
+func scanDependencies(rows *sql.Rows) ([]deps.Edge, error) {
+	var edges []deps.Edge
+	for rows.Next() {
+		var e deps.Edge
+		if err := rows.Scan(&e.Chunk, &e.DependsOn, &e.Kind, &e.Detail); err != nil {
+			return nil, err
+		}
+		edges = append(edges, e)
+	}
+	return edges, rows.Err()
+}
@@ -0,0 +1,207 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/feature"
+)
+
+// ErrFeatureNotFound is returned when a feature lookup by tag finds nothing.
+var ErrFeatureNotFound = errors.New("feature not found")
+
+// createFeatureTables creates the features table if it doesn't exist.
+func createFeatureTables(tx *sql.Tx) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS features (
+			tag TEXT PRIMARY KEY,
+			description TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			closed_at TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_chunks_feature_tag ON chunks(feature_tag);
+	`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// CreateFeature persists a new feature. Returns an error if a feature with the same tag exists.
+func (s *SQLiteStore) CreateFeature(f feature.Feature) error {
+	if err := feature.ValidateTag(string(f.Tag)); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO features (tag, description, status, created_at, closed_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	closedAt := sql.NullTime{Time: f.ClosedAt, Valid: !f.ClosedAt.IsZero()}
+	if _, err := s.db.Exec(query, f.Tag, f.Description, f.Status, f.CreatedAt, closedAt); err != nil {
+		return fmt.Errorf("failed to create feature %s: %w", f.Tag, err)
+	}
+	return nil
+}
+
+// GetFeature retrieves a feature by tag. Returns ErrFeatureNotFound if it doesn't exist.
+func (s *SQLiteStore) GetFeature(tag feature.Tag) (*feature.Feature, error) {
+	query := `
+		SELECT tag, description, status, created_at, closed_at
+		FROM features
+		WHERE tag = ?
+	`
+	rows, err := s.db.Query(query, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	features, err := s.scanFeatures(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(features) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrFeatureNotFound, tag)
+	}
+	return &features[0], nil
+}
+
+// ListFeatures retrieves all features, newest first.
+func (s *SQLiteStore) ListFeatures() ([]feature.Feature, error) {
+	query := `
+		SELECT tag, description, status, created_at, closed_at
+		FROM features
+		ORDER BY created_at DESC
+	`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return s.scanFeatures(rows)
+}
+
+// ActiveFeature retrieves the currently active feature, or nil if no feature is active.
+func (s *SQLiteStore) ActiveFeature() (*feature.Feature, error) {
+	query := `
+		SELECT tag, description, status, created_at, closed_at
+		FROM features
+		WHERE status = ?
+	`
+	rows, err := s.db.Query(query, feature.StatusActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	features, err := s.scanFeatures(rows)
+	if err != nil || len(features) == 0 {
+		return nil, err
+	}
+	return &features[0], nil
+}
+
+// CurrentTag returns the tag of the active feature, or the empty tag if none is active.
+// It implements chunk.Tagger so new chunks are tagged with the active feature.
+func (s *SQLiteStore) CurrentTag() feature.Tag {
+	active, err := s.ActiveFeature()
+	if err != nil || active == nil {
+		return ""
+	}
+	return active.Tag
+}
+
+// SetActiveFeature makes the feature with the given tag the active one, moving any
+// previously active feature back to open. An empty tag deactivates all features.
+func (s *SQLiteStore) SetActiveFeature(tag feature.Tag) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE features SET status = ? WHERE status = ?`, feature.StatusOpen, feature.StatusActive); err != nil {
+		return err
+	}
+
+	if tag != "" {
+		result, err := tx.Exec(`UPDATE features SET status = ?, closed_at = NULL WHERE tag = ?`, feature.StatusActive, tag)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("%w: %s", ErrFeatureNotFound, tag)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CloseFeature marks a feature as closed. Its chunks keep their tag.
+func (s *SQLiteStore) CloseFeature(tag feature.Tag) error {
+	result, err := s.db.Exec(`UPDATE features SET status = ?, closed_at = ? WHERE tag = ?`, feature.StatusClosed, time.Now(), tag)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrFeatureNotFound, tag)
+	}
+	return nil
+}
+
+// TagChunks assigns the given tag to each chunk. An empty tag removes the chunks' tags.
+func (s *SQLiteStore) TagChunks(ids []chunk.ChunkID, tag feature.Tag) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		result, err := tx.Exec(`UPDATE chunks SET feature_tag = ? WHERE id = ?`, tag, id)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("%w: %s", ErrChunkNotFound, id)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// FindChunksByFeature retrieves all chunks tagged with a feature, oldest first.
+func (s *SQLiteStore) FindChunksByFeature(tag feature.Tag) ([]chunk.Chunk, error) {
+	query := `
+		SELECT id, file_path, diff, start_time, end_time, hash, manual, feature_tag, op, old_path, branch, head, committed
+		FROM chunks
+		WHERE feature_tag = ?
+		ORDER BY created_at ASC
+	`
+	rows, err := s.db.Query(query, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return s.scanChunks(rows)
+}
+
+// scanFeatures converts SQL rows into a slice of Feature structs.
+func (s *SQLiteStore) scanFeatures(rows *sql.Rows) ([]feature.Feature, error) {
+	var features []feature.Feature
+	for rows.Next() {
+		var f feature.Feature
+		var closedAt sql.NullTime
+		if err := rows.Scan(&f.Tag, &f.Description, &f.Status, &f.CreatedAt, &closedAt); err != nil {
+			return nil, err
+		}
+		f.ClosedAt = closedAt.Time
+		features = append(features, f)
+	}
+	return features, rows.Err()
+}
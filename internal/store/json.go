@@ -0,0 +1,808 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/deps"
+	"carya/internal/feature"
+	"carya/internal/objects"
+)
+
+// jsonFormatVersion is the version of the JSON store's file format. Like a newer SQLite
+// schema, a file with a newer version is refused rather than risk losing data.
+const jsonFormatVersion = 2
+
+// JSONStore keeps chunks, features, dependencies and skipped changes in a single indented
+// JSON file, so the history can be read and diffed with ordinary tools and needs no cgo.
+// File snapshots live in the object store next to the file, as with SQLiteStore.
+//
+// A mutex serializes callers within a process. Between processes, each call first reloads
+// the file if another process replaced it, and each change rewrites the whole file through
+// a temporary file and a rename, so readers never see a partial write.
+type JSONStore struct {
+	mu       sync.Mutex     // Protects data and info
+	filePath string         // Path of the JSON file
+	objects  *objects.Store // Blob store holding file snapshots, next to the file
+	data     jsonFile       // Contents of the file as last read or written
+	info     os.FileInfo    // The file as last read or written; nil if it didn't exist
+	stale    bool           // Whether data holds changes that failed to be written and must be reread
+}
+
+// jsonFile is the layout of the JSON store's file.
+type jsonFile struct {
+	Version      int           `json:"version"`
+	Chunks       []jsonChunk   `json:"chunks"`
+	Features     []jsonFeature `json:"features"`
+	Dependencies []jsonEdge    `json:"dependencies"`
+	Skipped      []jsonSkipped `json:"skipped_changes"`
+}
+
+// jsonChunk is a chunk as stored in the JSON file, with its snapshots referenced by hash.
+type jsonChunk struct {
+	ID         chunk.ChunkID   `json:"id"`
+	FilePath   string          `json:"file_path"`
+	Op         chunk.Op        `json:"op"`
+	OldPath    string          `json:"old_path,omitempty"`
+	Branch     string          `json:"branch,omitempty"`
+	Head       string          `json:"head,omitempty"`
+	Committed  string          `json:"committed,omitempty"`
+	StartTime  time.Time       `json:"start_time"`
+	EndTime    time.Time       `json:"end_time"`
+	FeatureTag feature.Tag     `json:"feature_tag,omitempty"`
+	Manual     bool            `json:"manual,omitempty"`
+	Hash       chunk.ChunkHash `json:"hash"`
+	BeforeHash string          `json:"before_hash,omitempty"`
+	AfterHash  string          `json:"after_hash,omitempty"`
+	Diff       string          `json:"diff"`
+}
+
+// jsonFeature is a feature as stored in the JSON file.
+type jsonFeature struct {
+	Tag         feature.Tag    `json:"tag"`
+	Description string         `json:"description,omitempty"`
+	Status      feature.Status `json:"status"`
+	CreatedAt   time.Time      `json:"created_at"`
+	ClosedAt    time.Time      `json:"closed_at,omitzero"`
+}
+
+// jsonEdge is a dependency edge as stored in the JSON file.
+type jsonEdge struct {
+	Chunk     chunk.ChunkID `json:"chunk"`
+	DependsOn chunk.ChunkID `json:"depends_on"`
+	Kind      deps.Kind     `json:"kind"`
+	Detail    string        `json:"detail,omitempty"`
+}
+
+// jsonSkipped is a skipped change as stored in the JSON file.
+type jsonSkipped struct {
+	FilePath string           `json:"file_path"`
+	Size     int64            `json:"size"`
+	Hash     string           `json:"hash"`
+	Reason   chunk.SkipReason `json:"reason"`
+	Time     time.Time        `json:"time"`
+}
+
+// NewJSONStore opens the JSON store kept in filePath, loading the file if it exists.
+// The file is created on the first change. File snapshots are kept in the objects
+// directory next to it.
+func NewJSONStore(filePath string) (*JSONStore, error) {
+	s := &JSONStore{
+		filePath: filePath,
+		objects:  objects.New(filepath.Join(filepath.Dir(filePath), "objects")),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the file into memory. A missing file is an empty store.
+func (s *JSONStore) load() error {
+	s.data, s.info, s.stale = jsonFile{Version: jsonFormatVersion}, nil, false
+
+	file, err := os.Open(s.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Stat the open file, so the recorded info matches what is read even if it is replaced meanwhile
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", s.filePath, err)
+	}
+
+	var data jsonFile
+	if err := json.Unmarshal(content, &data); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", s.filePath, err)
+	}
+	if data.Version > jsonFormatVersion {
+		return fmt.Errorf("%w: %s has format version %d, but this version of Carya only supports up to %d",
+			ErrSchemaTooNew, s.filePath, data.Version, jsonFormatVersion)
+	}
+	s.data, s.info = data, info
+	return nil
+}
+
+// refresh reloads the file if it was written since it was last read or written here.
+// Must be called with s.mu held.
+func (s *JSONStore) refresh() error {
+	if s.stale {
+		return s.load()
+	}
+	info, err := os.Stat(s.filePath)
+	if errors.Is(err, os.ErrNotExist) && s.info == nil {
+		return nil
+	}
+	if err == nil && s.info != nil && os.SameFile(info, s.info) && info.ModTime().Equal(s.info.ModTime()) && info.Size() == s.info.Size() {
+		return nil
+	}
+	return s.load()
+}
+
+// persist atomically replaces the file with the data in memory.
+// Must be called with s.mu held.
+func (s *JSONStore) persist() error {
+	// Write empty lists as [] rather than null
+	data := s.data
+	data.Version = jsonFormatVersion
+	data.Chunks = append(make([]jsonChunk, 0, len(data.Chunks)), data.Chunks...)
+	data.Features = append(make([]jsonFeature, 0, len(data.Features)), data.Features...)
+	data.Dependencies = append(make([]jsonEdge, 0, len(data.Dependencies)), data.Dependencies...)
+	data.Skipped = append(make([]jsonSkipped, 0, len(data.Skipped)), data.Skipped...)
+
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", s.filePath, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.filePath), "."+filepath.Base(s.filePath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.filePath, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", s.filePath, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", s.filePath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.filePath, err)
+	}
+	if err := os.Rename(tmp.Name(), s.filePath); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.filePath, err)
+	}
+
+	info, err := os.Stat(s.filePath)
+	if err != nil {
+		return err
+	}
+	s.info = info
+	return nil
+}
+
+// read runs fn on the current contents of the store.
+func (s *JSONStore) read(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// update runs fn on the current contents of the store and writes the result. If fn fails,
+// its partial changes are discarded by rereading the file on the next call.
+func (s *JSONStore) update(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		s.stale = true
+		return err
+	}
+	if err := s.persist(); err != nil {
+		s.stale = true
+		return err
+	}
+	return nil
+}
+
+// SaveChunk persists a chunk and its file snapshots, replacing any existing chunk with the same ID.
+func (s *JSONStore) SaveChunk(c chunk.Chunk) error {
+	record, err := newJSONChunk(s.objects, c)
+	if err != nil {
+		return err
+	}
+	return s.update(func() error {
+		s.putChunk(record)
+		return nil
+	})
+}
+
+// newJSONChunk writes the chunk's snapshots to the object store and returns its record.
+func newJSONChunk(objs *objects.Store, c chunk.Chunk) (jsonChunk, error) {
+	record := jsonChunk{
+		ID:         c.ID,
+		FilePath:   c.FilePath,
+		Op:         chunk.Op(c.Op.String()),
+		OldPath:    c.OldPath,
+		Branch:     c.Branch,
+		Head:       c.Head,
+		Committed:  c.Committed,
+		StartTime:  c.StartTime,
+		EndTime:    c.EndTime,
+		FeatureTag: c.FeatureTag,
+		Manual:     c.Manual,
+		Hash:       c.Hash,
+		Diff:       c.Diff,
+	}
+	var err error
+	if record.BeforeHash, err = storeSnapshot(objs, c.Before); err != nil {
+		return record, err
+	}
+	if record.AfterHash, err = storeSnapshot(objs, c.After); err != nil {
+		return record, err
+	}
+	return record, nil
+}
+
+// storeSnapshot writes a file snapshot to the object store and returns its hash,
+// or the empty string for a snapshot that is unknown (nil).
+func storeSnapshot(objs *objects.Store, content []byte) (string, error) {
+	if content == nil {
+		return "", nil
+	}
+	hash, err := objs.Put(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to store snapshot: %w", err)
+	}
+	return hash, nil
+}
+
+// putChunk replaces the chunk with the record's ID or appends the record.
+// Must be called with s.mu held.
+func (s *JSONStore) putChunk(record jsonChunk) {
+	for i, existing := range s.data.Chunks {
+		if existing.ID == record.ID {
+			s.data.Chunks[i] = record
+			return
+		}
+	}
+	s.data.Chunks = append(s.data.Chunks, record)
+}
+
+// removeChunks drops the chunks with the given IDs and the dependency edges to and from them.
+// Must be called with s.mu held.
+func (s *JSONStore) removeChunks(remove map[chunk.ChunkID]bool) {
+	chunks := s.data.Chunks[:0]
+	for _, c := range s.data.Chunks {
+		if !remove[c.ID] {
+			chunks = append(chunks, c)
+		}
+	}
+	s.data.Chunks = chunks
+
+	edges := s.data.Dependencies[:0]
+	for _, e := range s.data.Dependencies {
+		if !remove[e.Chunk] && !remove[e.DependsOn] {
+			edges = append(edges, e)
+		}
+	}
+	s.data.Dependencies = edges
+}
+
+// DeleteChunks removes chunks and the dependency edges to and from them.
+// Their snapshots stay in the object store until PruneObjects finds them unreferenced.
+func (s *JSONStore) DeleteChunks(ids []chunk.ChunkID) error {
+	remove := make(map[chunk.ChunkID]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+	return s.update(func() error {
+		s.removeChunks(remove)
+		return nil
+	})
+}
+
+// MarkCommitted records that the changes of each chunk are contained in a git commit.
+// No chunk is changed if any of them doesn't exist.
+func (s *JSONStore) MarkCommitted(ids []chunk.ChunkID, commit string) error {
+	return s.update(func() error {
+		return s.setChunks(ids, func(r *jsonChunk) { r.Committed = commit })
+	})
+}
+
+// setChunks applies set to the record of each chunk, failing if any of them doesn't exist.
+// Must be called with s.mu held.
+func (s *JSONStore) setChunks(ids []chunk.ChunkID, set func(r *jsonChunk)) error {
+	index := make(map[chunk.ChunkID]int, len(s.data.Chunks))
+	for i, r := range s.data.Chunks {
+		index[r.ID] = i
+	}
+	for _, id := range ids {
+		i, ok := index[id]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrChunkNotFound, id)
+		}
+		set(&s.data.Chunks[i])
+	}
+	return nil
+}
+
+// ReplaceChunks saves merged in place of the replaced chunks, which are removed. Dependency
+// edges to and from the replaced chunks are moved to merged.
+func (s *JSONStore) ReplaceChunks(merged chunk.Chunk, replaced []chunk.ChunkID) error {
+	record, err := newJSONChunk(s.objects, merged)
+	if err != nil {
+		return err
+	}
+
+	remove := make(map[chunk.ChunkID]bool, len(replaced))
+	for _, id := range replaced {
+		if id != merged.ID {
+			remove[id] = true
+		}
+	}
+
+	return s.update(func() error {
+		s.putChunk(record)
+
+		// Redirect edges, keeping the first of any that end up duplicated and dropping self-edges
+		type edgeKey struct{ chunk, dependsOn chunk.ChunkID }
+		seen := make(map[edgeKey]bool)
+		edges := s.data.Dependencies[:0]
+		for _, e := range s.data.Dependencies {
+			if remove[e.Chunk] {
+				e.Chunk = merged.ID
+			}
+			if remove[e.DependsOn] {
+				e.DependsOn = merged.ID
+			}
+			key := edgeKey{e.Chunk, e.DependsOn}
+			if e.Chunk == e.DependsOn || seen[key] {
+				continue
+			}
+			seen[key] = true
+			edges = append(edges, e)
+		}
+		s.data.Dependencies = edges
+
+		s.removeChunks(remove)
+		return nil
+	})
+}
+
+// toChunk converts a stored record to a chunk without its snapshots.
+func (r jsonChunk) toChunk() chunk.Chunk {
+	return chunk.Chunk{
+		ID:         r.ID,
+		FilePath:   r.FilePath,
+		Diff:       r.Diff,
+		StartTime:  r.StartTime,
+		EndTime:    r.EndTime,
+		FeatureTag: r.FeatureTag,
+		Hash:       r.Hash,
+		Manual:     r.Manual,
+		Op:         r.Op,
+		OldPath:    r.OldPath,
+		Branch:     r.Branch,
+		Head:       r.Head,
+		Committed:  r.Committed,
+	}
+}
+
+// selectChunks returns the chunks (without snapshots) whose records satisfy match, in the
+// order they were first saved. Must be called with s.mu held.
+func (s *JSONStore) selectChunks(match func(r jsonChunk) bool) []chunk.Chunk {
+	var chunks []chunk.Chunk
+	for _, r := range s.data.Chunks {
+		if match == nil || match(r) {
+			chunks = append(chunks, r.toChunk())
+		}
+	}
+	return chunks
+}
+
+// reverseChunks reverses chunks in place, turning oldest first into newest first.
+func reverseChunks(chunks []chunk.Chunk) []chunk.Chunk {
+	for i, j := 0, len(chunks)-1; i < j; i, j = i+1, j-1 {
+		chunks[i], chunks[j] = chunks[j], chunks[i]
+	}
+	return chunks
+}
+
+// FindChunks retrieves all chunks for a specific file path, including renames away from it,
+// ordered by creation time (newest first).
+func (s *JSONStore) FindChunks(filePath string) ([]chunk.Chunk, error) {
+	var chunks []chunk.Chunk
+	err := s.read(func() error {
+		chunks = reverseChunks(s.selectChunks(func(r jsonChunk) bool {
+			return r.FilePath == filePath || r.OldPath == filePath
+		}))
+		return nil
+	})
+	return chunks, err
+}
+
+// GetRecentChunks retrieves the most recently created chunks up to the specified limit.
+func (s *JSONStore) GetRecentChunks(limit int) ([]chunk.Chunk, error) {
+	var chunks []chunk.Chunk
+	err := s.read(func() error {
+		chunks = reverseChunks(s.selectChunks(nil))
+		if limit >= 0 && len(chunks) > limit {
+			chunks = chunks[:limit]
+		}
+		return nil
+	})
+	return chunks, err
+}
+
+// GetChunk retrieves a single chunk by ID, including its file snapshots.
+// Returns ErrChunkNotFound if no chunk has that ID.
+func (s *JSONStore) GetChunk(id chunk.ChunkID) (*chunk.Chunk, error) {
+	var record *jsonChunk
+	err := s.read(func() error {
+		for _, r := range s.data.Chunks {
+			if r.ID == id {
+				record = &r
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrChunkNotFound, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record.withSnapshots(s.objects)
+}
+
+// withSnapshots converts a stored record to a chunk, reading its snapshots from the object store.
+func (r jsonChunk) withSnapshots(objs *objects.Store) (*chunk.Chunk, error) {
+	c := r.toChunk()
+	var err error
+	if c.Before, err = loadStoredSnapshot(objs, r.BeforeHash); err != nil {
+		return nil, err
+	}
+	if c.After, err = loadStoredSnapshot(objs, r.AfterHash); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// loadStoredSnapshot reads the snapshot with the given hash from the object store; an
+// empty hash is an unknown snapshot.
+func loadStoredSnapshot(objs *objects.Store, hash string) ([]byte, error) {
+	if hash == "" {
+		return nil, nil
+	}
+	content, err := objs.Get(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+	return content, nil
+}
+
+// FindChunksBetween retrieves all chunks that started within [since, until], ordered
+// by start time (oldest first). A zero since or until leaves that end of the range open.
+func (s *JSONStore) FindChunksBetween(since, until time.Time) ([]chunk.Chunk, error) {
+	var chunks []chunk.Chunk
+	err := s.read(func() error {
+		chunks = filterChunksBetween(s.selectChunks(nil), since, until)
+		return nil
+	})
+	return chunks, err
+}
+
+// QueryChunks returns the page of chunks matching q, newest first.
+func (s *JSONStore) QueryChunks(q chunk.Query) (*chunk.Page, error) {
+	var page *chunk.Page
+	err := s.read(func() error {
+		var err error
+		page, err = pageChunks(s.selectChunks(nil), q)
+		return err
+	})
+	return page, err
+}
+
+// CreateFeature persists a new feature. Returns an error if a feature with the same tag exists.
+func (s *JSONStore) CreateFeature(f feature.Feature) error {
+	if err := feature.ValidateTag(string(f.Tag)); err != nil {
+		return err
+	}
+	return s.update(func() error {
+		if s.findFeature(f.Tag) != nil {
+			return fmt.Errorf("failed to create feature %s: it already exists", f.Tag)
+		}
+		s.data.Features = append(s.data.Features, jsonFeature{
+			Tag:         f.Tag,
+			Description: f.Description,
+			Status:      f.Status,
+			CreatedAt:   f.CreatedAt,
+			ClosedAt:    f.ClosedAt,
+		})
+		return nil
+	})
+}
+
+// findFeature returns the stored feature with the given tag, or nil.
+// Must be called with s.mu held.
+func (s *JSONStore) findFeature(tag feature.Tag) *jsonFeature {
+	for i := range s.data.Features {
+		if s.data.Features[i].Tag == tag {
+			return &s.data.Features[i]
+		}
+	}
+	return nil
+}
+
+// toFeature converts a stored feature record.
+func (r jsonFeature) toFeature() feature.Feature {
+	return feature.Feature{
+		Tag:         r.Tag,
+		Description: r.Description,
+		Status:      r.Status,
+		CreatedAt:   r.CreatedAt,
+		ClosedAt:    r.ClosedAt,
+	}
+}
+
+// GetFeature retrieves a feature by tag. Returns ErrFeatureNotFound if it doesn't exist.
+func (s *JSONStore) GetFeature(tag feature.Tag) (*feature.Feature, error) {
+	var f feature.Feature
+	err := s.read(func() error {
+		r := s.findFeature(tag)
+		if r == nil {
+			return fmt.Errorf("%w: %s", ErrFeatureNotFound, tag)
+		}
+		f = r.toFeature()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// ListFeatures retrieves all features, newest first.
+func (s *JSONStore) ListFeatures() ([]feature.Feature, error) {
+	var features []feature.Feature
+	err := s.read(func() error {
+		for _, r := range s.data.Features {
+			features = append(features, r.toFeature())
+		}
+		return nil
+	})
+	sort.SliceStable(features, func(i, j int) bool {
+		return features[i].CreatedAt.After(features[j].CreatedAt)
+	})
+	return features, err
+}
+
+// ActiveFeature retrieves the currently active feature, or nil if no feature is active.
+func (s *JSONStore) ActiveFeature() (*feature.Feature, error) {
+	var active *feature.Feature
+	err := s.read(func() error {
+		for _, r := range s.data.Features {
+			if r.Status == feature.StatusActive {
+				f := r.toFeature()
+				active = &f
+				break
+			}
+		}
+		return nil
+	})
+	return active, err
+}
+
+// CurrentTag returns the tag of the active feature, or the empty tag if none is active.
+// It implements chunk.Tagger so new chunks are tagged with the active feature.
+func (s *JSONStore) CurrentTag() feature.Tag {
+	active, err := s.ActiveFeature()
+	if err != nil || active == nil {
+		return ""
+	}
+	return active.Tag
+}
+
+// SetActiveFeature makes the feature with the given tag the active one, moving any
+// previously active feature back to open. An empty tag deactivates all features.
+func (s *JSONStore) SetActiveFeature(tag feature.Tag) error {
+	return s.update(func() error {
+		var target *jsonFeature
+		if tag != "" {
+			if target = s.findFeature(tag); target == nil {
+				return fmt.Errorf("%w: %s", ErrFeatureNotFound, tag)
+			}
+		}
+		for i := range s.data.Features {
+			if s.data.Features[i].Status == feature.StatusActive {
+				s.data.Features[i].Status = feature.StatusOpen
+			}
+		}
+		if target != nil {
+			target.Status, target.ClosedAt = feature.StatusActive, time.Time{}
+		}
+		return nil
+	})
+}
+
+// CloseFeature marks a feature as closed. Its chunks keep their tag.
+func (s *JSONStore) CloseFeature(tag feature.Tag) error {
+	return s.update(func() error {
+		f := s.findFeature(tag)
+		if f == nil {
+			return fmt.Errorf("%w: %s", ErrFeatureNotFound, tag)
+		}
+		f.Status, f.ClosedAt = feature.StatusClosed, time.Now()
+		return nil
+	})
+}
+
+// TagChunks assigns the given tag to each chunk. An empty tag removes the chunks' tags.
+// No chunk is changed if any of them doesn't exist.
+func (s *JSONStore) TagChunks(ids []chunk.ChunkID, tag feature.Tag) error {
+	return s.update(func() error {
+		return s.setChunks(ids, func(r *jsonChunk) { r.FeatureTag = tag })
+	})
+}
+
+// FindChunksByFeature retrieves all chunks tagged with a feature, oldest first.
+func (s *JSONStore) FindChunksByFeature(tag feature.Tag) ([]chunk.Chunk, error) {
+	var chunks []chunk.Chunk
+	err := s.read(func() error {
+		chunks = s.selectChunks(func(r jsonChunk) bool { return r.FeatureTag == tag })
+		return nil
+	})
+	return chunks, err
+}
+
+// SaveDependencies replaces the recorded dependencies of a chunk with the given edges.
+func (s *JSONStore) SaveDependencies(id chunk.ChunkID, edges []deps.Edge) error {
+	return s.update(func() error {
+		kept := s.data.Dependencies[:0]
+		for _, e := range s.data.Dependencies {
+			if e.Chunk != id {
+				kept = append(kept, e)
+			}
+		}
+
+		// Like the SQLite store, a later edge to the same chunk replaces an earlier one
+		position := make(map[chunk.ChunkID]int)
+		for _, e := range edges {
+			record := jsonEdge{Chunk: id, DependsOn: e.DependsOn, Kind: e.Kind, Detail: e.Detail}
+			if i, ok := position[e.DependsOn]; ok {
+				kept[i] = record
+				continue
+			}
+			position[e.DependsOn] = len(kept)
+			kept = append(kept, record)
+		}
+		s.data.Dependencies = kept
+		return nil
+	})
+}
+
+// selectDependencies returns the edges satisfying match, sorted by dependent chunk and then prerequisite.
+func (s *JSONStore) selectDependencies(match func(e jsonEdge) bool) ([]deps.Edge, error) {
+	var edges []deps.Edge
+	err := s.read(func() error {
+		for _, e := range s.data.Dependencies {
+			if match == nil || match(e) {
+				edges = append(edges, deps.Edge{Chunk: e.Chunk, DependsOn: e.DependsOn, Kind: e.Kind, Detail: e.Detail})
+			}
+		}
+		return nil
+	})
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].Chunk != edges[j].Chunk {
+			return edges[i].Chunk < edges[j].Chunk
+		}
+		return edges[i].DependsOn < edges[j].DependsOn
+	})
+	return edges, err
+}
+
+// FindDependencies retrieves the edges from a chunk to the chunks it depends on.
+func (s *JSONStore) FindDependencies(id chunk.ChunkID) ([]deps.Edge, error) {
+	return s.selectDependencies(func(e jsonEdge) bool { return e.Chunk == id })
+}
+
+// FindDependents retrieves the edges from chunks that depend on the given chunk.
+func (s *JSONStore) FindDependents(id chunk.ChunkID) ([]deps.Edge, error) {
+	return s.selectDependencies(func(e jsonEdge) bool { return e.DependsOn == id })
+}
+
+// ListDependencies retrieves every recorded dependency edge.
+func (s *JSONStore) ListDependencies() ([]deps.Edge, error) {
+	return s.selectDependencies(nil)
+}
+
+// SaveSkippedChange records a change to a file whose contents are not tracked.
+func (s *JSONStore) SaveSkippedChange(sc chunk.SkippedChange) error {
+	return s.update(func() error {
+		s.data.Skipped = append(s.data.Skipped, jsonSkipped(sc))
+		return nil
+	})
+}
+
+// GetRecentSkippedChanges retrieves the most recently recorded skipped changes up to the specified limit.
+func (s *JSONStore) GetRecentSkippedChanges(limit int) ([]chunk.SkippedChange, error) {
+	var changes []chunk.SkippedChange
+	err := s.read(func() error {
+		for i := len(s.data.Skipped) - 1; i >= 0 && (limit < 0 || len(changes) < limit); i-- {
+			changes = append(changes, chunk.SkippedChange(s.data.Skipped[i]))
+		}
+		return nil
+	})
+	return changes, err
+}
+
+// FindSkippedChanges retrieves the skipped changes recorded for a file, oldest first.
+func (s *JSONStore) FindSkippedChanges(filePath string) ([]chunk.SkippedChange, error) {
+	var changes []chunk.SkippedChange
+	err := s.read(func() error {
+		for _, sc := range s.data.Skipped {
+			if sc.FilePath == filePath {
+				changes = append(changes, chunk.SkippedChange(sc))
+			}
+		}
+		return nil
+	})
+	return changes, err
+}
+
+// ObjectRefs counts the references from chunks to each object in the object store.
+func (s *JSONStore) ObjectRefs() (map[string]int, error) {
+	refs := make(map[string]int)
+	err := s.read(func() error {
+		for _, r := range s.data.Chunks {
+			for _, hash := range []string{r.BeforeHash, r.AfterHash} {
+				if hash != "" {
+					refs[hash]++
+				}
+			}
+		}
+		return nil
+	})
+	return refs, err
+}
+
+// PruneObjects removes objects no chunk refers to. Objects written within the grace period
+// are kept, since the chunk referring to them may not have been saved yet.
+func (s *JSONStore) PruneObjects(grace time.Duration) (*PruneResult, error) {
+	refs, err := s.ObjectRefs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count object references: %w", err)
+	}
+	return pruneObjects(s.objects, refs, grace)
+}
+
+// Close releases the store. Every change is already on disk, so there is nothing to flush.
+func (s *JSONStore) Close() error {
+	return nil
+}
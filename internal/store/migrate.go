@@ -0,0 +1,238 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrSchemaTooNew is returned when a database was migrated by a newer version of Carya.
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of Carya supports")
+
+// Migration upgrades the database schema by one version.
+type Migration struct {
+	Version     int                 // Schema version after the migration
+	Description string              // What the migration changes
+	up          func(*sql.Tx) error // Applies the migration
+}
+
+// migrations are applied in order. Databases created before schema versioning have no
+// recorded version, so every migration must also work on a schema that already has its changes.
+var migrations = []Migration{
+	{Version: 1, Description: "Create chunks table", up: createChunkTables},
+	{Version: 2, Description: "Add file snapshots to chunks", up: func(tx *sql.Tx) error {
+		if err := ensureColumn(tx, "chunks", "before_content", "BLOB"); err != nil {
+			return err
+		}
+		return ensureColumn(tx, "chunks", "after_content", "BLOB")
+	}},
+	{Version: 3, Description: "Add feature tags", up: func(tx *sql.Tx) error {
+		if err := ensureColumn(tx, "chunks", "feature_tag", "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+		return createFeatureTables(tx)
+	}},
+	{Version: 4, Description: "Add chunk dependencies", up: createDependencyTables},
+	{Version: 5, Description: "Add file operations to chunks", up: func(tx *sql.Tx) error {
+		if err := ensureColumn(tx, "chunks", "op", "TEXT NOT NULL DEFAULT 'modify'"); err != nil {
+			return err
+		}
+		return ensureColumn(tx, "chunks", "old_path", "TEXT NOT NULL DEFAULT ''")
+	}},
+	{Version: 6, Description: "Add skipped changes", up: createSkippedTables},
+	{Version: 7, Description: "Reference snapshots in the object store", up: func(tx *sql.Tx) error {
+		if err := ensureColumn(tx, "chunks", "before_hash", "TEXT"); err != nil {
+			return err
+		}
+		return ensureColumn(tx, "chunks", "after_hash", "TEXT")
+	}},
+	{Version: 8, Description: "Record the git branch and commit of chunks", up: func(tx *sql.Tx) error {
+		for _, column := range []string{"branch", "head", "committed"} {
+			if err := ensureColumn(tx, "chunks", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_chunks_branch ON chunks(branch)`)
+		return err
+	}},
+}
+
+// LatestSchemaVersion is the schema version this version of Carya uses.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// AppliedMigration records a migration that has been applied to a database.
+type AppliedMigration struct {
+	Version     int       // Schema version after the migration
+	Description string    // What the migration changed
+	AppliedAt   time.Time // When the migration was applied
+}
+
+// SchemaStatus describes the schema version of a database.
+type SchemaStatus struct {
+	Version int                // Current schema version, 0 for a new or unversioned database
+	Latest  int                // Schema version this version of Carya uses
+	Applied []AppliedMigration // Migrations recorded in the database, oldest first
+	Pending []Migration        // Migrations still to be applied, in order
+}
+
+// MigrationResult describes a completed migration run.
+type MigrationResult struct {
+	From       int         // Schema version before migrating
+	To         int         // Schema version after migrating
+	Applied    []Migration // Migrations that were applied, in order
+	BackupPath string      // Copy of the database taken before migrating, empty if none was needed
+}
+
+// ReadSchemaStatus reports the schema version of the database at dataSourceName without changing it.
+func ReadSchemaStatus(dataSourceName string) (*SchemaStatus, error) {
+	db, err := sql.Open("sqlite3", dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return schemaStatus(db)
+}
+
+// Migrate brings the database at dataSourceName up to the latest schema version,
+// backing it up first if it holds any data.
+func Migrate(dataSourceName string) (*MigrationResult, error) {
+	db, err := sql.Open("sqlite3", dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return migrate(db, dataSourceName)
+}
+
+// schemaStatus reads the applied migrations of db and works out which are pending.
+func schemaStatus(db *sql.DB) (*SchemaStatus, error) {
+	status := &SchemaStatus{Latest: LatestSchemaVersion()}
+	versioned, err := hasTable(db, "schema_version")
+	if err != nil {
+		return nil, err
+	}
+	if !versioned {
+		status.Pending = migrations
+		return status, nil
+	}
+
+	rows, err := db.Query(`SELECT version, description, applied_at FROM schema_version ORDER BY version ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m AppliedMigration
+		if err := rows.Scan(&m.Version, &m.Description, &m.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema version: %w", err)
+		}
+		status.Applied = append(status.Applied, m)
+		status.Version = max(status.Version, m.Version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.Version > status.Version {
+			status.Pending = append(status.Pending, m)
+		}
+	}
+	return status, nil
+}
+
+// migrate applies the pending migrations to db, each in its own transaction. An existing
+// database at path is backed up before the first migration.
+func migrate(db *sql.DB, path string) (*MigrationResult, error) {
+	status, err := schemaStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	if status.Version > status.Latest {
+		return nil, fmt.Errorf("%w (database is at version %d, latest known is %d)", ErrSchemaTooNew, status.Version, status.Latest)
+	}
+
+	result := &MigrationResult{From: status.Version, To: status.Version}
+	if len(status.Pending) == 0 {
+		return result, nil
+	}
+
+	hasData, err := hasTable(db, "chunks")
+	if err != nil {
+		return nil, err
+	}
+	if hasData {
+		if result.BackupPath, err = backup(db, path, status.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`
+	if _, err := db.Exec(query); err != nil {
+		return nil, fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	for _, m := range status.Pending {
+		if err := applyMigration(db, m); err != nil {
+			return result, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+		result.Applied = append(result.Applied, m)
+		result.To = m.Version
+	}
+	return result, nil
+}
+
+// applyMigration runs a migration and records it in a single transaction.
+func applyMigration(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	query := `INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)`
+	if _, err := tx.Exec(query, m.Version, m.Description, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// hasTable reports whether the database has a table with the given name.
+func hasTable(db *sql.DB, name string) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect database: %w", err)
+	}
+	return count > 0, nil
+}
+
+// backup writes a consistent copy of the database next to the file at path and returns
+// the copy's path. Databases that don't live in a file are not backed up.
+func backup(db *sql.DB, path string, version int) (string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return "", nil
+	}
+
+	backupPath := fmt.Sprintf("%s.v%d-%s.bak", path, version, time.Now().Format("20060102-150405"))
+	if _, err := db.Exec(`VACUUM INTO ?`, backupPath); err != nil {
+		return "", fmt.Errorf("failed to back up database to %s: %w", backupPath, err)
+	}
+	return backupPath, nil
+}
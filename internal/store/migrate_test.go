@@ -0,0 +1,187 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"carya/internal/chunk"
+)
+
+// newDatabaseAt creates a database file whose schema has the migrations up to version
+// applied. A version of 0 creates the chunks table of the first release, before schema
+// versions were recorded. The database holds one chunk saved with the first release's columns.
+func newDatabaseAt(t *testing.T, version int) string {
+	t.Helper()
+	if !sqliteAvailable {
+		t.Skip("SQLite needs cgo")
+	}
+	path := filepath.Join(t.TempDir(), "carya.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if version == 0 {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := createChunkTables(tx); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		_, err := db.Exec(`CREATE TABLE schema_version (version INTEGER PRIMARY KEY, description TEXT NOT NULL, applied_at TIMESTAMP NOT NULL)`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range migrations[:version] {
+			if err := applyMigration(db, m); err != nil {
+				t.Fatalf("migration %d: %v", m.Version, err)
+			}
+		}
+	}
+
+	_, err = db.Exec(`INSERT INTO chunks (id, file_path, diff, start_time, end_time, hash, manual) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		"old-chunk", "/repo/a.txt", "@@ -1 +1 @@\n-a\n+b", time.Now(), time.Now(), "hash", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMigrate(t *testing.T) {
+	latest := LatestSchemaVersion()
+	tests := []struct {
+		name    string
+		version int
+		applied int
+	}{
+		{name: "unversioned", version: 0, applied: latest},
+		{name: "version 1", version: 1, applied: latest - 1},
+		{name: "version 2", version: 2, applied: latest - 2},
+		{name: "version 3", version: 3, applied: latest - 3},
+		{name: "version 4", version: 4, applied: latest - 4},
+		{name: "version 5", version: 5, applied: latest - 5},
+		{name: "version 6", version: 6, applied: latest - 6},
+		{name: "version 7", version: 7, applied: latest - 7},
+		{name: "latest", version: latest, applied: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := newDatabaseAt(t, tt.version)
+
+			result, err := Migrate(path)
+			if err != nil {
+				t.Fatalf("Migrate() error = %v", err)
+			}
+			if result.From != tt.version || result.To != latest || len(result.Applied) != tt.applied {
+				t.Errorf("Migrate() = from %d to %d applying %d, want from %d to %d applying %d",
+					result.From, result.To, len(result.Applied), tt.version, latest, tt.applied)
+			}
+			if tt.applied > 0 {
+				if _, err := os.Stat(result.BackupPath); err != nil {
+					t.Errorf("backup %q: %v", result.BackupPath, err)
+				}
+			} else if result.BackupPath != "" {
+				t.Errorf("Migrate() backed up an up-to-date database to %s", result.BackupPath)
+			}
+
+			status, err := ReadSchemaStatus(path)
+			if err != nil {
+				t.Fatalf("ReadSchemaStatus() error = %v", err)
+			}
+			if status.Version != latest || len(status.Pending) != 0 {
+				t.Errorf("ReadSchemaStatus() = version %d with %d pending, want %d with none", status.Version, len(status.Pending), latest)
+			}
+
+			// The chunk saved before migrating reads back with the defaults of the new columns
+			s, err := NewSQLiteStore(path)
+			if err != nil {
+				t.Fatalf("NewSQLiteStore() error = %v", err)
+			}
+			defer s.Close()
+			c, err := s.GetChunk("old-chunk")
+			if err != nil {
+				t.Fatalf("GetChunk() error = %v", err)
+			}
+			if c.Op != chunk.OpModify || c.FeatureTag != "" || c.Branch != "" || c.Committed != "" {
+				t.Errorf("GetChunk() = op %v, tag %q, branch %q, committed %q; want the defaults", c.Op, c.FeatureTag, c.Branch, c.Committed)
+			}
+		})
+	}
+}
+
+func TestMigrateUnversionedCurrentSchema(t *testing.T) {
+	// Databases from before schema versions may already have the columns later migrations add
+	path := newDatabaseAt(t, 0)
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.up(tx); err != nil {
+			t.Fatalf("migration %d: %v", m.Version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	result, err := Migrate(path)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if result.To != LatestSchemaVersion() {
+		t.Errorf("Migrate() reached version %d, want %d", result.To, LatestSchemaVersion())
+	}
+}
+
+func TestMigrateNewDatabase(t *testing.T) {
+	if !sqliteAvailable {
+		t.Skip("SQLite needs cgo")
+	}
+	path := filepath.Join(t.TempDir(), "carya.db")
+
+	result, err := Migrate(path)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if result.From != 0 || result.To != LatestSchemaVersion() {
+		t.Errorf("Migrate() = from %d to %d, want from 0 to %d", result.From, result.To, LatestSchemaVersion())
+	}
+	if result.BackupPath != "" {
+		t.Errorf("Migrate() backed up an empty database to %s", result.BackupPath)
+	}
+}
+
+func TestMigrateTooNew(t *testing.T) {
+	path := newDatabaseAt(t, LatestSchemaVersion())
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)`,
+		LatestSchemaVersion()+1, "From the future", time.Now())
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Migrate(path); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Migrate() error = %v, want ErrSchemaTooNew", err)
+	}
+}
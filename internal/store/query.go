@@ -0,0 +1,123 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"carya/internal/chunk"
+)
+
+// ErrInvalidCursor is returned when a query continues from a cursor this store didn't return.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// QueryChunks returns the page of chunks matching q, newest first.
+func (s *SQLiteStore) QueryChunks(q chunk.Query) (*chunk.Page, error) {
+	query := `
+		SELECT id, file_path, diff, start_time, end_time, hash, manual, feature_tag, op, old_path, branch, head, committed
+		FROM chunks
+		WHERE 1 = 1
+	`
+	// Narrow down by the filters SQLite can evaluate; times and globs are matched afterwards
+	var args []interface{}
+	if q.FeatureTag != "" {
+		query += ` AND feature_tag = ?`
+		args = append(args, q.FeatureTag)
+	}
+	if q.Branch != "" {
+		query += ` AND branch = ?`
+		args = append(args, q.Branch)
+	}
+	if q.Manual != nil {
+		query += ` AND manual = ?`
+		args = append(args, *q.Manual)
+	}
+	if q.Text != "" {
+		query += ` AND diff LIKE ? ESCAPE '\'`
+		args = append(args, "%"+escapeLike(q.Text)+"%")
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chunks, err := s.scanChunks(rows)
+	if err != nil {
+		return nil, err
+	}
+	return pageChunks(chunks, q)
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern, using backslash as the escape character.
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+}
+
+// pageChunks filters chunks by q and returns the requested page, newest first.
+func pageChunks(chunks []chunk.Chunk, q chunk.Query) (*chunk.Page, error) {
+	var matched []chunk.Chunk
+	for _, c := range chunks {
+		if q.Matches(c) {
+			matched = append(matched, c)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return newerThan(matched[i].StartTime, matched[i].ID, matched[j].StartTime, matched[j].ID)
+	})
+
+	if q.After != "" {
+		start, id, err := decodeCursor(q.After)
+		if err != nil {
+			return nil, err
+		}
+		skip := sort.Search(len(matched), func(i int) bool {
+			return newerThan(start, id, matched[i].StartTime, matched[i].ID)
+		})
+		matched = matched[skip:]
+	}
+
+	page := &chunk.Page{Chunks: matched}
+	if q.Limit > 0 && len(matched) > q.Limit {
+		page.Chunks = matched[:q.Limit]
+		last := page.Chunks[q.Limit-1]
+		page.Next = encodeCursor(last.StartTime, last.ID)
+	}
+	return page, nil
+}
+
+// newerThan orders chunks newest first, breaking ties between equal start times by ID.
+func newerThan(startA time.Time, idA chunk.ChunkID, startB time.Time, idB chunk.ChunkID) bool {
+	if !startA.Equal(startB) {
+		return startA.After(startB)
+	}
+	return idA > idB
+}
+
+// encodeCursor returns a cursor pointing just past the chunk with the given start time and ID.
+func encodeCursor(start time.Time, id chunk.ChunkID) chunk.Cursor {
+	raw := strconv.FormatInt(start.UnixNano(), 10) + ":" + string(id)
+	return chunk.Cursor(base64.RawURLEncoding.EncodeToString([]byte(raw)))
+}
+
+// decodeCursor returns the start time and ID of the chunk a cursor points past.
+func decodeCursor(cursor chunk.Cursor) (time.Time, chunk.ChunkID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(string(cursor))
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("%w: %s", ErrInvalidCursor, cursor)
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, "", fmt.Errorf("%w: %s", ErrInvalidCursor, cursor)
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("%w: %s", ErrInvalidCursor, cursor)
+	}
+	return time.Unix(0, n), chunk.ChunkID(id), nil
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/feature"
+)
+
+// queryChunks returns the chunks saved by newQueryStore, in no particular order. Chunks b
+// and c start at the same time, so their order depends on their IDs alone.
+func queryChunks() []chunk.Chunk {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	newChunk := func(id, path string, tag feature.Tag, branch string, minutes int, manual bool) chunk.Chunk {
+		start := base.Add(time.Duration(minutes) * time.Minute)
+		return chunk.Chunk{
+			ID:         chunk.ChunkID(id),
+			FilePath:   path,
+			Diff:       "@@ -1 +1 @@\n-old\n+new " + id,
+			StartTime:  start,
+			EndTime:    start.Add(time.Minute),
+			Hash:       chunk.ChunkHash("hash-" + id),
+			Manual:     manual,
+			Op:         chunk.OpModify,
+			FeatureTag: tag,
+			Branch:     branch,
+		}
+	}
+	return []chunk.Chunk{
+		newChunk("a", "/repo/main.go", "login", "main", 0, false),
+		newChunk("c", "/repo/docs/guide.md", "login", "topic", 10, true),
+		newChunk("b", "/repo/util.go", "search", "topic", 10, false),
+		newChunk("e", "/repo/docs/index.md", "search", "main", 30, false),
+		newChunk("d", "/repo/cmd/run.go", "login", "main", 20, false),
+	}
+}
+
+// newQueryStore opens an empty store of the backend and saves queryChunks to it.
+func newQueryStore(t *testing.T, backend Backend) Store {
+	t.Helper()
+	if err := backend.Available(); err != nil {
+		t.Skip(err)
+	}
+	s, err := OpenBackend(backend, backend.Path(t.TempDir()))
+	if err != nil {
+		t.Fatalf("OpenBackend(%s) error = %v", backend, err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	for _, c := range queryChunks() {
+		if err := s.SaveChunk(c); err != nil {
+			t.Fatalf("SaveChunk(%s) error = %v", c.ID, err)
+		}
+	}
+	return s
+}
+
+// chunkIDs returns the IDs of chunks in order.
+func chunkIDs(chunks []chunk.Chunk) []chunk.ChunkID {
+	ids := []chunk.ChunkID{}
+	for _, c := range chunks {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func TestQueryChunksPaging(t *testing.T) {
+	manual := true
+	tests := []struct {
+		name  string
+		query chunk.Query
+		want  [][]chunk.ChunkID
+	}{
+		{
+			name:  "no limit",
+			query: chunk.Query{},
+			want:  [][]chunk.ChunkID{{"e", "d", "c", "b", "a"}},
+		},
+		{
+			name:  "pages of two",
+			query: chunk.Query{Limit: 2},
+			want:  [][]chunk.ChunkID{{"e", "d"}, {"c", "b"}, {"a"}},
+		},
+		{
+			name:  "page boundary between equal start times",
+			query: chunk.Query{Limit: 3},
+			want:  [][]chunk.ChunkID{{"e", "d", "c"}, {"b", "a"}},
+		},
+		{
+			name:  "limit equal to the number of matches",
+			query: chunk.Query{Limit: 5},
+			want:  [][]chunk.ChunkID{{"e", "d", "c", "b", "a"}},
+		},
+		{
+			name:  "feature",
+			query: chunk.Query{FeatureTag: "login", Limit: 2},
+			want:  [][]chunk.ChunkID{{"d", "c"}, {"a"}},
+		},
+		{
+			name:  "branch",
+			query: chunk.Query{Branch: "main", Limit: 2},
+			want:  [][]chunk.ChunkID{{"e", "d"}, {"a"}},
+		},
+		{
+			name:  "feature and branch",
+			query: chunk.Query{FeatureTag: "login", Branch: "topic"},
+			want:  [][]chunk.ChunkID{{"c"}},
+		},
+		{
+			name:  "path glob",
+			query: chunk.Query{PathGlob: "*.go", Limit: 1},
+			want:  [][]chunk.ChunkID{{"d"}, {"b"}, {"a"}},
+		},
+		{
+			name:  "manual",
+			query: chunk.Query{Manual: &manual},
+			want:  [][]chunk.ChunkID{{"c"}},
+		},
+		{
+			name:  "text ignores case",
+			query: chunk.Query{Text: "NEW E"},
+			want:  [][]chunk.ChunkID{{"e"}},
+		},
+		{
+			name: "time range",
+			query: chunk.Query{
+				Since: time.Date(2024, 1, 1, 12, 10, 0, 0, time.UTC),
+				Until: time.Date(2024, 1, 1, 12, 20, 0, 0, time.UTC),
+				Limit: 2,
+			},
+			want: [][]chunk.ChunkID{{"d", "c"}, {"b"}},
+		},
+		{
+			name:  "no matches",
+			query: chunk.Query{FeatureTag: "other", Limit: 2},
+			want:  [][]chunk.ChunkID{{}},
+		},
+	}
+
+	for _, backend := range []Backend{BackendSQLite, BackendBolt, BackendJSON} {
+		t.Run(string(backend), func(t *testing.T) {
+			s := newQueryStore(t, backend)
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					var got [][]chunk.ChunkID
+					q := tt.query
+					for {
+						page, err := s.QueryChunks(q)
+						if err != nil {
+							t.Fatalf("QueryChunks() error = %v", err)
+						}
+						got = append(got, chunkIDs(page.Chunks))
+						if page.Next == "" || len(got) > len(tt.want) {
+							break
+						}
+						q.After = page.Next
+					}
+					if !reflect.DeepEqual(got, tt.want) {
+						t.Errorf("pages = %v, want %v", got, tt.want)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestQueryChunksInvalidCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor chunk.Cursor
+	}{
+		{name: "not base64", cursor: "not a cursor!"},
+		{name: "no separator", cursor: "MTIzNDU"},
+		{name: "bad time", cursor: "bm93OmE"},
+	}
+
+	s := newQueryStore(t, BackendJSON)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.QueryChunks(chunk.Query{After: tt.cursor}); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("QueryChunks() error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		start time.Time
+		id    chunk.ChunkID
+	}{
+		{time.Date(2024, 1, 1, 12, 0, 0, 123456789, time.UTC), "a"},
+		{time.Unix(0, 0), "id:with:colons"},
+		{time.Date(2024, 6, 1, 0, 0, 0, 0, time.FixedZone("CEST", 2*60*60)), "0195f3c2-7c1e-7a9b-8f00-000000000000"},
+	}
+
+	for _, tt := range tests {
+		start, id, err := decodeCursor(encodeCursor(tt.start, tt.id))
+		if err != nil {
+			t.Errorf("decodeCursor(encodeCursor(%v, %q)) error = %v", tt.start, tt.id, err)
+			continue
+		}
+		if !start.Equal(tt.start) || id != tt.id {
+			t.Errorf("decodeCursor(encodeCursor(%v, %q)) = %v, %q", tt.start, tt.id, start, id)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"database/sql"
+
+	"carya/internal/chunk"
+)
+
+// createSkippedTables creates the table recording changes to untracked binary or large files if it doesn't exist.
+func createSkippedTables(tx *sql.Tx) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS skipped_changes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			file_path TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			hash TEXT NOT NULL,
+			reason TEXT NOT NULL,
+			time TIMESTAMP NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_skipped_changes_file_path ON skipped_changes(file_path);
+	`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// SaveSkippedChange records a change to a file whose contents are not tracked.
+func (s *SQLiteStore) SaveSkippedChange(sc chunk.SkippedChange) error {
+	query := `
+		INSERT INTO skipped_changes (file_path, size, hash, reason, time)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err := s.db.Exec(query, sc.FilePath, sc.Size, sc.Hash, string(sc.Reason), sc.Time)
+	return err
+}
+
+// GetRecentSkippedChanges retrieves the most recently recorded skipped changes up to the specified limit.
+func (s *SQLiteStore) GetRecentSkippedChanges(limit int) ([]chunk.SkippedChange, error) {
+	query := `
+		SELECT file_path, size, hash, reason, time
+		FROM skipped_changes
+		ORDER BY id DESC
+		LIMIT ?
+	`
+	rows, err := s.db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSkippedChanges(rows)
+}
+
+// FindSkippedChanges retrieves the skipped changes recorded for a file, oldest first.
+func (s *SQLiteStore) FindSkippedChanges(filePath string) ([]chunk.SkippedChange, error) {
+	query := `
+		SELECT file_path, size, hash, reason, time
+		FROM skipped_changes
+		WHERE file_path = ?
+		ORDER BY id ASC
+	`
+	rows, err := s.db.Query(query, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSkippedChanges(rows)
+}
+
+// scanSkippedChanges reads skipped changes from query rows.
+func scanSkippedChanges(rows *sql.Rows) ([]chunk.SkippedChange, error) {
+	var changes []chunk.SkippedChange
+	for rows.Next() {
+		var sc chunk.SkippedChange
+		var reason string
+		if err := rows.Scan(&sc.FilePath, &sc.Size, &sc.Hash, &reason, &sc.Time); err != nil {
+			return nil, err
+		}
+		sc.Reason = chunk.SkipReason(reason)
+		changes = append(changes, sc)
+	}
+	return changes, rows.Err()
+}
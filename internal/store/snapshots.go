@@ -0,0 +1,167 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"carya/internal/objects"
+)
+
+// putSnapshot writes a file snapshot to the object store and returns its hash,
+// or NULL for a snapshot that is unknown (nil).
+func (s *SQLiteStore) putSnapshot(content []byte) (sql.NullString, error) {
+	if content == nil {
+		return sql.NullString{}, nil
+	}
+	hash, err := s.objects.Put(content)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to store snapshot: %w", err)
+	}
+	return sql.NullString{String: hash, Valid: true}, nil
+}
+
+// loadSnapshot reads the snapshot with the given hash from the object store. Without a
+// hash the inline content (from before the object store existed) is returned.
+func (s *SQLiteStore) loadSnapshot(hash sql.NullString, inline []byte) ([]byte, error) {
+	if !hash.Valid {
+		return inline, nil
+	}
+	content, err := s.objects.Get(hash.String)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+	return content, nil
+}
+
+// ObjectRefs counts the references from chunks to each object in the object store.
+func (s *SQLiteStore) ObjectRefs() (map[string]int, error) {
+	query := `
+		SELECT hash, COUNT(*) FROM (
+			SELECT before_hash AS hash FROM chunks WHERE before_hash IS NOT NULL
+			UNION ALL
+			SELECT after_hash AS hash FROM chunks WHERE after_hash IS NOT NULL
+		)
+		GROUP BY hash
+	`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := make(map[string]int)
+	for rows.Next() {
+		var hash string
+		var count int
+		if err := rows.Scan(&hash, &count); err != nil {
+			return nil, err
+		}
+		refs[hash] = count
+	}
+	return refs, rows.Err()
+}
+
+// MoveInlineSnapshots moves snapshots stored inline in the chunks table (by versions of
+// Carya before the object store) into the object store, and returns how many chunks changed.
+func (s *SQLiteStore) MoveInlineSnapshots() (int, error) {
+	query := `
+		SELECT id, before_content, after_content
+		FROM chunks
+		WHERE before_content IS NOT NULL OR after_content IS NOT NULL
+	`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return 0, err
+	}
+
+	type inlineSnapshots struct {
+		id            string
+		before, after []byte
+	}
+	var pending []inlineSnapshots
+	for rows.Next() {
+		var snap inlineSnapshots
+		if err := rows.Scan(&snap.id, &snap.before, &snap.after); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, snap)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, snap := range pending {
+		beforeHash, err := s.putSnapshot(snap.before)
+		if err != nil {
+			return i, err
+		}
+		afterHash, err := s.putSnapshot(snap.after)
+		if err != nil {
+			return i, err
+		}
+
+		update := `
+			UPDATE chunks
+			SET before_hash = ?, after_hash = ?, before_content = NULL, after_content = NULL
+			WHERE id = ?
+		`
+		if _, err := s.db.Exec(update, beforeHash, afterHash, snap.id); err != nil {
+			return i, err
+		}
+	}
+	return len(pending), nil
+}
+
+// PruneResult describes the objects removed by PruneObjects.
+type PruneResult struct {
+	Removed int   // Number of objects removed
+	Freed   int64 // Bytes freed on disk
+	Kept    int   // Number of objects still referenced (or too recent to remove)
+}
+
+// PruneObjects removes objects no chunk refers to. Objects written within the grace period
+// are kept, since the chunk referring to them may not have been saved yet.
+func (s *SQLiteStore) PruneObjects(grace time.Duration) (*PruneResult, error) {
+	refs, err := s.ObjectRefs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count object references: %w", err)
+	}
+	return pruneObjects(s.objects, refs, grace)
+}
+
+// pruneObjects removes the objects without references that are older than the grace period.
+func pruneObjects(store *objects.Store, refs map[string]int, grace time.Duration) (*PruneResult, error) {
+	stored, err := store.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	cutoff := time.Now().Add(-grace)
+	result := &PruneResult{}
+	for _, obj := range stored {
+		if refs[obj.Hash] > 0 || obj.ModTime.After(cutoff) {
+			result.Kept++
+			continue
+		}
+		if err := store.Remove(obj.Hash); err != nil {
+			return result, fmt.Errorf("failed to remove object %s: %w", obj.Hash, err)
+		}
+		result.Removed++
+		result.Freed += obj.Size
+	}
+	return result, nil
+}
+
+// Vacuum rebuilds the database file to release the space of deleted data.
+func (s *SQLiteStore) Vacuum() error {
+	_, err := s.db.Exec(`VACUUM`)
+	return err
+}
+
+// Objects returns the object store holding the store's file snapshots.
+func (s *SQLiteStore) Objects() *objects.Store {
+	return s.objects
+}
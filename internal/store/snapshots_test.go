@@ -0,0 +1,68 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"carya/internal/objects"
+)
+
+func TestPruneObjects(t *testing.T) {
+	dir := t.TempDir()
+	s := objects.New(dir)
+	put := func(content string, age time.Duration) string {
+		t.Helper()
+		hash, err := s.Put([]byte(content))
+		if err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		modTime := time.Now().Add(-age)
+		if err := os.Chtimes(filepath.Join(dir, hash[:2], hash[2:]), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	referenced := put("referenced", 48*time.Hour)
+	unreferenced := put("unreferenced", 48*time.Hour)
+	recent := put("recent", time.Minute)
+	released := put("released", 48*time.Hour)
+	refs := map[string]int{referenced: 2, released: 0}
+
+	objs, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var freed int64
+	for _, obj := range objs {
+		if obj.Hash == unreferenced || obj.Hash == released {
+			freed += obj.Size
+		}
+	}
+
+	result, err := pruneObjects(s, refs, time.Hour)
+	if err != nil {
+		t.Fatalf("pruneObjects() error = %v", err)
+	}
+	if result.Removed != 2 || result.Kept != 2 || result.Freed != freed {
+		t.Errorf("pruneObjects() = %+v, want 2 removed freeing %d bytes and 2 kept", result, freed)
+	}
+
+	tests := []struct {
+		name string
+		hash string
+		kept bool
+	}{
+		{name: "referenced", hash: referenced, kept: true},
+		{name: "unreferenced", hash: unreferenced, kept: false},
+		{name: "within the grace period", hash: recent, kept: true},
+		{name: "no references left", hash: released, kept: false},
+	}
+	for _, tt := range tests {
+		if got := s.Has(tt.hash); got != tt.kept {
+			t.Errorf("%s: Has() = %v after pruning, want %v", tt.name, got, tt.kept)
+		}
+	}
+}
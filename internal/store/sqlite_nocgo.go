@@ -0,0 +1,6 @@
+//go:build !cgo
+
+package store
+
+// sqliteAvailable reports whether this build can use the SQLite backend, which needs cgo.
+const sqliteAvailable = false
@@ -1,37 +1,52 @@
 // Package store provides storage implementations for persisting chunks in the
-// Carya version control system, including SQLite and JSON-based storage.
+// Carya version control system, including SQLite, bolt and JSON-based storage.
 package store
 
 import (
 	"carya/internal/chunk"
+	"carya/internal/objects"
 	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// ErrChunkNotFound is returned when a chunk lookup by ID finds nothing.
+var ErrChunkNotFound = errors.New("chunk not found")
+
 // SQLiteStore provides SQLite-based persistent storage for chunks.
 type SQLiteStore struct {
-	db *sql.DB // SQLite database connection
+	db      *sql.DB        // SQLite database connection
+	objects *objects.Store // Blob store holding file snapshots, next to the database
 }
 
 // NewSQLiteStore creates a new SQLite store with the specified database file path.
-// It automatically initializes the required tables and indexes.
+// It creates the tables of a new database and migrates an existing one to the latest
+// schema, backing it up first. File snapshots are kept in the objects directory next
+// to the database.
 func NewSQLiteStore(dataSourceName string) (*SQLiteStore, error) {
 	db, err := sql.Open("sqlite3", dataSourceName)
 	if err != nil {
 		return nil, err
 	}
 
-	store := &SQLiteStore{db: db}
-	if err := store.initTables(); err != nil {
+	if _, err := migrate(db, dataSourceName); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	return store, nil
+	return &SQLiteStore{
+		db:      db,
+		objects: objects.New(filepath.Join(filepath.Dir(dataSourceName), "objects")),
+	}, nil
 }
 
-// initTables creates the chunks table and associated indexes if they don't exist.
-func (s *SQLiteStore) initTables() error {
+// createChunkTables creates the chunks table and associated indexes if they don't exist.
+func createChunkTables(tx *sql.Tx) error {
 	query := `
 		CREATE TABLE IF NOT EXISTS chunks (
 			id TEXT PRIMARY KEY,
@@ -46,29 +61,167 @@ func (s *SQLiteStore) initTables() error {
 		CREATE INDEX IF NOT EXISTS idx_chunks_file_path ON chunks(file_path);
 		CREATE INDEX IF NOT EXISTS idx_chunks_created_at ON chunks(created_at);
 	`
-	_, err := s.db.Exec(query)
+	_, err := tx.Exec(query)
+	return err
+}
+
+// ensureColumn adds a column to an existing table if it is not already present.
+func ensureColumn(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    bool
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
 	return err
 }
 
-// SaveChunk persists a chunk to the SQLite database, replacing any existing chunk with the same ID.
+// SaveChunk persists a chunk to the SQLite database and its file snapshots to the object
+// store, replacing any existing chunk with the same ID.
 func (s *SQLiteStore) SaveChunk(c chunk.Chunk) error {
+	return s.saveChunk(s.db, c)
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// saveChunk writes the chunk's snapshots to the object store and the chunk itself through db.
+func (s *SQLiteStore) saveChunk(db execer, c chunk.Chunk) error {
+	beforeHash, err := s.putSnapshot(c.Before)
+	if err != nil {
+		return err
+	}
+	afterHash, err := s.putSnapshot(c.After)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT OR REPLACE INTO chunks (id, file_path, diff, start_time, end_time, hash, manual)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT OR REPLACE INTO chunks (id, file_path, diff, start_time, end_time, hash, manual, feature_tag, op, old_path, branch, head, committed, before_hash, after_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := s.db.Exec(query, c.ID, c.FilePath, c.Diff, c.StartTime, c.EndTime, c.Hash, c.Manual)
+	_, err = db.Exec(query, c.ID, c.FilePath, c.Diff, c.StartTime, c.EndTime, c.Hash, c.Manual, c.FeatureTag, c.Op.String(), c.OldPath,
+		c.Branch, c.Head, c.Committed, beforeHash, afterHash)
 	return err
 }
 
-// FindChunks retrieves all chunks for a specific file path, ordered by creation time (newest first).
+// DeleteChunks removes chunks and the dependency edges to and from them.
+// Their snapshots stay in the object store until PruneObjects finds them unreferenced.
+func (s *SQLiteStore) DeleteChunks(ids []chunk.ChunkID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		if err := deleteChunk(tx, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// MarkCommitted records that the changes of each chunk are contained in a git commit.
+// No chunk is changed if any of them doesn't exist.
+func (s *SQLiteStore) MarkCommitted(ids []chunk.ChunkID, commit string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		result, err := tx.Exec(`UPDATE chunks SET committed = ? WHERE id = ?`, commit, id)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("%w: %s", ErrChunkNotFound, id)
+		}
+	}
+	return tx.Commit()
+}
+
+// ReplaceChunks saves merged in place of the replaced chunks, which are removed. Dependency
+// edges to and from the replaced chunks are moved to merged.
+func (s *SQLiteStore) ReplaceChunks(merged chunk.Chunk, replaced []chunk.ChunkID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.saveChunk(tx, merged); err != nil {
+		return err
+	}
+	for _, id := range replaced {
+		if id == merged.ID {
+			continue
+		}
+		queries := []string{
+			`UPDATE OR IGNORE chunk_dependencies SET depends_on = ? WHERE depends_on = ?`,
+			`UPDATE OR IGNORE chunk_dependencies SET chunk_id = ? WHERE chunk_id = ?`,
+		}
+		for _, query := range queries {
+			if _, err := tx.Exec(query, merged.ID, id); err != nil {
+				return err
+			}
+		}
+		// Edges that would have duplicated an existing one were left behind
+		if err := deleteChunk(tx, id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM chunk_dependencies WHERE chunk_id = depends_on`); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteChunk removes a chunk and its dependency edges within a transaction.
+func deleteChunk(tx *sql.Tx, id chunk.ChunkID) error {
+	if _, err := tx.Exec(`DELETE FROM chunks WHERE id = ?`, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM chunk_dependencies WHERE chunk_id = ? OR depends_on = ?`, id, id)
+	return err
+}
+
+// FindChunks retrieves all chunks for a specific file path, including renames away from it,
+// ordered by creation time (newest first).
 func (s *SQLiteStore) FindChunks(filePath string) ([]chunk.Chunk, error) {
 	query := `
-		SELECT id, file_path, diff, start_time, end_time, hash, manual
+		SELECT id, file_path, diff, start_time, end_time, hash, manual, feature_tag, op, old_path, branch, head, committed
 		FROM chunks 
-		WHERE file_path = ?
+		WHERE file_path = ? OR old_path = ?
 		ORDER BY created_at DESC
 	`
-	rows, err := s.db.Query(query, filePath)
+	rows, err := s.db.Query(query, filePath, filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -80,7 +233,7 @@ func (s *SQLiteStore) FindChunks(filePath string) ([]chunk.Chunk, error) {
 // GetRecentChunks retrieves the most recently created chunks up to the specified limit.
 func (s *SQLiteStore) GetRecentChunks(limit int) ([]chunk.Chunk, error) {
 	query := `
-		SELECT id, file_path, diff, start_time, end_time, hash, manual
+		SELECT id, file_path, diff, start_time, end_time, hash, manual, feature_tag, op, old_path, branch, head, committed
 		FROM chunks 
 		ORDER BY created_at DESC
 		LIMIT ?
@@ -94,12 +247,64 @@ func (s *SQLiteStore) GetRecentChunks(limit int) ([]chunk.Chunk, error) {
 	return s.scanChunks(rows)
 }
 
+// GetChunk retrieves a single chunk by ID, including its file snapshots.
+// Returns ErrChunkNotFound if no chunk has that ID.
+func (s *SQLiteStore) GetChunk(id chunk.ChunkID) (*chunk.Chunk, error) {
+	query := `
+		SELECT id, file_path, diff, start_time, end_time, hash, manual, feature_tag, op, old_path, branch, head, committed,
+			before_content, after_content, before_hash, after_hash
+		FROM chunks
+		WHERE id = ?
+	`
+	var c chunk.Chunk
+	var beforeHash, afterHash sql.NullString
+	err := s.db.QueryRow(query, id).Scan(&c.ID, &c.FilePath, &c.Diff, &c.StartTime, &c.EndTime, &c.Hash, &c.Manual, &c.FeatureTag, &c.Op, &c.OldPath, &c.Branch, &c.Head, &c.Committed,
+		&c.Before, &c.After, &beforeHash, &afterHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrChunkNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Chunks saved before snapshots moved to the object store keep them inline
+	if c.Before, err = s.loadSnapshot(beforeHash, c.Before); err != nil {
+		return nil, err
+	}
+	if c.After, err = s.loadSnapshot(afterHash, c.After); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// FindChunksBetween retrieves all chunks that started within [since, until], ordered
+// by start time (oldest first). A zero since or until leaves that end of the range open.
+func (s *SQLiteStore) FindChunksBetween(since, until time.Time) ([]chunk.Chunk, error) {
+	query := `
+		SELECT id, file_path, diff, start_time, end_time, hash, manual, feature_tag, op, old_path, branch, head, committed
+		FROM chunks
+	`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chunks, err := s.scanChunks(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Timestamps are stored as zone-dependent text, so range filtering happens here
+	return filterChunksBetween(chunks, since, until), nil
+}
+
 // scanChunks converts SQL rows into a slice of Chunk structs.
 func (s *SQLiteStore) scanChunks(rows *sql.Rows) ([]chunk.Chunk, error) {
 	var chunks []chunk.Chunk
 	for rows.Next() {
 		var c chunk.Chunk
-		err := rows.Scan(&c.ID, &c.FilePath, &c.Diff, &c.StartTime, &c.EndTime, &c.Hash, &c.Manual)
+		err := rows.Scan(&c.ID, &c.FilePath, &c.Diff, &c.StartTime, &c.EndTime, &c.Hash, &c.Manual, &c.FeatureTag, &c.Op, &c.OldPath, &c.Branch, &c.Head, &c.Committed)
 		if err != nil {
 			return nil, err
 		}
@@ -113,56 +318,21 @@ func (s *SQLiteStore) Close() error {
 	return s.db.Close()
 }
 
-// JSONStore provides in-memory storage for chunks with JSON persistence capability.
-// Note: This implementation currently doesn't persist to disk.
-type JSONStore struct {
-	filePath string        // Path where JSON data would be persisted
-	chunks   []chunk.Chunk // In-memory chunk storage
-}
-
-// NewJSONStore creates a new JSON-based chunk store with the specified file path.
-func NewJSONStore(filePath string) *JSONStore {
-	return &JSONStore{
-		filePath: filePath,
-		chunks:   make([]chunk.Chunk, 0),
-	}
-}
-
-// SaveChunk adds or updates a chunk in the in-memory store.
-// If a chunk with the same ID exists, it will be replaced.
-func (s *JSONStore) SaveChunk(c chunk.Chunk) error {
-	for i, existing := range s.chunks {
-		if existing.ID == c.ID {
-			s.chunks[i] = c
-			return s.persist()
-		}
-	}
-	s.chunks = append(s.chunks, c)
-	return s.persist()
-}
-
-// FindChunks retrieves all chunks for a specific file path from the in-memory store.
-func (s *JSONStore) FindChunks(filePath string) ([]chunk.Chunk, error) {
+// filterChunksBetween returns the chunks that started within [since, until], oldest first.
+func filterChunksBetween(chunks []chunk.Chunk, since, until time.Time) []chunk.Chunk {
 	var result []chunk.Chunk
-	for _, c := range s.chunks {
-		if c.FilePath == filePath {
-			result = append(result, c)
+	for _, c := range chunks {
+		if !since.IsZero() && c.StartTime.Before(since) {
+			continue
 		}
+		if !until.IsZero() && c.StartTime.After(until) {
+			continue
+		}
+		result = append(result, c)
 	}
-	return result, nil
-}
-
-// GetRecentChunks retrieves the most recently added chunks up to the specified limit.
-func (s *JSONStore) GetRecentChunks(limit int) ([]chunk.Chunk, error) {
-	if limit > len(s.chunks) {
-		limit = len(s.chunks)
-	}
-	result := make([]chunk.Chunk, limit)
-	copy(result, s.chunks[len(s.chunks)-limit:])
-	return result, nil
-}
 
-// persist would write the chunks to disk as JSON. Currently a no-op.
-func (s *JSONStore) persist() error {
-	return nil
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+	return result
 }
@@ -0,0 +1,248 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"carya/internal/chunk"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	viewerPageSize = 100 // Chunks loaded from the store at a time
+	viewerPrefetch = 20  // Load the next page once the cursor is this close to the end of the list
+)
+
+// GroupMode selects how the chunk list is grouped
+type GroupMode int
+
+const (
+	GroupNone    GroupMode = iota // One list, newest first
+	GroupFile                     // By file path
+	GroupDay                      // By the day the chunk started
+	GroupFeature                  // By feature tag
+)
+
+// String names the grouping for display
+func (g GroupMode) String() string {
+	switch g {
+	case GroupFile:
+		return "file"
+	case GroupDay:
+		return "day"
+	case GroupFeature:
+		return "feature"
+	}
+	return "none"
+}
+
+// MoreChunksMsg carries the next page of chunks loaded from the store
+type MoreChunksMsg struct {
+	Page  *chunk.Page
+	Error error
+}
+
+// loadMore loads the page of chunks after the ones already loaded, unless one is already
+// loading or the whole history is loaded. Only the first page is loaded up front, so the
+// list grows as the cursor nears its end; while a search is active, pages keep loading
+// until the matches fill the list or the history runs out.
+func (m *DiffViewerModel) loadMore() tea.Cmd {
+	if m.next == "" || m.loadingMore || len(m.chunks)-m.cursor > viewerPrefetch {
+		return nil
+	}
+
+	m.loadingMore = true
+	q := chunk.Query{Branch: m.onlyBranch, After: m.next, Limit: viewerPageSize}
+	return func() tea.Msg {
+		page, err := m.store.QueryChunks(q)
+		return MoreChunksMsg{Page: page, Error: err}
+	}
+}
+
+// appendPage adds a page of older chunks to the end of the list, keeping the selection in place
+func (m *DiffViewerModel) appendPage(page *chunk.Page) {
+	known := make(map[chunk.ChunkID]bool)
+	for _, c := range m.allChunks {
+		known[c.ID] = true
+	}
+
+	chunks := m.allChunks
+	for _, c := range page.Chunks {
+		if !known[c.ID] {
+			chunks = append(chunks, c)
+		}
+	}
+	m.next = page.Next
+
+	selected := m.selectedKey()
+	m.setChunks(chunks)
+	m.selectKey(selected)
+}
+
+// startSearch focuses the search input
+func (m *DiffViewerModel) startSearch() tea.Cmd {
+	m.searching = true
+	return m.search.Focus()
+}
+
+// updateSearch passes a key to the search input, filtering the list as the query changes.
+// Enter keeps the query and returns to the list; Esc clears it.
+func (m *DiffViewerModel) updateSearch(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.searching = false
+		m.search.Blur()
+		return nil
+	case tea.KeyEsc:
+		m.searching = false
+		m.search.Blur()
+		m.search.SetValue("")
+	}
+
+	var cmd tea.Cmd
+	if m.searching {
+		m.search, cmd = m.search.Update(msg)
+	}
+	if query := strings.TrimSpace(m.search.Value()); query != m.query {
+		m.query = query
+		m.cursor = 0
+		m.applyFilter()
+		m.updateDiffContent()
+	}
+	return tea.Batch(cmd, m.loadMore())
+}
+
+// matchesSearch reports whether a chunk matches every term of the search query, either by
+// a fuzzy match on its path or by the term appearing in its diff
+func (m *DiffViewerModel) matchesSearch(c chunk.Chunk) bool {
+	if m.query == "" {
+		return true
+	}
+
+	path := strings.ToLower(c.FilePath + " " + c.OldPath)
+	diff := strings.ToLower(c.Diff)
+	for _, term := range strings.Fields(strings.ToLower(m.query)) {
+		if !fuzzyMatch(term, path) && !strings.Contains(diff, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// fuzzyMatch reports whether the characters of pattern appear in text in order, like a
+// telescope or fzf file finder: "dvw" matches "diff_viewer.go"
+func fuzzyMatch(pattern, text string) bool {
+	for _, r := range pattern {
+		i := strings.IndexRune(text, r)
+		if i < 0 {
+			return false
+		}
+		text = text[i+utf8.RuneLen(r):]
+	}
+	return true
+}
+
+// cycleGrouping moves to the next grouping of the list, wrapping back to no grouping
+func (m *DiffViewerModel) cycleGrouping() {
+	m.groupBy = (m.groupBy + 1) % (GroupFeature + 1)
+	selected := m.selectedKey()
+	m.applyFilter()
+	m.selectKey(selected)
+}
+
+// groupChunks orders the visible list by group. Groups are ordered by their newest chunk,
+// and the chunks within a group stay newest first.
+func (m *DiffViewerModel) groupChunks() {
+	if m.groupBy == GroupNone {
+		return
+	}
+
+	order := make(map[string]int)
+	for _, c := range m.chunks {
+		label := m.groupLabel(c)
+		if _, ok := order[label]; !ok {
+			order[label] = len(order)
+		}
+	}
+	grouped := append([]chunk.Chunk(nil), m.chunks...)
+	sort.SliceStable(grouped, func(i, j int) bool {
+		return order[m.groupLabel(grouped[i])] < order[m.groupLabel(grouped[j])]
+	})
+	m.chunks = grouped
+}
+
+// groupLabel names the group a chunk is listed under; pending chunks form a group of their own
+func (m *DiffViewerModel) groupLabel(c chunk.Chunk) string {
+	if isPending(c) {
+		return IconPending + " pending"
+	}
+
+	switch m.groupBy {
+	case GroupFile:
+		return c.FilePath
+	case GroupDay:
+		return c.StartTime.Format("Mon Jan 2, 2006")
+	case GroupFeature:
+		if c.FeatureTag == "" {
+			return "untagged"
+		}
+		return string(c.FeatureTag)
+	}
+	return ""
+}
+
+// groupCounts counts the visible chunks in each group
+func (m *DiffViewerModel) groupCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, c := range m.chunks {
+		counts[m.groupLabel(c)]++
+	}
+	return counts
+}
+
+// renderGroupHeader renders the line introducing a group in the list
+func renderGroupHeader(label string, count int, width int) string {
+	if width > 12 && utf8.RuneCountInString(label) > width-8 {
+		// Keep the end of long paths, which names the file
+		runes := []rune(label)
+		label = "…" + string(runes[len(runes)-(width-9):])
+	}
+	return SubheaderStyle.Render(label) + " " + MutedTextStyle.Render(fmt.Sprintf("(%d)", count))
+}
+
+// toggleFullWidth switches between the split view and a diff using the full width of the terminal
+func (m *DiffViewerModel) toggleFullWidth() {
+	m.fullWidth = !m.fullWidth
+	m.layout()
+	m.updateDiffContent()
+}
+
+// layout sizes the panels for the terminal: 40% for the list and 60% for the diff, or the
+// whole width for the diff in full-width mode
+func (m *DiffViewerModel) layout() {
+	if m.fullWidth {
+		m.listWidth = 0
+	} else {
+		m.listWidth = int(float64(m.width) * 0.4)
+	}
+	m.diffWidth = m.width - m.listWidth
+
+	headerHeight := 2
+	footerHeight := 2
+	contentHeight := m.height - headerHeight - footerHeight
+
+	if !m.ready {
+		m.listViewport = viewport.New(max(m.listWidth-2, 0), contentHeight)
+		m.diffViewport = viewport.New(m.diffWidth-2, contentHeight)
+		m.ready = true
+		return
+	}
+	m.listViewport.Width = max(m.listWidth-2, 0)
+	m.listViewport.Height = contentHeight
+	m.diffViewport.Width = m.diffWidth - 2
+	m.diffViewport.Height = contentHeight
+}
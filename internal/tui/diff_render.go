@@ -0,0 +1,504 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"carya/internal/chunk"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Backgrounds marking changed lines, and the words that changed within them
+var (
+	colorAddedLine   = lipgloss.Color("#1f3a2c") // Dark green
+	colorAddedWord   = lipgloss.Color("#2f6a43") // Brighter green
+	colorRemovedLine = lipgloss.Color("#3f2330") // Dark red
+	colorRemovedWord = lipgloss.Color("#7a2f3f") // Brighter red
+)
+
+const (
+	tabWidth      = 4     // Columns a tab is expanded to
+	maxWordDiff   = 40000 // Largest product of word counts of a line pair that gets a word-level diff
+	minWordShared = 0.3   // Share of a line pair that must be unchanged for a word-level diff
+)
+
+// diffRowKind classifies a line of a rendered diff
+type diffRowKind int
+
+const (
+	rowMeta    diffRowKind = iota // File header before the first hunk
+	rowHunk                       // "@@ -a,b +c,d @@" hunk header
+	rowContext                    // Unchanged line
+	rowAdded                      // Line only in the new file
+	rowRemoved                    // Line only in the old file
+	rowNote                       // "\ No newline at end of file"
+)
+
+// diffRow is a parsed line of a unified diff, ready to be rendered
+type diffRow struct {
+	kind    diffRowKind
+	text    string         // Line without its diff prefix, tabs expanded
+	oldNum  int            // Line number in the old file (0 if the line isn't in it)
+	newNum  int            // Line number in the new file (0 if the line isn't in it)
+	tokens  []chroma.Token // Syntax tokens covering text
+	changed [][2]int       // Byte ranges of text that differ from the paired line of the other side
+}
+
+// diffRenderer renders the diff of a chunk with syntax highlighting and word-level changes
+type diffRenderer struct {
+	style *chroma.Style // Colors of syntax tokens
+	lexer chroma.Lexer  // Lexer for the chunk's language (nil for plain text)
+	width int           // Width to fill with the line backgrounds
+}
+
+// newDiffRenderer creates a renderer for a diff of the file at path, choosing the language
+// by its file name
+func newDiffRenderer(path string, width int) *diffRenderer {
+	r := &diffRenderer{style: styles.Get("tokyonight-night"), width: width}
+	if lexer := lexers.Match(path); lexer != nil {
+		r.lexer = chroma.Coalesce(lexer)
+	}
+	return r
+}
+
+// parseDiff splits a unified diff into rows, numbering the lines of each hunk and marking
+// the words that changed between removed lines and the added lines replacing them
+func (r *diffRenderer) parseDiff(diff string) []diffRow {
+	var rows []diffRow
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			break
+		}
+		if line != "" {
+			rows = append(rows, diffRow{kind: rowMeta, text: line})
+		}
+	}
+
+	hunks, err := chunk.ParseHunks(diff)
+	if err != nil {
+		// Show what we can rather than nothing
+		for _, line := range strings.Split(diff, "\n") {
+			rows = append(rows, diffRow{kind: rowMeta, text: line})
+		}
+		return rows
+	}
+
+	for _, h := range hunks {
+		rows = append(rows, diffRow{kind: rowHunk, text: h.Header()})
+		start := len(rows)
+		oldNum, newNum := h.OldStart, h.NewStart
+		for _, line := range h.Lines {
+			row := diffRow{text: expandTabs(line[1:])}
+			switch line[0] {
+			case '+':
+				row.kind, row.newNum = rowAdded, newNum
+				newNum++
+			case '-':
+				row.kind, row.oldNum = rowRemoved, oldNum
+				oldNum++
+			case '\\':
+				row.kind, row.text = rowNote, line
+			default:
+				row.kind, row.oldNum, row.newNum = rowContext, oldNum, newNum
+				oldNum++
+				newNum++
+			}
+			rows = append(rows, row)
+		}
+		r.highlight(rows[start:])
+		markWordChanges(rows[start:])
+	}
+	return rows
+}
+
+// highlight tokenizes the old and the new side of a hunk separately, so constructs spanning
+// several lines such as block comments are colored as a whole on either side
+func (r *diffRenderer) highlight(rows []diffRow) {
+	var oldSide, newSide []*diffRow
+	for i := range rows {
+		switch rows[i].kind {
+		case rowContext:
+			oldSide = append(oldSide, &rows[i])
+			newSide = append(newSide, &rows[i])
+		case rowRemoved:
+			oldSide = append(oldSide, &rows[i])
+		case rowAdded:
+			newSide = append(newSide, &rows[i])
+		}
+	}
+
+	// Context lines are on both sides and end up with the tokens of the new one
+	r.tokenize(oldSide)
+	r.tokenize(newSide)
+}
+
+// tokenize sets the syntax tokens of consecutive lines of one side of a hunk
+func (r *diffRenderer) tokenize(rows []*diffRow) {
+	texts := make([]string, len(rows))
+	for i, row := range rows {
+		texts[i] = row.text
+		row.tokens = []chroma.Token{{Type: chroma.Text, Value: row.text}}
+	}
+	if r.lexer == nil || len(rows) == 0 {
+		return
+	}
+
+	it, err := r.lexer.Tokenise(nil, strings.Join(texts, "\n")+"\n")
+	if err != nil {
+		return
+	}
+	line := 0
+	var tokens []chroma.Token
+	for _, tok := range it.Tokens() {
+		for {
+			before, after, found := strings.Cut(tok.Value, "\n")
+			if before != "" {
+				tokens = append(tokens, chroma.Token{Type: tok.Type, Value: before})
+			}
+			if !found {
+				break
+			}
+			if line < len(rows) {
+				rows[line].tokens = tokens
+			}
+			line++
+			tokens = nil
+			tok.Value = after
+		}
+	}
+}
+
+// markWordChanges pairs each run of removed lines with the run of added lines following
+// it and marks the words that differ between the lines of each pair
+func markWordChanges(rows []diffRow) {
+	for i := 0; i < len(rows); {
+		if rows[i].kind != rowRemoved {
+			i++
+			continue
+		}
+		removedStart := i
+		for i < len(rows) && rows[i].kind == rowRemoved {
+			i++
+		}
+		addedStart := i
+		for i < len(rows) && rows[i].kind == rowAdded {
+			i++
+		}
+
+		pairs := min(addedStart-removedStart, i-addedStart)
+		for p := 0; p < pairs; p++ {
+			old, new := &rows[removedStart+p], &rows[addedStart+p]
+			old.changed, new.changed = wordChanges(old.text, new.text)
+		}
+	}
+}
+
+// wordChanges compares two versions of a line word by word and returns the byte ranges
+// that changed in each. Lines with too little in common are left unmarked, since the
+// whole line changed.
+func wordChanges(old, new string) (oldChanged, newChanged [][2]int) {
+	a, b := splitWords(old), splitWords(new)
+	if len(a)*len(b) > maxWordDiff {
+		return nil, nil
+	}
+
+	// Longest common subsequence of the words, filled from the end
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	shared := 0
+	oldPos, newPos := 0, 0
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			shared += len(a[i])
+			oldPos += len(a[i])
+			newPos += len(b[j])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			newChanged = addRange(newChanged, newPos, newPos+len(b[j]))
+			newPos += len(b[j])
+			j++
+		default:
+			oldChanged = addRange(oldChanged, oldPos, oldPos+len(a[i]))
+			oldPos += len(a[i])
+			i++
+		}
+	}
+
+	if float64(shared) < minWordShared*float64(max(len(old), len(new))) {
+		return nil, nil
+	}
+	return oldChanged, newChanged
+}
+
+// addRange appends the byte range [start, end) to ranges, merging it with the last one if they touch
+func addRange(ranges [][2]int, start, end int) [][2]int {
+	if n := len(ranges); n > 0 && ranges[n-1][1] == start {
+		ranges[n-1][1] = end
+		return ranges
+	}
+	return append(ranges, [2]int{start, end})
+}
+
+// splitWords splits a line into runs of letters and digits, runs of spaces and single
+// other characters, which concatenate back to the line
+func splitWords(s string) []string {
+	var words []string
+	start := 0
+	class := -1
+	for i, r := range s {
+		c := 2 // Punctuation and symbols stand alone
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			c = 0
+		case unicode.IsSpace(r):
+			c = 1
+		}
+		if i > start && (c != class || c == 2) {
+			words = append(words, s[start:i])
+			start = i
+		}
+		class = c
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+// expandTabs replaces tabs with spaces up to the next tab stop, so widths can be measured
+func expandTabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range s {
+		if r == '\t' {
+			n := tabWidth - col%tabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+// renderUnified renders the diff as a single column, with the old and new line numbers in a gutter
+func (r *diffRenderer) renderUnified(diff string) string {
+	rows := r.parseDiff(diff)
+	gutter := gutterWidth(rows)
+
+	var lines []string
+	for _, row := range rows {
+		switch row.kind {
+		case rowMeta, rowHunk, rowNote:
+			lines = append(lines, renderHeaderRow(row))
+			continue
+		}
+		prefix := lineNumber(row.oldNum, gutter) + " " + lineNumber(row.newNum, gutter) + " "
+		lines = append(lines, r.renderCode(row, prefix, r.width))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderSideBySide renders the diff in two columns, the old file on the left and the new on
+// the right, with removed lines facing the added lines replacing them
+func (r *diffRenderer) renderSideBySide(diff string) string {
+	rows := r.parseDiff(diff)
+	gutter := gutterWidth(rows)
+	column := max((r.width-1)/2, gutter+4)
+	separator := MutedTextStyle.Render("│")
+	blank := strings.Repeat(" ", column)
+
+	side := func(row *diffRow, num int) string {
+		if row == nil {
+			return blank
+		}
+		return r.renderCode(*row, lineNumber(num, gutter)+" ", column)
+	}
+
+	var lines []string
+	for i := 0; i < len(rows); {
+		row := rows[i]
+		switch row.kind {
+		case rowMeta:
+			// The file headers add nothing the columns don't show
+			i++
+			continue
+		case rowHunk, rowNote:
+			lines = append(lines, renderHeaderRow(row))
+			i++
+			continue
+		case rowContext:
+			lines = append(lines, side(&row, row.oldNum)+separator+side(&row, row.newNum))
+			i++
+			continue
+		}
+
+		// A run of removed lines followed by added lines: put them next to each other
+		var removed, added []diffRow
+		for i < len(rows) && rows[i].kind == rowRemoved {
+			removed = append(removed, rows[i])
+			i++
+		}
+		for i < len(rows) && rows[i].kind == rowAdded {
+			added = append(added, rows[i])
+			i++
+		}
+		for j := 0; j < max(len(removed), len(added)); j++ {
+			left, right := blank, blank
+			if j < len(removed) {
+				left = side(&removed[j], removed[j].oldNum)
+			}
+			if j < len(added) {
+				right = side(&added[j], added[j].newNum)
+			}
+			lines = append(lines, left+separator+right)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderCode renders a line of code after prefix, coloring its syntax and the words that
+// changed, and fills width with the background of added or removed lines
+func (r *diffRenderer) renderCode(row diffRow, prefix string, width int) string {
+	var lineBg, wordBg lipgloss.Color
+	marker := " "
+	switch row.kind {
+	case rowAdded:
+		lineBg, wordBg, marker = colorAddedLine, colorAddedWord, "+"
+	case rowRemoved:
+		lineBg, wordBg, marker = colorRemovedLine, colorRemovedWord, "-"
+	}
+	base := lipgloss.NewStyle()
+	if lineBg != "" {
+		base = base.Background(lineBg)
+	}
+
+	var b strings.Builder
+	b.WriteString(MutedTextStyle.Render(prefix))
+	b.WriteString(base.Foreground(r.markerColor(row.kind)).Render(marker))
+
+	pos := 0
+	for _, tok := range row.tokens {
+		style := r.tokenStyle(base, tok.Type)
+		value := tok.Value
+		// Split the token where the changed ranges start and end
+		for value != "" {
+			changed, next := changeAt(row.changed, pos)
+			n := len(value)
+			if next > pos && next-pos < n {
+				n = next - pos
+			}
+			piece := style
+			if changed {
+				piece = piece.Background(wordBg)
+			}
+			b.WriteString(piece.Render(value[:n]))
+			value = value[n:]
+			pos += n
+		}
+	}
+
+	line := ansi.Truncate(b.String(), width, "…")
+	if pad := width - ansi.StringWidth(line); pad > 0 {
+		line += base.Render(strings.Repeat(" ", pad))
+	}
+	return line
+}
+
+// changeAt reports whether the byte at pos lies in one of the changed ranges, and where the
+// current range ends or the next one starts (-1 if there is none)
+func changeAt(ranges [][2]int, pos int) (bool, int) {
+	for _, rg := range ranges {
+		if pos < rg[0] {
+			return false, rg[0]
+		}
+		if pos < rg[1] {
+			return true, rg[1]
+		}
+	}
+	return false, -1
+}
+
+// tokenStyle adds the color of a syntax token to base
+func (r *diffRenderer) tokenStyle(base lipgloss.Style, t chroma.TokenType) lipgloss.Style {
+	entry := r.style.Get(t)
+	if entry.Colour.IsSet() {
+		base = base.Foreground(lipgloss.Color(entry.Colour.String()))
+	} else {
+		base = base.Foreground(ColorPrimary)
+	}
+	if entry.Bold == chroma.Yes {
+		base = base.Bold(true)
+	}
+	if entry.Italic == chroma.Yes {
+		base = base.Italic(true)
+	}
+	return base
+}
+
+// markerColor returns the color of the +/- marker in front of a line
+func (r *diffRenderer) markerColor(kind diffRowKind) lipgloss.Color {
+	switch kind {
+	case rowAdded:
+		return ColorSuccess
+	case rowRemoved:
+		return ColorError
+	}
+	return ColorTertiary
+}
+
+// renderHeaderRow renders a line that isn't code: a file header, hunk header or note
+func renderHeaderRow(row diffRow) string {
+	switch row.kind {
+	case rowHunk:
+		return lipgloss.NewStyle().Foreground(ColorWarning).Bold(true).Render(row.text)
+	case rowNote:
+		return SubtleTextStyle.Render(row.text)
+	}
+	if strings.HasPrefix(row.text, "---") || strings.HasPrefix(row.text, "+++") {
+		return lipgloss.NewStyle().Foreground(ColorAccent).Bold(true).Render(row.text)
+	}
+	return SubtleTextStyle.Render(row.text)
+}
+
+// gutterWidth returns the width of the widest line number in the rows
+func gutterWidth(rows []diffRow) int {
+	widest := 0
+	for _, row := range rows {
+		widest = max(widest, row.oldNum, row.newNum)
+	}
+	return len(fmt.Sprint(widest))
+}
+
+// lineNumber renders a line number right-aligned in width, or blanks if num is 0
+func lineNumber(num, width int) string {
+	if num == 0 {
+		return strings.Repeat(" ", width)
+	}
+	return fmt.Sprintf("%*d", width, num)
+}
@@ -2,13 +2,19 @@ package tui
 
 import (
 	"carya/internal/chunk"
+	"carya/internal/daemon"
+	"carya/internal/deps"
+	"carya/internal/feature"
+	"carya/internal/restore"
 	"carya/internal/store"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -17,65 +23,296 @@ import (
 // DiffViewerModel represents the Bubble Tea model for viewing diffs
 // Uses a telescope-style split view: list on left, diff on right
 type DiffViewerModel struct {
-	help           help.Model
-	keys           KeyMap
-	chunks         []chunk.Chunk
-	cursor         int
-	listViewport   viewport.Model
-	diffViewport   viewport.Model
-	store          ChunkStore
-	width          int
-	height         int
-	ready          bool
-	err            error
-	listWidth      int
-	diffWidth      int
-}
-
-// ChunkStore interface for retrieving chunks
+	help         help.Model
+	keys         KeyMap
+	allChunks    []chunk.Chunk // All loaded chunks
+	chunks       []chunk.Chunk // Chunks shown in the list after filtering
+	cursor       int
+	listViewport viewport.Model
+	diffViewport viewport.Model
+	store        ChunkStore
+	width        int
+	height       int
+	ready        bool
+	err          error
+	listWidth    int
+	diffWidth    int
+	restorer     *restore.Restorer                // Restores files from chunk snapshots (nil disables restore)
+	status       string                           // Result of the last action, shown in the footer
+	confirm      *RestoreCheckedMsg               // Restore waiting for y to go ahead (nil if none)
+	features     []feature.Tag                    // Feature tags present in the loaded chunks
+	featureIndex int                              // Index into features of the active filter (-1 shows all chunks)
+	branches     []string                         // Git branches present in the loaded chunks
+	branchIndex  int                              // Index into branches of the active filter (-1 shows all branches)
+	onlyBranch   string                           // Branch the loaded chunks are limited to (empty loads all branches)
+	dependsOn    []deps.Edge                      // Dependencies of the selected chunk
+	requiredBy   []deps.Edge                      // Chunks depending on the selected chunk
+	daemon       *daemon.Daemon                   // Running daemon whose chunk activity is followed (nil if not running)
+	stream       *daemon.Stream                   // Events streamed by the daemon (nil until connected)
+	pending      map[string]chunk.ActiveChunkInfo // Chunks the daemon is still collecting, by file path
+	search       textinput.Model                  // Input for the search query
+	searching    bool                             // Whether keys go to the search input
+	query        string                           // Search query the list is filtered by
+	groupBy      GroupMode                        // How the list is grouped
+	next         chunk.Cursor                     // Cursor for the next page of older chunks (empty once all are loaded)
+	loadingMore  bool                             // Whether the next page is being loaded
+	fullWidth    bool                             // Whether the diff uses the full width instead of the split view
+	sideBySide   bool                             // Whether the diff shows the old and new file side by side
+}
+
+// ChunkStore interface for retrieving chunks and their dependencies
 type ChunkStore interface {
+	deps.Graph
 	GetRecentChunks(limit int) ([]chunk.Chunk, error)
 	FindChunks(filePath string) ([]chunk.Chunk, error)
+	QueryChunks(q chunk.Query) (*chunk.Page, error)
 }
 
 // NewDiffViewerModel creates a new diff viewer model
 func NewDiffViewerModel(store ChunkStore) (*DiffViewerModel, error) {
+	return NewBranchDiffViewerModel(store, "")
+}
+
+// NewBranchDiffViewerModel creates a diff viewer model showing only the chunks made on a
+// git branch; an empty branch shows the chunks of all branches
+func NewBranchDiffViewerModel(store ChunkStore, branch string) (*DiffViewerModel, error) {
 	h := help.New()
 	h.Styles.ShortDesc = HelpDescStyle
 	h.Styles.ShortKey = HelpKeyStyle
 	h.Styles.FullDesc = HelpDescStyle
 	h.Styles.FullKey = HelpKeyStyle
 
-	// Load recent chunks
-	chunks, err := store.GetRecentChunks(100)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load chunks: %w", err)
-	}
+	search := textinput.New()
+	search.Prompt = "/"
+	search.Placeholder = "search paths and diffs"
+	search.CharLimit = 256
+	search.Width = 40
 
 	m := &DiffViewerModel{
-		help:   h,
-		keys:   DefaultKeys(),
-		chunks: chunks,
-		cursor: 0,
-		store:  store,
-		width:  80,
-		height: 24,
+		help:         h,
+		keys:         DefaultKeys(),
+		cursor:       0,
+		store:        store,
+		width:        80,
+		height:       24,
+		featureIndex: -1,
+		branchIndex:  -1,
+		onlyBranch:   branch,
+		search:       search,
 	}
 
+	// Load the most recent chunks; older ones are loaded as the list is scrolled
+	page, err := m.loadChunks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chunks: %w", err)
+	}
+	m.next = page.Next
+	m.setChunks(page.Chunks)
+
 	return m, nil
 }
 
 // Init initializes the model
 func (m *DiffViewerModel) Init() tea.Cmd {
-	return nil
+	if m.daemon == nil {
+		return nil
+	}
+	return m.connectLive()
 }
 
 // LoadedChunksMsg indicates chunks have been loaded
 type LoadedChunksMsg struct {
 	Chunks []chunk.Chunk
+	Next   chunk.Cursor // Cursor for the page after Chunks
 	Error  error
 }
 
+// RestoredMsg indicates a restore from the viewer has completed
+type RestoredMsg struct {
+	Result *restore.Result
+	Error  error
+}
+
+// RestoreCheckedMsg indicates the chunks depending on a restore's undone changes have been found
+type RestoreCheckedMsg struct {
+	ID     chunk.ChunkID
+	Path   string
+	Target restore.Target
+	Broken []deps.Edge // Edges from chunks in other files that depend on the undone changes
+	Error  error
+}
+
+// restoreSelected checks which chunks depend on the changes a restore of the selected chunk
+// to the given snapshot would undo, before asking for confirmation
+func (m *DiffViewerModel) restoreSelected(target restore.Target) tea.Cmd {
+	if m.restorer == nil || m.cursor >= len(m.chunks) {
+		return nil
+	}
+	if isPending(m.chunks[m.cursor]) {
+		m.status = SubtleTextStyle.Render("Save the chunk with F before restoring from it")
+		return nil
+	}
+
+	c := m.chunks[m.cursor]
+	return func() tea.Msg {
+		broken, err := m.restorer.BrokenDependents(m.store, c.ID, target)
+		return RestoreCheckedMsg{ID: c.ID, Path: c.FilePath, Target: target, Broken: broken, Error: err}
+	}
+}
+
+// askRestore asks for confirmation of a checked restore, warning about the chunks it breaks
+func (m *DiffViewerModel) askRestore(msg RestoreCheckedMsg) {
+	m.confirm = &msg
+	prompt := fmt.Sprintf("Restore %s to its state %s the chunk?", filepath.Base(msg.Path), msg.Target)
+	if len(msg.Broken) > 0 {
+		prompt += fmt.Sprintf(" %s %d chunks in other files depend on the changes it undoes", IconWarning, len(msg.Broken))
+	}
+	m.status = WarningStyle.Render(prompt) + " " + HelpKeyStyle.Render("y") + HelpDescStyle.Render(" restore • any other key cancels")
+}
+
+// answerRestore restores the file waiting for confirmation if the key is y, and cancels otherwise
+func (m *DiffViewerModel) answerRestore(msg tea.KeyMsg) tea.Cmd {
+	c := m.confirm
+	m.confirm = nil
+	if msg.String() != "y" {
+		m.status = SubtleTextStyle.Render("Restore cancelled")
+		return nil
+	}
+
+	m.status = ""
+	return func() tea.Msg {
+		result, err := m.restorer.Restore(c.ID, c.Target)
+		return RestoredMsg{Result: result, Error: err}
+	}
+}
+
+// loadChunks loads the first page of the most recent chunks from the store, limited to onlyBranch if set
+func (m *DiffViewerModel) loadChunks() (*chunk.Page, error) {
+	return m.store.QueryChunks(chunk.Query{Branch: m.onlyBranch, Limit: viewerPageSize})
+}
+
+// reloadChunks reloads the most recent chunks from the store
+func (m *DiffViewerModel) reloadChunks() tea.Cmd {
+	return func() tea.Msg {
+		page, err := m.loadChunks()
+		if err != nil {
+			return LoadedChunksMsg{Error: err}
+		}
+		return LoadedChunksMsg{Chunks: page.Chunks, Next: page.Next}
+	}
+}
+
+// setChunks replaces the loaded chunks, refreshing the known feature tags and branches and the filtered list
+func (m *DiffViewerModel) setChunks(chunks []chunk.Chunk) {
+	var current feature.Tag
+	if m.featureIndex >= 0 && m.featureIndex < len(m.features) {
+		current = m.features[m.featureIndex]
+	}
+	var currentBranch string
+	if m.branchIndex >= 0 && m.branchIndex < len(m.branches) {
+		currentBranch = m.branches[m.branchIndex]
+	}
+
+	m.allChunks = chunks
+	m.features = nil
+	m.branches = nil
+	seen := make(map[feature.Tag]bool)
+	seenBranches := make(map[string]bool)
+	for _, c := range chunks {
+		if c.FeatureTag != "" && !seen[c.FeatureTag] {
+			seen[c.FeatureTag] = true
+			m.features = append(m.features, c.FeatureTag)
+		}
+		if c.Branch != "" && !seenBranches[c.Branch] {
+			seenBranches[c.Branch] = true
+			m.branches = append(m.branches, c.Branch)
+		}
+	}
+	sort.Slice(m.features, func(i, j int) bool { return m.features[i] < m.features[j] })
+	sort.Strings(m.branches)
+
+	// Keep the current filters if their feature and branch are still present
+	m.featureIndex = -1
+	for i, tag := range m.features {
+		if tag == current {
+			m.featureIndex = i
+		}
+	}
+	m.branchIndex = -1
+	for i, branch := range m.branches {
+		if branch == currentBranch {
+			m.branchIndex = i
+		}
+	}
+
+	m.applyFilter()
+}
+
+// cycleFeatureFilter moves to the next feature filter, wrapping back to showing all chunks
+func (m *DiffViewerModel) cycleFeatureFilter() {
+	m.featureIndex++
+	if m.featureIndex >= len(m.features) {
+		m.featureIndex = -1
+	}
+	m.cursor = 0
+	m.applyFilter()
+}
+
+// cycleBranchFilter moves to the next branch filter, wrapping back to showing all branches
+func (m *DiffViewerModel) cycleBranchFilter() {
+	m.branchIndex++
+	if m.branchIndex >= len(m.branches) {
+		m.branchIndex = -1
+	}
+	m.cursor = 0
+	m.applyFilter()
+}
+
+// applyFilter rebuilds the visible chunk list from the active feature and branch filters and
+// the search query, grouping it if asked to. Pending chunks have no feature or branch yet,
+// so they are listed first when neither filter is active.
+func (m *DiffViewerModel) applyFilter() {
+	candidates := m.allChunks
+	if m.featureIndex < 0 && m.branchIndex < 0 {
+		candidates = append(m.pendingChunks(), m.allChunks...)
+	}
+
+	m.chunks = nil
+	for _, c := range candidates {
+		if m.featureIndex >= 0 && c.FeatureTag != m.features[m.featureIndex] {
+			continue
+		}
+		if m.branchIndex >= 0 && c.Branch != m.branches[m.branchIndex] {
+			continue
+		}
+		if !m.matchesSearch(c) {
+			continue
+		}
+		m.chunks = append(m.chunks, c)
+	}
+	m.groupChunks()
+
+	if m.cursor >= len(m.chunks) {
+		m.cursor = max(0, len(m.chunks)-1)
+	}
+}
+
+// featureFilterLabel describes the active feature filter for display
+func (m *DiffViewerModel) featureFilterLabel() string {
+	if m.featureIndex < 0 {
+		return "all features"
+	}
+	return "feature: " + string(m.features[m.featureIndex])
+}
+
+// branchFilterLabel describes the active branch filter for display
+func (m *DiffViewerModel) branchFilterLabel() string {
+	if m.branchIndex < 0 {
+		return "all branches"
+	}
+	return "branch: " + m.branches[m.branchIndex]
+}
+
 // Update handles messages and updates the model
 func (m *DiffViewerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
@@ -86,31 +323,59 @@ func (m *DiffViewerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.err = msg.Error
 			return m, nil
 		}
-		m.chunks = msg.Chunks
-		return m, nil
+		m.next = msg.Next
+		m.setChunks(msg.Chunks)
+		m.updateDiffContent()
+		return m, m.loadMore()
 
-	case tea.WindowSizeMsg:
-		m.width = msg.Width
-		m.height = msg.Height
+	case MoreChunksMsg:
+		m.loadingMore = false
+		if msg.Error != nil {
+			m.status = ErrorStyle.Render(fmt.Sprintf("%s Failed to load older chunks: %v", IconError, msg.Error))
+			return m, nil
+		}
+		m.appendPage(msg.Page)
+		return m, m.loadMore()
 
-		// Split width: 40% for list, 60% for diff
-		m.listWidth = int(float64(msg.Width) * 0.4)
-		m.diffWidth = msg.Width - m.listWidth
+	case liveConnectedMsg:
+		return m, m.handleLiveConnected(msg)
 
-		headerHeight := 2
-		footerHeight := 2
-		contentHeight := msg.Height - headerHeight - footerHeight
+	case liveEventMsg:
+		return m, m.handleLiveEvent(msg)
 
-		if !m.ready {
-			m.listViewport = viewport.New(m.listWidth-2, contentHeight)
-			m.diffViewport = viewport.New(m.diffWidth-2, contentHeight)
-			m.ready = true
-		} else {
-			m.listViewport.Width = m.listWidth - 2
-			m.listViewport.Height = contentHeight
-			m.diffViewport.Width = m.diffWidth - 2
-			m.diffViewport.Height = contentHeight
+	case ForceFlushedMsg:
+		if msg.Error != nil {
+			m.status = ErrorStyle.Render(fmt.Sprintf("%s Flush failed: %v", IconError, msg.Error))
+			return m, nil
+		}
+		m.status = SuccessStyle.Render(fmt.Sprintf("%s Saved the chunk of %s", IconSuccess, filepath.Base(msg.Path)))
+		return m, nil
+
+	case RestoreCheckedMsg:
+		if msg.Error != nil {
+			m.status = ErrorStyle.Render(fmt.Sprintf("%s Failed to check dependencies: %v", IconError, msg.Error))
+			return m, nil
+		}
+		m.askRestore(msg)
+		return m, nil
+
+	case RestoredMsg:
+		if msg.Error != nil {
+			m.status = ErrorStyle.Render(fmt.Sprintf("%s Restore failed: %v", IconError, msg.Error))
+			return m, nil
+		}
+		if !msg.Result.Modified {
+			m.status = SubtleTextStyle.Render(fmt.Sprintf("%s already matches that state", filepath.Base(msg.Result.Chunk.FilePath)))
+			return m, nil
 		}
+		m.status = SuccessStyle.Render(fmt.Sprintf("%s Restored %s to its state %s the chunk",
+			IconSuccess, filepath.Base(msg.Result.Chunk.FilePath), msg.Result.Target))
+		return m, m.reloadChunks()
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.layout()
 
 		// Update diff content if chunks exist
 		if len(m.chunks) > 0 && m.cursor < len(m.chunks) {
@@ -120,21 +385,72 @@ func (m *DiffViewerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
+		if m.confirm != nil {
+			return m, m.answerRestore(msg)
+		}
+		if m.searching {
+			// Arrow keys still move through the matches while typing
+			switch msg.Type {
+			case tea.KeyCtrlC:
+				return m, tea.Quit
+			case tea.KeyUp:
+				m.moveCursor(-1)
+				return m, nil
+			case tea.KeyDown:
+				m.moveCursor(1)
+				return m, m.loadMore()
+			}
+			return m, m.updateSearch(msg)
+		}
+
 		switch {
 		case key.Matches(msg, m.keys.Quit):
 			return m, tea.Quit
 
 		case key.Matches(msg, m.keys.Up):
-			if m.cursor > 0 {
-				m.cursor--
-				m.updateDiffContent()
-			}
+			m.moveCursor(-1)
 
 		case key.Matches(msg, m.keys.Down):
-			if m.cursor < len(m.chunks)-1 {
-				m.cursor++
-				m.updateDiffContent()
-			}
+			m.moveCursor(1)
+			return m, m.loadMore()
+
+		case key.Matches(msg, m.keys.Search):
+			return m, m.startSearch()
+
+		case msg.Type == tea.KeyEsc && m.query != "":
+			return m, m.updateSearch(msg)
+
+		case key.Matches(msg, m.keys.Group):
+			m.cycleGrouping()
+
+		case key.Matches(msg, m.keys.FullWidth):
+			m.toggleFullWidth()
+
+		case key.Matches(msg, m.keys.SideBySide):
+			m.sideBySide = !m.sideBySide
+			m.updateDiffContent()
+
+		case key.Matches(msg, m.keys.FeatureFilter):
+			m.cycleFeatureFilter()
+			m.updateDiffContent()
+			return m, m.loadMore()
+
+		case key.Matches(msg, m.keys.BranchFilter):
+			m.cycleBranchFilter()
+			m.updateDiffContent()
+			return m, m.loadMore()
+
+		case key.Matches(msg, m.keys.JumpDependency):
+			m.jumpToDependency()
+
+		case key.Matches(msg, m.keys.RestoreBefore):
+			return m, m.restoreSelected(restore.Before)
+
+		case key.Matches(msg, m.keys.RestoreAfter):
+			return m, m.restoreSelected(restore.After)
+
+		case key.Matches(msg, m.keys.ForceFlush):
+			return m, m.forceFlushSelected()
 
 		// Allow scrolling the diff with Ctrl+d and Ctrl+u
 		case msg.String() == "ctrl+d":
@@ -147,6 +463,15 @@ func (m *DiffViewerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// moveCursor moves the selection up (negative) or down (positive) the list
+func (m *DiffViewerModel) moveCursor(delta int) {
+	cursor := max(0, min(m.cursor+delta, len(m.chunks)-1))
+	if cursor != m.cursor {
+		m.cursor = cursor
+		m.updateDiffContent()
+	}
+}
+
 // View renders the model
 func (m *DiffViewerModel) View() string {
 	if m.err != nil {
@@ -175,7 +500,7 @@ func (m *DiffViewerModel) View() string {
 
 // renderSplitView renders the telescope-style split view
 func (m *DiffViewerModel) renderSplitView() string {
-	if len(m.chunks) == 0 {
+	if len(m.chunks) == 0 && len(m.allChunks) == 0 && len(m.pending) == 0 {
 		title := TitleStyle.Render("📋 CHUNK VIEWER")
 		emptyMsg := SubtleTextStyle.Render("No chunks found")
 		helpMsg := TextStyle.Render("Start making changes to see them here!")
@@ -190,22 +515,65 @@ func (m *DiffViewerModel) renderSplitView() string {
 		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, content)
 	}
 
-	// Render both panels
-	listPanel := m.renderChunkListPanel()
-	diffPanel := m.renderDiffPanel()
+	// Render both panels, or only the diff in full-width mode
+	content := m.renderDiffPanel()
+	if !m.fullWidth {
+		content = lipgloss.JoinHorizontal(lipgloss.Top, m.renderChunkListPanel(), content)
+	}
+
+	counter := fmt.Sprintf("%d/%d", min(m.cursor+1, len(m.chunks)), len(m.chunks))
+	if m.next != "" {
+		// Older chunks are loaded as the list is scrolled
+		counter += "+"
+	}
+	counter = SubtleTextStyle.Render(counter)
 
-	// Join horizontally
-	content := lipgloss.JoinHorizontal(lipgloss.Top, listPanel, diffPanel)
+	if m.searching {
+		footer := lipgloss.NewStyle().
+			Padding(0, 1).
+			Render(m.search.View() + "  " + counter + "  " + HelpDescStyle.Render("enter keep • esc clear"))
+		return lipgloss.JoinVertical(lipgloss.Left, content, footer)
+	}
 
 	// Add footer with better formatting
 	navHelp := HelpKeyStyle.Render("↑/↓") + HelpDescStyle.Render(" navigate")
 	scrollHelp := HelpKeyStyle.Render("ctrl+d/u") + HelpDescStyle.Render(" scroll")
+	filterHelp := HelpKeyStyle.Render("f") + HelpDescStyle.Render(" "+m.featureFilterLabel())
+	branchHelp := HelpKeyStyle.Render("b") + HelpDescStyle.Render(" "+m.branchFilterLabel())
+	depsHelp := HelpKeyStyle.Render("d") + HelpDescStyle.Render(" go to dependency")
+	restoreHelp := HelpKeyStyle.Render("r/R") + HelpDescStyle.Render(" restore before/after")
 	quitHelp := HelpKeyStyle.Render("q") + HelpDescStyle.Render(" quit")
-	counter := SubtleTextStyle.Render(fmt.Sprintf("%d/%d", m.cursor+1, len(m.chunks)))
+	searchHelp := HelpKeyStyle.Render("/") + HelpDescStyle.Render(" search")
+	if m.query != "" {
+		searchHelp = HelpKeyStyle.Render("/") + HelpDescStyle.Render(fmt.Sprintf(" %q", m.query)) +
+			" " + HelpKeyStyle.Render("esc") + HelpDescStyle.Render(" clear")
+	}
+	groupHelp := HelpKeyStyle.Render("g") + HelpDescStyle.Render(" group: "+m.groupBy.String())
+	widthHelp := HelpKeyStyle.Render("z") + HelpDescStyle.Render(" full width")
+	if m.fullWidth {
+		widthHelp = HelpKeyStyle.Render("z") + HelpDescStyle.Render(" split view")
+	}
+	sideHelp := HelpKeyStyle.Render("s") + HelpDescStyle.Render(" side by side")
+	if m.sideBySide {
+		sideHelp = HelpKeyStyle.Render("s") + HelpDescStyle.Render(" unified")
+	}
+
+	footerText := navHelp + " • " + scrollHelp + " • " + searchHelp + " • " + filterHelp + " • " + branchHelp + " • " + groupHelp + " • " + widthHelp + " • " + sideHelp +
+		" • " + depsHelp + " • " + restoreHelp
+	if m.stream != nil {
+		footerText += " • " + HelpKeyStyle.Render("F") + HelpDescStyle.Render(" flush file")
+	}
+	footerText += " • " + quitHelp + " • " + counter
+	if m.stream != nil {
+		footerText += " " + WarningStyle.Render(IconPending+" live")
+	}
+	if m.status != "" {
+		footerText += "  " + m.status
+	}
 
 	footer := lipgloss.NewStyle().
 		Padding(0, 1).
-		Render(navHelp + " • " + scrollHelp + " • " + quitHelp + " • " + counter)
+		Render(footerText)
 
 	return lipgloss.JoinVertical(lipgloss.Left, content, footer)
 }
@@ -215,7 +583,20 @@ func (m *DiffViewerModel) renderChunkListPanel() string {
 	title := HeaderStyle.Padding(1, 2).Render("📋 CHUNKS")
 
 	var items []string
+	cursorLine := m.cursor
+	counts := m.groupCounts()
+	group := ""
 	for i, c := range m.chunks {
+		if m.groupBy != GroupNone {
+			if label := m.groupLabel(c); i == 0 || label != group {
+				group = label
+				items = append(items, renderGroupHeader(label, counts[label], m.listViewport.Width))
+			}
+		}
+		if i == m.cursor {
+			cursorLine = len(items)
+		}
+
 		cursor := "  "
 		if m.cursor == i {
 			cursor = "❯ "
@@ -231,9 +612,23 @@ func (m *DiffViewerModel) renderChunkListPanel() string {
 		timeStr := SubtleTextStyle.Render(c.StartTime.Format("15:04"))
 
 		line := cursor + filename + " " + timeStr
+		if isPending(c) {
+			line += " " + pendingStyle.Render(IconPending+" pending")
+		}
+		if c.Op != "" && c.Op != chunk.OpModify {
+			line += " " + MutedTextStyle.Render("("+c.Op.String()+")")
+		}
+		if c.FeatureTag != "" {
+			line += " " + MutedTextStyle.Render("["+string(c.FeatureTag)+"]")
+		}
+		if c.Committed != "" {
+			line += " " + SuccessStyle.Render(IconSuccess)
+		}
 
 		if m.cursor == i {
 			line = SelectedItemStyle.Render(line)
+		} else if isPending(c) {
+			line = ItemStyle.Inherit(pendingStyle).Render(line)
 		} else {
 			line = ItemStyle.Render(line)
 		}
@@ -242,11 +637,13 @@ func (m *DiffViewerModel) renderChunkListPanel() string {
 
 	m.listViewport.SetContent(strings.Join(items, "\n"))
 
-	// Ensure selected item is visible
-	if m.cursor < m.listViewport.YOffset {
-		m.listViewport.YOffset = m.cursor
-	} else if m.cursor >= m.listViewport.YOffset+m.listViewport.Height {
-		m.listViewport.YOffset = m.cursor - m.listViewport.Height + 1
+	// Ensure selected item is visible, along with the header of its group
+	if m.cursor == 0 {
+		m.listViewport.YOffset = 0
+	} else if cursorLine < m.listViewport.YOffset {
+		m.listViewport.YOffset = cursorLine
+	} else if cursorLine >= m.listViewport.YOffset+m.listViewport.Height {
+		m.listViewport.YOffset = cursorLine - m.listViewport.Height + 1
 	}
 
 	listStyle := lipgloss.NewStyle().
@@ -261,8 +658,16 @@ func (m *DiffViewerModel) renderChunkListPanel() string {
 
 // renderDiffPanel renders the right panel with diff content
 func (m *DiffViewerModel) renderDiffPanel() string {
+	diffStyle := lipgloss.NewStyle().
+		Width(m.diffWidth).
+		Height(m.height).
+		BorderStyle(lipgloss.ThickBorder()).
+		BorderForeground(ColorTitle).
+		Padding(0, 1)
+
 	if m.cursor >= len(m.chunks) {
-		return ""
+		empty := SubtleTextStyle.Padding(1, 2).Render("No chunks match the filters")
+		return diffStyle.Render(empty)
 	}
 
 	c := m.chunks[m.cursor]
@@ -275,20 +680,56 @@ func (m *DiffViewerModel) renderDiffPanel() string {
 		c.StartTime.Format("15:04:05"),
 		c.EndTime.Format("15:04:05")))
 
+	headerText := fileLabel + " " + filePath + "  " + timeLabel + " " + timeRange
+	if isPending(c) {
+		headerText += "  " + pendingStyle.Render(IconPending+" pending")
+	}
+	switch c.Op {
+	case chunk.OpCreate, chunk.OpDelete:
+		headerText += "  " + SubtleTextStyle.Render("Op:") + " " + TextStyle.Render(c.Op.String())
+	case chunk.OpRename:
+		headerText += "\n" + SubtleTextStyle.Render("Renamed from:") + " " + TextStyle.Render(c.OldPath)
+	}
+	if c.FeatureTag != "" {
+		headerText += "  " + SubtleTextStyle.Render("Feature:") + " " + TextStyle.Render(string(c.FeatureTag))
+	}
+	if c.Branch != "" || c.Head != "" {
+		headerText += "\n" + SubtleTextStyle.Render("Branch:") + " " + TextStyle.Render(describeGitContext(c))
+	}
+	if c.Committed != "" {
+		headerText += "  " + SubtleTextStyle.Render("Committed in:") + " " + SuccessStyle.Render(shortCommit(c.Committed))
+	}
+	if len(m.dependsOn) > 0 || len(m.requiredBy) > 0 {
+		headerText += "\n" + SubtleTextStyle.Render("Depends on:") + " " + m.formatEdges(m.dependsOn, func(e deps.Edge) chunk.ChunkID { return e.DependsOn }) +
+			"  " + SubtleTextStyle.Render("Required by:") + " " + m.formatEdges(m.requiredBy, func(e deps.Edge) chunk.ChunkID { return e.Chunk })
+	}
+
 	header := lipgloss.NewStyle().
 		Padding(1, 2).
-		Render(fileLabel + " " + filePath + "  " + timeLabel + " " + timeRange)
-
-	diffStyle := lipgloss.NewStyle().
-		Width(m.diffWidth).
-		Height(m.height).
-		BorderStyle(lipgloss.ThickBorder()).
-		BorderForeground(ColorTitle).
-		Padding(0, 1)
+		Render(headerText)
 
 	return diffStyle.Render(lipgloss.JoinVertical(lipgloss.Left, header, m.diffViewport.View()))
 }
 
+// describeGitContext names the branch and commit a chunk was made on
+func describeGitContext(c chunk.Chunk) string {
+	switch {
+	case c.Branch == "":
+		return "detached at " + shortCommit(c.Head)
+	case c.Head == "":
+		return c.Branch + " (no commits yet)"
+	}
+	return c.Branch + " at " + shortCommit(c.Head)
+}
+
+// shortCommit abbreviates a commit hash for display
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
 // updateDiffContent updates the diff viewport with the current chunk's diff
 func (m *DiffViewerModel) updateDiffContent() {
 	if m.cursor >= len(m.chunks) || !m.ready {
@@ -296,61 +737,104 @@ func (m *DiffViewerModel) updateDiffContent() {
 	}
 
 	c := m.chunks[m.cursor]
-	diffContent := m.formatDiff(c.Diff)
+	if isPending(c) {
+		m.dependsOn, m.requiredBy = nil, nil
+		m.diffViewport.SetContent(pendingDetails(c))
+		m.diffViewport.GotoTop()
+		return
+	}
+	m.loadDependencies(c.ID)
+	diffContent := m.formatDiff(c)
 	m.diffViewport.SetContent(diffContent)
 	m.diffViewport.GotoTop()
 }
 
-// formatDiff applies syntax highlighting to diff content
-func (m *DiffViewerModel) formatDiff(diff string) string {
-	lines := strings.Split(diff, "\n")
-	var formatted []string
+// loadDependencies loads the dependency edges of the selected chunk
+func (m *DiffViewerModel) loadDependencies(id chunk.ChunkID) {
+	var err error
+	if m.dependsOn, err = m.store.FindDependencies(id); err != nil {
+		m.status = ErrorStyle.Render(fmt.Sprintf("%s Failed to load dependencies: %v", IconError, err))
+	}
+	if m.requiredBy, err = m.store.FindDependents(id); err != nil {
+		m.status = ErrorStyle.Render(fmt.Sprintf("%s Failed to load dependencies: %v", IconError, err))
+	}
+}
+
+// formatEdges renders the chunks at the other end of the edges as a short list
+func (m *DiffViewerModel) formatEdges(edges []deps.Edge, other func(deps.Edge) chunk.ChunkID) string {
+	if len(edges) == 0 {
+		return MutedTextStyle.Render("none")
+	}
 
-	// Style definitions for diff lines - using our new color palette
-	addedStyle := lipgloss.NewStyle().Foreground(ColorSuccess).Bold(false)
-	removedStyle := lipgloss.NewStyle().Foreground(ColorError).Bold(false)
-	contextStyle := lipgloss.NewStyle().Foreground(ColorTertiary)
-	headerStyle := lipgloss.NewStyle().Foreground(ColorAccent).Bold(true)
-	rangeStyle := lipgloss.NewStyle().Foreground(ColorWarning).Bold(true)
+	var labels []string
+	for _, e := range edges {
+		labels = append(labels, TextStyle.Render(m.chunkLabel(other(e)))+MutedTextStyle.Render(" ("+string(e.Kind)+")"))
+	}
+	return strings.Join(labels, ", ")
+}
 
-	for _, line := range lines {
-		switch {
-		case strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---"):
-			// File headers in diff
-			formatted = append(formatted, headerStyle.Render(line))
-		case strings.HasPrefix(line, "+"):
-			formatted = append(formatted, addedStyle.Render(line))
-		case strings.HasPrefix(line, "-"):
-			formatted = append(formatted, removedStyle.Render(line))
-		case strings.HasPrefix(line, "@@"):
-			formatted = append(formatted, rangeStyle.Render(line))
-		case strings.HasPrefix(line, "diff --git") || strings.HasPrefix(line, "index"):
-			formatted = append(formatted, SubtleTextStyle.Render(line))
-		case strings.HasPrefix(line, "File:") || strings.HasPrefix(line, "Time:") || strings.HasPrefix(line, "Hash:"):
-			formatted = append(formatted, contextStyle.Render(line))
-		default:
-			formatted = append(formatted, TextStyle.Render(line))
+// chunkLabel returns a short name for a chunk: its file name and start time when loaded, else its ID
+func (m *DiffViewerModel) chunkLabel(id chunk.ChunkID) string {
+	for _, c := range m.allChunks {
+		if c.ID == id {
+			return filepath.Base(c.FilePath) + " " + c.StartTime.Format("15:04")
 		}
 	}
+	return string(id)
+}
+
+// jumpToDependency moves the cursor to the first chunk the selected chunk depends on
+func (m *DiffViewerModel) jumpToDependency() {
+	if len(m.dependsOn) == 0 {
+		m.status = SubtleTextStyle.Render("Chunk has no dependencies")
+		return
+	}
 
-	return strings.Join(formatted, "\n")
+	target := m.dependsOn[0].DependsOn
+	for i, c := range m.chunks {
+		if c.ID == target {
+			m.cursor = i
+			m.status = ""
+			m.updateDiffContent()
+			return
+		}
+	}
+	m.status = SubtleTextStyle.Render(fmt.Sprintf("%s is not in the list", m.chunkLabel(target)))
 }
 
-// RunDiffViewer runs the diff viewer TUI
-func RunDiffViewer(dataSourceName string) error {
-	store, err := store.NewSQLiteStore(dataSourceName)
-	if err != nil {
-		return fmt.Errorf("failed to open store: %w", err)
+// pendingStyle marks chunks the daemon is still collecting
+var pendingStyle = lipgloss.NewStyle().Foreground(ColorWarning).Italic(true)
+
+// formatDiff renders the diff of a chunk with syntax highlighting for its language and the
+// words that changed within lines marked, as one column or side by side
+func (m *DiffViewerModel) formatDiff(c chunk.Chunk) string {
+	r := newDiffRenderer(c.FilePath, max(m.diffViewport.Width-2, 0))
+	if m.sideBySide {
+		return r.renderSideBySide(c.Diff)
 	}
-	defer store.Close()
+	return r.renderUnified(c.Diff)
+}
 
-	model, err := NewDiffViewerModel(store)
+// RunDiffViewer runs the diff viewer TUI on the chunks in store, limited to those made on
+// branch unless it is empty. root is the git work tree the chunks belong to, empty if
+// unknown. If d is not nil, the list follows the chunks the running daemon saves and shows
+// the ones it is still collecting.
+func RunDiffViewer(store store.Store, root, branch string, d *daemon.Daemon) error {
+	model, err := NewBranchDiffViewerModel(store, branch)
 	if err != nil {
 		return err
 	}
+	model.restorer = restore.New(store)
+	model.restorer.SetLinker(deps.NewTracker(store))
+	model.restorer.SetRoot(root)
+	model.daemon = d
 
 	p := tea.NewProgram(model, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	_, err = p.Run()
+	if model.stream != nil {
+		model.stream.Close()
+	}
+	if err != nil {
 		return fmt.Errorf("error running diff viewer: %w", err)
 	}
 
@@ -4,9 +4,11 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"carya/internal/housekeeping"
+	"carya/internal/repository"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
@@ -78,7 +80,11 @@ func NewHousekeepingModel() HousekeepingModel {
 	h.Styles.FullDesc = HelpDescStyle
 	h.Styles.FullKey = HelpKeyStyle
 
-	detector := housekeeping.NewDetector(".")
+	root := "."
+	if repo, err := repository.New(); err == nil {
+		root = repo.RootPath()
+	}
+	detector := housekeeping.NewDetector(root)
 
 	// Initialize text inputs for manual command entry
 	commandInput := textinput.New()
@@ -120,14 +126,18 @@ func (m HousekeepingModel) Init() tea.Cmd {
 
 // ensureCaryaDirectory creates .carya directory and adds it to .gitignore if needed
 func ensureCaryaDirectory() error {
+	repo, err := repository.New()
+	if err != nil {
+		return err
+	}
+
 	// Create .carya directory
-	caryaDir := ".carya"
-	if err := os.MkdirAll(caryaDir, 0755); err != nil {
-		return fmt.Errorf("failed to create .carya directory: %w", err)
+	if err := repo.EnsureExists(); err != nil {
+		return err
 	}
 
 	// Ensure .carya/ is in .gitignore
-	gitignorePath := ".gitignore"
+	gitignorePath := filepath.Join(repo.RootPath(), ".gitignore")
 	caryaEntry := ".carya/"
 
 	// Check if .gitignore exists and if .carya/ is already in it
@@ -14,6 +14,28 @@ type KeyMap struct {
 	Select key.Binding
 	Quit   key.Binding
 	Help   key.Binding
+
+	// Chunk viewer actions
+	RestoreBefore  key.Binding
+	RestoreAfter   key.Binding
+	FeatureFilter  key.Binding
+	BranchFilter   key.Binding
+	JumpDependency key.Binding
+	ForceFlush     key.Binding
+	Search         key.Binding
+	Group          key.Binding
+	FullWidth      key.Binding
+	SideBySide     key.Binding
+
+	// Staging actions
+	NewGroup    key.Binding
+	MoveMarked  key.Binding
+	MoveUp      key.Binding
+	MoveDown    key.Binding
+	Split       key.Binding
+	Unstage     key.Binding
+	EditMessage key.Binding
+	Commit      key.Binding
 }
 
 // ShortHelp returns keybindings to be shown in the mini help view
@@ -65,5 +87,77 @@ func DefaultKeys() KeyMap {
 			key.WithKeys("q", "ctrl+c"),
 			key.WithHelp("q", "quit"),
 		),
+		RestoreBefore: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "restore before chunk"),
+		),
+		RestoreAfter: key.NewBinding(
+			key.WithKeys("R"),
+			key.WithHelp("R", "restore after chunk"),
+		),
+		FeatureFilter: key.NewBinding(
+			key.WithKeys("f"),
+			key.WithHelp("f", "cycle feature filter"),
+		),
+		BranchFilter: key.NewBinding(
+			key.WithKeys("b"),
+			key.WithHelp("b", "cycle branch filter"),
+		),
+		JumpDependency: key.NewBinding(
+			key.WithKeys("d"),
+			key.WithHelp("d", "go to dependency"),
+		),
+		ForceFlush: key.NewBinding(
+			key.WithKeys("F"),
+			key.WithHelp("F", "flush selected file"),
+		),
+		Search: key.NewBinding(
+			key.WithKeys("/"),
+			key.WithHelp("/", "search"),
+		),
+		Group: key.NewBinding(
+			key.WithKeys("g"),
+			key.WithHelp("g", "cycle grouping"),
+		),
+		FullWidth: key.NewBinding(
+			key.WithKeys("z"),
+			key.WithHelp("z", "toggle full-width diff"),
+		),
+		SideBySide: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "toggle side-by-side diff"),
+		),
+		NewGroup: key.NewBinding(
+			key.WithKeys("n"),
+			key.WithHelp("n", "new commit from marked"),
+		),
+		MoveMarked: key.NewBinding(
+			key.WithKeys("m"),
+			key.WithHelp("m", "move marked here"),
+		),
+		MoveUp: key.NewBinding(
+			key.WithKeys("K"),
+			key.WithHelp("K", "move up"),
+		),
+		MoveDown: key.NewBinding(
+			key.WithKeys("J"),
+			key.WithHelp("J", "move down"),
+		),
+		Split: key.NewBinding(
+			key.WithKeys("S"),
+			key.WithHelp("S", "split into hunks"),
+		),
+		Unstage: key.NewBinding(
+			key.WithKeys("u"),
+			key.WithHelp("u", "unstage"),
+		),
+		EditMessage: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "edit commit message"),
+		),
+		Commit: key.NewBinding(
+			key.WithKeys("c"),
+			key.WithHelp("c", "create commits"),
+		),
 	}
 }
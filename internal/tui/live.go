@@ -0,0 +1,229 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"carya/internal/chunk"
+	"carya/internal/daemon"
+	"carya/internal/engine"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// liveConnectedMsg reports the result of connecting to the daemon's event stream
+type liveConnectedMsg struct {
+	stream *daemon.Stream
+	active []daemon.ActiveChunk
+	err    error
+}
+
+// liveEventMsg carries an event streamed by the daemon
+type liveEventMsg struct {
+	event engine.Event
+	err   error
+}
+
+// ForceFlushedMsg indicates a force flush requested from the viewer has completed
+type ForceFlushedMsg struct {
+	Path  string
+	Error error
+}
+
+// connectLive subscribes to the daemon's chunk events and then loads the chunks it is
+// still collecting, so no change falls between the two
+func (m *DiffViewerModel) connectLive() tea.Cmd {
+	d := m.daemon
+	return func() tea.Msg {
+		stream, err := d.Subscribe(daemon.Request{Command: daemon.CommandEvents})
+		if err != nil {
+			return liveConnectedMsg{err: err}
+		}
+		var active []daemon.ActiveChunk
+		if err := d.Call(daemon.CommandActive, &active); err != nil {
+			stream.Close()
+			return liveConnectedMsg{err: err}
+		}
+		return liveConnectedMsg{stream: stream, active: active}
+	}
+}
+
+// waitForEvent waits for the next event on the daemon's stream
+func waitForEvent(stream *daemon.Stream) tea.Cmd {
+	return func() tea.Msg {
+		var event engine.Event
+		err := stream.Next(&event)
+		return liveEventMsg{event: event, err: err}
+	}
+}
+
+// forceFlushSelected asks the daemon to save the chunk of the selected file right away
+func (m *DiffViewerModel) forceFlushSelected() tea.Cmd {
+	if m.cursor >= len(m.chunks) {
+		return nil
+	}
+	if m.stream == nil {
+		m.status = SubtleTextStyle.Render("Not connected to the daemon; run 'carya start' to flush from here")
+		return nil
+	}
+
+	d := m.daemon
+	path := m.chunks[m.cursor].FilePath
+	return func() tea.Msg {
+		err := d.Send(daemon.Request{Command: daemon.CommandFlushFile, Path: path}, nil)
+		return ForceFlushedMsg{Path: path, Error: err}
+	}
+}
+
+// handleLiveConnected starts following the daemon once the stream is open
+func (m *DiffViewerModel) handleLiveConnected(msg liveConnectedMsg) tea.Cmd {
+	if msg.err != nil {
+		m.status = ErrorStyle.Render(fmt.Sprintf("%s Not following the daemon: %v", IconError, msg.err))
+		return nil
+	}
+
+	m.stream = msg.stream
+	m.pending = make(map[string]chunk.ActiveChunkInfo)
+	for _, a := range msg.active {
+		m.pending[a.FilePath] = chunk.ActiveChunkInfo{
+			FilePath:   a.FilePath,
+			Op:         chunk.Op(a.Op),
+			StartTime:  a.StartTime,
+			LastUpdate: a.LastUpdate,
+		}
+	}
+	m.refreshList()
+	return waitForEvent(m.stream)
+}
+
+// handleLiveEvent applies an event streamed by the daemon to the list and waits for the next one
+func (m *DiffViewerModel) handleLiveEvent(msg liveEventMsg) tea.Cmd {
+	if msg.err != nil {
+		m.stream.Close()
+		m.stream = nil
+		m.pending = nil
+		m.refreshList()
+		m.status = SubtleTextStyle.Render("Lost the connection to the daemon; the list no longer updates")
+		return nil
+	}
+
+	event := msg.event
+	switch event.Type {
+	case engine.EventChunkStarted, engine.EventChunkUpdated:
+		m.pending[event.Active.FilePath] = *event.Active
+		m.refreshList()
+
+	case engine.EventChunkFlushed, engine.EventManualFlush:
+		var saved []chunk.Chunk
+		for _, c := range event.Chunks {
+			delete(m.pending, c.FilePath)
+			delete(m.pending, c.OldPath)
+			if m.onlyBranch == "" || c.Branch == m.onlyBranch {
+				saved = append(saved, c)
+			}
+		}
+		m.addChunks(saved)
+
+	case engine.EventWatcherError:
+		m.status = ErrorStyle.Render(fmt.Sprintf("%s Watcher error: %s", IconError, event.Error))
+	}
+
+	return waitForEvent(m.stream)
+}
+
+// addChunks puts newly saved chunks at the top of the list, keeping the selection in place
+func (m *DiffViewerModel) addChunks(saved []chunk.Chunk) {
+	known := make(map[chunk.ChunkID]bool)
+	for _, c := range m.allChunks {
+		known[c.ID] = true
+	}
+
+	var added []chunk.Chunk
+	for _, c := range saved {
+		if !known[c.ID] {
+			added = append(added, c)
+		}
+	}
+	sort.SliceStable(added, func(i, j int) bool { return added[i].StartTime.After(added[j].StartTime) })
+
+	selected := m.selectedKey()
+	m.setChunks(append(added, m.allChunks...))
+	m.selectKey(selected)
+}
+
+// refreshList rebuilds the visible list after the pending chunks changed, keeping the selection in place
+func (m *DiffViewerModel) refreshList() {
+	selected := m.selectedKey()
+	m.applyFilter()
+	m.selectKey(selected)
+}
+
+// selectedKey identifies the selected list entry across list rebuilds: by ID for saved
+// chunks and by file for pending ones
+func (m *DiffViewerModel) selectedKey() string {
+	if m.cursor >= len(m.chunks) {
+		return ""
+	}
+	c := m.chunks[m.cursor]
+	if isPending(c) {
+		return "pending:" + c.FilePath
+	}
+	return string(c.ID)
+}
+
+// selectKey moves the cursor back to the entry identified by selectedKey. A pending chunk
+// that has since been saved is followed to the newest chunk of its file.
+func (m *DiffViewerModel) selectKey(selected string) {
+	path, wasPending := strings.CutPrefix(selected, "pending:")
+	target := -1
+	for i, c := range m.chunks {
+		key := string(c.ID)
+		if isPending(c) {
+			key = "pending:" + c.FilePath
+		}
+		if key == selected {
+			if i != m.cursor {
+				m.cursor = i
+				m.updateDiffContent()
+			}
+			return
+		}
+		if wasPending && target < 0 && !isPending(c) && c.FilePath == path {
+			target = i
+		}
+	}
+	if target >= 0 {
+		m.cursor = target
+	}
+	m.updateDiffContent()
+}
+
+// pendingChunks lists the chunks the daemon is still collecting, most recently changed first
+func (m *DiffViewerModel) pendingChunks() []chunk.Chunk {
+	chunks := make([]chunk.Chunk, 0, len(m.pending))
+	for _, info := range m.pending {
+		chunks = append(chunks, chunk.Chunk{
+			FilePath:  info.FilePath,
+			Op:        info.Op,
+			StartTime: info.StartTime,
+			EndTime:   info.LastUpdate,
+		})
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].EndTime.After(chunks[j].EndTime) })
+	return chunks
+}
+
+// isPending reports whether a list entry is a chunk the daemon is still collecting; those
+// have no ID until they are saved
+func isPending(c chunk.Chunk) bool {
+	return c.ID == ""
+}
+
+// pendingDetails describes a pending chunk in the diff panel, which has no diff until it is saved
+func pendingDetails(c chunk.Chunk) string {
+	return SubtleTextStyle.Render(fmt.Sprintf("%s is still collecting changes; its diff is recorded once the chunk is saved.",
+		filepath.Base(c.FilePath))) + "\n\n" +
+		HelpKeyStyle.Render("F") + HelpDescStyle.Render(" save it now")
+}
@@ -0,0 +1,1003 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"carya/internal/chunk"
+	"carya/internal/staging"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// StagingStore interface for loading the chunks to stage and recording the commits made from them
+type StagingStore interface {
+	QueryChunks(q chunk.Query) (*chunk.Page, error)
+	MarkCommitted(ids []chunk.ChunkID, commit string) error
+}
+
+// stageItem is a chunk, or some of its hunks, placed in a staging group
+type stageItem struct {
+	chunk       chunk.Chunk
+	hunks       []int        // Indexes into the chunk's hunks covered by the item, in diff order
+	marked      bool         // Whether the whole item is marked
+	markedHunks map[int]bool // Hunks marked individually, by index into the chunk's hunks
+}
+
+// stageGroup is a commit to create from the items in it, or the items left out of every commit
+type stageGroup struct {
+	message  string       // Commit message
+	items    []*stageItem // Items in the order they are listed
+	unstaged bool         // Whether the group holds the items left out of every commit
+}
+
+// stageRow is a line of the staging list: the header of a group or one of its items
+type stageRow struct {
+	group int // Index into groups
+	item  int // Index into the group's items (-1 for the header)
+}
+
+// StagingModel represents the Bubble Tea model for curating chunks into commits
+// Uses the same split view as the chunk viewer: groups on the left, the selected item's hunks on the right
+type StagingModel struct {
+	keys         KeyMap
+	store        StagingStore
+	dir          string                         // Work tree the commits are created in
+	hunks        map[chunk.ChunkID][]chunk.Hunk // Hunks of the diff of each chunk
+	groups       []*stageGroup                  // Commits in the order they are created, then the unstaged items
+	rows         []stageRow                     // Lines of the list
+	cursor       int                            // Index into rows
+	hunkMode     bool                           // Whether keys move through and mark the hunks of the selected item
+	hunkCursor   int                            // Index into the selected item's hunks
+	message      textinput.Model                // Input for commit messages
+	editing      bool                           // Whether keys go to the message input
+	committing   bool                           // Whether the commits are being created
+	listViewport viewport.Model
+	diffViewport viewport.Model
+	width        int
+	height       int
+	listWidth    int
+	diffWidth    int
+	ready        bool
+	status       string // Result of the last action, shown in the footer
+}
+
+// CommittedMsg indicates the commits requested from the staging view have been created
+type CommittedMsg struct {
+	Hashes []string // Commits created, in order; fewer than requested if one failed
+	Error  error
+}
+
+// NewStagingModel creates a staging model holding the uncommitted chunks matching q whose
+// files are in the work tree at dir, all of them unstaged and oldest first
+func NewStagingModel(store StagingStore, dir string, q chunk.Query) (*StagingModel, error) {
+	message := textinput.New()
+	message.Prompt = "Message: "
+	message.Placeholder = "describe the commit"
+	message.CharLimit = 512
+	message.Width = 60
+
+	m := &StagingModel{
+		keys:    DefaultKeys(),
+		store:   store,
+		dir:     dir,
+		hunks:   make(map[chunk.ChunkID][]chunk.Hunk),
+		message: message,
+		width:   80,
+		height:  24,
+	}
+
+	var chunks []chunk.Chunk
+	for {
+		page, err := store.QueryChunks(q)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load chunks: %w", err)
+		}
+		chunks = append(chunks, page.Chunks...)
+		if page.Next == "" {
+			break
+		}
+		q.After = page.Next
+	}
+	sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].StartTime.Before(chunks[j].StartTime) })
+
+	unstaged := &stageGroup{unstaged: true}
+	for _, c := range chunks {
+		if c.Committed != "" {
+			continue
+		}
+		if _, err := staging.RelPath(dir, c.FilePath); err != nil {
+			continue
+		}
+		hunks, err := chunk.ParseHunks(c.Diff)
+		if err != nil || (len(hunks) == 0 && c.Op != chunk.OpDelete && c.Op != chunk.OpRename) {
+			// Nothing that could be staged
+			continue
+		}
+		m.hunks[c.ID] = hunks
+		item := &stageItem{chunk: c}
+		for i := range hunks {
+			item.hunks = append(item.hunks, i)
+		}
+		unstaged.items = append(unstaged.items, item)
+	}
+	m.groups = []*stageGroup{unstaged}
+	m.rebuildRows()
+
+	return m, nil
+}
+
+// Init initializes the model
+func (m *StagingModel) Init() tea.Cmd {
+	return nil
+}
+
+// rebuildRows lists the header and items of every group, keeping the cursor in range
+func (m *StagingModel) rebuildRows() {
+	m.rows = nil
+	for g, group := range m.groups {
+		m.rows = append(m.rows, stageRow{group: g, item: -1})
+		for i := range group.items {
+			m.rows = append(m.rows, stageRow{group: g, item: i})
+		}
+	}
+	m.cursor = max(0, min(m.cursor, len(m.rows)-1))
+}
+
+// selected returns the group and item under the cursor; the item is nil on a group header
+func (m *StagingModel) selected() (*stageGroup, *stageItem) {
+	if m.cursor >= len(m.rows) {
+		return nil, nil
+	}
+	row := m.rows[m.cursor]
+	group := m.groups[row.group]
+	if row.item < 0 {
+		return group, nil
+	}
+	return group, group.items[row.item]
+}
+
+// unstaged returns the group holding the items left out of every commit, which is always listed last
+func (m *StagingModel) unstaged() *stageGroup {
+	return m.groups[len(m.groups)-1]
+}
+
+// selectGroup moves the cursor to the header of a group
+func (m *StagingModel) selectGroup(target *stageGroup) {
+	for i, row := range m.rows {
+		if m.groups[row.group] == target && row.item < 0 {
+			m.cursor = i
+			return
+		}
+	}
+}
+
+// selectItem moves the cursor to an item
+func (m *StagingModel) selectItem(target *stageItem) {
+	for i, row := range m.rows {
+		if row.item >= 0 && m.groups[row.group].items[row.item] == target {
+			m.cursor = i
+			return
+		}
+	}
+}
+
+// Update handles messages and updates the model
+func (m *StagingModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.layout()
+		m.updateDiffContent()
+		return m, nil
+
+	case CommittedMsg:
+		m.committing = false
+		m.handleCommitted(msg)
+		m.updateDiffContent()
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+		if m.editing {
+			return m, m.updateMessage(msg)
+		}
+		if m.committing {
+			return m, nil
+		}
+		if m.hunkMode {
+			m.updateHunkMode(msg)
+			m.updateDiffContent()
+			return m, nil
+		}
+
+		var cmd tea.Cmd
+		switch {
+		case key.Matches(msg, m.keys.Quit):
+			return m, tea.Quit
+
+		case key.Matches(msg, m.keys.Up):
+			m.cursor = max(0, m.cursor-1)
+
+		case key.Matches(msg, m.keys.Down):
+			m.cursor = min(m.cursor+1, len(m.rows)-1)
+
+		case key.Matches(msg, m.keys.Select):
+			m.toggleMark()
+
+		case key.Matches(msg, m.keys.Enter):
+			if _, item := m.selected(); item != nil && len(item.hunks) > 0 {
+				m.hunkMode = true
+				m.hunkCursor = 0
+			}
+
+		case key.Matches(msg, m.keys.NewGroup):
+			cmd = m.newGroup()
+
+		case key.Matches(msg, m.keys.MoveMarked):
+			m.moveMarkedHere()
+
+		case key.Matches(msg, m.keys.MoveUp):
+			m.move(-1)
+
+		case key.Matches(msg, m.keys.MoveDown):
+			m.move(1)
+
+		case key.Matches(msg, m.keys.Split):
+			m.split()
+
+		case key.Matches(msg, m.keys.Unstage):
+			m.unstage()
+
+		case key.Matches(msg, m.keys.EditMessage):
+			cmd = m.editMessage()
+
+		case key.Matches(msg, m.keys.Commit):
+			cmd = m.commit()
+
+		// Allow scrolling the diff with Ctrl+d and Ctrl+u
+		case msg.String() == "ctrl+d":
+			m.diffViewport.ViewDown()
+			return m, nil
+		case msg.String() == "ctrl+u":
+			m.diffViewport.ViewUp()
+			return m, nil
+		}
+		m.updateDiffContent()
+		return m, cmd
+	}
+
+	return m, nil
+}
+
+// updateHunkMode moves through and marks the hunks of the selected item; Enter or Esc returns to the list
+func (m *StagingModel) updateHunkMode(msg tea.KeyMsg) {
+	_, item := m.selected()
+	if item == nil {
+		m.hunkMode = false
+		return
+	}
+
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		m.hunkCursor = max(0, m.hunkCursor-1)
+	case key.Matches(msg, m.keys.Down):
+		m.hunkCursor = min(m.hunkCursor+1, len(item.hunks)-1)
+	case key.Matches(msg, m.keys.Select):
+		if item.markedHunks == nil {
+			item.markedHunks = make(map[int]bool)
+		}
+		h := item.hunks[m.hunkCursor]
+		item.markedHunks[h] = !item.markedHunks[h]
+		if !item.markedHunks[h] {
+			delete(item.markedHunks, h)
+		}
+		item.marked = false
+	case key.Matches(msg, m.keys.Enter), key.Matches(msg, m.keys.Left), key.Matches(msg, m.keys.Quit), msg.Type == tea.KeyEsc:
+		m.hunkMode = false
+	}
+}
+
+// toggleMark marks or unmarks the item under the cursor; on a group header, every item of the group
+func (m *StagingModel) toggleMark() {
+	group, item := m.selected()
+	if group == nil {
+		return
+	}
+	items := group.items
+	if item != nil {
+		items = []*stageItem{item}
+	}
+
+	// Mark all of them unless they are all marked already
+	mark := false
+	for _, it := range items {
+		if !it.marked {
+			mark = true
+		}
+	}
+	for _, it := range items {
+		it.marked = mark
+		it.markedHunks = nil
+	}
+}
+
+// hasMarks reports whether any item or hunk is marked
+func (m *StagingModel) hasMarks() bool {
+	for _, group := range m.groups {
+		for _, item := range group.items {
+			if item.marked || len(item.markedHunks) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// takeMarked removes the marked items from their groups, splitting marked hunks off the items
+// they belong to, and returns them in list order with their marks cleared
+func (m *StagingModel) takeMarked() []*stageItem {
+	var taken []*stageItem
+	for _, group := range m.groups {
+		var kept []*stageItem
+		for _, item := range group.items {
+			switch {
+			case item.marked:
+				item.marked = false
+				item.markedHunks = nil
+				taken = append(taken, item)
+			case len(item.markedHunks) > 0:
+				split := &stageItem{chunk: item.chunk}
+				var rest []int
+				for _, h := range item.hunks {
+					if item.markedHunks[h] {
+						split.hunks = append(split.hunks, h)
+					} else {
+						rest = append(rest, h)
+					}
+				}
+				item.hunks = rest
+				item.markedHunks = nil
+				taken = append(taken, split)
+				if len(rest) > 0 {
+					kept = append(kept, item)
+				}
+			default:
+				kept = append(kept, item)
+			}
+		}
+		group.items = kept
+	}
+	return taken
+}
+
+// addItems puts items at the end of a group, merging them into an item of the same chunk
+// already in the group
+func addItems(group *stageGroup, items []*stageItem) {
+	for _, item := range items {
+		insertItem(group, item, false)
+	}
+}
+
+// insertItem puts an item at the start or the end of a group, or merges its hunks into an
+// item of the same chunk already in the group, and returns the item now holding them
+func insertItem(group *stageGroup, item *stageItem, front bool) *stageItem {
+	for _, existing := range group.items {
+		if existing.chunk.ID == item.chunk.ID {
+			existing.hunks = append(existing.hunks, item.hunks...)
+			sort.Ints(existing.hunks)
+			return existing
+		}
+	}
+	if front {
+		group.items = append([]*stageItem{item}, group.items...)
+	} else {
+		group.items = append(group.items, item)
+	}
+	return item
+}
+
+// pruneGroups drops commits left without items
+func (m *StagingModel) pruneGroups() {
+	var groups []*stageGroup
+	for _, group := range m.groups {
+		if group.unstaged || len(group.items) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	m.groups = groups
+	m.rebuildRows()
+}
+
+// newGroup creates a commit from the marked items and hunks, or from the item under the
+// cursor if nothing is marked, and starts editing its message
+func (m *StagingModel) newGroup() tea.Cmd {
+	var items []*stageItem
+	if m.hasMarks() {
+		items = m.takeMarked()
+	} else if group, item := m.selected(); item != nil {
+		group.items = removeItem(group.items, item)
+		items = []*stageItem{item}
+	} else {
+		m.status = SubtleTextStyle.Render("Mark chunks with x, or hunks with enter and x, to put them in a new commit")
+		return nil
+	}
+
+	group := &stageGroup{}
+	addItems(group, items)
+	m.groups = append(m.groups[:len(m.groups)-1], group, m.unstaged())
+	m.pruneGroups()
+	m.selectGroup(group)
+	m.status = ""
+	return m.editMessage()
+}
+
+// moveMarkedHere moves the marked items and hunks into the group under the cursor
+func (m *StagingModel) moveMarkedHere() {
+	target, _ := m.selected()
+	if target == nil {
+		return
+	}
+	if !m.hasMarks() {
+		m.status = SubtleTextStyle.Render("Nothing is marked")
+		return
+	}
+
+	addItems(target, m.takeMarked())
+	m.pruneGroups()
+	m.selectGroup(target)
+}
+
+// move moves the item under the cursor up (negative) or down (positive) the list, into the
+// neighbouring group at either end of its own. On a commit's header it moves the whole
+// commit before or after its neighbour.
+func (m *StagingModel) move(delta int) {
+	if m.cursor >= len(m.rows) {
+		return
+	}
+	row := m.rows[m.cursor]
+	group := m.groups[row.group]
+
+	if row.item < 0 {
+		other := row.group + delta
+		if group.unstaged || other < 0 || m.groups[other].unstaged {
+			return
+		}
+		m.groups[row.group], m.groups[other] = m.groups[other], group
+		m.rebuildRows()
+		m.selectGroup(group)
+		return
+	}
+
+	item := group.items[row.item]
+	switch other := row.item + delta; {
+	case other >= 0 && other < len(group.items):
+		group.items[row.item], group.items[other] = group.items[other], item
+	case delta < 0 && row.group > 0:
+		group.items = removeItem(group.items, item)
+		item = insertItem(m.groups[row.group-1], item, false)
+	case delta > 0 && row.group < len(m.groups)-1:
+		group.items = removeItem(group.items, item)
+		item = insertItem(m.groups[row.group+1], item, true)
+	default:
+		return
+	}
+	m.pruneGroups()
+	m.selectItem(item)
+}
+
+// split replaces the item under the cursor with one item per hunk
+func (m *StagingModel) split() {
+	group, item := m.selected()
+	if item == nil {
+		return
+	}
+	if len(item.hunks) < 2 {
+		m.status = SubtleTextStyle.Render("Only chunks with several hunks can be split")
+		return
+	}
+
+	for i, it := range group.items {
+		if it == item {
+			var parts []*stageItem
+			for _, h := range item.hunks {
+				parts = append(parts, &stageItem{chunk: item.chunk, hunks: []int{h}, marked: item.marked || item.markedHunks[h]})
+			}
+			group.items = append(group.items[:i], append(parts, group.items[i+1:]...)...)
+			break
+		}
+	}
+	m.rebuildRows()
+	m.status = SuccessStyle.Render(fmt.Sprintf("%s Split %s into %d hunks", IconSuccess, filepath.Base(item.chunk.FilePath), len(item.hunks)))
+}
+
+// unstage moves the marked items and hunks, or the item under the cursor if nothing is
+// marked, out of their commits. On a commit's header it drops the whole commit.
+func (m *StagingModel) unstage() {
+	group, item := m.selected()
+	if group == nil {
+		return
+	}
+	unstaged := m.unstaged()
+
+	switch {
+	case m.hasMarks():
+		addItems(unstaged, m.takeMarked())
+	case item == nil && !group.unstaged:
+		addItems(unstaged, group.items)
+		group.items = nil
+	case item != nil && !group.unstaged:
+		group.items = removeItem(group.items, item)
+		addItems(unstaged, []*stageItem{item})
+	default:
+		return
+	}
+	sortItems(unstaged.items)
+	m.pruneGroups()
+}
+
+// removeItem returns items without item
+func removeItem(items []*stageItem, item *stageItem) []*stageItem {
+	var kept []*stageItem
+	for _, it := range items {
+		if it != item {
+			kept = append(kept, it)
+		}
+	}
+	return kept
+}
+
+// sortItems orders items by when their chunk started, then by their first hunk
+func sortItems(items []*stageItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if !a.chunk.StartTime.Equal(b.chunk.StartTime) {
+			return a.chunk.StartTime.Before(b.chunk.StartTime)
+		}
+		return len(a.hunks) > 0 && len(b.hunks) > 0 && a.hunks[0] < b.hunks[0]
+	})
+}
+
+// editMessage starts editing the commit message of the group under the cursor
+func (m *StagingModel) editMessage() tea.Cmd {
+	group, _ := m.selected()
+	if group == nil || group.unstaged {
+		m.status = SubtleTextStyle.Render("Unstaged chunks aren't committed; create a commit for them with n")
+		return nil
+	}
+	m.selectGroup(group)
+	m.editing = true
+	m.message.SetValue(group.message)
+	m.message.CursorEnd()
+	return m.message.Focus()
+}
+
+// updateMessage passes a key to the message input. Enter keeps the message; Esc discards the changes.
+func (m *StagingModel) updateMessage(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEnter:
+		if group, _ := m.selected(); group != nil {
+			group.message = strings.TrimSpace(m.message.Value())
+		}
+		fallthrough
+	case tea.KeyEsc:
+		m.editing = false
+		m.message.Blur()
+		return nil
+	}
+
+	var cmd tea.Cmd
+	m.message, cmd = m.message.Update(msg)
+	return cmd
+}
+
+// commitPlan is a commit to create from the staging groups
+type commitPlan struct {
+	message  string
+	items    []staging.Item
+	complete []chunk.ChunkID // Chunks whose last hunks are in this commit
+}
+
+// commit creates a commit for each group in order, marking the chunks that end up wholly
+// committed in the store
+func (m *StagingModel) commit() tea.Cmd {
+	commits := m.groups[:len(m.groups)-1]
+	if len(commits) == 0 {
+		m.status = SubtleTextStyle.Render("Nothing to commit; mark chunks with x and create a commit with n")
+		return nil
+	}
+	for i, group := range commits {
+		if group.message == "" {
+			m.selectGroup(group)
+			m.status = ErrorStyle.Render(fmt.Sprintf("%s Commit %d needs a message; press e to write one", IconError, i+1))
+			return nil
+		}
+	}
+
+	// A chunk is wholly committed by the last commit holding its hunks, unless some stay unstaged
+	last := make(map[chunk.ChunkID]int)
+	for i, group := range commits {
+		for _, item := range group.items {
+			last[item.chunk.ID] = i
+		}
+	}
+	for _, item := range m.unstaged().items {
+		delete(last, item.chunk.ID)
+	}
+
+	plans := make([]commitPlan, len(commits))
+	for i, group := range commits {
+		plans[i].message = group.message
+		plans[i].items = m.stagingItems(group)
+		for _, item := range plans[i].items {
+			if n, ok := last[item.Chunk.ID]; ok && n == i {
+				plans[i].complete = append(plans[i].complete, item.Chunk.ID)
+			}
+		}
+	}
+
+	m.committing = true
+	m.status = SubtleTextStyle.Render(fmt.Sprintf("%s Creating %d commits...", IconLoading, len(plans)))
+	store, dir := m.store, m.dir
+	return func() tea.Msg {
+		var hashes []string
+		for i, plan := range plans {
+			hash, err := staging.Commit(dir, plan.items, plan.message)
+			if err != nil {
+				return CommittedMsg{Hashes: hashes, Error: fmt.Errorf("commit %d: %w", i+1, err)}
+			}
+			hashes = append(hashes, hash)
+			if len(plan.complete) > 0 {
+				if err := store.MarkCommitted(plan.complete, hash); err != nil {
+					return CommittedMsg{Hashes: hashes, Error: fmt.Errorf("failed to mark the chunks of commit %s as committed: %w", shortCommit(hash), err)}
+				}
+			}
+		}
+		return CommittedMsg{Hashes: hashes}
+	}
+}
+
+// stagingItems combines the items of a group by chunk, oldest chunk first, so each chunk's
+// hunks are staged together and a file's chunks apply in the order they were made
+func (m *StagingModel) stagingItems(group *stageGroup) []staging.Item {
+	byChunk := make(map[chunk.ChunkID]*stageItem)
+	var combined []*stageItem
+	for _, item := range group.items {
+		if existing, ok := byChunk[item.chunk.ID]; ok {
+			existing.hunks = append(existing.hunks, item.hunks...)
+			continue
+		}
+		copied := &stageItem{chunk: item.chunk, hunks: append([]int(nil), item.hunks...)}
+		byChunk[item.chunk.ID] = copied
+		combined = append(combined, copied)
+	}
+	sort.SliceStable(combined, func(i, j int) bool {
+		return combined[i].chunk.StartTime.Before(combined[j].chunk.StartTime)
+	})
+
+	items := make([]staging.Item, len(combined))
+	for i, item := range combined {
+		items[i] = staging.Item{Chunk: item.chunk}
+		if len(item.hunks) < len(m.hunks[item.chunk.ID]) {
+			items[i].Hunks = item.hunks
+		}
+	}
+	return items
+}
+
+// handleCommitted drops the commits that were created from the list
+func (m *StagingModel) handleCommitted(msg CommittedMsg) {
+	m.groups = m.groups[len(msg.Hashes):]
+	m.rebuildRows()
+
+	var short []string
+	for _, hash := range msg.Hashes {
+		short = append(short, shortCommit(hash))
+	}
+	if msg.Error != nil {
+		m.status = ErrorStyle.Render(fmt.Sprintf("%s %v", IconError, msg.Error))
+		if len(short) > 0 {
+			m.status = SuccessStyle.Render(fmt.Sprintf("%s Created %s", IconSuccess, strings.Join(short, ", "))) + "  " + m.status
+		}
+		return
+	}
+	m.status = SuccessStyle.Render(fmt.Sprintf("%s Created %d commits: %s", IconSuccess, len(short), strings.Join(short, ", ")))
+}
+
+// layout sizes the panels for the terminal: 40% for the list and 60% for the diff
+func (m *StagingModel) layout() {
+	m.listWidth = int(float64(m.width) * 0.4)
+	m.diffWidth = m.width - m.listWidth
+
+	headerHeight := 2
+	footerHeight := 2
+	contentHeight := m.height - headerHeight - footerHeight
+
+	if !m.ready {
+		m.listViewport = viewport.New(max(m.listWidth-2, 0), contentHeight)
+		m.diffViewport = viewport.New(m.diffWidth-2, contentHeight)
+		m.ready = true
+		return
+	}
+	m.listViewport.Width = max(m.listWidth-2, 0)
+	m.listViewport.Height = contentHeight
+	m.diffViewport.Width = m.diffWidth - 2
+	m.diffViewport.Height = contentHeight
+}
+
+// View renders the model
+func (m *StagingModel) View() string {
+	if !m.ready {
+		spinner := SubtleTextStyle.Render("◐")
+		loadingText := TextStyle.Render("  Loading...")
+		return lipgloss.JoinVertical(lipgloss.Center, spinner+" "+loadingText)
+	}
+
+	if len(m.rows) == 1 && m.status == "" {
+		title := TitleStyle.Render("📦 STAGING")
+		emptyMsg := SubtleTextStyle.Render("No uncommitted chunks")
+		helpMsg := TextStyle.Render("Chunks appear here once they are saved and until they are committed.")
+
+		emptyBox := DimBoxStyle.Width(50).Align(lipgloss.Center).Render(
+			lipgloss.JoinVertical(lipgloss.Center, emptyMsg, "", helpMsg),
+		)
+
+		instructions := HelpDescStyle.Margin(1, 0, 0, 0).Render("q quit")
+
+		content := lipgloss.JoinVertical(lipgloss.Center, title, "", emptyBox, instructions)
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, content)
+	}
+
+	content := lipgloss.JoinHorizontal(lipgloss.Top, m.renderListPanel(), m.renderDiffPanel())
+
+	if m.editing {
+		footer := lipgloss.NewStyle().
+			Padding(0, 1).
+			Render(m.message.View() + "  " + HelpDescStyle.Render("enter save • esc cancel"))
+		return lipgloss.JoinVertical(lipgloss.Left, content, footer)
+	}
+
+	help := func(k key.Binding, desc string) string {
+		return HelpKeyStyle.Render(k.Help().Key) + HelpDescStyle.Render(" "+desc)
+	}
+	var parts []string
+	if m.hunkMode {
+		parts = []string{
+			HelpKeyStyle.Render("↑/↓") + HelpDescStyle.Render(" hunk"),
+			help(m.keys.Select, "mark hunk"),
+			HelpKeyStyle.Render("esc") + HelpDescStyle.Render(" back to list"),
+		}
+	} else {
+		parts = []string{
+			HelpKeyStyle.Render("↑/↓") + HelpDescStyle.Render(" navigate"),
+			help(m.keys.Select, "mark"),
+			HelpKeyStyle.Render("enter") + HelpDescStyle.Render(" mark hunks"),
+			help(m.keys.NewGroup, "new commit"),
+			help(m.keys.MoveMarked, "move marked here"),
+			HelpKeyStyle.Render("J/K") + HelpDescStyle.Render(" reorder"),
+			help(m.keys.Split, "split"),
+			help(m.keys.Unstage, "unstage"),
+			help(m.keys.EditMessage, "message"),
+			help(m.keys.Commit, "commit"),
+			help(m.keys.Quit, "quit"),
+		}
+	}
+	footerText := strings.Join(parts, " • ")
+	if m.status != "" {
+		footerText += "  " + m.status
+	}
+
+	footer := lipgloss.NewStyle().
+		Padding(0, 1).
+		Render(footerText)
+
+	return lipgloss.JoinVertical(lipgloss.Left, content, footer)
+}
+
+// renderListPanel renders the left panel with the commits and the unstaged chunks
+func (m *StagingModel) renderListPanel() string {
+	title := HeaderStyle.Padding(1, 2).Render("📦 STAGING")
+
+	var lines []string
+	for i, row := range m.rows {
+		group := m.groups[row.group]
+		cursor := "  "
+		if i == m.cursor {
+			cursor = IconCursor + " "
+		}
+
+		if row.item < 0 {
+			var label string
+			if group.unstaged {
+				label = SubheaderStyle.Render("Not staged")
+			} else {
+				label = SubheaderStyle.Render(fmt.Sprintf("Commit %d", row.group+1)) + " "
+				if group.message == "" {
+					label += MutedTextStyle.Render("no message")
+				} else {
+					label += TextStyle.Render(group.message)
+				}
+			}
+			label += " " + MutedTextStyle.Render(fmt.Sprintf("(%d)", len(group.items)))
+			if i == m.cursor {
+				label = SelectedItemStyle.PaddingLeft(0).Render(cursor) + label
+			} else {
+				label = cursor + label
+			}
+			lines = append(lines, label)
+			continue
+		}
+
+		item := group.items[row.item]
+		box := IconCheckbox
+		if item.marked {
+			box = IconChecked
+		}
+
+		filename := filepath.Base(item.chunk.FilePath)
+		if len(filename) > 25 {
+			filename = filename[:22] + "..."
+		}
+
+		line := cursor + box + " " + filename + " " + SubtleTextStyle.Render(item.chunk.StartTime.Format("15:04"))
+		if item.chunk.Op != "" && item.chunk.Op != chunk.OpModify {
+			line += " " + MutedTextStyle.Render("("+item.chunk.Op.String()+")")
+		}
+		if total := len(m.hunks[item.chunk.ID]); len(item.hunks) < total {
+			line += " " + MutedTextStyle.Render(fmt.Sprintf("%d/%d hunks", len(item.hunks), total))
+		}
+		if n := len(item.markedHunks); n > 0 {
+			line += " " + WarningStyle.Render(fmt.Sprintf("%d marked", n))
+		}
+		if item.chunk.FeatureTag != "" {
+			line += " " + MutedTextStyle.Render("["+string(item.chunk.FeatureTag)+"]")
+		}
+
+		if i == m.cursor {
+			line = SelectedItemStyle.Render(line)
+		} else {
+			line = ItemStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+
+	m.listViewport.SetContent(strings.Join(lines, "\n"))
+
+	// Ensure the selected line is visible
+	if m.cursor < m.listViewport.YOffset {
+		m.listViewport.YOffset = m.cursor
+	} else if m.cursor >= m.listViewport.YOffset+m.listViewport.Height {
+		m.listViewport.YOffset = m.cursor - m.listViewport.Height + 1
+	}
+
+	listStyle := lipgloss.NewStyle().
+		Width(m.listWidth).
+		Height(m.height).
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(ColorBorder).
+		Padding(0, 1)
+
+	return listStyle.Render(lipgloss.JoinVertical(lipgloss.Left, title, m.listViewport.View()))
+}
+
+// renderDiffPanel renders the right panel with the hunks of the selected item
+func (m *StagingModel) renderDiffPanel() string {
+	borderColor := ColorTitle
+	if m.hunkMode {
+		borderColor = ColorAccent
+	}
+	diffStyle := lipgloss.NewStyle().
+		Width(m.diffWidth).
+		Height(m.height).
+		BorderStyle(lipgloss.ThickBorder()).
+		BorderForeground(borderColor).
+		Padding(0, 1)
+
+	group, item := m.selected()
+	if item == nil {
+		var summary string
+		switch {
+		case group == nil:
+		case group.unstaged:
+			summary = SubtleTextStyle.Render(fmt.Sprintf("%d items are left out of the commits.", len(group.items)))
+		default:
+			summary = SubtleTextStyle.Render(fmt.Sprintf("Commit %d stages %d items.", m.rows[m.cursor].group+1, len(group.items)))
+			if group.message == "" {
+				summary += "\n\n" + HelpKeyStyle.Render("e") + HelpDescStyle.Render(" write its message")
+			}
+		}
+		return diffStyle.Render(lipgloss.NewStyle().Padding(1, 2).Render(summary))
+	}
+
+	c := item.chunk
+	headerText := SubtleTextStyle.Render("File:") + " " + TextStyle.Bold(true).Render(c.FilePath) + "  " +
+		SubtleTextStyle.Render("Time:") + " " + TextStyle.Render(fmt.Sprintf("%s → %s", c.StartTime.Format("15:04:05"), c.EndTime.Format("15:04:05")))
+	switch c.Op {
+	case chunk.OpCreate, chunk.OpDelete:
+		headerText += "  " + SubtleTextStyle.Render("Op:") + " " + TextStyle.Render(c.Op.String())
+	case chunk.OpRename:
+		headerText += "\n" + SubtleTextStyle.Render("Renamed from:") + " " + TextStyle.Render(c.OldPath)
+	}
+	if total := len(m.hunks[c.ID]); total > 0 {
+		headerText += "  " + SubtleTextStyle.Render("Hunks:") + " " + TextStyle.Render(fmt.Sprintf("%d of %d", len(item.hunks), total))
+	}
+
+	header := lipgloss.NewStyle().
+		Padding(1, 2).
+		Render(headerText)
+
+	return diffStyle.Render(lipgloss.JoinVertical(lipgloss.Left, header, m.diffViewport.View()))
+}
+
+// updateDiffContent renders the hunks of the selected item into the diff viewport, scrolled
+// to the hunk under the cursor while marking hunks
+func (m *StagingModel) updateDiffContent() {
+	if !m.ready {
+		return
+	}
+	_, item := m.selected()
+	if item == nil {
+		m.diffViewport.SetContent("")
+		return
+	}
+
+	hunks := m.hunks[item.chunk.ID]
+	if len(item.hunks) == 0 {
+		m.diffViewport.SetContent(SubtleTextStyle.Render(fmt.Sprintf("%s the file without changing its contents",
+			strings.ToUpper(item.chunk.Op.String()[:1])+item.chunk.Op.String()[1:])))
+		m.diffViewport.GotoTop()
+		return
+	}
+
+	r := newDiffRenderer(item.chunk.FilePath, max(m.diffViewport.Width-2, 0))
+	var lines []string
+	offset := 0
+	for i, h := range item.hunks {
+		box := IconCheckbox
+		if item.marked || item.markedHunks[h] {
+			box = IconChecked
+		}
+		label := fmt.Sprintf("%s Hunk %d of %d", box, h+1, len(hunks))
+		if m.hunkMode && i == m.hunkCursor {
+			offset = len(lines)
+			label = SelectedItemStyle.PaddingLeft(0).Render(IconCursor + " " + label)
+		} else {
+			label = SubheaderStyle.Render("  " + label)
+		}
+		lines = append(lines, label)
+		lines = append(lines, strings.Split(r.renderUnified(hunks[h].String()), "\n")...)
+		lines = append(lines, "")
+	}
+
+	m.diffViewport.SetContent(strings.Join(lines, "\n"))
+	if m.hunkMode {
+		m.diffViewport.SetYOffset(offset)
+	} else {
+		m.diffViewport.GotoTop()
+	}
+}
+
+// RunStaging runs the staging TUI on the uncommitted chunks in store matching q whose files
+// are in the git work tree at dir, creating the commits there
+func RunStaging(store StagingStore, dir string, q chunk.Query) error {
+	model, err := NewStagingModel(store, dir, q)
+	if err != nil {
+		return err
+	}
+
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		return fmt.Errorf("error running staging view: %w", err)
+	}
+
+	return nil
+}
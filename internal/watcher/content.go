@@ -0,0 +1,84 @@
+package watcher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"carya/internal/chunk"
+)
+
+const (
+	// DefaultMaxFileSize is the size above which a file's contents are not tracked.
+	DefaultMaxFileSize = 10 << 20
+	// sniffLen is how much of the start of a file is inspected to decide whether it is text.
+	sniffLen = 8000
+)
+
+// skippedFile describes a file whose contents are not tracked.
+type skippedFile struct {
+	size   int64            // Size of the file
+	hash   string           // SHA-256 of the file contents
+	reason chunk.SkipReason // Why the contents are not tracked
+}
+
+// readContents reads a file's contents if they should be tracked. A file that is larger than
+// maxSize or doesn't look like text is not returned; instead its size and hash are, along
+// with the reason it was skipped.
+func readContents(path string, maxSize int64) ([]byte, *skippedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if fi.Size() > maxSize {
+		// Hash without holding the whole file in memory
+		hash := sha256.New()
+		size, err := io.Copy(hash, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, &skippedFile{size: size, hash: fmt.Sprintf("%x", hash.Sum(nil)), reason: chunk.SkipTooLarge}, nil
+	}
+
+	contents, err := io.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	if looksBinary(contents) {
+		return nil, &skippedFile{
+			size:   int64(len(contents)),
+			hash:   fmt.Sprintf("%x", sha256.Sum256(contents)),
+			reason: chunk.SkipBinary,
+		}, nil
+	}
+	return contents, nil, nil
+}
+
+// looksBinary reports whether the start of the contents contains a NUL byte or isn't valid UTF-8.
+func looksBinary(contents []byte) bool {
+	block := contents
+	if len(block) > sniffLen {
+		block = block[:sniffLen]
+		// Don't count a character cut in half at the end of the block against the file
+		for i := 1; i < utf8.UTFMax && i <= len(block); i++ {
+			if utf8.RuneStart(block[len(block)-i]) {
+				if !utf8.FullRune(block[len(block)-i:]) {
+					block = block[:len(block)-i]
+				}
+				break
+			}
+		}
+	}
+
+	return bytes.IndexByte(block, 0) >= 0 || !utf8.Valid(block)
+}
@@ -0,0 +1,68 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"carya/internal/chunk"
+)
+
+func TestReadContents(t *testing.T) {
+	text := strings.Repeat("a", sniffLen-1)
+
+	tests := []struct {
+		name    string
+		content string
+		maxSize int64
+		reason  chunk.SkipReason // Empty if the contents are tracked
+	}{
+		{name: "text", content: "package main\n", maxSize: 100},
+		{name: "empty", content: "", maxSize: 100},
+		{name: "utf-8", content: "héllo wörld ✓\n", maxSize: 100},
+		{name: "nul byte", content: "abc\x00def", maxSize: 100, reason: chunk.SkipBinary},
+		{name: "invalid utf-8", content: "abc\xff\xfedef", maxSize: 100, reason: chunk.SkipBinary},
+		{name: "character cut at the end of the sniffed block", content: text + "é more", maxSize: 1 << 20},
+		{name: "invalid utf-8 in the sniffed block", content: text + "\xff", maxSize: 1 << 20, reason: chunk.SkipBinary},
+		{name: "nul byte after the sniffed block", content: text + "a\x00", maxSize: 1 << 20},
+		{name: "exactly the maximum size", content: "0123456789", maxSize: 10},
+		{name: "too large", content: "0123456789a", maxSize: 10, reason: chunk.SkipTooLarge},
+		{name: "too large binary", content: "\x00\x01\x02\x03", maxSize: 3, reason: chunk.SkipTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			contents, skipped, err := readContents(path, tt.maxSize)
+			if err != nil {
+				t.Fatalf("readContents() error = %v", err)
+			}
+			if tt.reason == "" {
+				if skipped != nil || string(contents) != tt.content {
+					t.Errorf("readContents() = %q, %+v, want the contents", contents, skipped)
+				}
+				return
+			}
+
+			want := skippedFile{
+				size:   int64(len(tt.content)),
+				hash:   fmt.Sprintf("%x", sha256.Sum256([]byte(tt.content))),
+				reason: tt.reason,
+			}
+			if contents != nil || skipped == nil || *skipped != want {
+				t.Errorf("readContents() = %q, %+v, want skipped %+v", contents, skipped, want)
+			}
+		})
+	}
+
+	if _, _, err := readContents(filepath.Join(t.TempDir(), "missing"), 100); !os.IsNotExist(err) {
+		t.Errorf("readContents() of a missing file error = %v, want not exist", err)
+	}
+}
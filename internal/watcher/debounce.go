@@ -0,0 +1,120 @@
+package watcher
+
+import (
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// DefaultQuietWindow is how long a file has to stay untouched before its events are handled.
+	DefaultQuietWindow = 100 * time.Millisecond
+	// DefaultMaxDelay bounds how long a file that keeps changing waits before its events are handled.
+	DefaultMaxDelay = 2 * time.Second
+)
+
+// pendingEvent collects the events seen for a path during its quiet window.
+type pendingEvent struct {
+	path     string      // Path the events are for
+	since    time.Time   // When the first event in the burst arrived
+	first    fsnotify.Op // Operation of the first event in the burst
+	ops      fsnotify.Op // Union of all operations in the burst
+	deadline time.Time   // When the burst is handled unless another event arrives
+	expires  time.Time   // When the burst is handled even if events keep arriving
+}
+
+// debouncer coalesces the events of each path until the path has been quiet for a while,
+// so a burst of writes results in a single read of the file. It is only used from the
+// watch loop goroutine.
+type debouncer struct {
+	quiet    time.Duration            // Quiet window after the last event
+	maxDelay time.Duration            // Upper bound on how long events are held back
+	pending  map[string]*pendingEvent // Bursts waiting to be handled, by path
+	timer    *time.Timer              // Fires at or before the earliest deadline
+	next     time.Time                // When the timer fires, zero if it is stopped
+}
+
+// newDebouncer creates a debouncer with the given quiet window and maximum delay.
+func newDebouncer(quiet, maxDelay time.Duration) *debouncer {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	return &debouncer{
+		quiet:    quiet,
+		maxDelay: maxDelay,
+		pending:  make(map[string]*pendingEvent),
+		timer:    timer,
+	}
+}
+
+// add records an event for path, pushing back the moment its burst is handled.
+func (d *debouncer) add(path string, op fsnotify.Op, now time.Time) {
+	p, exists := d.pending[path]
+	if !exists {
+		p = &pendingEvent{path: path, since: now, first: op, expires: now.Add(d.maxDelay)}
+		d.pending[path] = p
+	}
+	p.ops |= op
+	p.deadline = now.Add(d.quiet)
+	if p.deadline.After(p.expires) {
+		p.deadline = p.expires
+	}
+
+	// A deadline that moved back is picked up when the timer fires, which keeps add cheap
+	// when thousands of files change at once
+	if d.next.IsZero() || p.deadline.Before(d.next) {
+		d.reset(p.deadline, now)
+	}
+}
+
+// due removes and returns the bursts whose deadline has passed, then reschedules the timer.
+func (d *debouncer) due(now time.Time) []*pendingEvent {
+	var ready []*pendingEvent
+	for path, p := range d.pending {
+		if !p.deadline.After(now) {
+			ready = append(ready, p)
+			delete(d.pending, path)
+		}
+	}
+	d.schedule(now)
+	return inArrivalOrder(ready)
+}
+
+// drain removes and returns all pending bursts.
+func (d *debouncer) drain() []*pendingEvent {
+	ready := make([]*pendingEvent, 0, len(d.pending))
+	for _, p := range d.pending {
+		ready = append(ready, p)
+	}
+	d.pending = make(map[string]*pendingEvent)
+	d.timer.Stop()
+	d.next = time.Time{}
+	return inArrivalOrder(ready)
+}
+
+// inArrivalOrder sorts bursts by their first event, so the deletion half of a rename is
+// handled before the creation half.
+func inArrivalOrder(events []*pendingEvent) []*pendingEvent {
+	sort.Slice(events, func(i, j int) bool { return events[i].since.Before(events[j].since) })
+	return events
+}
+
+// schedule sets the timer to the earliest pending deadline.
+func (d *debouncer) schedule(now time.Time) {
+	d.timer.Stop()
+	d.next = time.Time{}
+	for _, p := range d.pending {
+		if d.next.IsZero() || p.deadline.Before(d.next) {
+			d.next = p.deadline
+		}
+	}
+	if !d.next.IsZero() {
+		d.reset(d.next, now)
+	}
+}
+
+// reset makes the timer fire at the given time.
+func (d *debouncer) reset(at, now time.Time) {
+	d.next = at
+	d.timer.Reset(max(at.Sub(now), 0))
+}
@@ -0,0 +1,170 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// event is a file system event arriving at an offset from the start of a test.
+type event struct {
+	path string
+	op   fsnotify.Op
+	at   time.Duration
+}
+
+// burst is what the debouncer is expected to hand over for a path.
+type burst struct {
+	path  string
+	first fsnotify.Op
+	ops   fsnotify.Op
+}
+
+func TestDebouncer(t *testing.T) {
+	const (
+		quiet    = 100 * time.Millisecond
+		maxDelay = 300 * time.Millisecond
+	)
+	ms := time.Millisecond
+	writes := func(path string, at ...time.Duration) []event {
+		var events []event
+		for _, offset := range at {
+			events = append(events, event{path: path, op: fsnotify.Write, at: offset})
+		}
+		return events
+	}
+
+	tests := []struct {
+		name   string
+		events []event
+		due    time.Duration // When the due bursts are collected
+		want   []burst       // In the order they are handed over
+	}{
+		{
+			name:   "within the quiet window",
+			events: writes("a", 0),
+			due:    99 * ms,
+		},
+		{
+			name:   "quiet window over",
+			events: writes("a", 0),
+			due:    100 * ms,
+			want:   []burst{{path: "a", first: fsnotify.Write, ops: fsnotify.Write}},
+		},
+		{
+			name:   "later events push the deadline back",
+			events: writes("a", 0, 60*ms, 120*ms),
+			due:    219 * ms,
+		},
+		{
+			name: "burst is coalesced",
+			events: []event{
+				{path: "a", op: fsnotify.Create, at: 0},
+				{path: "a", op: fsnotify.Write, at: 60 * ms},
+				{path: "a", op: fsnotify.Write, at: 120 * ms},
+			},
+			due:  220 * ms,
+			want: []burst{{path: "a", first: fsnotify.Create, ops: fsnotify.Create | fsnotify.Write}},
+		},
+		{
+			name:   "max delay not reached",
+			events: writes("a", 0, 90*ms, 180*ms, 270*ms),
+			due:    299 * ms,
+		},
+		{
+			name:   "max delay reached while events keep arriving",
+			events: writes("a", 0, 90*ms, 180*ms, 270*ms),
+			due:    300 * ms,
+			want:   []burst{{path: "a", first: fsnotify.Write, ops: fsnotify.Write}},
+		},
+		{
+			name:   "paths are debounced separately",
+			events: append(writes("a", 0), writes("b", 80*ms)...),
+			due:    120 * ms,
+			want:   []burst{{path: "a", first: fsnotify.Write, ops: fsnotify.Write}},
+		},
+		{
+			name: "handed over in arrival order",
+			events: []event{
+				{path: "old", op: fsnotify.Rename, at: 0},
+				{path: "new", op: fsnotify.Create, at: 10 * ms},
+				{path: "old", op: fsnotify.Write, at: 50 * ms},
+			},
+			due: 200 * ms,
+			want: []burst{
+				{path: "old", first: fsnotify.Rename, ops: fsnotify.Rename | fsnotify.Write},
+				{path: "new", first: fsnotify.Create, ops: fsnotify.Create},
+			},
+		},
+	}
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDebouncer(quiet, maxDelay)
+			defer d.timer.Stop()
+			paths := make(map[string]bool)
+			for _, e := range tt.events {
+				d.add(e.path, e.op, start.Add(e.at))
+				paths[e.path] = true
+			}
+
+			got := d.due(start.Add(tt.due))
+			if len(got) != len(tt.want) {
+				t.Fatalf("due() = %d bursts, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got[i].path != want.path || got[i].first != want.first || got[i].ops != want.ops {
+					t.Errorf("burst %d = %s first %v ops %v, want %s first %v ops %v",
+						i, got[i].path, got[i].first, got[i].ops, want.path, want.first, want.ops)
+				}
+			}
+			if len(d.pending) != len(paths)-len(tt.want) {
+				t.Errorf("%d bursts still pending, want %d", len(d.pending), len(paths)-len(tt.want))
+			}
+		})
+	}
+}
+
+func TestDebouncerSchedule(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	d := newDebouncer(100*time.Millisecond, time.Second)
+	defer d.timer.Stop()
+
+	// The timer follows the earliest deadline
+	d.add("a", fsnotify.Write, start)
+	d.add("b", fsnotify.Write, start.Add(50*time.Millisecond))
+	if want := start.Add(100 * time.Millisecond); !d.next.Equal(want) {
+		t.Errorf("timer fires at %v, want %v", d.next, want)
+	}
+	d.due(start.Add(100 * time.Millisecond))
+	if want := start.Add(150 * time.Millisecond); !d.next.Equal(want) {
+		t.Errorf("timer fires at %v after a's burst, want %v", d.next, want)
+	}
+
+	// Draining hands over everything and stops the timer
+	d.add("c", fsnotify.Create, start.Add(60*time.Millisecond))
+	drained := d.drain()
+	if len(drained) != 2 || drained[0].path != "b" || drained[1].path != "c" {
+		t.Errorf("drain() = %d bursts, want b and c", len(drained))
+	}
+	if len(d.pending) != 0 || !d.next.IsZero() {
+		t.Errorf("after drain() %d bursts pending and timer at %v, want none and stopped", len(d.pending), d.next)
+	}
+}
+
+func TestDebouncerFiresTimer(t *testing.T) {
+	d := newDebouncer(10*time.Millisecond, time.Second)
+	defer d.timer.Stop()
+	d.add("a", fsnotify.Write, time.Now())
+
+	select {
+	case <-d.timer.C:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timer didn't fire after the quiet window")
+	}
+	if got := d.due(time.Now()); len(got) != 1 || got[0].path != "a" {
+		t.Errorf("due() after the timer fired = %d bursts, want a", len(got))
+	}
+}
@@ -3,12 +3,17 @@
 package watcher
 
 import (
-	"bufio"
 	"log"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/ignore"
 
 	"github.com/fsnotify/fsnotify"
 )
@@ -17,20 +22,77 @@ import (
 type FileChangeHandler interface {
 	// OnFileChange is called when a tracked file is modified.
 	OnFileChange(path string, contents []byte)
+	// OnFileCreate is called when a tracked file is created or moved to path.
+	OnFileCreate(path string, contents []byte)
+	// OnFileDelete is called when a tracked file is deleted or moved away from path.
+	OnFileDelete(path string)
+	// OnFileSkipped is called instead of OnFileChange or OnFileCreate when a file changed
+	// but its contents are not tracked because it is binary or too large.
+	OnFileSkipped(path string, size int64, hash string, reason chunk.SkipReason)
+}
+
+// ErrorHandler is implemented by a FileChangeHandler that wants to hear about watcher failures.
+type ErrorHandler interface {
+	// OnWatcherError is called when watching the file system fails.
+	OnWatcherError(err error)
 }
 
 // Watcher monitors file system changes in a directory tree, respecting gitignore rules
-// and filtering out binary files and unwanted directories.
+// and filtering out unwanted directories. The contents of binary and large files are not
+// read into chunks; their changes are reported as skipped instead.
 type Watcher struct {
-	fsWatcher      *fsnotify.Watcher // Underlying file system watcher
-	handler        FileChangeHandler // Handler for file change events
-	stopCh         chan struct{}     // Channel to signal shutdown
-	gitignoreRules []string          // Rules for ignoring files/directories
-	watchDir       string            // Root directory being watched
+	fsWatcher *fsnotify.Watcher  // Underlying file system watcher
+	handler   FileChangeHandler  // Handler for file change events
+	stopCh    chan struct{}      // Channel to signal shutdown
+	ignore    *ignore.Matcher    // Decides which files and directories are ignored
+	watchDir  string             // Root directory being watched
+	paused    atomic.Bool        // Whether file changes are currently dropped
+	debounce  *debouncer         // Coalesces bursts of events per file; owned by the watch loop
+	flushCh   chan chan struct{} // Requests to handle all held back events right away
+	optionsCh chan optionsChange // Requests to change the options of the watch loop
+	maxSize   int64              // Size above which file contents are not tracked
+	defaults  []string           // Ignore patterns applied before the ignore files
+
+	filesMu sync.Mutex          // Protects files
+	files   map[string]struct{} // Tracked files known to exist, so a removed directory can report them deleted
+
+	dirsMu sync.Mutex                     // Protects dirs
+	dirs   map[string]map[string]struct{} // Watched directories, each with the watched directories directly below it
+}
+
+// Options configures a Watcher.
+type Options struct {
+	QuietWindow time.Duration // Quiet period after a file's last event before it is read
+	MaxDelay    time.Duration // Longest a file's events are held back while it keeps changing
+	MaxFileSize int64         // Size in bytes above which file contents are not tracked
+	Ignore      []string      // Patterns ignored unless an ignore file re-includes them; .git and .carya always are
+}
+
+// optionsChange asks the watch loop to switch to new options, closing done once it has.
+type optionsChange struct {
+	opts Options
+	done chan struct{}
+}
+
+// DefaultOptions returns the options used by New.
+func DefaultOptions() Options {
+	return Options{
+		QuietWindow: DefaultQuietWindow,
+		MaxDelay:    DefaultMaxDelay,
+		MaxFileSize: DefaultMaxFileSize,
+		Ignore:      ignore.DefaultPatterns,
+	}
 }
 
 // New creates a new file system watcher with the specified change handler.
 func New(handler FileChangeHandler) (*Watcher, error) {
+	return NewWithOptions(handler, DefaultOptions())
+}
+
+// NewWithOptions creates a new file system watcher with the specified change handler and options.
+// A zero QuietWindow, MaxDelay or MaxFileSize, or a nil Ignore, falls back to its default.
+func NewWithOptions(handler FileChangeHandler, opts Options) (*Watcher, error) {
+	opts = opts.withDefaults()
 	fsWatcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
@@ -40,19 +102,50 @@ func New(handler FileChangeHandler) (*Watcher, error) {
 		fsWatcher: fsWatcher,
 		handler:   handler,
 		stopCh:    make(chan struct{}),
+		debounce:  newDebouncer(opts.QuietWindow, opts.MaxDelay),
+		flushCh:   make(chan chan struct{}),
+		optionsCh: make(chan optionsChange),
+		maxSize:   opts.MaxFileSize,
+		defaults:  opts.Ignore,
+		files:     make(map[string]struct{}),
+		dirs:      make(map[string]map[string]struct{}),
 	}, nil
 }
 
+// withDefaults returns the options with zero values replaced by their defaults.
+func (opts Options) withDefaults() Options {
+	if opts.QuietWindow <= 0 {
+		opts.QuietWindow = DefaultQuietWindow
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultMaxDelay
+	}
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+	if opts.Ignore == nil {
+		opts.Ignore = ignore.DefaultPatterns
+	}
+	return opts
+}
+
 // Start begins watching the specified directory tree for file changes.
 // It loads gitignore rules and recursively adds directories to the watch list.
 func (w *Watcher) Start(watchDir string) error {
 	w.watchDir = watchDir
-	w.loadGitignoreRules()
+	w.ignore = ignore.NewMatcherWithDefaults(watchDir, w.defaults)
+	w.watchExternalIgnoreFiles()
 
 	go w.watchLoop()
 
 	log.Println("Walking directory:", watchDir)
-	return filepath.Walk(watchDir, func(path string, fi os.FileInfo, err error) error {
+	return w.addDirectories(watchDir)
+}
+
+// addDirectories adds root and every directory below it that isn't ignored to the watch list,
+// and remembers the tracked files in them.
+func (w *Watcher) addDirectories(root string) error {
+	return filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -61,15 +154,126 @@ func (w *Watcher) Start(watchDir string) error {
 			if w.shouldIgnore(path, true) {
 				return filepath.SkipDir
 			}
-			if err := w.fsWatcher.Add(path); err != nil {
+			if err := w.watchDirectory(path); err != nil {
 				return err
 			}
 			log.Println("Watching:", path)
+		} else if w.shouldTrackFile(path) {
+			w.rememberFile(path)
 		}
 		return nil
 	})
 }
 
+// rememberFile records that the tracked file at path exists.
+func (w *Watcher) rememberFile(path string) {
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+	w.files[path] = struct{}{}
+}
+
+// forgetFile records that the file at path is gone, reporting whether it was known.
+func (w *Watcher) forgetFile(path string) bool {
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+	_, known := w.files[path]
+	delete(w.files, path)
+	return known
+}
+
+// forgetFilesUnder forgets the known files below dir and returns them.
+func (w *Watcher) forgetFilesUnder(dir string) []string {
+	prefix := dir + string(filepath.Separator)
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+	var paths []string
+	for path := range w.files {
+		if strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
+			delete(w.files, path)
+		}
+	}
+	return paths
+}
+
+// watchDirectory adds dir to the watch list and to the index of watched directories.
+func (w *Watcher) watchDirectory(dir string) error {
+	if err := w.fsWatcher.Add(dir); err != nil {
+		return err
+	}
+	w.dirsMu.Lock()
+	defer w.dirsMu.Unlock()
+	if _, ok := w.dirs[dir]; !ok {
+		w.dirs[dir] = make(map[string]struct{})
+	}
+	if children, ok := w.dirs[filepath.Dir(dir)]; ok && dir != filepath.Dir(dir) {
+		children[dir] = struct{}{}
+	}
+	return nil
+}
+
+// isWatched reports whether dir is on the watch list.
+func (w *Watcher) isWatched(dir string) bool {
+	w.dirsMu.Lock()
+	defer w.dirsMu.Unlock()
+	_, ok := w.dirs[dir]
+	return ok
+}
+
+// unwatchTree removes dir and the watched directories below it from the watch list,
+// following the index instead of scanning the whole watch list.
+func (w *Watcher) unwatchTree(dir string) {
+	w.dirsMu.Lock()
+	defer w.dirsMu.Unlock()
+	if children, ok := w.dirs[filepath.Dir(dir)]; ok {
+		delete(children, dir)
+	}
+	stack := []string{dir}
+	for len(stack) > 0 {
+		path := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		children, ok := w.dirs[path]
+		if !ok {
+			continue
+		}
+		for child := range children {
+			stack = append(stack, child)
+		}
+		delete(w.dirs, path)
+		w.fsWatcher.Remove(path)
+	}
+}
+
+// watchExternalIgnoreFiles watches the directories holding ignore files outside the tree
+// (.git/info/exclude and the global excludes file), so edits to them are picked up.
+func (w *Watcher) watchExternalIgnoreFiles() {
+	for _, path := range w.ignore.ExternalFiles() {
+		if err := w.watchDirectory(filepath.Dir(path)); err == nil {
+			log.Println("Watching ignore file:", path)
+		}
+	}
+}
+
+// refreshWatches brings the watch list in line with the current ignore rules: directories
+// that are now ignored are removed and directories that are no longer ignored are added.
+func (w *Watcher) refreshWatches() {
+	external := make(map[string]bool)
+	for _, path := range w.ignore.ExternalFiles() {
+		external[filepath.Dir(path)] = true
+	}
+
+	for _, path := range w.fsWatcher.WatchList() {
+		if !external[path] && w.shouldIgnore(path, true) {
+			w.unwatchTree(path)
+			log.Println("Stopped watching:", path)
+		}
+	}
+
+	if err := w.addDirectories(w.watchDir); err != nil {
+		w.reportError(err)
+	}
+}
+
 // Stop gracefully shuts down the watcher and closes all resources.
 func (w *Watcher) Stop() {
 	close(w.stopCh)
@@ -78,6 +282,72 @@ func (w *Watcher) Stop() {
 	}
 }
 
+// Flush passes the file changes still held back by the quiet window to the handler and
+// returns once they have been handled.
+func (w *Watcher) Flush() {
+	done := make(chan struct{})
+	select {
+	case w.flushCh <- done:
+		<-done
+	case <-w.stopCh:
+	}
+}
+
+// SetOptions changes the options of the running watcher, falling back to the defaults as
+// NewWithOptions does, and returns once they are in effect. Changes already held back keep
+// their deadlines; a change to the ignore patterns updates the watch list.
+func (w *Watcher) SetOptions(opts Options) {
+	change := optionsChange{opts: opts.withDefaults(), done: make(chan struct{})}
+	select {
+	case w.optionsCh <- change:
+		<-change.done
+	case <-w.stopCh:
+	}
+}
+
+// applyOptions switches the watch loop to new options.
+func (w *Watcher) applyOptions(opts Options) {
+	w.debounce.quiet = opts.QuietWindow
+	w.debounce.maxDelay = opts.MaxDelay
+	w.maxSize = opts.MaxFileSize
+	if !slices.Equal(w.defaults, opts.Ignore) {
+		w.defaults = opts.Ignore
+		w.ignore.SetDefaults(opts.Ignore)
+		w.refreshWatches()
+	}
+}
+
+// Pause stops passing file changes to the handler until Resume is called. Changes made
+// before the call are still passed on once their quiet window ends.
+// Directories created while paused are still added to the watch list.
+func (w *Watcher) Pause() {
+	w.paused.Store(true)
+}
+
+// Resume continues passing file changes to the handler after Pause.
+func (w *Watcher) Resume() {
+	w.paused.Store(false)
+}
+
+// Paused reports whether the watcher is paused.
+func (w *Watcher) Paused() bool {
+	return w.paused.Load()
+}
+
+// WatchDir returns the root directory being watched.
+func (w *Watcher) WatchDir() string {
+	return w.watchDir
+}
+
+// Reload re-reads all ignore files. Edits to ignore files are picked up automatically;
+// this also catches changes to git's configuration, such as a new core.excludesFile.
+func (w *Watcher) Reload() {
+	w.ignore.Reload()
+	w.watchExternalIgnoreFiles()
+	w.refreshWatches()
+	log.Println("Reloaded ignore rules")
+}
+
 // watchLoop runs in a separate goroutine and processes file system events.
 func (w *Watcher) watchLoop() {
 	for {
@@ -92,7 +362,18 @@ func (w *Watcher) watchLoop() {
 			if !ok {
 				return
 			}
-			log.Println("Watcher ERROR:", err)
+			w.reportError(err)
+
+		case <-w.debounce.timer.C:
+			w.handleBursts(w.debounce.due(time.Now()))
+
+		case done := <-w.flushCh:
+			w.handleBursts(w.debounce.drain())
+			close(done)
+
+		case change := <-w.optionsCh:
+			w.applyOptions(change.opts)
+			close(change.done)
 
 		case <-w.stopCh:
 			return
@@ -100,97 +381,162 @@ func (w *Watcher) watchLoop() {
 	}
 }
 
-// loadGitignoreRules loads ignore rules from .gitignore file and adds default rules.
-func (w *Watcher) loadGitignoreRules() {
-	// Default ignore rules
-	w.gitignoreRules = []string{".git/", "node_modules/", ".vscode/", ".idea/"}
-
-	gitignorePath := filepath.Join(w.watchDir, ".gitignore")
-	file, err := os.Open(gitignorePath)
-	if err != nil {
-		return
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" && !strings.HasPrefix(line, "#") {
-			w.gitignoreRules = append(w.gitignoreRules, line)
-		}
+// reportError logs a watcher failure and passes it on to the handler if it wants to hear about it.
+func (w *Watcher) reportError(err error) {
+	log.Println("Watcher ERROR:", err)
+	if h, ok := w.handler.(ErrorHandler); ok {
+		h.OnWatcherError(err)
 	}
 }
 
 // shouldIgnore determines if a path should be ignored based on gitignore rules.
 func (w *Watcher) shouldIgnore(path string, isDir bool) bool {
-	relPath, err := filepath.Rel(w.watchDir, path)
-	if err != nil {
-		return false
+	return w.ignore.Ignored(path, isDir)
+}
+
+// handleEvent processes a file system event and triggers appropriate actions.
+func (w *Watcher) handleEvent(event fsnotify.Event) {
+	if w.ignore.Invalidate(event.Name) {
+		log.Println("Reloaded ignore rules from", event.Name)
+		w.refreshWatches()
 	}
 
-	for _, rule := range w.gitignoreRules {
-		if w.matchesRule(relPath, rule, isDir) {
-			return true
+	// Rename reports the old path; the new path arrives as a separate Create
+	if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		if w.isWatched(event.Name) {
+			w.removeDirectory(event.Name, event.Op)
+			return
 		}
+		// Paths never seen as files, such as a removed directory reported again by its
+		// parent's watch, are not reported deleted
+		_, pending := w.debounce.pending[event.Name]
+		if known := w.forgetFile(event.Name); (known || pending) && !w.Paused() {
+			w.debounce.add(event.Name, event.Op, time.Now())
+		}
+		return
 	}
-	return false
-}
 
-// matchesRule checks if a path matches a specific gitignore rule.
-func (w *Watcher) matchesRule(path, rule string, isDir bool) bool {
-	// Directory-specific rules
-	if strings.HasSuffix(rule, "/") {
-		if !isDir {
-			return false
+	if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+		if event.Op&fsnotify.Create == fsnotify.Create {
+			// New directories are watched right away, so files created in them aren't missed
+			fi, err := os.Stat(event.Name)
+			if err != nil {
+				return
+			}
+			if fi.IsDir() {
+				if !w.shouldIgnore(event.Name, true) {
+					w.addCreatedDirectory(event.Name)
+				}
+				return
+			}
 		}
-		rule = strings.TrimSuffix(rule, "/")
-	}
 
-	// Simple glob or exact match
-	if matched, _ := filepath.Match(rule, path); matched {
-		return true
+		if !w.shouldTrackFile(event.Name) {
+			return
+		}
+		if w.Paused() {
+			w.rememberFile(event.Name)
+			return
+		}
+		w.debounce.add(event.Name, event.Op, time.Now())
 	}
-
-	// Check if any part of the path matches
-	parts := strings.Split(path, "/")
-	return slices.Contains(parts, rule)
 }
 
-// handleEvent processes a file system event and triggers appropriate actions.
-func (w *Watcher) handleEvent(event fsnotify.Event) {
-	if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
-		log.Print("test")
-		fi, err := os.Stat(event.Name)
-		if err != nil {
-			return
+// removeDirectory stops watching a directory that was removed or moved away, along with the
+// directories below it, and reports the files that were in it as deleted. When the directory
+// was moved within the tree, addCreatedDirectory reports the same files as created at their
+// new paths, so they can be paired up as renames.
+func (w *Watcher) removeDirectory(dir string, op fsnotify.Op) {
+	prefix := dir + string(filepath.Separator)
+	w.unwatchTree(dir)
+	log.Println("Stopped watching removed directory:", dir)
+
+	// Bursts already pending below the directory, such as files created in it moments ago,
+	// are treated like the files that were known to be there
+	paths := w.forgetFilesUnder(dir)
+	for path := range w.debounce.pending {
+		if strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
 		}
+	}
+	if w.Paused() {
+		return
+	}
+	now := time.Now()
+	for _, path := range paths {
+		w.debounce.add(path, op, now)
+	}
+}
 
-		if fi.IsDir() && event.Op&fsnotify.Create == fsnotify.Create {
-			if !w.shouldIgnore(event.Name, true) {
-				w.fsWatcher.Add(event.Name)
-				log.Println("Added to watch:", event.Name)
+// handleBursts passes the coalesced events of each file to the handler, reading each file once.
+func (w *Watcher) handleBursts(bursts []*pendingEvent) {
+	if w.handler == nil {
+		return
+	}
+	for _, p := range bursts {
+		fi, err := os.Stat(p.path)
+		if err != nil {
+			// A file created and removed within the quiet window never existed as far as we know
+			if p.first&fsnotify.Create == 0 && p.ops&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				w.handler.OnFileDelete(p.path)
 			}
-			return
+			w.forgetFile(p.path)
+			continue
+		}
+		if fi.IsDir() {
+			continue
 		}
 
-		if !fi.IsDir() && w.shouldTrackFile(event.Name) {
-			contents, err := os.ReadFile(event.Name)
-			if err != nil {
-				return
-			}
-			if w.handler != nil {
-				w.handler.OnFileChange(event.Name, contents)
-			}
+		contents, skipped, err := readContents(p.path, w.maxSize)
+		if err != nil {
+			continue
+		}
+		w.rememberFile(p.path)
+		if skipped != nil {
+			w.handler.OnFileSkipped(p.path, skipped.size, skipped.hash, skipped.reason)
+			continue
+		}
+		if p.ops&fsnotify.Create == fsnotify.Create {
+			w.handler.OnFileCreate(p.path, contents)
+		} else {
+			w.handler.OnFileChange(p.path, contents)
 		}
 	}
+}
 
-	if event.Op&fsnotify.Remove == fsnotify.Remove {
-		w.fsWatcher.Remove(event.Name)
+// addCreatedDirectory watches a directory that appeared while running, and reports the files
+// already inside it (for example when a directory is moved into the tree) as created.
+func (w *Watcher) addCreatedDirectory(dir string) {
+	if err := w.addDirectories(dir); err != nil {
+		w.reportError(err)
+		return
 	}
+	log.Println("Added to watch:", dir)
+
+	if w.Paused() {
+		return
+	}
+	now := time.Now()
+	filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if fi.IsDir() {
+			if w.shouldIgnore(path, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if w.shouldTrackFile(path) {
+			w.debounce.add(path, fsnotify.Create, now)
+		}
+		return nil
+	})
 }
 
-// shouldTrackFile determines if a file should be tracked based on ignore rules and file type.
-// It excludes binary files, temporary files, and files matching gitignore patterns.
+// shouldTrackFile determines if a file should be tracked based on ignore rules and file name.
+// It excludes temporary files and files matching gitignore patterns. Binary files are
+// detected from their contents when they are read.
 func (w *Watcher) shouldTrackFile(path string) bool {
 	if w.shouldIgnore(path, false) {
 		return false
@@ -207,12 +553,5 @@ func (w *Watcher) shouldTrackFile(path string) bool {
 		return false
 	}
 
-	// Skip binary files
-	ext := strings.ToLower(filepath.Ext(path))
-	binaryExts := []string{
-		".exe", ".dll", ".so", ".bin", ".out", ".o", ".a",
-		".jpg", ".jpeg", ".png", ".gif", ".pdf", ".zip", ".tar", ".gz",
-	}
-
-	return !slices.Contains(binaryExts, ext)
+	return true
 }
@@ -0,0 +1,174 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"carya/internal/chunk"
+	"carya/internal/ignore"
+)
+
+// recorder is a FileChangeHandler that records which paths were reported created and deleted.
+type recorder struct {
+	mu      sync.Mutex
+	created []string
+	deleted []string
+}
+
+func (r *recorder) OnFileChange(path string, contents []byte) {}
+
+func (r *recorder) OnFileCreate(path string, contents []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.created = append(r.created, path)
+}
+
+func (r *recorder) OnFileDelete(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deleted = append(r.deleted, path)
+}
+
+func (r *recorder) OnFileSkipped(path string, size int64, hash string, reason chunk.SkipReason) {}
+
+// reported returns the sorted paths reported created and deleted so far.
+func (r *recorder) reported() (created, deleted []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	created = slices.Clone(r.created)
+	deleted = slices.Clone(r.deleted)
+	sort.Strings(created)
+	sort.Strings(deleted)
+	return created, deleted
+}
+
+// makeTree creates the files, given relative to root, along with their directories.
+func makeTree(t *testing.T, root string, files ...string) {
+	t.Helper()
+	for _, name := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// watchedUnder returns the sorted watch list with paths relative to root.
+func watchedUnder(w *Watcher, root string) []string {
+	var watched []string
+	for _, path := range w.fsWatcher.WatchList() {
+		// Directories holding ignore files outside the tree are left out
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			watched = append(watched, rel)
+		}
+	}
+	sort.Strings(watched)
+	return watched
+}
+
+func TestUnwatchTree(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/b/c/f.txt", "a/g.txt", "ab/h.txt", "d/i.txt")
+
+	w, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.fsWatcher.Close()
+	w.ignore = ignore.NewMatcher(root)
+	if err := w.addDirectories(root); err != nil {
+		t.Fatalf("addDirectories() error = %v", err)
+	}
+
+	// Only a and the directories below it go; ab shares its name as a prefix
+	w.unwatchTree(filepath.Join(root, "a"))
+	want := []string{".", "ab", "d"}
+	if got := watchedUnder(w, root); !slices.Equal(got, want) {
+		t.Errorf("watch list = %v, want %v", got, want)
+	}
+	for _, dir := range []string{"a", "a/b", "a/b/c"} {
+		if w.isWatched(filepath.Join(root, dir)) {
+			t.Errorf("isWatched(%s) = true after unwatching a", dir)
+		}
+	}
+	if children := w.dirs[root]; len(children) != 2 {
+		t.Errorf("root has %d watched directories below it in the index, want 2", len(children))
+	}
+}
+
+func TestRemoveDirectory(t *testing.T) {
+	tests := []struct {
+		name        string
+		change      func(root string) error
+		wantCreated []string
+		wantWatched []string
+	}{
+		{
+			name:        "removed",
+			change:      func(root string) error { return os.RemoveAll(filepath.Join(root, "sub")) },
+			wantWatched: []string{".", "other"},
+		},
+		{
+			name: "moved within the tree",
+			change: func(root string) error {
+				return os.Rename(filepath.Join(root, "sub"), filepath.Join(root, "other", "moved"))
+			},
+			wantCreated: []string{"other/moved/deep/y.txt", "other/moved/x.txt"},
+			wantWatched: []string{".", "other", "other/moved", "other/moved/deep"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			makeTree(t, root, "sub/x.txt", "sub/deep/y.txt", "other/z.txt")
+
+			r := &recorder{}
+			w, err := NewWithOptions(r, Options{QuietWindow: 10 * time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Stop()
+			if err := w.Start(root); err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+
+			if err := tt.change(root); err != nil {
+				t.Fatal(err)
+			}
+			wantDeleted := []string{filepath.Join(root, "sub/deep/y.txt"), filepath.Join(root, "sub/x.txt")}
+			var wantCreated []string
+			for _, name := range tt.wantCreated {
+				wantCreated = append(wantCreated, filepath.Join(root, name))
+			}
+
+			var created, deleted []string
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				created, deleted = r.reported()
+				if slices.Equal(deleted, wantDeleted) && slices.Equal(created, wantCreated) {
+					break
+				}
+			}
+			if !slices.Equal(deleted, wantDeleted) {
+				t.Errorf("deleted = %v, want %v", deleted, wantDeleted)
+			}
+			if !slices.Equal(created, wantCreated) {
+				t.Errorf("created = %v, want %v", created, wantCreated)
+			}
+
+			w.Flush()
+			if got := watchedUnder(w, root); !slices.Equal(got, tt.wantWatched) {
+				t.Errorf("watch list = %v, want %v", got, tt.wantWatched)
+			}
+		})
+	}
+}